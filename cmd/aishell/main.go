@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/dean2027/aishell/pkg/app"
 	"github.com/dean2027/aishell/pkg/cli"
@@ -18,11 +22,93 @@ var (
 )
 
 func main() {
+	// 解析命令行选项，遇到第一个非选项参数（子命令）时停止
+	fs := flag.NewFlagSet("aishell", flag.ContinueOnError)
+	flags := app.BindFlags(fs)
+	resume := ""
+	fs.BoolFunc("resume", "恢复最近的会话，--resume=<ID> 恢复指定会话", func(value string) error {
+		switch value {
+		case "true":
+			resume = app.ResumeLatest
+		case "false":
+			resume = ""
+		default:
+			resume = value
+		}
+		return nil
+	})
+	prompt := ""
+	fs.StringVar(&prompt, "c", "", "非交互模式：回答问题后退出，回答写入标准输出")
+	yes, no := false, false
+	fs.BoolVar(&yes, "yes", false, "非交互模式下自动同意执行危险命令")
+	fs.BoolVar(&no, "no", false, "非交互模式下自动拒绝危险命令（没有终端可询问时的默认行为）")
+	showVersion := false
+	fs.BoolVar(&showVersion, "version", false, "显示版本信息")
+	fs.BoolVar(&showVersion, "v", false, "显示版本信息")
+	fs.Usage = func() { printHelp(fs) }
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if showVersion {
+		printVersion()
+		os.Exit(0)
+	}
+
+	// 不依赖配置的子命令
+	args := fs.Args()
+	if len(args) > 0 {
+		switch args[0] {
+		case "policy":
+			os.Exit(cli.RunPolicyCommand(args[1:]))
+		case "sessions":
+			os.Exit(cli.RunSessionsCommand(args[1:]))
+		case "backups":
+			os.Exit(cli.RunBackupsCommand(args[1:]))
+		}
+	}
+
+	// 恢复会话时先切换到会话的工作目录，项目配置和策略按该目录查找
+	if resume != "" {
+		if err := app.EnterSessionDir(resume); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// 加载配置：配置文件 < 环境变量 < 命令行选项
+	config, err := app.LoadConfig(flags)
+	if err != nil {
+		log.Fatal("加载配置失败: ", err)
+	}
+	for _, warning := range config.Warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+	}
+	config.Resume = resume
+
+	// 处理子命令
+	if len(args) > 0 {
+		switch args[0] {
+		case "config":
+			os.Exit(cli.RunConfigCommand(config, args[1:]))
+		case "audit":
+			os.Exit(cli.RunAuditCommand(config, args[1:]))
+		}
+	}
+
 	// 创建上下文
 	ctx := context.Background()
 
-	// 加载配置
-	config := app.LoadConfig()
+	// 非交互模式：-c、位置参数或管道输入
+	if prompt != "" || len(args) > 0 || cli.StdinIsPiped() {
+		os.Exit(runOnce(ctx, config, prompt, args, yes, no))
+	}
+
+	if config.Output != app.OutputText {
+		fmt.Fprintf(os.Stderr, "--output %s 只能用于非交互模式（-c、问题参数或管道输入）\n", config.Output)
+		os.Exit(cli.ExitUsage)
+	}
 
 	// 创建CLI运行器
 	runner, err := cli.NewRunner(ctx, config)
@@ -43,23 +129,32 @@ func init() {
 
 	// 设置日志格式
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
-
-	// 处理版本信息查询
-	handleVersionFlag()
 }
 
-// handleVersionFlag 处理版本信息标志
-func handleVersionFlag() {
-	for _, arg := range os.Args[1:] {
-		if arg == "--version" || arg == "-v" {
-			printVersion()
-			os.Exit(0)
-		}
-		if arg == "--help" || arg == "-h" {
-			printHelp()
-			os.Exit(0)
-		}
+// runOnce 以非交互模式回答问题，返回退出码
+func runOnce(ctx context.Context, config *app.Config, prompt string, args []string, yes, no bool) int {
+	if yes && no {
+		fmt.Fprintln(os.Stderr, "--yes 和 --no 不能同时使用")
+		return cli.ExitUsage
+	}
+	if prompt != "" && len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "-c 与问题参数不能同时使用: %s\n", strings.Join(args, " "))
+		return cli.ExitUsage
+	}
+	if prompt == "" {
+		prompt = strings.Join(args, " ")
+	}
+
+	input, err := cli.ReadPipedInput()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return cli.ExitError
 	}
+	return cli.RunOnce(ctx, config, cli.OneShot{
+		Prompt:   prompt,
+		Input:    input,
+		Approval: cli.DefaultApproval(yes, no),
+	})
 }
 
 // printVersion 打印版本信息
@@ -72,21 +167,63 @@ func printVersion() {
 }
 
 // printHelp 打印命令行帮助
-func printHelp() {
+func printHelp(fs *flag.FlagSet) {
 	println("🤖 AI Shell - 智能终端助手")
 	println("")
 	println("用法:")
 	println("  aishell [选项]")
+	println("  aishell [选项] -c \"<问题>\"          非交互模式：回答后退出")
+	println("  aishell [选项] \"<问题>\"             同上，问题也可以直接作为参数")
+	println("  <命令> | aishell \"<问题>\"           把管道输入作为上下文回答问题")
+	println("  aishell policy check \"<command>\"   检查命令会命中哪条策略规则")
+	println("  aishell config show               显示生效的配置及每一项的来源")
+	println("  aishell sessions list|show|delete 管理保存的对话会话")
+	println("  aishell backups list|show|prune   管理文件写入前的备份")
+	println("  aishell audit [--since 24h] [--tool <名称>]  查询工具执行的审计日志")
+	println("  aishell --resume[=<id>]           恢复最近的（或指定的）会话")
 	println("")
 	println("选项:")
 	println("  -h, --help     显示此帮助信息")
 	println("  -v, --version  显示版本信息")
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "help" || f.Name == "version" || f.Name == "v" {
+			return
+		}
+		name := "--" + f.Name
+		if len(f.Name) == 1 {
+			name = "-" + f.Name
+		}
+		if _, isBool := f.Value.(interface{ IsBoolFlag() bool }); !isBool {
+			name += " <值>"
+		}
+		println(fmt.Sprintf("  %-32s %s", name, f.Usage))
+	})
 	println("")
 	println("环境变量:")
-	println("  OPENAI_API_KEY     OpenAI API密钥 (必需)")
+	println("  AISHELL_PROVIDER   模型提供方: openai (默认), azure, anthropic, ollama, local")
+	println("  AISHELL_MODEL      模型名称 (Azure 为部署名称)")
+	println("  AISHELL_TEMPERATURE  采样温度 (0-2)")
+	println("  AISHELL_MAX_TOKENS   单次回复最大输出长度")
+	println("  AISHELL_API_KEY    模型服务密钥 (优先于各提供方的密钥变量)")
+	println("  AISHELL_BASE_URL   模型服务端点 (优先于各提供方的端点变量)")
+	println("  AISHELL_API_VERSION  API版本 (Azure，也可用 AZURE_OPENAI_API_VERSION)")
+	println("  OPENAI_API_KEY     OpenAI API密钥 (openai 必需)")
 	println("  OPENAI_BASE_URL    OpenAI API基础URL (可选，用于自定义端点)")
+	println("  AZURE_OPENAI_API_KEY, AZURE_OPENAI_ENDPOINT  Azure OpenAI 密钥和资源地址")
+	println("  ANTHROPIC_API_KEY  Anthropic API密钥 (anthropic 必需)")
+	println("  OLLAMA_HOST        Ollama 服务地址 (默认 127.0.0.1:11434)")
 	println("  SERPAPI_API_KEY    SerpAPI密钥 (可选，用于搜索功能)")
 	println("  AISHELL_DEBUG      启用调试模式 (true/false)")
+	println("  AISHELL_PERSISTENT_SHELL  在持久shell会话中执行命令，保留cd/export等状态 (true/false)")
+	println("  AISHELL_DRY_RUN    启用演练模式 (true/false)")
+	println("  AISHELL_OUTPUT     非交互模式的输出格式: text (默认), json, jsonl")
+	println("  AISHELL_AUDIT_LOG  审计日志路径 (默认 ~/.local/share/aishell/audit.log，off 关闭)")
+	println("  AISHELL_AUDIT_SYSLOG  同时把审计记录发送到 syslog (true/false)")
+	println("  AISHELL_AGENT      代理类型: functions (默认，原生函数调用) 或 conversational")
+	println("  AISHELL_CONVERSATION_BUFFER_SIZE  保留的对话轮数 (默认100)")
+	println("  AISHELL_MAX_ITERATIONS  单次输入最多调用模型的次数 (默认30)")
+	println("  AISHELL_HISTORY_FILE    命令历史文件路径")
+	println("  AISHELL_PROMPT          命令行提示符")
 	println("")
 	println("示例:")
 	println("  export OPENAI_API_KEY=your_key")
@@ -94,8 +231,30 @@ func printHelp() {
 	println("")
 	println("  # 使用自定义OpenAI端点")
 	println("  export OPENAI_API_KEY=your_key")
-	println("  export OPENAI_BASE_URL=https://your-resource.openai.azure.com")
+	println("  export OPENAI_BASE_URL=https://api.openai-proxy.com/v1")
 	println("  aishell")
 	println("")
+	println("  # 使用 Anthropic")
+	println("  AISHELL_PROVIDER=anthropic ANTHROPIC_API_KEY=your_key aishell")
+	println("")
+	println("  # 使用本地 Ollama 的 OpenAI 兼容端点（支持函数调用）")
+	println("  AISHELL_PROVIDER=local AISHELL_BASE_URL=http://localhost:11434/v1 AISHELL_MODEL=qwen2.5 aishell")
+	println("")
 	println("  AISHELL_DEBUG=true aishell")
+	println("")
+	println("  # 非交互模式")
+	println("  aishell -c \"为什么 8080 端口被占用\"")
+	println("  journalctl -n 200 | aishell \"总结其中的错误\" > summary.txt")
+	println("  aishell --output json -c \"磁盘还剩多少空间\" | jq .answer")
+	println("")
+	println("非交互模式的退出码:")
+	println("  0 成功  1 出错  2 用法错误  3 有危险命令被拒绝  130 被中断")
+	println("")
+	println("配置文件 (优先级: 用户配置 < 项目配置 < 环境变量 < 命令行选项):")
+	println("  ~/.config/aishell/config.toml       用户级配置")
+	println("  .aishell/config.toml                项目级配置（从当前目录向上查找）")
+	println("")
+	println("命令策略文件:")
+	println("  ~/.config/aishell/policy.yaml|toml   用户级策略")
+	println("  .aishell/policy.yaml|toml            项目级策略（未受信任的项目只能收紧）")
 }
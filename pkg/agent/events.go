@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/tmc/langchaingo/tools"
+
+	"github.com/dean2027/aishell/pkg/redact"
+	localtools "github.com/dean2027/aishell/pkg/tools"
+)
+
+// EventType 代理事件类型
+type EventType string
+
+const (
+	// EventToken 模型输出的一段文本
+	EventToken EventType = "token"
+	// EventToolStart 开始调用工具
+	EventToolStart EventType = "tool_start"
+	// EventToolEnd 工具调用结束
+	EventToolEnd EventType = "tool_end"
+	// EventUsage 一次模型调用结束，报告 token 用量
+	EventUsage EventType = "usage"
+	// EventRedacted 发送给模型的内容中有密钥被遮盖
+	EventRedacted EventType = "redacted"
+)
+
+// Usage 模型调用的 token 用量，提供方没有返回时为 0
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add 累加用量
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// usageFromGenerationInfo 从模型返回的 GenerationInfo 中提取 token 用量。
+// OpenAI 和 Ollama 使用 PromptTokens/CompletionTokens，Anthropic 使用 InputTokens/OutputTokens。
+func usageFromGenerationInfo(info map[string]any) Usage {
+	number := func(keys ...string) int {
+		for _, key := range keys {
+			switch v := info[key].(type) {
+			case int:
+				return v
+			case int64:
+				return int(v)
+			case float64:
+				return int(v)
+			}
+		}
+		return 0
+	}
+	usage := Usage{
+		PromptTokens:     number("PromptTokens", "InputTokens"),
+		CompletionTokens: number("CompletionTokens", "OutputTokens"),
+		TotalTokens:      number("TotalTokens"),
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
+
+// Event 代理运行过程中的事件，用于实时显示
+type Event struct {
+	// Type 事件类型
+	Type EventType
+	// Text 文本片段（EventToken）
+	Text string
+	// Tool 工具名称
+	Tool string
+	// Input 工具输入
+	Input string
+	// Output 工具输出（EventToolEnd）
+	Output string
+	// Err 工具错误（EventToolEnd）
+	Err error
+	// Duration 工具耗时（EventToolEnd）
+	Duration time.Duration
+	// ExitCode 系统命令的退出码（EventToolEnd），没有执行命令时为 nil
+	ExitCode *int
+	// Usage 本次模型调用的 token 用量（EventUsage）
+	Usage Usage
+	// Redacted 被遮盖的密钥（EventRedacted），Tool 为空表示用户输入
+	Redacted redact.Report
+}
+
+// EventHandler 事件处理函数。并行执行的工具会在不同 goroutine 中触发事件，处理函数需要自行加锁。
+type EventHandler func(Event)
+
+// isToolCallChunk 判断流式片段是否为工具调用的增量数据。
+// langchaingo 的 OpenAI 客户端会把 tool_calls 增量序列化为 JSON 数组传给流式回调，这部分不是回复文本。
+func isToolCallChunk(chunk []byte) bool {
+	if len(chunk) == 0 || chunk[0] != '[' {
+		return false
+	}
+	var deltas []struct {
+		Function *json.RawMessage `json:"function"`
+	}
+	if err := json.Unmarshal(chunk, &deltas); err != nil {
+		return false
+	}
+	for _, d := range deltas {
+		if d.Function != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// redactText 遮盖发送给模型的内容，有密钥被遮盖时触发 EventRedacted 事件
+func redactText(redactor *redact.Redactor, tool, text string, onEvent EventHandler) string {
+	text, report := redactor.Redact(text)
+	if len(report) > 0 && onEvent != nil {
+		onEvent(Event{Type: EventRedacted, Tool: tool, Redacted: report})
+	}
+	return text
+}
+
+// ObserveTools 包装工具列表，在每次调用前后触发工具事件，并遮盖工具结果中的密钥。
+// 用于不直接产生事件的对话代理（conversational）。
+func ObserveTools(list []tools.Tool, redactor *redact.Redactor, onEvent EventHandler) []tools.Tool {
+	if onEvent == nil && redactor == nil {
+		return list
+	}
+	observed := make([]tools.Tool, len(list))
+	for i, tool := range list {
+		observed[i] = observedTool{Tool: tool, redactor: redactor, onEvent: onEvent}
+	}
+	return observed
+}
+
+// observedTool 触发工具事件、遮盖密钥的包装
+type observedTool struct {
+	tools.Tool
+	redactor *redact.Redactor
+	onEvent  EventHandler
+}
+
+// Call 还原输入中的占位符后调用工具，遮盖结果中的密钥，并触发开始和结束事件
+func (t observedTool) Call(ctx context.Context, input string) (string, error) {
+	t.emit(Event{Type: EventToolStart, Tool: t.Name(), Input: input})
+	ctx, info := localtools.WithCallInfo(ctx)
+	start := time.Now()
+	output, err := t.Tool.Call(ctx, t.redactor.Restore(input))
+	output = redactText(t.redactor, t.Name(), output, t.onEvent)
+	t.emit(Event{Type: EventToolEnd, Tool: t.Name(), Input: input, Output: output, Err: err, Duration: time.Since(start), ExitCode: info.ExitCode})
+	return output, err
+}
+
+// emit 触发事件
+func (t observedTool) emit(event Event) {
+	if t.onEvent != nil {
+		t.onEvent(event)
+	}
+}
@@ -0,0 +1,362 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+
+	"github.com/dean2027/aishell/pkg/redact"
+	localtools "github.com/dean2027/aishell/pkg/tools"
+)
+
+// ErrMaxIterations 达到最大迭代次数仍未得到最终回答
+var ErrMaxIterations = errors.New("达到最大迭代次数，未能得到最终回答")
+
+// interruptedNote 被中断的回复末尾追加的说明，让模型在下一轮知道上一轮没有完成
+const interruptedNote = "[本轮对话已被用户中断]"
+
+// FunctionAgent 基于原生函数调用（tool calling）的代理。
+// 工具以 JSON Schema 的形式提供给模型，模型返回结构化的工具调用，不再依赖解析 "Action:" 文本；
+// 同一轮返回的多个工具调用会一起执行，参数在调用工具前按 Schema 严格校验。
+type FunctionAgent struct {
+	// LLM 支持工具调用的模型
+	LLM llms.Model
+	// Tools 可用工具
+	Tools []tools.Tool
+	// SystemPrompt 系统提示
+	SystemPrompt string
+	// MaxIterations 单次输入最多调用模型的次数
+	MaxIterations int
+	// MaxTurns 保留的历史对话轮数，0 表示不限制
+	MaxTurns int
+	// CallbacksHandler 回调处理器，为空时不回调
+	CallbacksHandler callbacks.Handler
+	// CallOptions 调用模型时的额外选项
+	CallOptions []llms.CallOption
+	// OnEvent 事件处理函数，设置后模型输出以流式方式逐段回调，为空时不产生事件
+	OnEvent EventHandler
+	// Redactor 遮盖工具结果中的密钥，工具输入中的占位符在执行前还原，为空时不遮盖
+	Redactor *redact.Redactor
+
+	mu      sync.Mutex
+	history []llms.MessageContent
+}
+
+// NewFunctionAgent 创建函数调用代理
+func NewFunctionAgent(llm llms.Model, agentTools []tools.Tool, systemPrompt string) *FunctionAgent {
+	return &FunctionAgent{
+		LLM:           llm,
+		Tools:         agentTools,
+		SystemPrompt:  systemPrompt,
+		MaxIterations: 30,
+	}
+}
+
+// inputSchema 没有提供 Schema 的工具统一使用单个 input 字符串参数
+var inputSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"input": map[string]any{"type": "string", "description": "工具输入"},
+	},
+	"required":             []string{"input"},
+	"additionalProperties": false,
+}
+
+// Run 处理一次用户输入，循环调用模型和工具直到得到最终回答
+func (a *FunctionAgent) Run(ctx context.Context, input string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	turn := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, input)}
+	// 记录流式输出的文本，中断时保留已生成的部分
+	var partial strings.Builder
+	options := append([]llms.CallOption{llms.WithTools(a.toolDefinitions())}, a.CallOptions...)
+	if a.OnEvent != nil {
+		options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			if len(chunk) == 0 || isToolCallChunk(chunk) {
+				return nil
+			}
+			partial.Write(chunk)
+			a.OnEvent(Event{Type: EventToken, Text: string(chunk)})
+			return nil
+		}))
+	}
+
+	maxIterations := a.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 30
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		partial.Reset()
+		messages := a.messages(turn)
+		if a.CallbacksHandler != nil {
+			a.CallbacksHandler.HandleLLMGenerateContentStart(ctx, messages)
+		}
+		resp, err := a.LLM.GenerateContent(ctx, messages, options...)
+		if err != nil {
+			if a.CallbacksHandler != nil {
+				a.CallbacksHandler.HandleLLMError(ctx, err)
+			}
+			// 被中断时保留本轮已完成的工具调用和已生成的部分回复
+			if ctx.Err() != nil {
+				a.commitInterrupted(turn, partial.String())
+				return partial.String(), ctx.Err()
+			}
+			return "", err
+		}
+		if a.CallbacksHandler != nil {
+			a.CallbacksHandler.HandleLLMGenerateContentEnd(ctx, resp)
+		}
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("模型没有返回任何内容")
+		}
+		choice := resp.Choices[0]
+		if usage := usageFromGenerationInfo(choice.GenerationInfo); usage.TotalTokens > 0 {
+			a.emit(Event{Type: EventUsage, Usage: usage})
+		}
+
+		// 没有工具调用即为最终回答
+		if len(choice.ToolCalls) == 0 {
+			turn = append(turn, llms.TextParts(llms.ChatMessageTypeAI, choice.Content))
+			a.commit(turn)
+			return choice.Content, nil
+		}
+
+		assistant := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+		if choice.Content != "" {
+			assistant.Parts = append(assistant.Parts, llms.TextContent{Text: choice.Content})
+		}
+		for _, call := range choice.ToolCalls {
+			assistant.Parts = append(assistant.Parts, call)
+		}
+		turn = append(turn, assistant)
+
+		for _, response := range a.executeToolCalls(ctx, choice.ToolCalls) {
+			turn = append(turn, llms.MessageContent{
+				Role:  llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{response},
+			})
+		}
+
+		if ctx.Err() != nil {
+			a.commitInterrupted(turn, "")
+			return "", ctx.Err()
+		}
+	}
+
+	// 保留本轮的工具调用记录，便于用户继续追问
+	a.commit(turn)
+	return "", ErrMaxIterations
+}
+
+// emit 触发事件
+func (a *FunctionAgent) emit(event Event) {
+	if a.OnEvent != nil {
+		a.OnEvent(event)
+	}
+}
+
+// Reset 清空对话历史
+func (a *FunctionAgent) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.history = nil
+}
+
+// History 返回对话历史的副本（不含系统提示）
+func (a *FunctionAgent) History() []llms.MessageContent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]llms.MessageContent(nil), a.history...)
+}
+
+// SetHistory 替换对话历史，用于恢复保存的会话
+func (a *FunctionAgent) SetHistory(history []llms.MessageContent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.history = append([]llms.MessageContent(nil), history...)
+}
+
+// commitInterrupted 保存被中断的一轮对话：已完成的工具调用保留，末尾追加中断说明
+func (a *FunctionAgent) commitInterrupted(turn []llms.MessageContent, partial string) {
+	text := interruptedNote
+	if partial != "" {
+		text = partial + "\n" + interruptedNote
+	}
+	a.commit(append(turn, llms.TextParts(llms.ChatMessageTypeAI, text)))
+}
+
+// messages 组合系统提示、历史对话和本轮消息
+func (a *FunctionAgent) messages(turn []llms.MessageContent) []llms.MessageContent {
+	messages := make([]llms.MessageContent, 0, len(a.history)+len(turn)+1)
+	if a.SystemPrompt != "" {
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeSystem, a.SystemPrompt))
+	}
+	messages = append(messages, a.history...)
+	return append(messages, turn...)
+}
+
+// commit 把本轮消息加入历史，并只保留最近 MaxTurns 轮
+func (a *FunctionAgent) commit(turn []llms.MessageContent) {
+	a.history = append(a.history, turn...)
+	if a.MaxTurns <= 0 {
+		return
+	}
+	turns := 0
+	for i := len(a.history) - 1; i >= 0; i-- {
+		if a.history[i].Role == llms.ChatMessageTypeHuman {
+			turns++
+			if turns == a.MaxTurns {
+				a.history = a.history[i:]
+				return
+			}
+		}
+	}
+}
+
+// toolDefinitions 把工具转换为函数定义
+func (a *FunctionAgent) toolDefinitions() []llms.Tool {
+	definitions := make([]llms.Tool, 0, len(a.Tools))
+	for _, tool := range a.Tools {
+		definitions = append(definitions, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				Parameters:  toolSchema(tool),
+			},
+		})
+	}
+	return definitions
+}
+
+// toolSchema 返回工具的参数 Schema
+func toolSchema(tool tools.Tool) map[string]any {
+	if st, ok := tool.(localtools.SchemaTool); ok {
+		return st.Parameters()
+	}
+	return inputSchema
+}
+
+// findTool 按名称查找工具
+func (a *FunctionAgent) findTool(name string) tools.Tool {
+	for _, tool := range a.Tools {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// executeToolCalls 执行一轮中的全部工具调用，结果顺序与调用顺序一致。
+// 连续的可并行只读工具并发执行，其余工具（可能请求用户确认或实时输出）等待前面的调用完成后依次执行。
+func (a *FunctionAgent) executeToolCalls(ctx context.Context, calls []llms.ToolCall) []llms.ToolCallResponse {
+	responses := make([]llms.ToolCallResponse, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		if ctx.Err() != nil {
+			// 已中断，不再执行剩余的工具调用，但仍需为每个调用返回结果
+			responses[i] = llms.ToolCallResponse{ToolCallID: call.ID, Content: "已取消：用户中断了本轮对话"}
+			if call.FunctionCall != nil {
+				responses[i].Name = call.FunctionCall.Name
+			}
+			continue
+		}
+		if call.FunctionCall == nil {
+			responses[i] = llms.ToolCallResponse{ToolCallID: call.ID, Content: "错误：缺少函数调用内容"}
+			continue
+		}
+		tool := a.findTool(call.FunctionCall.Name)
+		if ct, ok := tool.(localtools.ConcurrentTool); ok && ct.Concurrent() {
+			wg.Add(1)
+			go func(i int, call llms.ToolCall) {
+				defer wg.Done()
+				responses[i] = a.executeToolCall(ctx, tool, call)
+			}(i, call)
+			continue
+		}
+		// 依次执行的工具可能修改前面并发读取的文件，先等待它们完成
+		wg.Wait()
+		responses[i] = a.executeToolCall(ctx, tool, call)
+	}
+	wg.Wait()
+	return responses
+}
+
+// executeToolCall 校验参数并执行单个工具调用，错误以文本形式返回给模型
+func (a *FunctionAgent) executeToolCall(ctx context.Context, tool tools.Tool, call llms.ToolCall) llms.ToolCallResponse {
+	response := llms.ToolCallResponse{ToolCallID: call.ID, Name: call.FunctionCall.Name}
+	if tool == nil {
+		response.Content = fmt.Sprintf("错误：工具 '%s' 不存在，可用工具: %s", call.FunctionCall.Name, strings.Join(a.toolNames(), ", "))
+		return response
+	}
+
+	input, err := toolInput(tool, call.FunctionCall.Arguments)
+	if err != nil {
+		a.emit(Event{Type: EventToolStart, Tool: response.Name, Input: call.FunctionCall.Arguments})
+		a.emit(Event{Type: EventToolEnd, Tool: response.Name, Input: call.FunctionCall.Arguments, Err: err})
+		response.Content = fmt.Sprintf("错误：%v，请按工具的参数定义重新调用", err)
+		return response
+	}
+
+	if a.CallbacksHandler != nil {
+		a.CallbacksHandler.HandleToolStart(ctx, input)
+	}
+	a.emit(Event{Type: EventToolStart, Tool: response.Name, Input: input})
+	ctx, info := localtools.WithCallInfo(ctx)
+	start := time.Now()
+	output, err := tool.Call(ctx, a.Redactor.Restore(input))
+	output = redactText(a.Redactor, response.Name, output, a.OnEvent)
+	a.emit(Event{Type: EventToolEnd, Tool: response.Name, Input: input, Output: output, Err: err, Duration: time.Since(start), ExitCode: info.ExitCode})
+	if err != nil {
+		if a.CallbacksHandler != nil {
+			a.CallbacksHandler.HandleToolError(ctx, err)
+		}
+		response.Content = redactText(a.Redactor, response.Name, fmt.Sprintf("工具执行失败: %v", err), a.OnEvent)
+		return response
+	}
+	if a.CallbacksHandler != nil {
+		a.CallbacksHandler.HandleToolEnd(ctx, output)
+	}
+	response.Content = output
+	return response
+}
+
+// toolInput 校验参数并转换为工具的输入字符串
+func toolInput(tool tools.Tool, arguments string) (string, error) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	schema := toolSchema(tool)
+	if err := localtools.ValidateArguments(schema, arguments); err != nil {
+		return "", err
+	}
+	if _, ok := tool.(localtools.SchemaTool); ok {
+		return arguments, nil
+	}
+	var args struct {
+		Input string `json:"input"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("参数不是合法的JSON: %w", err)
+	}
+	return args.Input, nil
+}
+
+// toolNames 返回全部工具名称
+func (a *FunctionAgent) toolNames() []string {
+	names := make([]string, 0, len(a.Tools))
+	for _, tool := range a.Tools {
+		names = append(names, tool.Name())
+	}
+	return names
+}
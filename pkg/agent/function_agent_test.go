@@ -0,0 +1,420 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+
+	"github.com/dean2027/aishell/pkg/redact"
+	localtools "github.com/dean2027/aishell/pkg/tools"
+)
+
+// scriptedLLM 按顺序返回预设响应，并记录每次收到的消息
+type scriptedLLM struct {
+	responses []*llms.ContentChoice
+	calls     [][]llms.MessageContent
+}
+
+func (m *scriptedLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls = append(m.calls, messages)
+	if len(m.calls) > len(m.responses) {
+		return nil, fmt.Errorf("unexpected call %d", len(m.calls))
+	}
+	choice := m.responses[len(m.calls)-1]
+
+	// 模拟 OpenAI 客户端的流式回调：文本逐字符，工具调用为 JSON 增量
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.StreamingFunc != nil {
+		for _, r := range choice.Content {
+			if err := opts.StreamingFunc(ctx, []byte(string(r))); err != nil {
+				return nil, err
+			}
+		}
+		for _, call := range choice.ToolCalls {
+			chunk := fmt.Sprintf(`[{"id":%q,"type":"function","function":{"name":%q,"arguments":""}}]`, call.ID, call.FunctionCall.Name)
+			if err := opts.StreamingFunc(ctx, []byte(chunk)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}, nil
+}
+
+func (m *scriptedLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// echoTool 带 Schema 的测试工具，返回收到的输入
+type echoTool struct {
+	mu     sync.Mutex
+	inputs []string
+}
+
+type echoParams struct {
+	Text  string `json:"text"`
+	Count int    `json:"count,omitempty"`
+}
+
+func (e *echoTool) Name() string               { return "echo" }
+func (e *echoTool) Description() string        { return "echo" }
+func (e *echoTool) Parameters() map[string]any { return localtools.SchemaFor(echoParams{}) }
+func (e *echoTool) Concurrent() bool           { return true }
+func (e *echoTool) Call(ctx context.Context, input string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inputs = append(e.inputs, input)
+	return "echo:" + input, nil
+}
+
+func toolCall(id, name, args string) llms.ToolCall {
+	return llms.ToolCall{ID: id, Type: "function", FunctionCall: &llms.FunctionCall{Name: name, Arguments: args}}
+}
+
+func TestFunctionAgent_ParallelToolCalls(t *testing.T) {
+	echo := &echoTool{}
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{
+			toolCall("1", "echo", `{"text": "a"}`),
+			toolCall("2", "echo", `{"text": "b", "count": 2}`),
+		}},
+		{Content: "完成"},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{echo}, "system")
+
+	answer, err := a.Run(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "完成" {
+		t.Errorf("answer = %q", answer)
+	}
+	if len(echo.inputs) != 2 {
+		t.Fatalf("两个工具调用都应执行, got %v", echo.inputs)
+	}
+
+	// 第二次调用模型时应带上 AI 的工具调用和按顺序排列的两条工具结果
+	second := llm.calls[1]
+	if len(second) != 5 {
+		t.Fatalf("消息数应为 5 (system, human, ai, tool, tool), got %d", len(second))
+	}
+	for i, id := range []string{"1", "2"} {
+		resp, ok := second[3+i].Parts[0].(llms.ToolCallResponse)
+		if !ok || resp.ToolCallID != id || !strings.HasPrefix(resp.Content, "echo:") {
+			t.Errorf("第 %d 条工具结果不正确: %+v", i+1, second[3+i].Parts[0])
+		}
+	}
+}
+
+// fileTools 共享一份"文件"内容的读写工具，读取是可并行的，写入依次执行
+type fileTools struct {
+	mu      sync.Mutex
+	content string
+}
+
+type fileReadTool struct{ f *fileTools }
+
+func (r fileReadTool) Name() string        { return "read" }
+func (r fileReadTool) Description() string { return "read" }
+func (r fileReadTool) Concurrent() bool    { return true }
+func (r fileReadTool) Call(ctx context.Context, input string) (string, error) {
+	// 故意晚于后面的写入开始读取
+	time.Sleep(20 * time.Millisecond)
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	return r.f.content, nil
+}
+
+type fileWriteTool struct{ f *fileTools }
+
+func (w fileWriteTool) Name() string        { return "write" }
+func (w fileWriteTool) Description() string { return "write" }
+func (w fileWriteTool) Call(ctx context.Context, input string) (string, error) {
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	w.f.content = "new"
+	return "ok", nil
+}
+
+func TestFunctionAgent_ReadBeforeWrite(t *testing.T) {
+	f := &fileTools{content: "old"}
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{
+			toolCall("1", "read", `{"input": "a.txt"}`),
+			toolCall("2", "write", `{"input": "a.txt"}`),
+			toolCall("3", "read", `{"input": "a.txt"}`),
+		}},
+		{Content: "完成"},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{fileReadTool{f}, fileWriteTool{f}}, "system")
+	if _, err := a.Run(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	second := llm.calls[1]
+	for i, want := range []string{"old", "ok", "new"} {
+		resp, ok := second[3+i].Parts[0].(llms.ToolCallResponse)
+		if !ok || resp.Content != want {
+			t.Errorf("第 %d 个工具调用的结果应为 %q, got %+v", i+1, want, second[3+i].Parts[0])
+		}
+	}
+}
+
+func TestFunctionAgent_StrictValidation(t *testing.T) {
+	echo := &echoTool{}
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{
+			toolCall("1", "echo", `{"txt": "a"}`),
+			toolCall("2", "echo", `{"text": 1}`),
+			toolCall("3", "missing", `{}`),
+		}},
+		{Content: "ok"},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{echo}, "")
+
+	if _, err := a.Run(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if len(echo.inputs) != 0 {
+		t.Errorf("参数不合法时不应调用工具, got %v", echo.inputs)
+	}
+
+	want := []string{"缺少必填字段 'text'", "应为字符串", "工具 'missing' 不存在"}
+	second := llm.calls[1]
+	for i, w := range want {
+		resp := second[2+i].Parts[0].(llms.ToolCallResponse)
+		if !strings.Contains(resp.Content, w) {
+			t.Errorf("第 %d 条结果应包含 %q, got %q", i+1, w, resp.Content)
+		}
+	}
+}
+
+func TestFunctionAgent_InputSchemaForPlainTools(t *testing.T) {
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{toolCall("1", "calculator", `{"input": "1+2"}`)}},
+		{Content: "3"},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{tools.Calculator{}}, "")
+	if _, err := a.Run(context.Background(), "1+2"); err != nil {
+		t.Fatal(err)
+	}
+	resp := llm.calls[1][2].Parts[0].(llms.ToolCallResponse)
+	if strings.TrimSpace(resp.Content) != "3" {
+		t.Errorf("calculator 结果应为 3, got %q", resp.Content)
+	}
+}
+
+func TestFunctionAgent_HistoryWindow(t *testing.T) {
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{{Content: "1"}, {Content: "2"}, {Content: "3"}}}
+	a := NewFunctionAgent(llm, nil, "")
+	a.MaxTurns = 1
+
+	for _, input := range []string{"a", "b", "c"} {
+		if _, err := a.Run(context.Background(), input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第三次调用只应包含上一轮 (human b, ai 2) 和本轮输入
+	last := llm.calls[2]
+	if len(last) != 3 {
+		t.Fatalf("只应保留 1 轮历史, got %d 条消息", len(last))
+	}
+	if text := last[0].Parts[0].(llms.TextContent).Text; text != "b" {
+		t.Errorf("历史第一条应为 'b', got %q", text)
+	}
+}
+
+func TestFunctionAgent_SetHistory(t *testing.T) {
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{{Content: "继续"}}}
+	a := NewFunctionAgent(llm, nil, "系统")
+	a.SetHistory([]llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, "昨天的问题"),
+		llms.TextParts(llms.ChatMessageTypeAI, "昨天的回答"),
+	})
+
+	if _, err := a.Run(context.Background(), "今天"); err != nil {
+		t.Fatal(err)
+	}
+	// 系统提示 + 恢复的两条历史 + 本轮输入
+	if got := len(llm.calls[0]); got != 4 {
+		t.Fatalf("恢复的历史应发送给模型, got %d 条消息", got)
+	}
+	if history := a.History(); len(history) != 4 {
+		t.Errorf("历史应包含恢复的消息和本轮对话, got %d", len(history))
+	}
+}
+
+func TestFunctionAgent_MaxIterations(t *testing.T) {
+	call := &llms.ContentChoice{ToolCalls: []llms.ToolCall{toolCall("1", "echo", `{"text": "x"}`)}}
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{call, call}}
+	a := NewFunctionAgent(llm, []tools.Tool{&echoTool{}}, "")
+	a.MaxIterations = 2
+
+	if _, err := a.Run(context.Background(), "loop"); err != ErrMaxIterations {
+		t.Errorf("应返回 ErrMaxIterations, got %v", err)
+	}
+}
+
+func TestFunctionAgent_Events(t *testing.T) {
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{toolCall("1", "echo", `{"text": "a"}`)}},
+		{Content: "答案[1]"},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{&echoTool{}}, "")
+
+	var mu sync.Mutex
+	var text strings.Builder
+	var types []EventType
+	a.OnEvent = func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Type == EventToken {
+			text.WriteString(e.Text)
+			return
+		}
+		types = append(types, e.Type)
+		if e.Type == EventToolEnd && e.Output != `echo:{"text": "a"}` {
+			t.Errorf("工具结束事件应包含输出, got %q", e.Output)
+		}
+	}
+
+	if _, err := a.Run(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if text.String() != "答案[1]" {
+		t.Errorf("流式文本应只包含回复内容, got %q", text.String())
+	}
+	if len(types) != 2 || types[0] != EventToolStart || types[1] != EventToolEnd {
+		t.Errorf("应依次产生工具开始和结束事件, got %v", types)
+	}
+}
+
+func TestFunctionAgent_UsageEvents(t *testing.T) {
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{toolCall("1", "echo", `{"text": "a"}`)}, GenerationInfo: map[string]any{"PromptTokens": 10, "CompletionTokens": 5}},
+		{Content: "答案", GenerationInfo: map[string]any{"InputTokens": 20, "OutputTokens": 3}},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{&echoTool{}}, "")
+
+	var total Usage
+	a.OnEvent = func(e Event) {
+		if e.Type == EventUsage {
+			total.Add(e.Usage)
+		}
+	}
+	if _, err := a.Run(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if total != (Usage{PromptTokens: 30, CompletionTokens: 8, TotalTokens: 38}) {
+		t.Errorf("token 用量累计错误: %+v", total)
+	}
+}
+
+func TestIsToolCallChunk(t *testing.T) {
+	tests := map[string]bool{
+		`[{"index":0,"id":"call_1","type":"function","function":{"name":"echo","arguments":""}}]`: true,
+		`[{"function":{"arguments":"{\"te"}}]`:                                                    true,
+		`[1, 2]`:                                                                                  false,
+		`[`:                                                                                       false,
+		`hello`:                                                                                   false,
+		``:                                                                                        false,
+	}
+	for chunk, want := range tests {
+		if got := isToolCallChunk([]byte(chunk)); got != want {
+			t.Errorf("isToolCallChunk(%q) = %v, want %v", chunk, got, want)
+		}
+	}
+}
+
+// cancelTool 模拟工具执行期间用户按下 Ctrl+C
+type cancelTool struct {
+	cancel context.CancelFunc
+}
+
+func (c cancelTool) Name() string        { return "slow" }
+func (c cancelTool) Description() string { return "slow" }
+func (c cancelTool) Call(ctx context.Context, input string) (string, error) {
+	c.cancel()
+	return "partial output", nil
+}
+
+func TestFunctionAgent_InterruptKeepsTranscript(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{
+			toolCall("1", "slow", `{"input": "x"}`),
+			toolCall("2", "slow", `{"input": "y"}`),
+		}},
+		{Content: "下一轮"},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{cancelTool{cancel: cancel}}, "")
+
+	if _, err := a.Run(ctx, "run"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("应返回 context.Canceled, got %v", err)
+	}
+	if len(llm.calls) != 1 {
+		t.Errorf("中断后不应再请求模型, got %d 次", len(llm.calls))
+	}
+
+	// 下一轮应带上被中断的一轮：human, ai(tool calls), tool, tool, ai(中断说明)
+	if _, err := a.Run(context.Background(), "继续"); err != nil {
+		t.Fatal(err)
+	}
+	next := llm.calls[1]
+	if len(next) != 6 {
+		t.Fatalf("应保留被中断的一轮, got %d 条消息", len(next))
+	}
+	first := next[2].Parts[0].(llms.ToolCallResponse)
+	second := next[3].Parts[0].(llms.ToolCallResponse)
+	if first.Content != "partial output" || !strings.Contains(second.Content, "已取消") {
+		t.Errorf("已执行的工具结果应保留、未执行的应标记取消, got %q / %q", first.Content, second.Content)
+	}
+	if note := next[4].Parts[0].(llms.TextContent).Text; !strings.Contains(note, "中断") {
+		t.Errorf("应追加中断说明, got %q", note)
+	}
+}
+
+func TestFunctionAgent_Redaction(t *testing.T) {
+	echo := &echoTool{}
+	llm := &scriptedLLM{responses: []*llms.ContentChoice{
+		{ToolCalls: []llms.ToolCall{toolCall("1", "echo", `{"text": "DB_PASSWORD=hunter2hunter2"}`)}},
+		{ToolCalls: []llms.ToolCall{toolCall("2", "echo", `{"text": "[REDACTED_SECRET_1]"}`)}},
+		{Content: "完成"},
+	}}
+	a := NewFunctionAgent(llm, []tools.Tool{echo}, "system")
+	a.Redactor = redact.New()
+	var redacted []Event
+	a.OnEvent = func(e Event) {
+		if e.Type == EventRedacted {
+			redacted = append(redacted, e)
+		}
+	}
+
+	if _, err := a.Run(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模型看到的工具结果中只有占位符
+	for i, call := range llm.calls[1:] {
+		resp := call[len(call)-1].Parts[0].(llms.ToolCallResponse)
+		if strings.Contains(resp.Content, "hunter2") || !strings.Contains(resp.Content, "[REDACTED_SECRET_1]") {
+			t.Errorf("第 %d 次工具结果未遮盖: %s", i+1, resp.Content)
+		}
+	}
+	// 工具收到的是还原后的原值
+	if len(echo.inputs) != 2 || !strings.Contains(echo.inputs[1], "hunter2hunter2") {
+		t.Errorf("占位符应在执行前还原, inputs = %v", echo.inputs)
+	}
+	if len(redacted) != 2 || redacted[0].Tool != "echo" || redacted[0].Redacted[0].Kind != redact.KindSecret {
+		t.Errorf("redacted events = %+v", redacted)
+	}
+}
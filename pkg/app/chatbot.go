@@ -3,27 +3,48 @@ package app
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/tmc/langchaingo/agents"
-	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/chains"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/tmc/langchaingo/memory"
 	"github.com/tmc/langchaingo/tools"
 	"github.com/tmc/langchaingo/tools/serpapi"
 
+	"github.com/dean2027/aishell/pkg/agent"
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/backup"
+	"github.com/dean2027/aishell/pkg/policy"
 	"github.com/dean2027/aishell/pkg/prompt"
+	"github.com/dean2027/aishell/pkg/redact"
+	"github.com/dean2027/aishell/pkg/session"
 	localtools "github.com/dean2027/aishell/pkg/tools"
 )
 
 // ChatBot AI聊天机器人
 type ChatBot struct {
-	executor *agents.Executor
+	agent    agentRunner
 	llm      llms.Model
 	ctx      context.Context
 	config   *Config
+	shell    *localtools.ShellSession
+	sandbox  *localtools.Sandbox
+	planner  *localtools.Planner
+	approver *localtools.Approver
+	audit    *audit.Logger
+	backups  *backup.Store
+	redactor *redact.Redactor
+	tools    []tools.Tool
+	sessions *session.Store
+	session  *session.Session
+
+	eventMu sync.RWMutex
+	onEvent agent.EventHandler
 }
 
 // NewChatBot 创建新的聊天机器人实例
@@ -31,90 +52,290 @@ func NewChatBot(ctx context.Context, config *Config) (*ChatBot, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
-	// 初始化OpenAI LLM
-	var llm llms.Model
-	var err error
-
-	// 添加调试日志
+	// 按配置的提供方初始化模型
 	if config.DebugMode {
-		fmt.Printf("🔍 [DEBUG] 开始初始化OpenAI LLM...\n")
-		if config.OpenAIBaseURL != "" {
-			fmt.Printf("🔍 [DEBUG] 使用自定义BaseURL: %s\n", config.OpenAIBaseURL)
+		debugf("🔍 [DEBUG] 开始初始化LLM: %s (%s)\n", config.Provider, config.ModelName())
+		if config.BaseURL != "" {
+			debugf("🔍 [DEBUG] 使用自定义BaseURL: %s\n", config.BaseURL)
 		} else {
-			fmt.Printf("🔍 [DEBUG] 使用默认OpenAI端点\n")
+			debugf("🔍 [DEBUG] 使用默认端点\n")
 		}
 	}
 
-	// 如果有自定义BaseURL，使用它
-	if config.OpenAIBaseURL != "" {
-		llm, err = openai.New(
-			openai.WithBaseURL(config.OpenAIBaseURL),
-		)
-	} else {
-		// 使用默认的OpenAI端点
-		llm, err = openai.New()
-	}
-
+	llm, err := NewLLM(config)
 	if err != nil {
 		if config.DebugMode {
-			fmt.Printf("🔍 [DEBUG] OpenAI LLM初始化失败: %v\n", err)
+			debugf("🔍 [DEBUG] LLM初始化失败: %v\n", err)
 		}
 		return nil, fmt.Errorf("初始化LLM失败: %w", err)
 	}
 
 	if config.DebugMode {
-		fmt.Printf("🔍 [DEBUG] OpenAI LLM初始化成功\n")
+		debugf("🔍 [DEBUG] LLM初始化成功\n")
 	}
 
-	// 初始化对话窗口缓冲内存 (保持最近N轮对话)
-	conversationMemory := memory.NewConversationWindowBuffer(config.ConversationBufferSize)
+	// 加载命令策略文件，策略文件有误时拒绝启动，避免安全规则静默失效
+	commandPolicy, err := policy.Load()
+	if err != nil {
+		return nil, fmt.Errorf("加载命令策略失败: %w", err)
+	}
+	for _, warning := range commandPolicy.Warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+	}
+	if config.DebugMode && !commandPolicy.IsEmpty() {
+		debugf("🔍 [DEBUG] 已加载命令策略: %v (%d条规则)\n", commandPolicy.Files, len(commandPolicy.Rules))
+	}
 
-	// 创建工具列表
-	toolsList := createToolsList(config)
+	// 新建或恢复对话会话，恢复时调用方已通过 EnterSessionDir 切换到会话保存的工作目录
+	sessions := session.NewStore(session.DefaultDir())
+	sess, err := openSession(sessions, config)
+	if err != nil {
+		return nil, err
+	}
 
-	// 创建智能终端助手的专用系统提示
-	systemPromptPrefix := prompt.CreateSystemPrompt()
+	// 危险操作的确认器由所有工具共享，非交互模式下可改为自动同意或拒绝
+	approver := localtools.NewApprover()
 
-	// 创建使用内存和自定义系统提示的对话代理
-	agent := agents.NewConversationalAgent(llm, toolsList,
-		agents.WithMemory(conversationMemory),
-		agents.WithPromptPrefix(systemPromptPrefix),
-	)
+	// 文件工具的访问范围：允许的目录内直接访问，之外需要确认，敏感路径始终拒绝
+	workspace := localtools.NewWorkspace(config.AllowedRoots, config.DeniedPaths)
+	workspace.Approver = approver
+	if config.DebugMode {
+		debugf("🔍 [DEBUG] 文件工具工作区: %v\n", workspace.Roots())
+	}
 
-	// 创建执行器选项
-	executorOptions := createExecutorOptions(config, conversationMemory)
-	executor := agents.NewExecutor(agent, executorOptions...)
+	// 启用沙箱时系统命令只能写入工作区，资源受限
+	sandbox := newSandbox(config, workspace.Roots())
 
-	return &ChatBot{
-		executor: executor,
+	// 启用持久 shell 会话时，所有系统命令共享同一个 shell
+	var shell *localtools.ShellSession
+	if config.PersistentShell {
+		shell = localtools.NewShellSession()
+		shell.Sandbox = sandbox
+		// 文件工具的相对路径跟随会话中 cd 之后的当前目录
+		workspace.Cwd = shell.Cwd
+	}
+
+	// 演练模式状态由系统命令和文件写入工具共享，可在运行时切换
+	planner := localtools.NewPlanner(config.DryRun)
+
+	// 审计日志记录每一次命令执行和文件读写
+	var auditLog *audit.Logger
+	if config.AuditLog != "" && config.AuditLog != AuditOff {
+		auditLog, err = audit.Open(config.AuditLog, config.AuditSyslog)
+		if err != nil {
+			return nil, err
+		}
+		auditLog.SetSession(sess.ID)
+	}
+
+	// file_writer 覆盖文件前按会话保存原内容，用于 /undo
+	backups := backup.NewStore(backup.DefaultDir())
+
+	// 用户输入和工具结果发送给模型之前遮盖密钥，同一个密钥在本次运行中使用同一个占位符
+	var redactor *redact.Redactor
+	if config.RedactSecrets {
+		redactor = redact.New()
+		reservePlaceholders(redactor, sess.Messages)
+	}
+
+	// 创建工具列表
+	toolsList := createToolsList(config, toolDeps{
+		policy:    commandPolicy,
+		shell:     shell,
+		sandbox:   sandbox,
+		planner:   planner,
+		approver:  approver,
+		workspace: workspace,
+		audit:     auditLog,
+		backups:   backups,
+		session:   sess.ID,
+	})
+
+	cb := &ChatBot{
 		llm:      llm,
 		ctx:      ctx,
 		config:   config,
-	}, nil
+		shell:    shell,
+		sandbox:  sandbox,
+		planner:  planner,
+		approver: approver,
+		audit:    auditLog,
+		backups:  backups,
+		redactor: redactor,
+		tools:    toolsList,
+		sessions: sessions,
+		session:  sess,
+	}
+
+	// 创建代理，代理事件统一转发给当前设置的事件处理函数
+	cb.agent, err = createAgent(config, llm, toolsList, redactor, cb.dispatchEvent)
+	if err != nil {
+		return nil, err
+	}
+	if len(sess.Messages) > 0 {
+		cb.agent.SetHistory(session.ToLLM(sess.Messages))
+	}
+
+	return cb, nil
+}
+
+// newSandbox 按配置创建系统命令的沙箱，未启用时返回 nil
+func newSandbox(config *Config, writable []string) *localtools.Sandbox {
+	if config.Sandbox == "" || config.Sandbox == localtools.SandboxOff {
+		return nil
+	}
+	sandbox := localtools.NewSandbox(config.Sandbox)
+	sandbox.Writable = writable
+	sandbox.Network = config.SandboxNetwork
+	sandbox.CPUTime = time.Duration(config.SandboxCPU) * time.Second
+	sandbox.Memory = int64(config.SandboxMemory) << 20
+	sandbox.MaxOutput = int64(config.SandboxOutput) << 20
+	if config.DebugMode {
+		if err := sandbox.Available(); err != nil {
+			debugf("🔍 [DEBUG] 命令沙箱不可用: %v\n", err)
+		} else {
+			debugf("🔍 [DEBUG] 命令沙箱: 可写目录 %v，网络 %v\n", writable, config.SandboxNetwork)
+		}
+	}
+	return sandbox
+}
+
+// openSession 按配置新建会话，或恢复指定（latest 为最近）的会话
+func openSession(store *session.Store, config *Config) (*session.Session, error) {
+	if config.Resume == "" {
+		return session.New(config.Provider, config.ModelName(), config.AgentType), nil
+	}
+
+	sess, err := loadSession(store, config.Resume)
+	if err != nil {
+		return nil, err
+	}
+	// 恢复后使用当前配置的模型继续对话
+	sess.Provider, sess.Model, sess.Agent = config.Provider, config.ModelName(), config.AgentType
+	return sess, nil
+}
+
+// loadSession 读取要恢复的会话，resume 为 latest 时读取最近的会话
+func loadSession(store *session.Store, resume string) (*session.Session, error) {
+	var sess *session.Session
+	var err error
+	if resume == ResumeLatest {
+		sess, err = store.Latest()
+	} else {
+		sess, err = store.Load(resume)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("恢复会话失败: %w", err)
+	}
+	return sess, nil
+}
+
+// EnterSessionDir 切换到要恢复的会话保存的工作目录。需要在加载配置之前调用，
+// 这样项目配置、命令策略、文件工具工作区和 shell 会话都来自会话所在的项目。
+func EnterSessionDir(resume string) error {
+	sess, err := loadSession(session.NewStore(session.DefaultDir()), resume)
+	if err != nil {
+		return err
+	}
+	if sess.Cwd != "" {
+		if err := os.Chdir(sess.Cwd); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  无法切换到会话的工作目录 %s: %v\n", sess.Cwd, err)
+		}
+	}
+	return nil
+}
+
+// reservePlaceholders 让恢复的会话历史中已有的占位符编号不再分配给本次运行的新密钥，
+// 否则模型引用旧占位符时会被还原成无关的新密钥
+func reservePlaceholders(redactor *redact.Redactor, messages []session.Message) {
+	for _, msg := range messages {
+		redactor.Reserve(msg.Content)
+		for _, call := range msg.ToolCalls {
+			redactor.Reserve(call.Arguments)
+		}
+	}
+}
+
+// Session 返回当前会话
+func (cb *ChatBot) Session() *session.Session {
+	return cb.session
+}
+
+// Sessions 返回会话存储
+func (cb *ChatBot) Sessions() *session.Store {
+	return cb.sessions
+}
+
+// saveSession 把代理的对话历史写入会话文件，没有任何消息的会话不保存
+func (cb *ChatBot) saveSession() error {
+	cb.session.SetMessages(cb.agent.History())
+	if len(cb.session.Messages) == 0 {
+		return nil
+	}
+	cb.session.Updated = time.Now()
+	if cb.shell != nil && cb.shell.Cwd() != "" {
+		cb.session.Cwd = cb.shell.Cwd()
+	} else if wd, err := os.Getwd(); err == nil {
+		cb.session.Cwd = wd
+	}
+	return cb.sessions.Save(cb.session)
+}
+
+// SetEventHandler 设置代理事件处理函数（流式回复、工具调用），为 nil 时不处理事件
+func (cb *ChatBot) SetEventHandler(handler agent.EventHandler) {
+	cb.eventMu.Lock()
+	defer cb.eventMu.Unlock()
+	cb.onEvent = handler
+}
+
+// dispatchEvent 把代理事件转发给事件处理函数
+func (cb *ChatBot) dispatchEvent(event agent.Event) {
+	cb.eventMu.RLock()
+	handler := cb.onEvent
+	cb.eventMu.RUnlock()
+	if handler != nil {
+		handler(event)
+	}
 }
 
 // ProcessInput 处理用户输入
 func (cb *ChatBot) ProcessInput(input string) (string, error) {
+	return cb.ProcessInputContext(cb.ctx, input)
+}
+
+// ProcessInputContext 使用指定的上下文处理用户输入，取消 ctx 会中断模型请求和正在执行的工具。
+// 中断后已完成的工具调用和部分回复保留在对话历史中，返回的错误满足 errors.Is(err, context.Canceled)。
+func (cb *ChatBot) ProcessInputContext(ctx context.Context, input string) (string, error) {
+	// 用户粘贴的密钥同样不发送给模型
+	input, report := cb.redactor.Redact(input)
+	if len(report) > 0 {
+		cb.dispatchEvent(agent.Event{Type: agent.EventRedacted, Redacted: report})
+	}
+
 	// 添加调试日志
 	if cb.config.DebugMode {
-		fmt.Printf("🔍 [DEBUG] 开始处理用户输入: %s\n", input)
-		fmt.Printf("🔍 [DEBUG] 调用chains.Run...\n")
+		debugf("🔍 [DEBUG] 开始处理用户输入: %s\n", input)
+		debugf("🔍 [DEBUG] 调用%s代理...\n", cb.config.AgentType)
 	}
 
-	// 调用执行器处理输入
-	result, err := chains.Run(cb.ctx, cb.executor, input)
+	// 调用代理处理输入，每轮结束（包括中断和失败）后保存会话，意外退出也不会丢失对话
+	result, err := cb.agent.Run(ctx, input)
+	if saveErr := cb.saveSession(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  保存会话失败: %v\n", saveErr)
+	}
 	if err != nil {
-		// ConversationalAgent 现在应该足够稳定，直接返回错误
-		// 如果频繁出现解析错误，可以考虑重新启用 fallback 机制
 		if cb.config.DebugMode {
-			fmt.Printf("🔍 [DEBUG] chains.Run调用失败: %v\n", err)
+			debugf("🔍 [DEBUG] 代理调用失败: %v\n", err)
 		}
 		return "", fmt.Errorf("处理输入失败: %w", err)
 	}
 
 	if cb.config.DebugMode {
-		fmt.Printf("🔍 [DEBUG] chains.Run调用成功，结果长度: %d\n", len(result))
+		debugf("🔍 [DEBUG] 代理调用成功，结果长度: %d\n", len(result))
 	}
 
 	return result, nil
@@ -125,19 +346,161 @@ func (cb *ChatBot) GetConfig() *Config {
 	return cb.config
 }
 
-// Close 关闭聊天机器人，清理资源
+// DryRun 是否处于演练模式
+func (cb *ChatBot) DryRun() bool {
+	return cb.planner.Enabled()
+}
+
+// SetDryRun 开启或关闭演练模式，关闭时丢弃尚未执行的计划
+func (cb *ChatBot) SetDryRun(enabled bool) {
+	cb.planner.SetEnabled(enabled)
+	cb.config.DryRun = enabled
+	if !enabled {
+		cb.planner.Take()
+	}
+}
+
+// TakePlan 取出本轮对话记录的计划步骤
+func (cb *ChatBot) TakePlan() []localtools.PlanStep {
+	return cb.planner.Take()
+}
+
+// ExecutePlanStep 真正执行一个计划步骤，危险命令确认和策略检查照常生效。
+// 取消 ctx 会中断正在执行的步骤
+func (cb *ChatBot) ExecutePlanStep(ctx context.Context, step localtools.PlanStep) (string, error) {
+	for _, tool := range cb.tools {
+		if tool.Name() == step.Tool {
+			return tool.Call(localtools.WithPlanExecution(ctx), step.Input)
+		}
+	}
+	return "", fmt.Errorf("未找到工具: %s", step.Tool)
+}
+
+// Approver 返回危险操作的确认器
+func (cb *ChatBot) Approver() *localtools.Approver {
+	return cb.approver
+}
+
+// SetCommandOutput 设置系统命令输出的实时显示位置，为 nil 时不显示
+func (cb *ChatBot) SetCommandOutput(w io.Writer) {
+	for _, tool := range cb.tools {
+		if systemCommand, ok := tool.(*localtools.SystemCommand); ok {
+			systemCommand.Output = w
+		}
+	}
+}
+
+// Undo 撤销当前会话中最近一次文件写入，path 不为空时撤销该文件最近一次写入
+func (cb *ChatBot) Undo(path string) (*backup.Entry, error) {
+	return cb.backups.Undo(cb.session.ID, path)
+}
+
+// ResetShell 重置持久 shell 会话，丢弃工作目录、环境变量等状态。
+// 未启用持久会话时返回错误。
+func (cb *ChatBot) ResetShell() error {
+	if cb.shell == nil {
+		return fmt.Errorf("未启用持久 shell 会话（设置 AISHELL_PERSISTENT_SHELL=true 启用）")
+	}
+	return cb.shell.Reset()
+}
+
+// Close 关闭聊天机器人：保存对话会话，关闭 shell 会话并清理沙箱临时目录
 func (cb *ChatBot) Close() error {
-	// 这里可以添加清理逻辑，比如保存对话历史等
-	return nil
+	err := cb.saveSession()
+	if cb.shell != nil {
+		if closeErr := cb.shell.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := cb.sandbox.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := cb.audit.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// toolDeps 工具之间共享的状态
+type toolDeps struct {
+	// policy 命令策略
+	policy *policy.Policy
+	// shell 持久 shell 会话，为空时每条命令独立执行
+	shell *localtools.ShellSession
+	// sandbox 系统命令的沙箱，为空时不隔离
+	sandbox *localtools.Sandbox
+	// planner 演练模式状态
+	planner *localtools.Planner
+	// approver 危险操作的确认器
+	approver *localtools.Approver
+	// workspace 文件工具的访问范围
+	workspace *localtools.Workspace
+	// audit 审计日志，为空时不记录
+	audit *audit.Logger
+	// backups 文件写入前的备份存储
+	backups *backup.Store
+	// session 当前会话 ID，备份按会话保存
+	session string
 }
 
 // createToolsList 创建工具列表
-func createToolsList(config *Config) []tools.Tool {
+func createToolsList(config *Config, deps toolDeps) []tools.Tool {
+	systemCommand := localtools.NewSystemCommand()
+	systemCommand.Policy = deps.policy
+	systemCommand.Session = deps.shell
+	systemCommand.Sandbox = deps.sandbox
+	systemCommand.Planner = deps.planner
+	systemCommand.Approver = deps.approver
+	systemCommand.Audit = deps.audit
+
+	// 命令输出溢出文件需要通过 file_reader 分页查看
+	if systemCommand.Budget != nil && systemCommand.Budget.SpillDir != "" {
+		deps.workspace.AddInternalRoot(systemCommand.Budget.SpillDir)
+	}
+
+	fileReader := localtools.NewFileReader()
+	fileReader.Audit = deps.audit
+	fileReader.Workspace = deps.workspace
+
+	fileSearch := localtools.NewFileSearch()
+	fileSearch.Audit = deps.audit
+	fileSearch.Workspace = deps.workspace
+
+	dirList := localtools.NewDirList()
+	dirList.Audit = deps.audit
+	dirList.Workspace = deps.workspace
+
+	dataQuery := localtools.NewDataQuery()
+	dataQuery.Audit = deps.audit
+	dataQuery.Workspace = deps.workspace
+
+	fileWriter := localtools.NewFileWriter()
+	fileWriter.Planner = deps.planner
+	fileWriter.Policy = deps.policy
+	fileWriter.Workspace = deps.workspace
+	fileWriter.Approver = deps.approver
+	fileWriter.Audit = deps.audit
+	fileWriter.Backups = deps.backups
+	fileWriter.BackupSession = deps.session
+
+	fileEditor := localtools.NewFileEditor()
+	fileEditor.Planner = deps.planner
+	fileEditor.Policy = deps.policy
+	fileEditor.Workspace = deps.workspace
+	fileEditor.Approver = deps.approver
+	fileEditor.Audit = deps.audit
+	fileEditor.Backups = deps.backups
+	fileEditor.BackupSession = deps.session
+
 	toolsList := []tools.Tool{
 		tools.Calculator{},
-		localtools.NewSystemCommand(),
-		localtools.NewFileReader(),
-		localtools.NewFileWriter(),
+		systemCommand,
+		fileReader,
+		fileSearch,
+		dirList,
+		dataQuery,
+		fileWriter,
+		fileEditor,
 	}
 
 	// 如果设置了SERPAPI_API_KEY，添加搜索工具
@@ -151,6 +514,104 @@ func createToolsList(config *Config) []tools.Tool {
 	return toolsList
 }
 
+// agentRunner 处理一次用户输入的代理
+type agentRunner interface {
+	Run(ctx context.Context, input string) (string, error)
+	// History 返回对话历史，用于保存会话
+	History() []llms.MessageContent
+	// SetHistory 恢复对话历史
+	SetHistory(history []llms.MessageContent)
+}
+
+// executorRunner 基于文本解析的对话代理执行器
+type executorRunner struct {
+	executor *agents.Executor
+	memory   *memory.ConversationWindowBuffer
+}
+
+// Run 调用执行器处理输入。被中断时执行器不会写入记忆，这里补记本轮输入，保留对话上下文。
+func (e executorRunner) Run(ctx context.Context, input string) (string, error) {
+	result, err := chains.Run(ctx, e.executor, input)
+	if err != nil && ctx.Err() != nil {
+		_ = e.memory.SaveContext(context.Background(),
+			map[string]any{"input": input},
+			map[string]any{"output": "[本轮对话已被用户中断]"})
+		return "", ctx.Err()
+	}
+	return result, err
+}
+
+// History 从对话记忆中读取历史。对话代理的记忆只保存输入和最终回复，不含工具调用。
+func (e executorRunner) History() []llms.MessageContent {
+	messages, err := e.memory.ChatHistory.Messages(context.Background())
+	if err != nil {
+		return nil
+	}
+	history := make([]llms.MessageContent, 0, len(messages))
+	for _, m := range messages {
+		history = append(history, llms.TextParts(m.GetType(), m.GetContent()))
+	}
+	return history
+}
+
+// SetHistory 把历史写回对话记忆，工具调用和结果无法还原为文本对话，只保留输入和回复
+func (e executorRunner) SetHistory(history []llms.MessageContent) {
+	var messages []llms.ChatMessage
+	for _, mc := range history {
+		var text []string
+		for _, part := range mc.Parts {
+			if t, ok := part.(llms.TextContent); ok && t.Text != "" {
+				text = append(text, t.Text)
+			}
+		}
+		if len(text) == 0 {
+			continue
+		}
+		switch mc.Role {
+		case llms.ChatMessageTypeHuman:
+			messages = append(messages, llms.HumanChatMessage{Content: strings.Join(text, "\n")})
+		case llms.ChatMessageTypeAI:
+			messages = append(messages, llms.AIChatMessage{Content: strings.Join(text, "\n")})
+		}
+	}
+	_ = e.memory.ChatHistory.SetMessages(context.Background(), messages)
+}
+
+// createAgent 按配置创建代理：默认使用原生函数调用，conversational 为文本解析的旧代理
+func createAgent(config *Config, llm llms.Model, toolsList []tools.Tool, redactor *redact.Redactor, onEvent agent.EventHandler) (agentRunner, error) {
+	switch config.AgentType {
+	case AgentConversational:
+		// 初始化对话窗口缓冲内存 (保持最近N轮对话)
+		conversationMemory := memory.NewConversationWindowBuffer(config.ConversationBufferSize)
+
+		// 创建使用内存和自定义系统提示的对话代理，对话代理不产生工具事件，由包装后的工具触发
+		conversational := agents.NewConversationalAgent(llm, agent.ObserveTools(toolsList, redactor, onEvent),
+			agents.WithMemory(conversationMemory),
+			agents.WithPromptPrefix(prompt.CreateSystemPrompt()),
+		)
+
+		// 创建执行器选项
+		executorOptions := createExecutorOptions(config, conversationMemory)
+		return executorRunner{
+			executor: agents.NewExecutor(conversational, executorOptions...),
+			memory:   conversationMemory,
+		}, nil
+
+	case AgentFunctions, "":
+		functionAgent := agent.NewFunctionAgent(llm, toolsList, prompt.CreateFunctionCallingPrompt())
+		functionAgent.MaxIterations = config.MaxExecutorIterations
+		functionAgent.MaxTurns = config.ConversationBufferSize
+		functionAgent.OnEvent = onEvent
+		functionAgent.Redactor = redactor
+		if config.DebugMode {
+			debugf("🔍 调试模式已启用 - 将显示详细的执行日志\n")
+			functionAgent.CallbacksHandler = debugHandler{}
+		}
+		return functionAgent, nil
+	}
+	return nil, fmt.Errorf("不支持的代理类型: %s", config.AgentType)
+}
+
 // createExecutorOptions 创建执行器选项
 func createExecutorOptions(config *Config, conversationMemory *memory.ConversationWindowBuffer) []agents.Option {
 	var executorOptions []agents.Option
@@ -159,22 +620,14 @@ func createExecutorOptions(config *Config, conversationMemory *memory.Conversati
 	executorOptions = append(executorOptions, agents.WithMemory(conversationMemory))
 
 	if config.DebugMode {
-		fmt.Println("🔍 调试模式已启用 - 将显示详细的执行日志")
-		debugHandler := callbacks.LogHandler{}
-		executorOptions = append(executorOptions, agents.WithCallbacksHandler(debugHandler))
+		debugf("🔍 调试模式已启用 - 将显示详细的执行日志\n")
+		handler := debugHandler{}
+		executorOptions = append(executorOptions, agents.WithCallbacksHandler(handler))
 	}
 
 	return executorOptions
 }
 
-// ValidateRequirements 验证运行环境要求
-func ValidateRequirements() error {
-	if !HasOpenAIAPI() {
-		return fmt.Errorf("未设置OPENAI_API_KEY环境变量，请设置: export OPENAI_API_KEY=your_api_key")
-	}
-	return nil
-}
-
 // init 初始化包
 func init() {
 	// 设置默认的环境变量获取函数
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dean2027/aishell/pkg/redact"
+	"github.com/dean2027/aishell/pkg/session"
+)
+
+func TestReservePlaceholders_Resume(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+
+	// 第一次运行：密钥在写入历史前已被遮盖
+	first := redact.New()
+	input, _ := first.Redact("部署时使用 sk-proj-firstrunsecretAAAAAAAAAAAA1")
+	sess := session.New("openai", "gpt-4o", "tools")
+	sess.Messages = []session.Message{
+		{Role: session.RoleHuman, Content: input},
+		{Role: session.RoleAI, ToolCalls: []session.ToolCall{{ID: "1", Name: "system_command", Arguments: `{"command":"deploy --key [REDACTED_API_KEY_1]"}`}}},
+	}
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二次运行：恢复会话后出现新的密钥
+	resumed, err := store.Load(sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := redact.New()
+	reservePlaceholders(second, resumed.Messages)
+	out, _ := second.Redact("换成 sk-proj-secondrunsecretBBBBBBBBBBB2")
+
+	if strings.Contains(out, "[REDACTED_API_KEY_1]") {
+		t.Fatalf("新密钥不应复用旧占位符，得到 %q", out)
+	}
+	if got := second.Restore("deploy --key [REDACTED_API_KEY_1]"); got != "deploy --key [REDACTED_API_KEY_1]" {
+		t.Errorf("旧占位符不应还原为新密钥，得到 %q", got)
+	}
+
+	// 会话文件中只有占位符，没有原始密钥
+	resumed.Messages = append(resumed.Messages, session.Message{Role: session.RoleHuman, Content: out})
+	if err := store.Save(resumed); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(store.Dir, resumed.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "firstrunsecret") || strings.Contains(string(data), "secondrunsecret") {
+		t.Errorf("会话文件中不应出现原始密钥: %s", data)
+	}
+}
+
+func TestEnterSessionDir_BeforeConfig(t *testing.T) {
+	withEnv(t, nil)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	project := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(project, ".aishell"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ".aishell", ConfigFileName), []byte("model = \"session-model\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sess := session.New("openai", "gpt-4o", "tools")
+	sess.Cwd = project
+	sess.Messages = []session.Message{{Role: session.RoleHuman, Content: "hi"}}
+	if err := session.NewStore(session.DefaultDir()).Save(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnterSessionDir(ResumeLatest); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Model != "session-model" {
+		t.Errorf("恢复会话时应使用会话所在项目的配置，得到 model=%q", config.Model)
+	}
+}
@@ -1,6 +1,37 @@
 package app
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	localtools "github.com/dean2027/aishell/pkg/tools"
+)
+
+// 代理类型
+const (
+	// AgentFunctions 基于原生函数调用的代理（默认）
+	AgentFunctions = "functions"
+	// AgentConversational 基于文本解析（Action/Action Input）的对话代理，用于不支持函数调用的模型
+	AgentConversational = "conversational"
+)
+
+// 非交互模式的输出格式
+const (
+	// OutputText 纯文本回答（默认）
+	OutputText = "text"
+	// OutputJSON 结束时输出一个包含回答和全部步骤的 JSON 对象
+	OutputJSON = "json"
+	// OutputJSONL 每个事件输出一行 JSON
+	OutputJSONL = "jsonl"
+)
+
+// AuditOff 关闭审计日志的 audit_log 取值
+const AuditOff = "off"
+
+// ResumeLatest 恢复最近的会话
+const ResumeLatest = "latest"
 
 // Config 应用配置
 type Config struct {
@@ -22,8 +53,78 @@ type Config struct {
 	// HasSearchAPI 是否有搜索API
 	HasSearchAPI bool
 
-	// OpenAIBaseURL OpenAI API基础URL，用于自定义端点
-	OpenAIBaseURL string
+	// Provider 模型提供方：openai、azure、anthropic、ollama、local
+	Provider string
+
+	// Model 模型名称（Azure 为部署名称），为空时使用提供方的默认模型
+	Model string
+
+	// Temperature 采样温度，为 nil 时使用模型默认值
+	Temperature *float64
+
+	// MaxTokens 单次回复的最大输出长度，0 表示使用默认值
+	MaxTokens int
+
+	// APIVersion API版本，仅 Azure OpenAI 使用
+	APIVersion string
+
+	// APIKey 模型服务的密钥，按提供方从对应的环境变量读取
+	APIKey string
+
+	// BaseURL 模型服务的自定义端点，用于代理服务、Azure 资源地址或本地服务
+	BaseURL string
+
+	// PersistentShell 是否在持久 shell 会话中执行系统命令，保留 cd/export 等状态
+	PersistentShell bool
+
+	// DryRun 演练模式，系统命令和文件写入只生成计划不执行
+	DryRun bool
+
+	// AgentType 代理类型：functions（原生函数调用）或 conversational（文本解析）
+	AgentType string
+
+	// AuditLog 审计日志文件路径，AuditOff 表示不记录
+	AuditLog string
+
+	// AuditSyslog 是否同时把审计记录发送到 syslog
+	AuditSyslog bool
+
+	// Output 非交互模式的输出格式：text、json 或 jsonl
+	Output string
+
+	// RedactSecrets 发送给模型之前遮盖用户输入和工具结果中的密钥
+	RedactSecrets bool
+
+	// AllowedRoots 文件工具可以直接访问的目录，为空时使用启动时的当前目录，之外的文件需要用户确认
+	AllowedRoots []string
+
+	// DeniedPaths 在内置敏感路径之外，文件工具禁止访问的路径（支持 glob）
+	DeniedPaths []string
+
+	// Sandbox 系统命令的沙箱模式：off、on（不支持时照常执行）或 required（不支持时拒绝执行）
+	Sandbox string
+
+	// SandboxNetwork 沙箱内是否允许访问网络
+	SandboxNetwork bool
+
+	// SandboxCPU 沙箱内每个进程的 CPU 时间上限（秒），0 表示不限制
+	SandboxCPU int
+
+	// SandboxMemory 沙箱内每个进程的内存上限（MB），0 表示不限制
+	SandboxMemory int
+
+	// SandboxOutput 沙箱内命令输出和写入单个文件的大小上限（MB），0 表示不限制
+	SandboxOutput int
+
+	// Resume 启动时恢复的会话 ID（可以是唯一前缀），ResumeLatest 表示最近的会话，为空时新建会话。
+	// 只能通过命令行 --resume 指定，不写入配置文件
+	Resume string
+
+	// Warnings 加载时被忽略的配置项
+	Warnings []string
+
+	// sources 记录被覆盖的配置项来源，键为配置文件中的键名
+	sources map[string]string
 }
 
 // DefaultConfig 返回默认配置
@@ -35,47 +136,112 @@ func DefaultConfig() *Config {
 		Prompt:                 "💻 智能终端> ",
 		DebugMode:              false,
 		HasSearchAPI:           false,
-		OpenAIBaseURL:          "", // 默认为空，使用OpenAI官方端点
+		Provider:               ProviderOpenAI,
+		BaseURL:                "", // 默认为空，使用提供方的官方端点
+		PersistentShell:        false,
+		DryRun:                 false,
+		AgentType:              AgentFunctions,
+		Output:                 OutputText,
+		AuditLog:               audit.DefaultPath(),
+		RedactSecrets:          true,
+		Sandbox:                localtools.SandboxOff,
+		SandboxCPU:             int(localtools.DefaultSandboxCPU / time.Second),
+		SandboxMemory:          localtools.DefaultSandboxMemory >> 20,
+		SandboxOutput:          localtools.DefaultSandboxOutput >> 20,
 	}
 }
 
-// LoadConfig 从环境变量加载配置
-func LoadConfig() *Config {
+// LoadConfig 加载配置，优先级从低到高：默认值、用户配置文件 (~/.config/aishell/config.toml)、
+// 项目配置文件 (.aishell/config.toml)、环境变量、命令行选项。flags 为 nil 时不应用命令行选项。
+func LoadConfig(flags *Flags) (*Config, error) {
 	config := DefaultConfig()
 
-	// 从环境变量读取配置
-	if isDebugEnabled() {
-		config.DebugMode = true
+	// 配置文件
+	for _, file := range ConfigFiles() {
+		if !file.Exists {
+			continue
+		}
+		if err := config.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	// 环境变量
+	for _, s := range settings {
+		if value := getEnv(s.env); value != "" {
+			if err := config.apply(s, value, "环境变量 "+s.env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 命令行选项
+	if err := flags.apply(config); err != nil {
+		return nil, err
 	}
 
 	if hasSearchAPI() {
 		config.HasSearchAPI = true
 	}
 
-	// 读取OpenAI BaseURL配置
-	if baseURL := getEnv("OPENAI_BASE_URL"); baseURL != "" {
-		config.OpenAIBaseURL = baseURL
+	// 各提供方自己的环境变量（OPENAI_API_KEY 等）只在上面各层都没有设置时使用
+	config.loadProviderEnv()
+
+	if config.Source("agent") == SourceDefault && config.Provider == ProviderOllama {
+		// Ollama 原生接口不支持函数调用，默认使用文本解析的对话代理
+		config.AgentType = AgentConversational
 	}
 
 	// 添加调试日志
 	if config.DebugMode {
-		apiKey := getEnv("OPENAI_API_KEY")
-		if apiKey != "" {
-			fmt.Printf("🔍 [DEBUG] OpenAI API Key: %s... (前10个字符)\n", apiKey[:min(10, len(apiKey))])
+		if config.APIKey != "" {
+			debugf("🔍 [DEBUG] API Key: (已设置，%d个字符)\n", len(config.APIKey))
 		} else {
-			fmt.Printf("🔍 [DEBUG] OpenAI API Key: (未设置)\n")
+			debugf("🔍 [DEBUG] API Key: (未设置)\n")
 		}
-		fmt.Printf("🔍 [DEBUG] OpenAI Base URL: %s\n", config.OpenAIBaseURL)
-		fmt.Printf("🔍 [DEBUG] Has Search API: %v\n", config.HasSearchAPI)
-		fmt.Printf("🔍 [DEBUG] Debug Mode: %v\n", config.DebugMode)
+		debugf("🔍 [DEBUG] Provider: %s\n", config.Provider)
+		debugf("🔍 [DEBUG] Model: %s\n", config.ModelName())
+		debugf("🔍 [DEBUG] Base URL: %s\n", config.BaseURL)
+		if config.Temperature != nil {
+			debugf("🔍 [DEBUG] Temperature: %g\n", *config.Temperature)
+		}
+		debugf("🔍 [DEBUG] Max Tokens: %d\n", config.MaxTokens)
+		if config.Provider == ProviderAzure {
+			debugf("🔍 [DEBUG] API Version: %s\n", config.APIVersion)
+		}
+		debugf("🔍 [DEBUG] Has Search API: %v\n", config.HasSearchAPI)
+		debugf("🔍 [DEBUG] Debug Mode: %v\n", config.DebugMode)
+		debugf("🔍 [DEBUG] Persistent Shell: %v\n", config.PersistentShell)
+		debugf("🔍 [DEBUG] Dry Run: %v\n", config.DryRun)
+		debugf("🔍 [DEBUG] Redact Secrets: %v\n", config.RedactSecrets)
+		debugf("🔍 [DEBUG] Sandbox: %s\n", config.Sandbox)
+		debugf("🔍 [DEBUG] Agent Type: %s\n", config.AgentType)
 	}
 
-	return config
+	return config, nil
 }
 
-// isDebugEnabled 检查是否启用调试模式
-func isDebugEnabled() bool {
-	return getEnv("AISHELL_DEBUG") == "true"
+// Validate 校验配置
+func (c *Config) Validate() error {
+	switch c.AgentType {
+	case AgentFunctions, AgentConversational:
+	default:
+		return fmt.Errorf("不支持的代理类型 '%s'，可选: %s, %s", c.AgentType, AgentFunctions, AgentConversational)
+	}
+	switch c.Output {
+	case OutputText, OutputJSON, OutputJSONL:
+	default:
+		return fmt.Errorf("不支持的输出格式 '%s'，可选: %s, %s, %s", c.Output, OutputText, OutputJSON, OutputJSONL)
+	}
+	if !localtools.ValidSandboxMode(c.Sandbox) {
+		return fmt.Errorf("不支持的沙箱模式 '%s'，可选: %s, %s, %s", c.Sandbox, localtools.SandboxOff, localtools.SandboxOn, localtools.SandboxRequired)
+	}
+	return c.validateProvider()
+}
+
+// debugf 输出调试信息。调试信息写入标准错误，不会混入通过管道输出的回答或 JSON。
+func debugf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
 }
 
 // hasSearchAPI 检查是否有搜索API配置
@@ -83,16 +249,6 @@ func hasSearchAPI() bool {
 	return getEnv("SERPAPI_API_KEY") != ""
 }
 
-// hasOpenAIAPI 检查是否有OpenAI API配置
-func HasOpenAIAPI() bool {
-	return getEnv("OPENAI_API_KEY") != ""
-}
-
-// getOpenAIBaseURL 获取OpenAI BaseURL
-func getOpenAIBaseURL() string {
-	return getEnv("OPENAI_BASE_URL")
-}
-
 // getEnv 安全获取环境变量
 func getEnv(key string) string {
 	// 这里可以添加更复杂的环境变量处理逻辑
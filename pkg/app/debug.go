@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// debugHandler 调试模式的执行日志，与 callbacks.LogHandler 内容相同，但写到标准错误，
+// 不会混进 --output json/jsonl 的标准输出
+type debugHandler struct {
+	callbacks.SimpleHandler
+}
+
+var _ callbacks.Handler = debugHandler{}
+
+func (debugHandler) HandleLLMGenerateContentStart(_ context.Context, ms []llms.MessageContent) {
+	debugf("Entering LLM with messages:\n")
+	for _, m := range ms {
+		var buf strings.Builder
+		for _, part := range m.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				buf.WriteString(text.Text)
+			}
+		}
+		debugf("Role: %s\nText: %s\n", m.Role, buf.String())
+	}
+}
+
+func (debugHandler) HandleLLMGenerateContentEnd(_ context.Context, res *llms.ContentResponse) {
+	debugf("Exiting LLM with response:\n")
+	for _, c := range res.Choices {
+		if c.Content != "" {
+			debugf("Content: %s\n", c.Content)
+		}
+		if c.StopReason != "" {
+			debugf("StopReason: %s\n", c.StopReason)
+		}
+		for _, call := range c.ToolCalls {
+			if call.FunctionCall != nil {
+				debugf("ToolCall: %s %s\n", call.FunctionCall.Name, call.FunctionCall.Arguments)
+			}
+		}
+	}
+}
+
+func (debugHandler) HandleText(_ context.Context, text string) {
+	debugf("%s\n", text)
+}
+
+func (debugHandler) HandleLLMError(_ context.Context, err error) {
+	debugf("Exiting LLM with error: %v\n", err)
+}
+
+func (debugHandler) HandleChainStart(_ context.Context, inputs map[string]any) {
+	debugf("Entering chain with inputs: %v\n", inputs)
+}
+
+func (debugHandler) HandleChainEnd(_ context.Context, outputs map[string]any) {
+	debugf("Exiting chain with outputs: %v\n", outputs)
+}
+
+func (debugHandler) HandleChainError(_ context.Context, err error) {
+	debugf("Exiting chain with error: %v\n", err)
+}
+
+func (debugHandler) HandleToolStart(_ context.Context, input string) {
+	debugf("Entering tool with input: %s\n", oneLine(input))
+}
+
+func (debugHandler) HandleToolEnd(_ context.Context, output string) {
+	debugf("Exiting tool with output: %s\n", oneLine(output))
+}
+
+func (debugHandler) HandleToolError(_ context.Context, err error) {
+	debugf("Exiting tool with error: %v\n", err)
+}
+
+func (debugHandler) HandleAgentAction(_ context.Context, action schema.AgentAction) {
+	debugf("Agent selected action: %q with input %q\n", action.Tool, oneLine(action.ToolInput))
+}
+
+func (debugHandler) HandleAgentFinish(_ context.Context, finish schema.AgentFinish) {
+	debugf("Agent finish: %v\n", finish)
+}
+
+// oneLine 把多行文本合并为一行
+func oneLine(s string) string {
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,277 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// 模型提供方
+const (
+	// ProviderOpenAI OpenAI 官方接口（默认）
+	ProviderOpenAI = "openai"
+	// ProviderAzure Azure OpenAI，模型名为部署名称
+	ProviderAzure = "azure"
+	// ProviderAnthropic Anthropic Claude 接口
+	ProviderAnthropic = "anthropic"
+	// ProviderOllama 本地 Ollama 服务（原生接口）
+	ProviderOllama = "ollama"
+	// ProviderLocal 本地或自建的 OpenAI 兼容服务（vLLM、LM Studio、llama.cpp server、Ollama 的 /v1 等）
+	ProviderLocal = "local"
+)
+
+// Providers 支持的模型提供方
+var Providers = []string{ProviderOpenAI, ProviderAzure, ProviderAnthropic, ProviderOllama, ProviderLocal}
+
+const (
+	// defaultAzureAPIVersion Azure OpenAI 默认 API 版本（支持函数调用）
+	defaultAzureAPIVersion = "2024-06-01"
+	// defaultAnthropicModel Anthropic 默认模型
+	defaultAnthropicModel = "claude-3-5-sonnet-latest"
+	// defaultAnthropicMaxTokens Anthropic 接口要求必须指定最大输出长度
+	defaultAnthropicMaxTokens = 4096
+	// defaultOllamaModel Ollama 默认模型
+	defaultOllamaModel = "llama3.1"
+	// localPlaceholderKey 本地服务通常不校验密钥，但 OpenAI 客户端要求非空
+	localPlaceholderKey = "local"
+)
+
+// providerEnv 各提供方默认读取的环境变量，AISHELL_* 通用变量优先
+type providerEnv struct {
+	apiKey  []string
+	baseURL []string
+}
+
+var providerEnvs = map[string]providerEnv{
+	ProviderOpenAI:    {apiKey: []string{"OPENAI_API_KEY"}, baseURL: []string{"OPENAI_BASE_URL"}},
+	ProviderAzure:     {apiKey: []string{"AZURE_OPENAI_API_KEY", "OPENAI_API_KEY"}, baseURL: []string{"AZURE_OPENAI_ENDPOINT", "OPENAI_BASE_URL"}},
+	ProviderAnthropic: {apiKey: []string{"ANTHROPIC_API_KEY"}, baseURL: []string{"ANTHROPIC_BASE_URL"}},
+	ProviderOllama:    {baseURL: []string{"OLLAMA_HOST"}},
+	ProviderLocal:     {apiKey: []string{"OPENAI_API_KEY"}, baseURL: []string{"OPENAI_BASE_URL"}},
+}
+
+// loadProviderEnv 按提供方读取密钥、端点等，已有值（来自配置文件或 AISHELL_* 变量）不覆盖
+func (c *Config) loadProviderEnv() {
+	env := providerEnvs[c.Provider]
+	c.fallbackEnv("api_key", &c.APIKey, env.apiKey...)
+	c.fallbackEnv("base_url", &c.BaseURL, env.baseURL...)
+	if c.Provider == ProviderOpenAI || c.Provider == ProviderLocal {
+		c.fallbackEnv("model", &c.Model, "OPENAI_MODEL")
+	}
+	if c.Provider == ProviderAzure {
+		c.fallbackEnv("api_version", &c.APIVersion, "AZURE_OPENAI_API_VERSION")
+	}
+}
+
+// fallbackEnv 配置项为空时读取第一个非空的环境变量并记录来源
+func (c *Config) fallbackEnv(key string, field *string, envs ...string) {
+	if *field != "" {
+		return
+	}
+	for _, env := range envs {
+		if value := getEnv(env); value != "" {
+			*field = value
+			c.setSource(key, "环境变量 "+env)
+			return
+		}
+	}
+}
+
+// validateProvider 校验提供方名称和生成参数
+func (c *Config) validateProvider() error {
+	if _, ok := providerEnvs[c.Provider]; !ok {
+		return fmt.Errorf("不支持的模型提供方 '%s'，可选: %s", c.Provider, strings.Join(Providers, ", "))
+	}
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2) {
+		return fmt.Errorf("temperature 应在 0 到 2 之间，当前为 %g", *c.Temperature)
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("max tokens 不能为负数，当前为 %d", c.MaxTokens)
+	}
+	// langchaingo 的 Ollama 原生接口不支持工具调用
+	if c.Provider == ProviderOllama && c.AgentType == AgentFunctions {
+		return fmt.Errorf("ollama 原生接口不支持函数调用，请设置 AISHELL_AGENT=conversational，" +
+			"或使用 AISHELL_PROVIDER=local 并把 AISHELL_BASE_URL 指向 Ollama 的 OpenAI 兼容端点（如 http://localhost:11434/v1）")
+	}
+	return nil
+}
+
+// ValidateRequirements 按模型提供方验证运行环境要求（密钥、端点、模型名称）
+func ValidateRequirements(config *Config) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	switch config.Provider {
+	case ProviderOpenAI:
+		if config.APIKey == "" {
+			return fmt.Errorf("未设置OPENAI_API_KEY环境变量，请设置: export OPENAI_API_KEY=your_api_key")
+		}
+	case ProviderAzure:
+		if config.APIKey == "" {
+			return fmt.Errorf("未设置Azure OpenAI密钥，请设置: export AZURE_OPENAI_API_KEY=your_api_key")
+		}
+		if config.BaseURL == "" {
+			return fmt.Errorf("未设置Azure OpenAI端点，请设置: export AZURE_OPENAI_ENDPOINT=https://your-resource.openai.azure.com")
+		}
+		if config.Model == "" {
+			return fmt.Errorf("Azure OpenAI需要指定部署名称，请设置: export AISHELL_MODEL=your_deployment")
+		}
+	case ProviderAnthropic:
+		if config.APIKey == "" {
+			return fmt.Errorf("未设置ANTHROPIC_API_KEY环境变量，请设置: export ANTHROPIC_API_KEY=your_api_key")
+		}
+	case ProviderLocal:
+		if config.BaseURL == "" {
+			return fmt.Errorf("本地模型需要指定服务地址，请设置: export AISHELL_BASE_URL=http://localhost:8000/v1")
+		}
+	}
+
+	if config.BaseURL != "" {
+		if err := validateBaseURL(config.Provider, config.BaseURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBaseURL 校验端点地址。OLLAMA_HOST 允许只写 host:port。
+func validateBaseURL(provider, baseURL string) error {
+	if provider == ProviderOllama && !strings.Contains(baseURL, "://") {
+		return nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("无效的服务地址 '%s'，应为 http(s)://host[:port][/path] 形式", baseURL)
+	}
+	return nil
+}
+
+// ModelName 返回实际使用的模型名称，未配置时为提供方的默认模型
+func (c *Config) ModelName() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	switch c.Provider {
+	case ProviderAnthropic:
+		return defaultAnthropicModel
+	case ProviderOllama:
+		return defaultOllamaModel
+	}
+	return "默认模型"
+}
+
+// NewLLM 按配置创建模型客户端，温度和最大输出长度作为每次调用的默认选项
+func NewLLM(config *Config) (llms.Model, error) {
+	var llm llms.Model
+	var err error
+
+	switch config.Provider {
+	case ProviderOpenAI, "":
+		opts := []openai.Option{}
+		if config.APIKey != "" {
+			opts = append(opts, openai.WithToken(config.APIKey))
+		}
+		if config.Model != "" {
+			opts = append(opts, openai.WithModel(config.Model))
+		}
+		if config.BaseURL != "" {
+			opts = append(opts, openai.WithBaseURL(config.BaseURL))
+		}
+		llm, err = openai.New(opts...)
+
+	case ProviderAzure:
+		apiVersion := config.APIVersion
+		if apiVersion == "" {
+			apiVersion = defaultAzureAPIVersion
+		}
+		llm, err = openai.New(
+			openai.WithAPIType(openai.APITypeAzure),
+			openai.WithAPIVersion(apiVersion),
+			openai.WithToken(config.APIKey),
+			openai.WithBaseURL(config.BaseURL),
+			openai.WithModel(config.Model),
+		)
+
+	case ProviderLocal:
+		apiKey := config.APIKey
+		if apiKey == "" {
+			apiKey = localPlaceholderKey
+		}
+		opts := []openai.Option{openai.WithToken(apiKey), openai.WithBaseURL(config.BaseURL)}
+		if config.Model != "" {
+			opts = append(opts, openai.WithModel(config.Model))
+		}
+		llm, err = openai.New(opts...)
+
+	case ProviderAnthropic:
+		opts := []anthropic.Option{anthropic.WithModel(config.ModelName())}
+		if config.APIKey != "" {
+			opts = append(opts, anthropic.WithToken(config.APIKey))
+		}
+		if config.BaseURL != "" {
+			opts = append(opts, anthropic.WithBaseURL(config.BaseURL))
+		}
+		llm, err = anthropic.New(opts...)
+
+	case ProviderOllama:
+		opts := []ollama.Option{ollama.WithModel(config.ModelName())}
+		if config.BaseURL != "" && strings.Contains(config.BaseURL, "://") {
+			opts = append(opts, ollama.WithServerURL(config.BaseURL))
+		}
+		llm, err = ollama.New(opts...)
+
+	default:
+		return nil, fmt.Errorf("不支持的模型提供方: %s", config.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if options := config.callOptions(); len(options) > 0 {
+		return &configuredModel{Model: llm, options: options}, nil
+	}
+	return llm, nil
+}
+
+// callOptions 由配置生成的默认调用选项
+func (c *Config) callOptions() []llms.CallOption {
+	var options []llms.CallOption
+	if c.Temperature != nil {
+		options = append(options, llms.WithTemperature(*c.Temperature))
+	}
+	maxTokens := c.MaxTokens
+	if maxTokens == 0 && c.Provider == ProviderAnthropic {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+	if maxTokens > 0 {
+		options = append(options, llms.WithMaxTokens(maxTokens))
+	}
+	return options
+}
+
+// configuredModel 为每次调用附加默认选项的模型包装，调用方传入的选项优先。
+// 两种代理都通过它调用模型，因此温度等参数对函数调用代理和对话代理同样生效。
+type configuredModel struct {
+	llms.Model
+	options []llms.CallOption
+}
+
+// GenerateContent 附加默认选项后调用模型
+func (m *configuredModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	return m.Model.GenerateContent(ctx, messages, append(append([]llms.CallOption{}, m.options...), options...)...)
+}
+
+// Call 以单个提示调用模型
+func (m *configuredModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+// withEnv 在测试期间用给定的环境变量替换 getOSEnv，并隔离用户和项目配置文件
+func withEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	original := getOSEnv
+	getOSEnv = func(key string) string { return env[key] }
+	t.Cleanup(func() { getOSEnv = original })
+}
+
+func TestLoadConfig_ProviderEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		provider    string
+		apiKey      string
+		baseURL     string
+		agentType   string
+		temperature float64
+	}{
+		{
+			name:      "默认使用OpenAI",
+			env:       map[string]string{"OPENAI_API_KEY": "sk-openai", "OPENAI_BASE_URL": "https://proxy.example.com/v1"},
+			provider:  ProviderOpenAI,
+			apiKey:    "sk-openai",
+			baseURL:   "https://proxy.example.com/v1",
+			agentType: AgentFunctions,
+		},
+		{
+			name:      "Anthropic读取自己的密钥",
+			env:       map[string]string{"AISHELL_PROVIDER": "anthropic", "ANTHROPIC_API_KEY": "sk-ant", "OPENAI_API_KEY": "sk-openai"},
+			provider:  ProviderAnthropic,
+			apiKey:    "sk-ant",
+			agentType: AgentFunctions,
+		},
+		{
+			name:      "Azure优先读取AZURE变量",
+			env:       map[string]string{"AISHELL_PROVIDER": "azure", "AZURE_OPENAI_API_KEY": "az", "OPENAI_API_KEY": "sk", "AZURE_OPENAI_ENDPOINT": "https://r.openai.azure.com"},
+			provider:  ProviderAzure,
+			apiKey:    "az",
+			baseURL:   "https://r.openai.azure.com",
+			agentType: AgentFunctions,
+		},
+		{
+			name:      "AISHELL变量优先",
+			env:       map[string]string{"AISHELL_PROVIDER": "local", "AISHELL_BASE_URL": "http://localhost:8000/v1", "OPENAI_BASE_URL": "https://api.example.com"},
+			provider:  ProviderLocal,
+			baseURL:   "http://localhost:8000/v1",
+			agentType: AgentFunctions,
+		},
+		{
+			name:      "Ollama默认使用对话代理",
+			env:       map[string]string{"AISHELL_PROVIDER": "ollama", "OLLAMA_HOST": "127.0.0.1:11434"},
+			provider:  ProviderOllama,
+			baseURL:   "127.0.0.1:11434",
+			agentType: AgentConversational,
+		},
+		{
+			name:        "生成参数",
+			env:         map[string]string{"OPENAI_API_KEY": "sk", "AISHELL_TEMPERATURE": "0.2", "AISHELL_MAX_TOKENS": "1024"},
+			provider:    ProviderOpenAI,
+			apiKey:      "sk",
+			agentType:   AgentFunctions,
+			temperature: 0.2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withEnv(t, tt.env)
+			config, err := LoadConfig(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Provider != tt.provider || config.APIKey != tt.apiKey || config.BaseURL != tt.baseURL || config.AgentType != tt.agentType {
+				t.Errorf("得到 provider=%s key=%s url=%s agent=%s", config.Provider, config.APIKey, config.BaseURL, config.AgentType)
+			}
+			if tt.temperature != 0 && (config.Temperature == nil || *config.Temperature != tt.temperature || config.MaxTokens != 1024) {
+				t.Errorf("生成参数未生效: %v %d", config.Temperature, config.MaxTokens)
+			}
+		})
+	}
+}
+
+func TestValidateRequirements(t *testing.T) {
+	temperature := 3.0
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "OpenAI缺少密钥", modify: func(c *Config) {}, wantErr: "OPENAI_API_KEY"},
+		{name: "OpenAI", modify: func(c *Config) { c.APIKey = "sk" }},
+		{name: "Anthropic缺少密钥", modify: func(c *Config) { c.Provider = ProviderAnthropic }, wantErr: "ANTHROPIC_API_KEY"},
+		{name: "Azure缺少部署名称", modify: func(c *Config) {
+			c.Provider, c.APIKey, c.BaseURL = ProviderAzure, "az", "https://r.openai.azure.com"
+		}, wantErr: "部署名称"},
+		{name: "Azure", modify: func(c *Config) {
+			c.Provider, c.APIKey, c.BaseURL, c.Model = ProviderAzure, "az", "https://r.openai.azure.com", "gpt4o"
+		}},
+		{name: "本地服务缺少地址", modify: func(c *Config) { c.Provider = ProviderLocal }, wantErr: "AISHELL_BASE_URL"},
+		{name: "本地服务不需要密钥", modify: func(c *Config) { c.Provider, c.BaseURL = ProviderLocal, "http://localhost:8000/v1" }},
+		{name: "无效地址", modify: func(c *Config) { c.Provider, c.BaseURL = ProviderLocal, "localhost:8000" }, wantErr: "无效的服务地址"},
+		{name: "Ollama不需要密钥", modify: func(c *Config) { c.Provider, c.AgentType = ProviderOllama, AgentConversational }},
+		{name: "Ollama不支持函数调用", modify: func(c *Config) { c.Provider = ProviderOllama }, wantErr: "函数调用"},
+		{name: "未知提供方", modify: func(c *Config) { c.Provider = "gemini" }, wantErr: "不支持的模型提供方"},
+		{name: "温度超出范围", modify: func(c *Config) { c.APIKey, c.Temperature = "sk", &temperature }, wantErr: "temperature"},
+		{name: "未知沙箱模式", modify: func(c *Config) { c.APIKey, c.Sandbox = "sk", "strict" }, wantErr: "不支持的沙箱模式"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.modify(config)
+			err := ValidateRequirements(config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("不应返回错误: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("期望包含 %q 的错误，得到 %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewLLM_AppliesCallOptions(t *testing.T) {
+	config := DefaultConfig()
+	config.Provider, config.APIKey = ProviderAnthropic, "sk-ant"
+	llm, err := NewLLM(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Anthropic 必须指定最大输出长度，未配置时使用默认值
+	if _, ok := llm.(*configuredModel); !ok {
+		t.Errorf("Anthropic 模型应附加默认调用选项，得到 %T", llm)
+	}
+
+	config = DefaultConfig()
+	config.APIKey = "sk"
+	llm, err = NewLLM(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := llm.(*configuredModel); ok {
+		t.Error("未配置生成参数时不应包装模型")
+	}
+}
@@ -0,0 +1,454 @@
+package app
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// ConfigFileName 配置文件名，用户级位于 ~/.config/aishell/，项目级位于 .aishell/
+const ConfigFileName = "config.toml"
+
+// 配置项来源
+const (
+	// SourceDefault 内置默认值
+	SourceDefault = "默认值"
+	// ScopeUser 用户配置文件
+	ScopeUser = "用户配置"
+	// ScopeProject 项目配置文件
+	ScopeProject = "项目配置"
+)
+
+// setting 一个可配置项：配置文件键名、环境变量和命令行选项共用同一份定义。
+// 优先级从低到高：默认值 < 用户配置文件 < 项目配置文件 < 环境变量 < 命令行选项。
+type setting struct {
+	// key 配置文件中的键名，命令行选项名为把下划线换成连字符
+	key string
+	// env 对应的环境变量
+	env string
+	// usage 说明，用于命令行帮助
+	usage string
+	// noFlag 不提供命令行选项（如密钥，避免出现在进程列表中）
+	noFlag bool
+	// secret 显示时隐藏
+	secret bool
+	// boolean 布尔选项，命令行中可以不带取值
+	boolean bool
+	// list 逗号分隔的列表，配置文件中也可以写成字符串数组
+	list bool
+	// userOnly 涉及密钥去向、审计和访问限制，项目配置文件随仓库分发，其中的值被忽略
+	userOnly bool
+	// enableOnly 起限制作用的开关（如演练模式），项目配置只能开启，不能关闭用户的设置
+	enableOnly bool
+	get        func(c *Config) string
+	set        func(c *Config, value string) error
+}
+
+// flagName 命令行选项名
+func (s *setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// settings 全部配置项，顺序即 `aishell config show` 的显示顺序
+var settings = []*setting{
+	{
+		key: "provider", env: "AISHELL_PROVIDER", usage: "模型提供方: " + strings.Join(Providers, ", "),
+		get: func(c *Config) string { return c.Provider },
+		set: func(c *Config, v string) error { c.Provider = v; return nil },
+	},
+	{
+		key: "model", env: "AISHELL_MODEL", usage: "模型名称（Azure 为部署名称）",
+		get: func(c *Config) string { return c.Model },
+		set: func(c *Config, v string) error { c.Model = v; return nil },
+	},
+	{
+		key: "temperature", env: "AISHELL_TEMPERATURE", usage: "采样温度 (0-2)",
+		get: func(c *Config) string {
+			if c.Temperature == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*c.Temperature, 'g', -1, 64)
+		},
+		set: func(c *Config, v string) error {
+			temperature, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("无效的数字 '%s'", v)
+			}
+			c.Temperature = &temperature
+			return nil
+		},
+	},
+	{
+		key: "max_tokens", env: "AISHELL_MAX_TOKENS", usage: "单次回复最大输出长度",
+		get: func(c *Config) string { return strconv.Itoa(c.MaxTokens) },
+		set: intSetter(func(c *Config) *int { return &c.MaxTokens }),
+	},
+	{
+		key: "api_key", env: "AISHELL_API_KEY", usage: "模型服务密钥", noFlag: true, secret: true, userOnly: true,
+		get: func(c *Config) string { return c.APIKey },
+		set: func(c *Config, v string) error { c.APIKey = v; return nil },
+	},
+	{
+		key: "base_url", env: "AISHELL_BASE_URL", usage: "模型服务端点", userOnly: true,
+		get: func(c *Config) string { return c.BaseURL },
+		set: func(c *Config, v string) error { c.BaseURL = v; return nil },
+	},
+	{
+		key: "api_version", env: "AISHELL_API_VERSION", usage: "API版本（Azure）",
+		get: func(c *Config) string { return c.APIVersion },
+		set: func(c *Config, v string) error { c.APIVersion = v; return nil },
+	},
+	{
+		key: "agent", env: "AISHELL_AGENT", usage: "代理类型: functions 或 conversational",
+		get: func(c *Config) string { return c.AgentType },
+		set: func(c *Config, v string) error { c.AgentType = v; return nil },
+	},
+	{
+		key: "conversation_buffer_size", env: "AISHELL_CONVERSATION_BUFFER_SIZE", usage: "保留的对话轮数",
+		get: func(c *Config) string { return strconv.Itoa(c.ConversationBufferSize) },
+		set: intSetter(func(c *Config) *int { return &c.ConversationBufferSize }),
+	},
+	{
+		key: "max_iterations", env: "AISHELL_MAX_ITERATIONS", usage: "单次输入最多调用模型的次数",
+		get: func(c *Config) string { return strconv.Itoa(c.MaxExecutorIterations) },
+		set: intSetter(func(c *Config) *int { return &c.MaxExecutorIterations }),
+	},
+	{
+		key: "history_file", env: "AISHELL_HISTORY_FILE", usage: "命令历史文件路径", userOnly: true,
+		get: func(c *Config) string { return c.HistoryFile },
+		set: func(c *Config, v string) error { c.HistoryFile = utils.ExpandHome(v); return nil },
+	},
+	{
+		key: "prompt", env: "AISHELL_PROMPT", usage: "命令行提示符",
+		get: func(c *Config) string { return c.Prompt },
+		set: func(c *Config, v string) error { c.Prompt = v; return nil },
+	},
+	{
+		key: "debug", env: "AISHELL_DEBUG", usage: "调试模式", boolean: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.DebugMode) },
+		set: boolSetter(func(c *Config) *bool { return &c.DebugMode }),
+	},
+	{
+		key: "persistent_shell", env: "AISHELL_PERSISTENT_SHELL", usage: "在持久shell会话中执行命令，保留cd/export等状态", boolean: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.PersistentShell) },
+		set: boolSetter(func(c *Config) *bool { return &c.PersistentShell }),
+	},
+	{
+		key: "dry_run", env: "AISHELL_DRY_RUN", usage: "演练模式：只生成命令和文件写入计划，不真正执行", boolean: true, enableOnly: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.DryRun) },
+		set: boolSetter(func(c *Config) *bool { return &c.DryRun }),
+	},
+	{
+		key: "audit_log", env: "AISHELL_AUDIT_LOG", usage: "审计日志文件路径，off 表示不记录", userOnly: true,
+		get: func(c *Config) string { return c.AuditLog },
+		set: func(c *Config, v string) error { c.AuditLog = utils.ExpandHome(v); return nil },
+	},
+	{
+		key: "audit_syslog", env: "AISHELL_AUDIT_SYSLOG", usage: "同时把审计记录发送到 syslog", boolean: true, userOnly: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.AuditSyslog) },
+		set: boolSetter(func(c *Config) *bool { return &c.AuditSyslog }),
+	},
+	{
+		key: "output", env: "AISHELL_OUTPUT", usage: "非交互模式的输出格式: text、json 或 jsonl",
+		get: func(c *Config) string { return c.Output },
+		set: func(c *Config, v string) error { c.Output = v; return nil },
+	},
+	{
+		key: "redact_secrets", env: "AISHELL_REDACT_SECRETS", usage: "发送给模型之前遮盖输入和工具结果中的密钥", boolean: true, userOnly: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.RedactSecrets) },
+		set: boolSetter(func(c *Config) *bool { return &c.RedactSecrets }),
+	},
+	{
+		key: "allowed_roots", env: "AISHELL_ALLOWED_ROOTS", usage: "文件工具可直接访问的目录，逗号分隔，默认为当前目录", list: true, userOnly: true,
+		get: func(c *Config) string { return strings.Join(c.AllowedRoots, ",") },
+		set: listSetter(func(c *Config) *[]string { return &c.AllowedRoots }),
+	},
+	{
+		key: "denied_paths", env: "AISHELL_DENIED_PATHS", usage: "文件工具禁止访问的路径，逗号分隔，支持 glob（另有内置敏感路径）", list: true, userOnly: true,
+		get: func(c *Config) string { return strings.Join(c.DeniedPaths, ",") },
+		set: listSetter(func(c *Config) *[]string { return &c.DeniedPaths }),
+	},
+	{
+		key: "sandbox", env: "AISHELL_SANDBOX", usage: "系统命令沙箱（仅 Linux）: off、on 或 required", userOnly: true,
+		get: func(c *Config) string { return c.Sandbox },
+		set: func(c *Config, v string) error { c.Sandbox = v; return nil },
+	},
+	{
+		key: "sandbox_network", env: "AISHELL_SANDBOX_NETWORK", usage: "沙箱内允许访问网络", boolean: true, userOnly: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.SandboxNetwork) },
+		set: boolSetter(func(c *Config) *bool { return &c.SandboxNetwork }),
+	},
+	{
+		key: "sandbox_cpu", env: "AISHELL_SANDBOX_CPU", usage: "沙箱内每个进程的CPU时间上限（秒），0 表示不限制", userOnly: true,
+		get: func(c *Config) string { return strconv.Itoa(c.SandboxCPU) },
+		set: intSetter(func(c *Config) *int { return &c.SandboxCPU }),
+	},
+	{
+		key: "sandbox_memory", env: "AISHELL_SANDBOX_MEMORY", usage: "沙箱内每个进程的内存上限（MB），0 表示不限制", userOnly: true,
+		get: func(c *Config) string { return strconv.Itoa(c.SandboxMemory) },
+		set: intSetter(func(c *Config) *int { return &c.SandboxMemory }),
+	},
+	{
+		key: "sandbox_output", env: "AISHELL_SANDBOX_OUTPUT", usage: "沙箱内命令输出和单个文件的大小上限（MB），0 表示不限制", userOnly: true,
+		get: func(c *Config) string { return strconv.Itoa(c.SandboxOutput) },
+		set: intSetter(func(c *Config) *int { return &c.SandboxOutput }),
+	},
+}
+
+// intSetter 非负整数配置项的设置函数
+func intSetter(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 0 {
+			return fmt.Errorf("无效的非负整数 '%s'", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// boolSetter 布尔配置项的设置函数，接受 true/false/1/0
+func boolSetter(field func(c *Config) *bool) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("无效的布尔值 '%s'，应为 true 或 false", v)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// listSetter 逗号分隔的列表配置项的设置函数，空项被忽略
+func listSetter(field func(c *Config) *[]string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(c) = items
+		return nil
+	}
+}
+
+// findSetting 按配置文件键名查找配置项
+func findSetting(key string) *setting {
+	for _, s := range settings {
+		if s.key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+// apply 设置配置项并记录来源
+func (c *Config) apply(s *setting, value, source string) error {
+	if err := s.set(c, value); err != nil {
+		return fmt.Errorf("%s: %s %w", source, s.key, err)
+	}
+	c.setSource(s.key, source)
+	return nil
+}
+
+// setSource 记录配置项的来源
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = map[string]string{}
+	}
+	c.sources[key] = source
+}
+
+// Source 返回配置项的来源，未被覆盖时为默认值
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// ConfigFile 一个配置文件层
+type ConfigFile struct {
+	// Scope 层级：用户配置或项目配置
+	Scope string
+	// Path 文件路径
+	Path string
+	// Exists 文件是否存在
+	Exists bool
+}
+
+// ConfigFiles 返回按优先级从低到高排列的配置文件位置：用户级、项目级（当前目录向上查找 .aishell）
+func ConfigFiles() []ConfigFile {
+	files := []ConfigFile{{Scope: ScopeUser, Path: filepath.Join(utils.ConfigDir(), ConfigFileName)}}
+	if dir := utils.FindProjectConfigDir(); dir != "" {
+		files = append(files, ConfigFile{Scope: ScopeProject, Path: filepath.Join(dir, ConfigFileName)})
+	}
+	for i := range files {
+		if info, err := os.Stat(files[i].Path); err == nil && !info.IsDir() {
+			files[i].Exists = true
+		}
+	}
+	return files
+}
+
+// loadFile 加载一个 TOML 配置文件，未知的键视为错误，避免拼写错误的配置静默失效
+func (c *Config) loadFile(file ConfigFile) error {
+	path := file.Path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	var values map[string]any
+	if err := toml.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := findSetting(key)
+		if s == nil {
+			return fmt.Errorf("配置文件 %s 包含未知的配置项 '%s'，可用配置项: %s", path, key, strings.Join(settingKeys(), ", "))
+		}
+		if s.userOnly && file.Scope == ScopeProject {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("项目配置 %s: %s 只能在用户配置、环境变量或命令行中设置，已忽略", path, key))
+			continue
+		}
+		if s.enableOnly && file.Scope == ScopeProject {
+			if on, err := strconv.ParseBool(fmt.Sprint(values[key])); err == nil && !on {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("项目配置 %s: %s 只能开启，不能关闭，已忽略", path, key))
+				continue
+			}
+		}
+		switch v := values[key].(type) {
+		case string, bool, int64, float64:
+			if err := c.apply(s, fmt.Sprint(v), file.Scope+" "+path); err != nil {
+				return err
+			}
+		case []any:
+			if !s.list {
+				return fmt.Errorf("配置文件 %s: %s 不接受数组", path, key)
+			}
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("配置文件 %s: %s 的数组元素必须是字符串", path, key)
+				}
+				items = append(items, str)
+			}
+			if err := c.apply(s, strings.Join(items, ","), file.Scope+" "+path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("配置文件 %s: %s 的值类型不受支持", path, key)
+		}
+	}
+	return nil
+}
+
+// settingKeys 返回全部配置项键名
+func settingKeys() []string {
+	keys := make([]string, 0, len(settings))
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+// Flags 命令行中设置的配置项，解析命令行后传给 LoadConfig 作为最高优先级的一层
+type Flags struct {
+	values map[string]string
+	order  []string
+}
+
+// BindFlags 为每个配置项注册命令行选项（如 --model、--max-tokens、--dry-run）
+func BindFlags(fs *flag.FlagSet) *Flags {
+	flags := &Flags{values: map[string]string{}}
+	for _, s := range settings {
+		if s.noFlag {
+			continue
+		}
+		key := s.key
+		record := func(value string) error {
+			if _, ok := flags.values[key]; !ok {
+				flags.order = append(flags.order, key)
+			}
+			flags.values[key] = value
+			return nil
+		}
+		if s.boolean {
+			fs.BoolFunc(s.flagName(), s.usage, func(value string) error { return record(value) })
+		} else {
+			fs.Func(s.flagName(), s.usage, record)
+		}
+	}
+	// --plan 是 --dry-run 的别名
+	fs.BoolFunc("plan", "同 --dry-run", func(value string) error {
+		if _, ok := flags.values["dry_run"]; !ok {
+			flags.order = append(flags.order, "dry_run")
+		}
+		flags.values["dry_run"] = value
+		return nil
+	})
+	return flags
+}
+
+// apply 把命令行选项写入配置
+func (f *Flags) apply(c *Config) error {
+	if f == nil {
+		return nil
+	}
+	for _, key := range f.order {
+		s := findSetting(key)
+		if err := c.apply(s, f.values[key], "命令行 --"+s.flagName()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfigEntry 一个配置项的生效值及其来源
+type ConfigEntry struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Entries 返回全部配置项的生效值和来源，密钥只显示前几位
+func (c *Config) Entries() []ConfigEntry {
+	entries := make([]ConfigEntry, 0, len(settings))
+	for _, s := range settings {
+		value := s.get(c)
+		if s.secret {
+			value = maskSecret(value)
+		}
+		entries = append(entries, ConfigEntry{Key: s.key, Value: value, Source: c.Source(s.key)})
+	}
+	return entries
+}
+
+// maskSecret 隐藏密钥，只保留前 4 个字符
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 8 {
+		return "****"
+	}
+	return value[:4] + "****"
+}
@@ -0,0 +1,204 @@
+// Package audit 记录代理执行的每一次工具调用，便于事后追查在机器上做过什么。
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// 工具调用的结果状态
+const (
+	// StatusOK 执行成功
+	StatusOK = "ok"
+	// StatusFailed 命令以非零退出码结束
+	StatusFailed = "failed"
+	// StatusError 工具返回错误
+	StatusError = "error"
+	// StatusDeclined 用户（或 --no）拒绝执行
+	StatusDeclined = "declined"
+	// StatusDenied 被命令策略拒绝
+	StatusDenied = "denied"
+	// StatusPlanned 演练模式下只记录为计划，未执行
+	StatusPlanned = "planned"
+)
+
+// 危险操作的确认结果
+const (
+	// ApprovalNotRequired 不需要确认
+	ApprovalNotRequired = "not_required"
+	// ApprovalApproved 用户确认执行
+	ApprovalApproved = "approved"
+	// ApprovalAutoApproved 通过 --yes 自动确认
+	ApprovalAutoApproved = "auto_approved"
+	// ApprovalDeclined 用户拒绝执行
+	ApprovalDeclined = "declined"
+	// ApprovalAutoDeclined 非交互模式下自动拒绝
+	ApprovalAutoDeclined = "auto_declined"
+)
+
+// Record 一条审计记录，对应一次工具调用
+type Record struct {
+	Time         time.Time `json:"time"`
+	User         string    `json:"user"`
+	Session      string    `json:"session,omitempty"`
+	Cwd          string    `json:"cwd"`
+	Tool         string    `json:"tool"`
+	Input        string    `json:"input"`
+	Approval     string    `json:"approval"`
+	Status       string    `json:"status"`
+	ExitCode     *int      `json:"exit_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	InputSHA256  string    `json:"input_sha256,omitempty"`
+	InputBytes   int       `json:"input_bytes,omitempty"`
+	OutputSHA256 string    `json:"output_sha256"`
+	OutputBytes  int       `json:"output_bytes"`
+	DurationMs   int64     `json:"duration_ms"`
+}
+
+// SetOutput 记录输出的 SHA-256 和长度。审计日志不保存输出原文，避免泄露读取到的文件内容。
+func (r *Record) SetOutput(output string) {
+	sum := sha256.Sum256([]byte(output))
+	r.OutputSHA256 = hex.EncodeToString(sum[:])
+	r.OutputBytes = len(output)
+}
+
+// maxSyslogInput 发送到 syslog 的输入最多保留的字节数
+const maxSyslogInput = 1024
+
+// syslogRecord 返回发送到 syslog 的记录。file_writer 等工具的输入包含完整的文件内容，
+// 超过 maxSyslogInput 时只保留开头部分，并附上完整输入的 SHA-256 和长度，原文只写入本地审计日志。
+func (r Record) syslogRecord() Record {
+	if len(r.Input) <= maxSyslogInput {
+		return r
+	}
+	sum := sha256.Sum256([]byte(r.Input))
+	r.InputSHA256 = hex.EncodeToString(sum[:])
+	r.InputBytes = len(r.Input)
+	cut := maxSyslogInput
+	for cut > 0 && !utf8.RuneStart(r.Input[cut]) {
+		cut--
+	}
+	r.Input = r.Input[:cut] + "…"
+	return r
+}
+
+// DefaultPath 默认审计日志路径 (~/.local/share/aishell/audit.log)
+func DefaultPath() string {
+	return filepath.Join(utils.DataDir(), "audit.log")
+}
+
+// Logger 追加写入的 JSONL 审计日志，可同时发送到 syslog。
+// 多个进程可以同时追加同一个文件，每条记录以单次 write 写入一行。
+type Logger struct {
+	// Path 审计日志文件路径
+	Path string
+
+	mu      sync.Mutex
+	file    *os.File
+	syslog  io.WriteCloser
+	user    string
+	session string
+}
+
+// Open 打开审计日志，useSyslog 为 true 时同时发送到本机 syslog
+func Open(path string, useSyslog bool) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	logger := &Logger{Path: path, file: file, user: currentUser()}
+	if useSyslog {
+		sink, err := newSyslogSink()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("连接 syslog 失败: %w", err)
+		}
+		logger.syslog = sink
+	}
+	return logger, nil
+}
+
+// SetSession 设置之后记录所属的会话 ID
+func (l *Logger) SetSession(id string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.session = id
+}
+
+// Log 写入一条记录，自动补充时间、用户和会话。nil 日志不记录。
+func (l *Logger) Log(record Record) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if record.User == "" {
+		record.User = l.user
+	}
+	if record.Session == "" {
+		record.Session = l.session
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("序列化审计记录失败: %w", err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	if l.syslog != nil {
+		if summary := record.syslogRecord(); summary.InputBytes > 0 {
+			if data, err = json.Marshal(summary); err != nil {
+				return fmt.Errorf("序列化审计记录失败: %w", err)
+			}
+		}
+		if _, err := l.syslog.Write(data); err != nil {
+			return fmt.Errorf("写入 syslog 失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭审计日志
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.syslog != nil {
+		l.syslog.Close()
+	}
+	return l.file.Close()
+}
+
+// currentUser 返回当前用户名
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestLogger_AppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+
+	logger, err := Open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetSession("20261017-093000-abcd")
+	code := 0
+	first := Record{Tool: "system_command", Input: "df -h", Status: StatusOK, ExitCode: &code}
+	first.SetOutput("/dev/sda1 100G")
+	if err := logger.Log(first); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	// 重新打开后继续追加，不覆盖已有记录
+	logger, err = Open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Log(Record{Tool: "file_writer", Input: `{"file_path":"a.txt"}`, Status: StatusDeclined, Approval: ApprovalDeclined}); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	records, err := Read(path, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("期望 2 条记录，得到 %d", len(records))
+	}
+	r := records[0]
+	if r.User == "" || r.Session != "20261017-093000-abcd" || r.Time.IsZero() {
+		t.Errorf("应自动补充用户、会话和时间: %+v", r)
+	}
+	if r.OutputBytes != 14 || len(r.OutputSHA256) != 64 || r.ExitCode == nil || *r.ExitCode != 0 {
+		t.Errorf("输出摘要或退出码错误: %+v", r)
+	}
+
+	tests := []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{Tool: "file_writer"}, 1},
+		{Filter{Status: StatusOK}, 1},
+		{Filter{Session: "20261017"}, 1},
+		{Filter{Contains: "df"}, 1},
+		{Filter{Since: time.Now().Add(time.Hour)}, 0},
+		{Filter{Limit: 1}, 1},
+	}
+	for _, tt := range tests {
+		records, err := Read(path, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != tt.want {
+			t.Errorf("%+v: 期望 %d 条，得到 %d", tt.filter, tt.want, len(records))
+		}
+	}
+}
+
+func TestRead_MissingFile(t *testing.T) {
+	records, err := Read(filepath.Join(t.TempDir(), "none.log"), Filter{})
+	if err != nil || len(records) != 0 {
+		t.Errorf("日志不存在时应返回空列表: %v %v", records, err)
+	}
+}
+
+// bufferSink 记录写入内容的 syslog 替身
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (b *bufferSink) Close() error { return nil }
+
+func TestLogger_SyslogTruncatesInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := Open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &bufferSink{}
+	logger.syslog = sink
+
+	content := strings.Repeat("配置内容", 1000)
+	input := `{"file_path":"app.conf","content":"` + content + `"}`
+	if err := logger.Log(Record{Tool: "file_writer", Input: input, Status: StatusOK}); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+
+	var sent Record
+	if err := json.Unmarshal(sink.Bytes(), &sent); err != nil {
+		t.Fatalf("syslog 消息应为 JSON: %v", err)
+	}
+	if len(sent.Input) > maxSyslogInput+len("…") || !utf8.ValidString(sent.Input) {
+		t.Errorf("syslog 中的输入应截断为合法的 UTF-8，长度 %d", len(sent.Input))
+	}
+	if sent.InputBytes != len(input) || len(sent.InputSHA256) != 64 {
+		t.Errorf("syslog 记录应包含完整输入的长度和摘要: %+v", sent)
+	}
+
+	records, err := Read(path, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Input != input || records[0].InputBytes != 0 {
+		t.Errorf("本地审计日志应保留完整输入")
+	}
+}
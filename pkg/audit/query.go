@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxRecordSize 单条记录的最大长度，file_writer 的输入包含完整的文件内容
+const maxRecordSize = 64 << 20
+
+// Filter 查询条件，零值字段不参与过滤
+type Filter struct {
+	// Since 只返回该时间之后的记录
+	Since time.Time
+	// Tool 工具名称
+	Tool string
+	// Session 会话 ID 或其前缀
+	Session string
+	// Status 结果状态
+	Status string
+	// Contains 输入中包含的文本
+	Contains string
+	// Limit 只返回最后的 Limit 条，0 表示不限制
+	Limit int
+}
+
+// Match 判断记录是否满足条件
+func (f Filter) Match(r Record) bool {
+	switch {
+	case !f.Since.IsZero() && r.Time.Before(f.Since):
+		return false
+	case f.Tool != "" && r.Tool != f.Tool:
+		return false
+	case f.Session != "" && !strings.HasPrefix(r.Session, f.Session):
+		return false
+	case f.Status != "" && r.Status != f.Status:
+		return false
+	case f.Contains != "" && !strings.Contains(r.Input, f.Contains):
+		return false
+	}
+	return true
+}
+
+// Read 按写入顺序读取满足条件的记录，无法解析的行会被跳过。日志文件不存在时返回空列表。
+func Read(path string, filter Filter) ([]Record, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		var record Record
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if filter.Match(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %w", err)
+	}
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, nil
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package audit
+
+import (
+	"errors"
+	"io"
+)
+
+// newSyslogSink 当前平台不支持 syslog
+func newSyslogSink() (io.WriteCloser, error) {
+	return nil, errors.New("当前平台不支持 syslog")
+}
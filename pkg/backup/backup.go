@@ -0,0 +1,317 @@
+// Package backup 在 file_writer、file_editor 覆盖文件之前保存原内容，支持按会话撤销写入。
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// ErrNothingToUndo 没有可以撤销的写入
+var ErrNothingToUndo = errors.New("没有可以撤销的文件写入")
+
+// indexFile 每个会话目录中记录备份列表的文件
+const indexFile = "index.json"
+
+// Entry 一次写入前的快照
+type Entry struct {
+	// Seq 会话内的序号，从 1 开始
+	Seq int `json:"seq"`
+	// Path 被写入文件的绝对路径
+	Path string `json:"path"`
+	// Time 写入时间
+	Time time.Time `json:"time"`
+	// Existed 写入前文件是否存在，不存在时撤销即删除文件
+	Existed bool `json:"existed"`
+	// Mode 原文件的权限
+	Mode fs.FileMode `json:"mode,omitempty"`
+	// Size 原文件的大小
+	Size int64 `json:"size"`
+	// Restored 是否已经撤销
+	Restored bool `json:"restored,omitempty"`
+}
+
+// Store 备份存储，每个会话一个目录：index.json 记录快照列表，<seq>.bak 保存原内容
+type Store struct {
+	// Dir 备份根目录
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewStore 创建备份存储
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// DefaultDir 默认备份目录 (~/.local/share/aishell/backups)
+func DefaultDir() string {
+	return filepath.Join(utils.DataDir(), "backups")
+}
+
+// sessionDir 会话的备份目录
+func (s *Store) sessionDir(session string) (string, error) {
+	if session == "" || strings.ContainsAny(session, `/\`) || session == "." || session == ".." {
+		return "", fmt.Errorf("无效的会话 ID: %q", session)
+	}
+	return filepath.Join(s.Dir, session), nil
+}
+
+// blobPath 快照内容的保存位置
+func blobPath(dir string, seq int) string {
+	return filepath.Join(dir, strconv.Itoa(seq)+".bak")
+}
+
+// Snapshot 在写入 path 之前保存它的当前内容，文件不存在时只记录"新建"
+func (s *Store) Snapshot(session, path string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.sessionDir(session)
+	if err != nil {
+		return nil, err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := Entry{Seq: len(entries) + 1, Path: path, Time: time.Now()}
+	if n := len(entries); n > 0 {
+		entry.Seq = entries[n-1].Seq + 1
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, fmt.Errorf("%s 是目录", path)
+	case err == nil:
+		entry.Existed = true
+		entry.Mode = info.Mode().Perm()
+		entry.Size = info.Size()
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("读取原文件信息失败: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+	if entry.Existed {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取原文件失败: %w", err)
+		}
+		if err := os.WriteFile(blobPath(dir, entry.Seq), data, 0600); err != nil {
+			return nil, fmt.Errorf("保存备份失败: %w", err)
+		}
+	}
+
+	if err := writeIndex(dir, append(entries, entry)); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Undo 撤销会话中最近一次尚未撤销的写入；path 不为空时只撤销该文件最近一次写入。
+// 写入前已存在的文件恢复原内容和权限，新建的文件被删除。
+func (s *Store) Undo(session, path string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.sessionDir(session)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if path, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := readIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := &entries[i]
+		if entry.Restored || (path != "" && entry.Path != path) {
+			continue
+		}
+		if err := restore(dir, entry); err != nil {
+			return nil, err
+		}
+		entry.Restored = true
+		if err := writeIndex(dir, entries); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+	if path != "" {
+		return nil, fmt.Errorf("%w: %s", ErrNothingToUndo, path)
+	}
+	return nil, ErrNothingToUndo
+}
+
+// restore 把文件恢复到快照时的状态
+func restore(dir string, entry *Entry) error {
+	if !entry.Existed {
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除新建的文件失败: %w", err)
+		}
+		return nil
+	}
+	data, err := os.ReadFile(blobPath(dir, entry.Seq))
+	if err != nil {
+		return fmt.Errorf("读取备份失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.WriteFile(entry.Path, data, entry.Mode); err != nil {
+		return fmt.Errorf("恢复文件失败: %w", err)
+	}
+	// WriteFile 不会修改已存在文件的权限
+	return os.Chmod(entry.Path, entry.Mode)
+}
+
+// List 列出会话的全部快照，按写入顺序排列
+func (s *Store) List(session string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.sessionDir(session)
+	if err != nil {
+		return nil, err
+	}
+	return readIndex(dir)
+}
+
+// Summary 一个会话的备份概况
+type Summary struct {
+	Session string
+	Entries int
+	// Pending 尚未撤销的快照数
+	Pending int
+	// Bytes 备份内容占用的空间
+	Bytes int64
+	// Updated 最近一次快照的时间
+	Updated time.Time
+}
+
+// Sessions 列出有备份的会话，最近的在前
+func (s *Store) Sessions() ([]Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirs, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	var summaries []Summary
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entries, err := readIndex(filepath.Join(s.Dir, d.Name()))
+		if err != nil || len(entries) == 0 {
+			continue
+		}
+		summary := Summary{Session: d.Name(), Entries: len(entries), Updated: entries[len(entries)-1].Time}
+		for _, e := range entries {
+			if !e.Restored {
+				summary.Pending++
+			}
+			if e.Existed {
+				summary.Bytes += e.Size
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Updated.After(summaries[j].Updated)
+	})
+	return summaries, nil
+}
+
+// Prune 删除最近一次快照早于 before 的会话备份，返回删除的会话 ID
+func (s *Store) Prune(before time.Time) ([]string, error) {
+	summaries, err := s.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, summary := range summaries {
+		if summary.Updated.Before(before) {
+			if err := s.Remove(summary.Session); err != nil {
+				return removed, err
+			}
+			removed = append(removed, summary.Session)
+		}
+	}
+	return removed, nil
+}
+
+// Remove 删除会话的全部备份
+func (s *Store) Remove(session string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.sessionDir(session)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("删除备份失败: %w", err)
+	}
+	return nil
+}
+
+// readIndex 读取会话的快照列表，目录不存在时返回空列表
+func readIndex(dir string) ([]Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取备份索引失败: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析备份索引 %s 失败: %w", dir, err)
+	}
+	return entries, nil
+}
+
+// writeIndex 写入快照列表，先写临时文件再重命名
+func writeIndex(dir string, entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化备份索引失败: %w", err)
+	}
+	tmp := filepath.Join(dir, indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("保存备份索引失败: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, indexFile)); err != nil {
+		return fmt.Errorf("保存备份索引失败: %w", err)
+	}
+	return nil
+}
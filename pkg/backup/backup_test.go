@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_SnapshotAndUndo(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "backups"))
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "app.conf")
+	created := filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(existing, []byte("v1"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	// 两次覆盖 app.conf，一次新建 new.txt
+	for _, content := range []string{"v2", "v3"} {
+		if _, err := store.Snapshot("s1", existing); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(existing, []byte(content), 0644)
+	}
+	entry, err := store.Snapshot("s1", created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Existed || entry.Seq != 3 {
+		t.Errorf("新建文件的快照错误: %+v", entry)
+	}
+	os.WriteFile(created, []byte("x"), 0644)
+
+	// 不带路径撤销最近一次写入：删除新建的文件
+	if entry, err = store.Undo("s1", ""); err != nil || entry.Path != created {
+		t.Fatalf("应撤销最近一次写入: %+v %v", entry, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("撤销新建应删除文件")
+	}
+
+	// 按文件撤销，依次回到 v2、v1，并恢复原权限
+	for _, want := range []string{"v2", "v1"} {
+		if _, err := store.Undo("s1", existing); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(existing); string(data) != want {
+			t.Errorf("期望恢复为 %q，得到 %q", want, data)
+		}
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0640 {
+		t.Errorf("应恢复原权限，得到 %v", info.Mode().Perm())
+	}
+
+	if _, err := store.Undo("s1", ""); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("全部撤销后应返回 ErrNothingToUndo，得到 %v", err)
+	}
+	if _, err := store.Undo("other", ""); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("其他会话没有可撤销的写入，得到 %v", err)
+	}
+}
+
+func TestStore_SessionsAndPrune(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "backups"))
+	file := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+
+	for _, session := range []string{"old", "new"} {
+		if _, err := store.Snapshot(session, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 把 old 会话的快照时间改到 10 天前
+	dir := filepath.Join(store.Dir, "old")
+	entries, _ := readIndex(dir)
+	entries[0].Time = time.Now().AddDate(0, 0, -10)
+	writeIndex(dir, entries)
+
+	summaries, err := store.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].Session != "new" || summaries[0].Bytes != 5 || summaries[0].Pending != 1 {
+		t.Fatalf("会话列表错误: %+v", summaries)
+	}
+
+	removed, err := store.Prune(time.Now().AddDate(0, 0, -7))
+	if err != nil || len(removed) != 1 || removed[0] != "old" {
+		t.Fatalf("应只删除 old: %v %v", removed, err)
+	}
+	if _, err := store.Snapshot("../x", file); err == nil {
+		t.Error("包含路径分隔符的会话 ID 应报错")
+	}
+}
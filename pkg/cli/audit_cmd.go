@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/app"
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/ui"
+)
+
+// RunAuditCommand 执行 `aishell audit` 子命令，查询审计日志，返回进程退出码
+func RunAuditCommand(config *app.Config, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list", "ls":
+			args = args[1:]
+		case "path":
+			fmt.Println(config.AuditLog)
+			return 0
+		case "help", "-h", "--help":
+			printAuditUsage()
+			return 0
+		}
+	}
+
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	since := fs.String("since", "", "")
+	var filter audit.Filter
+	fs.StringVar(&filter.Tool, "tool", "", "")
+	fs.StringVar(&filter.Session, "session", "", "")
+	fs.StringVar(&filter.Status, "status", "", "")
+	fs.StringVar(&filter.Contains, "grep", "", "")
+	fs.IntVar(&filter.Limit, "n", 50, "")
+	asJSON := fs.Bool("json", false, "")
+	if err := fs.Parse(args); err != nil {
+		color.Red("❌ %v", err)
+		printAuditUsage()
+		return 2
+	}
+	if fs.NArg() > 0 {
+		color.Red("❌ 未知的参数: %s", fs.Arg(0))
+		printAuditUsage()
+		return 2
+	}
+	if *since != "" {
+		t, err := parseSince(*since, time.Now())
+		if err != nil {
+			color.Red("❌ %v", err)
+			return 2
+		}
+		filter.Since = t
+	}
+
+	if config.AuditLog == "" || config.AuditLog == app.AuditOff {
+		color.Yellow("审计日志已关闭 (audit_log = off)")
+		return 0
+	}
+	records, err := audit.Read(config.AuditLog, filter)
+	if err != nil {
+		color.Red("❌ %v", err)
+		return 1
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		for _, record := range records {
+			enc.Encode(record)
+		}
+		return 0
+	}
+	if len(records) == 0 {
+		fmt.Println("没有匹配的审计记录")
+		return 0
+	}
+	printAuditRecords(records)
+	return 0
+}
+
+// printAuditUsage 打印 audit 子命令帮助
+func printAuditUsage() {
+	fmt.Println("用法:")
+	fmt.Println("  aishell audit [list] [选项]   查询审计日志（默认显示最近 50 条）")
+	fmt.Println("  aishell audit path            显示审计日志路径")
+	fmt.Println()
+	fmt.Println("选项:")
+	fmt.Println("  --since <时间>     只显示该时间之后的记录，如 2h、7d、2026-10-01、2026-10-01T09:00:00+08:00")
+	fmt.Println("  --tool <名称>      工具名称: system_command、file_writer、file_editor、file_reader、file_search、dir_list、data_query")
+	fmt.Println("  --session <ID>     会话 ID 或其前缀")
+	fmt.Println("  --status <状态>    ok、failed、error、declined、denied、planned")
+	fmt.Println("  --grep <文本>      输入中包含的文本")
+	fmt.Println("  -n <数量>          最多显示的条数，0 表示全部（默认 50）")
+	fmt.Println("  --json             按 JSONL 输出完整记录")
+}
+
+// printAuditRecords 逐条显示审计记录
+func printAuditRecords(records []audit.Record) {
+	faint := color.New(color.Faint)
+	for _, r := range records {
+		status := color.New(color.FgGreen)
+		switch r.Status {
+		case audit.StatusFailed, audit.StatusError:
+			status = color.New(color.FgRed)
+		case audit.StatusDeclined, audit.StatusDenied:
+			status = color.New(color.FgYellow)
+		case audit.StatusPlanned:
+			status = color.New(color.FgCyan)
+		}
+
+		exit := ""
+		if r.ExitCode != nil {
+			exit = " 退出码=" + strconv.Itoa(*r.ExitCode)
+		}
+		fmt.Printf("%s  ", r.Time.Local().Format("2006-01-02 15:04:05"))
+		status.Printf("%-8s", r.Status)
+		fmt.Printf(" %s%s (%dms)  %s\n", r.Tool, exit, r.DurationMs, ui.SummarizeToolInput(r.Input))
+
+		details := fmt.Sprintf("    %s · %s", r.User, r.Cwd)
+		if r.Session != "" {
+			details += " · 会话 " + r.Session
+		}
+		if r.Approval != audit.ApprovalNotRequired {
+			details += " · " + r.Approval
+		}
+		if r.Error != "" {
+			details += " · " + r.Error
+		}
+		faint.Println(details)
+	}
+}
+
+// parseSince 解析 --since：相对时长（2h、30m、7d）或绝对时间（日期或 RFC 3339）
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if n := len(value); n > 1 && value[n-1] == 'd' {
+		if days, err := strconv.Atoi(value[:n-1]); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("无法解析时间 " + strconv.Quote(value) + "，可以使用 2h、7d、2026-10-01 或 RFC 3339 格式")
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/backup"
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// defaultBackupRetention prune 默认保留最近 7 天的备份
+const defaultBackupRetention = 7 * 24 * time.Hour
+
+// RunBackupsCommand 执行 `aishell backups` 子命令，返回进程退出码
+func RunBackupsCommand(args []string) int {
+	store := backup.NewStore(backup.DefaultDir())
+	if len(args) == 0 {
+		return runBackupsList(store)
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return runBackupsList(store)
+	case "show":
+		if len(args) != 2 {
+			color.Red("❌ 用法: aishell backups show <会话ID>")
+			return 2
+		}
+		return runBackupsShow(store, args[1])
+	case "prune":
+		return runBackupsPrune(store, args[1:])
+	case "help", "-h", "--help":
+		printBackupsUsage()
+		return 0
+	default:
+		color.Red("❌ 未知的 backups 子命令: %s", args[0])
+		printBackupsUsage()
+		return 2
+	}
+}
+
+// printBackupsUsage 打印 backups 子命令帮助
+func printBackupsUsage() {
+	fmt.Println("用法:")
+	fmt.Println("  aishell backups list                      列出有文件备份的会话")
+	fmt.Println("  aishell backups show <会话ID>              列出会话中每次写入前的快照")
+	fmt.Println("  aishell backups prune [--older-than 7d]   删除早于指定时间的备份（默认 7 天）")
+	fmt.Println("  aishell backups prune --session <会话ID>   删除指定会话的备份")
+	fmt.Println()
+	fmt.Println("在交互界面输入 /undo 撤销最近一次写入，/undo <文件> 撤销该文件最近一次写入。")
+	fmt.Println("备份保存在", backup.DefaultDir())
+}
+
+// runBackupsList 列出有备份的会话
+func runBackupsList(store *backup.Store) int {
+	summaries, err := store.Sessions()
+	if err != nil {
+		color.Red("❌ %v", err)
+		return 1
+	}
+	if len(summaries) == 0 {
+		fmt.Println("没有文件备份")
+		return 0
+	}
+	for _, s := range summaries {
+		fmt.Printf("%s  %s  %2d次写入  %d次可撤销  %s\n", s.Session, s.Updated.Format("2006-01-02 15:04"),
+			s.Entries, s.Pending, utils.FormatBytes(s.Bytes))
+	}
+	return 0
+}
+
+// runBackupsShow 列出会话的快照
+func runBackupsShow(store *backup.Store, id string) int {
+	id, err := resolveBackupSession(store, id)
+	if err != nil {
+		color.Red("❌ %v", err)
+		return 1
+	}
+	entries, err := store.List(id)
+	if err != nil {
+		color.Red("❌ %v", err)
+		return 1
+	}
+
+	faint := color.New(color.Faint)
+	for _, e := range entries {
+		state := "覆盖"
+		if !e.Existed {
+			state = "新建"
+		}
+		line := fmt.Sprintf("%3d  %s  %s  %s", e.Seq, e.Time.Format("2006-01-02 15:04:05"), state, e.Path)
+		if e.Existed {
+			line += fmt.Sprintf(" (原 %s)", utils.FormatBytes(e.Size))
+		}
+		if e.Restored {
+			faint.Println(line + "  已撤销")
+		} else {
+			fmt.Println(line)
+		}
+	}
+	return 0
+}
+
+// runBackupsPrune 删除旧的备份
+func runBackupsPrune(store *backup.Store, args []string) int {
+	retention := defaultBackupRetention
+	session := ""
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			color.Red("❌ %s 缺少参数", args[i])
+			return 2
+		}
+		switch args[i] {
+		case "--older-than":
+			t, err := parseSince(args[i+1], time.Now())
+			if err != nil {
+				color.Red("❌ %v", err)
+				return 2
+			}
+			retention = time.Since(t)
+		case "--session":
+			session = args[i+1]
+		default:
+			color.Red("❌ 未知的选项: %s", args[i])
+			return 2
+		}
+		i++
+	}
+
+	if session != "" {
+		id, err := resolveBackupSession(store, session)
+		if err != nil {
+			color.Red("❌ %v", err)
+			return 1
+		}
+		if err := store.Remove(id); err != nil {
+			color.Red("❌ %v", err)
+			return 1
+		}
+		fmt.Printf("已删除会话 %s 的备份\n", id)
+		return 0
+	}
+
+	removed, err := store.Prune(time.Now().Add(-retention))
+	if err != nil {
+		color.Red("❌ %v", err)
+		return 1
+	}
+	fmt.Printf("已删除 %d 个会话的备份\n", len(removed))
+	return 0
+}
+
+// resolveBackupSession 把会话 ID 前缀解析为有备份的完整会话 ID
+func resolveBackupSession(store *backup.Store, prefix string) (string, error) {
+	summaries, err := store.Sessions()
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, s := range summaries {
+		if s.Session == prefix {
+			return prefix, nil
+		}
+		if strings.HasPrefix(s.Session, prefix) {
+			matches = append(matches, s.Session)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("会话 %s 没有文件备份", prefix)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("会话 ID 前缀 %s 不唯一，匹配: %s", prefix, strings.Join(matches, ", "))
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/app"
+)
+
+// RunConfigCommand 执行 `aishell config` 子命令，返回进程退出码
+func RunConfigCommand(config *app.Config, args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printConfigUsage()
+		return 0
+	}
+
+	switch args[0] {
+	case "show":
+		return runConfigShow(config)
+	default:
+		color.Red("❌ 未知的 config 子命令: %s", args[0])
+		printConfigUsage()
+		return 2
+	}
+}
+
+// printConfigUsage 打印 config 子命令帮助
+func printConfigUsage() {
+	fmt.Println("用法:")
+	fmt.Println("  aishell [选项] config show   显示生效的配置及每一项的来源")
+	fmt.Println()
+	fmt.Println("优先级从低到高: 默认值 < 用户配置文件 < 项目配置文件 < 环境变量 < 命令行选项")
+}
+
+// runConfigShow 显示配置文件位置、每个配置项的生效值和来源
+func runConfigShow(config *app.Config) int {
+	cyan := color.New(color.FgCyan, color.Bold)
+	faint := color.New(color.Faint)
+
+	cyan.Println("📄 配置文件:")
+	for _, file := range app.ConfigFiles() {
+		status := "未找到"
+		if file.Exists {
+			status = "已加载"
+		}
+		fmt.Printf("  %s: %s (%s)\n", file.Scope, file.Path, status)
+	}
+	fmt.Println()
+
+	cyan.Println("⚙️  生效配置:")
+	for _, entry := range config.Entries() {
+		value := entry.Value
+		if value == "" {
+			value = "(空)"
+		}
+		fmt.Printf("  %-26s %-30s ", entry.Key, value)
+		faint.Println(entry.Source)
+	}
+	return 0
+}
@@ -28,6 +28,22 @@ func (ip *InputProcessor) ReadInput() (string, error) {
 	return strings.TrimSpace(line), nil
 }
 
+// Ask 显示提示并读取一行回答，回答不记入命令历史
+func (ip *InputProcessor) Ask(prompt string) (string, error) {
+	ip.rl.HistoryDisable()
+	defer ip.rl.HistoryEnable()
+
+	oldPrompt := ip.rl.Config.Prompt
+	ip.rl.SetPrompt(prompt)
+	defer ip.rl.SetPrompt(oldPrompt)
+
+	line, err := ip.rl.Readline()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
 // IsExitCommand 检查是否为退出命令
 func IsExitCommand(input string) bool {
 	lower := strings.ToLower(input)
@@ -52,6 +68,70 @@ func IsClearCommand(input string) bool {
 	return lower == "clear" || lower == "cls"
 }
 
+// IsResetShellCommand 检查是否为重置 shell 会话命令
+func IsResetShellCommand(input string) bool {
+	lower := strings.ToLower(input)
+	return lower == "reset-shell" || lower == "/reset-shell" || lower == "重置shell"
+}
+
+// IsSessionsCommand 检查是否为会话列表命令
+func IsSessionsCommand(input string) bool {
+	lower := strings.ToLower(input)
+	return lower == "sessions" || lower == "/sessions" || lower == "会话列表"
+}
+
+// IsUndoCommand 检查是否为撤销文件写入命令：/undo、/undo <file>
+func IsUndoCommand(input string) bool {
+	_, ok := parseUndoCommand(input)
+	return ok
+}
+
+// parseUndoCommand 解析撤销命令，返回要撤销的文件（为空表示最近一次写入）
+func parseUndoCommand(input string) (string, bool) {
+	command, path, _ := strings.Cut(strings.TrimSpace(input), " ")
+	switch strings.ToLower(command) {
+	case "undo", "/undo", "撤销":
+		return strings.TrimSpace(path), true
+	}
+	return "", false
+}
+
+// IsDryRunCommand 检查是否为演练模式切换命令：plan、plan on、plan off
+func IsDryRunCommand(input string) bool {
+	_, ok := parseDryRunCommand(input)
+	return ok
+}
+
+// parseDryRunCommand 解析演练模式切换命令，返回参数（""、"on" 或 "off"）
+func parseDryRunCommand(input string) (string, bool) {
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", false
+	}
+	switch fields[0] {
+	case "plan", "/plan", "dry-run", "/dry-run", "演练模式":
+	default:
+		return "", false
+	}
+	if len(fields) == 1 {
+		return "", true
+	}
+	switch fields[1] {
+	case "on", "off":
+		return fields[1], true
+	}
+	return "", false
+}
+
+// IsYes 检查回答是否为肯定
+func IsYes(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes", "是", "确定":
+		return true
+	}
+	return false
+}
+
 // FilterInput 过滤输入字符
 func FilterInput(r rune) (rune, bool) {
 	switch r {
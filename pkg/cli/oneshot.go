@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/app"
+	"github.com/dean2027/aishell/pkg/tools"
+	"github.com/dean2027/aishell/pkg/ui"
+)
+
+// 非交互模式的退出码
+const (
+	// ExitOK 成功回答
+	ExitOK = 0
+	// ExitError 初始化或调用模型失败
+	ExitError = 1
+	// ExitUsage 命令行用法错误
+	ExitUsage = 2
+	// ExitDeclined 回答过程中有危险命令被拒绝执行
+	ExitDeclined = 3
+	// ExitInterrupted 被 Ctrl+C 或 SIGTERM 中断
+	ExitInterrupted = 130
+)
+
+// stdinMaxTokens 管道输入作为上下文时的 token 上限，超出部分保存到溢出文件
+const stdinMaxTokens = 16000
+
+// OneShot 非交互模式的一次性请求
+type OneShot struct {
+	// Prompt 用户的问题，为空时把管道输入本身作为问题
+	Prompt string
+	// Input 通过管道传入的标准输入内容
+	Input string
+	// Approval 危险命令的确认方式
+	Approval tools.ApprovalMode
+	// Stdout 回答的输出位置
+	Stdout io.Writer
+	// Stderr 工具调用、命令输出和确认提示的输出位置
+	Stderr io.Writer
+}
+
+// StdinIsPiped 判断标准输入是否来自管道或文件而不是终端
+func StdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// ReadPipedInput 读取通过管道传入的标准输入，标准输入是终端时返回空字符串
+func ReadPipedInput() (string, error) {
+	if !StdinIsPiped() {
+		return "", nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("读取标准输入失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// DefaultApproval 根据 --yes/--no 选择危险命令的确认方式。
+// 都未指定时，标准输入是终端则交互询问，否则没有终端可询问，自动拒绝。
+func DefaultApproval(yes, no bool) tools.ApprovalMode {
+	switch {
+	case yes:
+		return tools.ApprovalYes
+	case no:
+		return tools.ApprovalNo
+	case StdinIsPiped():
+		return tools.ApprovalNo
+	}
+	return tools.ApprovalAsk
+}
+
+// BuildPrompt 把管道输入作为上下文附加到问题之前
+func BuildPrompt(prompt, input string) string {
+	prompt = strings.TrimSpace(prompt)
+	input = strings.TrimRight(input, "\n")
+	if strings.TrimSpace(input) == "" {
+		return prompt
+	}
+	if prompt == "" {
+		return input
+	}
+	return "以下是通过标准输入提供的内容:\n```\n" + input + "\n```\n\n" + prompt
+}
+
+// RunOnce 以非交互模式回答一个问题并返回进程退出码：
+// 回答（或 --output json/jsonl 的结构化结果）写入标准输出，工具调用和命令输出写入标准错误，
+// 便于在脚本和管道中使用。
+func RunOnce(ctx context.Context, config *app.Config, req OneShot) int {
+	stdout, stderr := req.Stdout, req.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	red := color.New(color.FgRed)
+
+	// 裁剪过大的管道输入，完整内容保存到溢出文件
+	input := req.Input
+	if input != "" {
+		report := tools.NewOutputBudget(stdinMaxTokens, true).Apply("stdin", input)
+		if report.Truncated {
+			color.New(color.FgYellow).Fprintf(stderr, "⚠️  标准输入过长（约 %d tokens），已省略中间 %d 行\n", report.TotalTokens, report.OmittedLines)
+		}
+		input = report.Text
+	}
+	prompt := BuildPrompt(req.Prompt, input)
+	if prompt == "" {
+		red.Fprintln(stderr, "❌ 没有要处理的问题，用法: aishell -c \"<问题>\" 或 <命令> | aishell \"<问题>\"")
+		return ExitUsage
+	}
+
+	if err := app.ValidateRequirements(config); err != nil {
+		red.Fprintf(stderr, "❌ %v\n", err)
+		return ExitError
+	}
+
+	// Ctrl+C 或 SIGTERM 取消本次请求，正在运行的命令随之终止
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	chatBot, err := app.NewChatBot(ctx, config)
+	if err != nil {
+		red.Fprintf(stderr, "❌ 初始化聊天机器人失败: %v\n", err)
+		return ExitError
+	}
+	defer chatBot.Close()
+
+	approver := chatBot.Approver()
+	approver.Mode = req.Approval
+	approver.Out = stderr
+	chatBot.SetCommandOutput(stderr)
+
+	// 文本格式逐段输出回答；json/jsonl 格式输出回答和每个步骤，标准输出只包含 JSON
+	var printer *ui.StreamPrinter
+	var jsonPrinter *ui.JSONPrinter
+	if config.Output == app.OutputJSON || config.Output == app.OutputJSONL {
+		jsonPrinter = ui.NewJSONPrinter(stdout, config.Output == app.OutputJSONL)
+		chatBot.SetEventHandler(jsonPrinter.Handle)
+	} else {
+		printer = ui.NewOneShotPrinter(stdout, stderr)
+		chatBot.SetEventHandler(printer.Handle)
+	}
+
+	response, err := chatBot.ProcessInputContext(ctx, prompt)
+	plan := chatBot.TakePlan()
+
+	code, status := ExitOK, ui.StatusOK
+	switch {
+	case errors.Is(err, context.Canceled) || ctx.Err() != nil:
+		code, status = ExitInterrupted, ui.StatusInterrupted
+	case err != nil:
+		code, status = ExitError, ui.StatusError
+	case approver.Declined() > 0:
+		code, status = ExitDeclined, ui.StatusDeclined
+	}
+
+	if jsonPrinter != nil {
+		jsonPrinter.Finish(response, err, status, plan)
+	} else {
+		printer.Finish(response, err)
+		// 演练模式下只展示计划，不执行
+		if len(plan) > 0 {
+			ui.FprintPlan(stderr, plan)
+		}
+	}
+
+	switch status {
+	case ui.StatusInterrupted:
+		red.Fprintln(stderr, "⛔ 已中断")
+	case ui.StatusError:
+		red.Fprintf(stderr, "❌ 处理失败: %v\n", err)
+	}
+	return code
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/policy"
+	"github.com/dean2027/aishell/pkg/tools"
+)
+
+// RunPolicyCommand 执行 `aishell policy` 子命令，返回进程退出码
+func RunPolicyCommand(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printPolicyUsage()
+		return 0
+	}
+
+	switch args[0] {
+	case "check":
+		if len(args) < 2 {
+			color.Red("❌ 用法: aishell policy check \"<command>\"")
+			return 2
+		}
+		return runPolicyCheck(strings.Join(args[1:], " "))
+	case "write":
+		if len(args) != 2 {
+			color.Red("❌ 用法: aishell policy write <文件路径>")
+			return 2
+		}
+		return runPolicyWrite(args[1])
+	case "files":
+		return runPolicyFiles()
+	default:
+		color.Red("❌ 未知的 policy 子命令: %s", args[0])
+		printPolicyUsage()
+		return 2
+	}
+}
+
+// printPolicyUsage 打印 policy 子命令帮助
+func printPolicyUsage() {
+	fmt.Println("用法:")
+	fmt.Println("  aishell policy check \"<command>\"   解释命令会被允许、确认还是拒绝，以及命中的规则")
+	fmt.Println("  aishell policy write <文件路径>      解释写入该文件前是否展示差异并确认")
+	fmt.Println("  aishell policy files               列出已加载的策略文件")
+}
+
+// loadPolicy 加载策略并提示被忽略的规则
+func loadPolicy() (*policy.Policy, error) {
+	p, err := policy.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range p.Warnings {
+		color.Yellow("⚠️  %s", warning)
+	}
+	return p, nil
+}
+
+// runPolicyCheck 分析命令并解释每个子命令的策略结果
+func runPolicyCheck(command string) int {
+	p, err := loadPolicy()
+	if err != nil {
+		color.Red("❌ 加载策略失败: %v", err)
+		return 1
+	}
+
+	systemCommand := tools.NewSystemCommand()
+	systemCommand.Policy = p
+	analysis := systemCommand.AnalyzeCommand(command)
+
+	cyan := color.New(color.FgCyan, color.Bold)
+	yellow := color.New(color.FgYellow)
+
+	cyan.Printf("🔎 命令: %s\n", command)
+	if len(p.Files) == 0 {
+		yellow.Println("💡 未找到策略文件，仅使用内置危险命令列表")
+	}
+	fmt.Println()
+
+	if analysis.ParseError != nil {
+		color.Red("⚠️  解析失败: %v", analysis.ParseError)
+		fmt.Println()
+	}
+
+	for i, seg := range analysis.Segments {
+		fmt.Printf("%d. %s", i+1, seg.String())
+		if seg.Context != "" {
+			fmt.Printf("  (%s)", seg.Context)
+		}
+		fmt.Println()
+		decision := analysis.Decisions[i]
+		fmt.Printf("   策略: %s\n", decision.Explain())
+		if timeout := p.TimeoutFor(seg.Subject(analysis.Cwd)); timeout > 0 {
+			fmt.Printf("   超时: %s\n", timeout)
+		}
+	}
+	fmt.Println()
+
+	if len(analysis.Risks) > 0 {
+		yellow.Println("⚠️  风险项:")
+		for _, risk := range analysis.Risks {
+			fmt.Printf("  • %s\n", risk.Reason)
+		}
+		fmt.Println()
+	}
+
+	switch {
+	case analysis.Denied != nil:
+		color.Red("⛔ 结果: deny — %s", analysis.Denied.Explain())
+	case analysis.IsDangerous():
+		color.Yellow("⚠️  结果: confirm — 执行前需要用户确认")
+	default:
+		color.Green("✅ 结果: allow — 可直接执行")
+	}
+	return 0
+}
+
+// runPolicyWrite 解释 file_writer 写入文件时的策略结果
+func runPolicyWrite(path string) int {
+	p, err := loadPolicy()
+	if err != nil {
+		color.Red("❌ 加载策略失败: %v", err)
+		return 1
+	}
+
+	decision := p.EvaluateWrite(path)
+	color.New(color.FgCyan, color.Bold).Printf("🔎 文件: %s\n", decision.Subject.Text)
+	fmt.Printf("   策略: %s\n", decision.Explain())
+	fmt.Println()
+
+	switch decision.Action {
+	case policy.ActionDeny:
+		color.Red("⛔ 结果: deny — 拒绝写入")
+	case policy.ActionConfirm:
+		color.Yellow("⚠️  结果: confirm — 写入前展示差异并需要用户确认")
+	case policy.ActionAllow:
+		color.Green("✅ 结果: allow — 直接写入")
+	default:
+		color.Yellow("⚠️  结果: 覆盖已有文件前展示差异并需要用户确认，新建文件直接写入")
+	}
+	return 0
+}
+
+// runPolicyFiles 列出已加载的策略文件
+func runPolicyFiles() int {
+	p, err := loadPolicy()
+	if err != nil {
+		color.Red("❌ 加载策略失败: %v", err)
+		return 1
+	}
+	if len(p.Files) == 0 {
+		fmt.Println("未找到策略文件")
+		return 0
+	}
+	for _, file := range p.Files {
+		fmt.Println(file)
+	}
+	return 0
+}
@@ -2,9 +2,12 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/chzyer/readline"
 
@@ -19,6 +22,7 @@ type Runner struct {
 	inputProcessor *InputProcessor
 	config         *app.Config
 	ctx            context.Context
+	onInterrupt    func()
 }
 
 // RunnerConfig 运行器配置
@@ -30,7 +34,7 @@ type RunnerConfig struct {
 // NewRunner 创建新的CLI运行器
 func NewRunner(ctx context.Context, config *app.Config) (*Runner, error) {
 	// 验证环境要求
-	if err := app.ValidateRequirements(); err != nil {
+	if err := app.ValidateRequirements(config); err != nil {
 		return nil, err
 	}
 
@@ -57,13 +61,15 @@ func NewRunner(ctx context.Context, config *app.Config) (*Runner, error) {
 
 	inputProcessor := NewInputProcessor(rl)
 
-	return &Runner{
+	runner := &Runner{
 		chatBot:         chatBot,
 		rl:             rl,
 		inputProcessor: inputProcessor,
 		config:         config,
 		ctx:            ctx,
-	}, nil
+	}
+	runner.updatePrompt()
+	return runner, nil
 }
 
 // Run 运行CLI应用
@@ -72,6 +78,10 @@ func (r *Runner) Run() error {
 
 	// 打印欢迎信息
 	ui.PrintWelcome()
+	ui.PrintModelInfo(r.config.Provider, r.config.ModelName())
+	if sess := r.chatBot.Session(); len(sess.Messages) > 0 {
+		ui.PrintInfo(fmt.Sprintf("已恢复会话 %s（%d 轮）: %s", sess.ID, sess.Turns(), sess.Title))
+	}
 	ui.PrintUsageTips()
 
 	// 主循环
@@ -104,6 +114,11 @@ func (r *Runner) mainLoop() error {
 			continue
 		}
 
+		// 退出命令结束循环，由 Run 中的 Close 保存会话并释放 shell 和沙箱
+		if IsExitCommand(input) {
+			break
+		}
+
 		// 处理特殊命令
 		if r.handleSpecialCommands(input) {
 			continue
@@ -112,8 +127,10 @@ func (r *Runner) mainLoop() error {
 		// 处理用户输入
 		if err := r.processUserInput(input); err != nil {
 			ui.PrintError("处理输入失败", err)
-			continue
 		}
+
+		// 演练模式下展示本轮生成的计划
+		r.reviewPlan()
 	}
 
 	ui.PrintGoodbye()
@@ -123,10 +140,6 @@ func (r *Runner) mainLoop() error {
 // handleSpecialCommands 处理特殊命令
 func (r *Runner) handleSpecialCommands(input string) bool {
 	switch {
-	case IsExitCommand(input):
-		ui.PrintGoodbye()
-		os.Exit(0)
-		return true
 	case IsHelpCommand(input):
 		ui.PrintHelp()
 		return true
@@ -136,28 +149,145 @@ func (r *Runner) handleSpecialCommands(input string) bool {
 	case IsClearCommand(input):
 		r.clearScreen()
 		return true
+	case IsDryRunCommand(input):
+		r.toggleDryRun(input)
+		return true
+	case IsSessionsCommand(input):
+		runSessionsList(r.chatBot.Sessions(), r.chatBot.Session().ID)
+		ui.PrintInfo("使用 aishell --resume=<id> 恢复会话，aishell sessions show <id> 查看内容")
+		return true
+	case IsUndoCommand(input):
+		r.undo(input)
+		return true
+	case IsResetShellCommand(input):
+		if err := r.chatBot.ResetShell(); err != nil {
+			ui.PrintError("重置shell会话失败", err)
+		} else {
+			ui.PrintInfo("shell 会话已重置，工作目录和环境变量恢复初始状态")
+		}
+		return true
 	}
 	return false
 }
 
 // processUserInput 处理用户输入
 func (r *Runner) processUserInput(input string) error {
+	// 每轮对话使用独立的可取消上下文，Ctrl+C 只中断当前这一轮
+	turnCtx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	stopWatching := r.watchInterrupt(cancel)
+	defer stopWatching()
+
+	// 实时显示回复和工具调用，经 readline 的输出通道写入以便正确重绘提示符
+	printer := ui.NewStreamPrinter(r.rl.Stdout())
+	r.chatBot.SetEventHandler(printer.Handle)
+	defer r.chatBot.SetEventHandler(nil)
+
 	// 显示思考状态
-	ui.PrintThinking()
-	
+	printer.Start()
+
 	// 处理输入
-	response, err := r.chatBot.ProcessInput(input)
-	
-	// 清除思考状态
-	ui.ClearThinking()
-	
+	response, err := r.chatBot.ProcessInputContext(turnCtx, input)
+
+	// 结束显示，未流式输出时一次性显示回复
+	printer.Finish(response, err)
+	if errors.Is(err, context.Canceled) {
+		ui.PrintInterrupted()
+		return nil
+	}
+	return err
+}
+
+// toggleDryRun 切换演练模式
+func (r *Runner) toggleDryRun(input string) {
+	arg, _ := parseDryRunCommand(input)
+	enabled := !r.chatBot.DryRun()
+	if arg != "" {
+		enabled = arg == "on"
+	}
+	r.chatBot.SetDryRun(enabled)
+	r.updatePrompt()
+
+	if enabled {
+		ui.PrintInfo("演练模式已开启：系统命令和文件写入只生成计划，不会真正执行")
+	} else {
+		ui.PrintInfo("演练模式已关闭：工具调用将直接执行")
+	}
+}
+
+// undo 撤销最近一次（或指定文件最近一次）的文件写入
+func (r *Runner) undo(input string) {
+	path, _ := parseUndoCommand(input)
+	entry, err := r.chatBot.Undo(path)
 	if err != nil {
-		return err
+		ui.PrintError("撤销失败", err)
+		return
 	}
+	if entry.Existed {
+		ui.PrintInfo(fmt.Sprintf("已恢复 %s 到 %s 写入前的内容（%d 字节）", entry.Path, entry.Time.Format("15:04:05"), entry.Size))
+	} else {
+		ui.PrintInfo(fmt.Sprintf("已删除 %s 写入的新文件 %s", entry.Time.Format("15:04:05"), entry.Path))
+	}
+}
 
-	// 显示回复
-	ui.PrintResponse(response)
-	return nil
+// updatePrompt 根据演练模式更新提示符
+func (r *Runner) updatePrompt() {
+	prompt := r.config.Prompt
+	if r.chatBot.DryRun() {
+		prompt = "[演练] " + prompt
+	}
+	r.rl.SetPrompt(prompt)
+}
+
+// reviewPlan 展示本轮对话生成的计划，并按用户选择逐步执行
+func (r *Runner) reviewPlan() {
+	steps := r.chatBot.TakePlan()
+	if len(steps) == 0 {
+		return
+	}
+
+	ui.PrintPlan(steps)
+	answer, err := r.inputProcessor.Ask("是否逐步执行该计划? [y/N]: ")
+	if err != nil || !IsYes(answer) {
+		ui.PrintInfo("计划未执行")
+		return
+	}
+
+	for i, step := range steps {
+		ui.PrintPlanStep(i+1, len(steps), step)
+		answer, err := r.inputProcessor.Ask("执行此步骤? [y]执行 / [s]跳过 / [q]停止: ")
+		if err != nil {
+			return
+		}
+		switch strings.ToLower(answer) {
+		case "q", "quit", "停止":
+			ui.PrintInfo(fmt.Sprintf("已停止，剩余 %d 步未执行", len(steps)-i))
+			return
+		case "s", "skip", "跳过":
+			continue
+		}
+		if !IsYes(answer) {
+			continue
+		}
+
+		// 每一步使用独立的可取消上下文，Ctrl+C 只中断当前步骤并停止计划
+		stepCtx, cancel := context.WithCancel(r.ctx)
+		stopWatching := r.watchInterrupt(cancel)
+		result, err := r.chatBot.ExecutePlanStep(stepCtx, step)
+		stopWatching()
+		interrupted := stepCtx.Err() != nil
+		cancel()
+		if interrupted {
+			ui.PrintInfo(fmt.Sprintf("计划已中断，剩余 %d 步未执行", len(steps)-i))
+			return
+		}
+		if err != nil {
+			ui.PrintError("执行失败", err)
+			continue
+		}
+		ui.PrintStepResult(result)
+	}
+	ui.PrintInfo("计划执行完毕")
 }
 
 // clearScreen 清屏
@@ -185,10 +315,43 @@ func (r *Runner) Close() error {
 	return nil
 }
 
-// SetInterruptHandler 设置中断处理器
+// SetInterruptHandler 设置中断处理器，对话进行中按下 Ctrl+C 时在取消当前这一轮之后调用
 func (r *Runner) SetInterruptHandler(handler func()) {
-	// 这里可以设置自定义的中断处理逻辑
-	// 比如优雅地保存状态、清理资源等
+	r.onInterrupt = handler
+}
+
+// watchInterrupt 在一轮对话期间监听 Ctrl+C：第一次取消当前轮，第二次强制退出程序。
+// 返回的函数用于停止监听，之后 Ctrl+C 重新交给 readline 处理。
+func (r *Runner) watchInterrupt(cancel context.CancelFunc) func() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	done := make(chan struct{})
+
+	go func() {
+		interrupted := false
+		for {
+			select {
+			case <-sigCh:
+				if interrupted {
+					ui.PrintGoodbye()
+					os.Exit(130)
+				}
+				interrupted = true
+				cancel()
+				if r.onInterrupt != nil {
+					r.onInterrupt()
+				}
+				ui.PrintInterrupting()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
 }
 
 // GetChatBot 获取聊天机器人实例（用于测试或扩展）
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/session"
+	"github.com/dean2027/aishell/pkg/ui"
+)
+
+// sessionToolResultLines 显示会话时每个工具结果最多显示的行数
+const sessionToolResultLines = 20
+
+// RunSessionsCommand 执行 `aishell sessions` 子命令，返回进程退出码
+func RunSessionsCommand(args []string) int {
+	store := session.NewStore(session.DefaultDir())
+	if len(args) == 0 {
+		return runSessionsList(store, "")
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return runSessionsList(store, "")
+	case "show":
+		full := false
+		var ids []string
+		for _, arg := range args[1:] {
+			if arg == "--full" {
+				full = true
+				continue
+			}
+			ids = append(ids, arg)
+		}
+		if len(ids) != 1 {
+			color.Red("❌ 用法: aishell sessions show <id> [--full]")
+			return 2
+		}
+		return runSessionsShow(store, ids[0], full)
+	case "delete", "rm":
+		if len(args) < 2 {
+			color.Red("❌ 用法: aishell sessions delete <id>...")
+			return 2
+		}
+		return runSessionsDelete(store, args[1:])
+	case "help", "-h", "--help":
+		printSessionsUsage()
+		return 0
+	default:
+		color.Red("❌ 未知的 sessions 子命令: %s", args[0])
+		printSessionsUsage()
+		return 2
+	}
+}
+
+// printSessionsUsage 打印 sessions 子命令帮助
+func printSessionsUsage() {
+	fmt.Println("用法:")
+	fmt.Println("  aishell sessions list               列出保存的会话（最近的在前）")
+	fmt.Println("  aishell sessions show <id> [--full] 显示会话内容，--full 显示完整的工具结果")
+	fmt.Println("  aishell sessions delete <id>...     删除会话")
+	fmt.Println("  aishell --resume                    恢复最近的会话")
+	fmt.Println("  aishell --resume=<id>               恢复指定会话")
+	fmt.Println()
+	fmt.Println("会话 ID 可以只写能唯一确定会话的前缀。会话保存在", session.DefaultDir())
+}
+
+// runSessionsList 列出会话，current 为当前会话 ID（在 REPL 中标记）
+func runSessionsList(store *session.Store, current string) int {
+	summaries, err := store.List()
+	if err != nil {
+		color.Red("❌ %v", err)
+		return 1
+	}
+	if len(summaries) == 0 {
+		fmt.Println("没有保存的会话")
+		return 0
+	}
+
+	faint := color.New(color.Faint)
+	for _, s := range summaries {
+		marker := "  "
+		if s.ID == current {
+			marker = "* "
+		}
+		title := s.Title
+		if title == "" {
+			title = "(无标题)"
+		}
+		fmt.Printf("%s%s  %s  %2d轮  %s\n", marker, s.ID, s.Updated.Format("2006-01-02 15:04"), s.Turns, title)
+		faint.Printf("    %s · %s\n", s.Cwd, s.Model)
+	}
+	return 0
+}
+
+// runSessionsShow 显示会话内容：用户输入、回复、工具调用及结果
+func runSessionsShow(store *session.Store, id string, full bool) int {
+	sess, err := store.Load(id)
+	if err != nil {
+		color.Red("❌ %v", err)
+		return 1
+	}
+
+	cyan := color.New(color.FgCyan, color.Bold)
+	faint := color.New(color.Faint)
+
+	cyan.Printf("💬 会话 %s\n", sess.ID)
+	fmt.Printf("标题: %s\n", sess.Title)
+	fmt.Printf("目录: %s\n", sess.Cwd)
+	fmt.Printf("模型: %s / %s (%s)\n", sess.Provider, sess.Model, sess.Agent)
+	fmt.Printf("时间: %s ~ %s\n", sess.Created.Format("2006-01-02 15:04:05"), sess.Updated.Format("2006-01-02 15:04:05"))
+	fmt.Println()
+
+	for _, m := range sess.Messages {
+		switch m.Role {
+		case session.RoleHuman:
+			color.New(color.FgGreen, color.Bold).Print("👤 用户: ")
+			fmt.Println(m.Content)
+		case session.RoleAI:
+			if m.Content != "" {
+				color.New(color.FgBlue).Println("🤖 终端助手:")
+				fmt.Println(m.Content)
+			}
+			for _, call := range m.ToolCalls {
+				color.New(color.FgCyan).Printf("🔧 %s", call.Name)
+				faint.Printf(" %s\n", ui.SummarizeToolInput(call.Arguments))
+			}
+		case session.RoleTool:
+			faint.Println(indent(truncateLines(m.Content, full), "   "))
+		}
+		fmt.Println()
+	}
+	return 0
+}
+
+// runSessionsDelete 删除会话
+func runSessionsDelete(store *session.Store, ids []string) int {
+	code := 0
+	for _, id := range ids {
+		deleted, err := store.Delete(id)
+		if err != nil {
+			color.Red("❌ %v", err)
+			if !errors.Is(err, session.ErrNotFound) {
+				return 1
+			}
+			code = 1
+			continue
+		}
+		fmt.Printf("已删除会话 %s\n", deleted)
+	}
+	return code
+}
+
+// truncateLines 截断过长的工具结果
+func truncateLines(text string, full bool) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if full || len(lines) <= sessionToolResultLines {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:sessionToolResultLines], "\n") +
+		fmt.Sprintf("\n… (共 %d 行，使用 --full 查看完整内容)", len(lines))
+}
+
+// indent 为每行添加缩进
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
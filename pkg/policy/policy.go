@@ -0,0 +1,446 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// Action 策略动作
+type Action string
+
+const (
+	// ActionAllow 允许执行，即使命令在内置危险列表中（其他内置风险仍需确认）
+	ActionAllow Action = "allow"
+	// ActionConfirm 执行前需要用户确认
+	ActionConfirm Action = "confirm"
+	// ActionDeny 拒绝执行
+	ActionDeny Action = "deny"
+)
+
+// actionPriority 动作优先级，数值越大越严格
+var actionPriority = map[Action]int{
+	ActionAllow:   1,
+	ActionConfirm: 2,
+	ActionDeny:    3,
+}
+
+// policyFileNames 支持的策略文件名，按顺序查找
+var policyFileNames = []string{"policy.yaml", "policy.yml", "policy.toml"}
+
+// Rule 单条策略规则。规则中所有非空的匹配条件都满足时规则才会命中。
+type Rule struct {
+	// Name 规则名称，用于解释命中原因
+	Name string `yaml:"name" toml:"name"`
+	// Action 命中后的动作: allow / confirm / deny
+	Action Action `yaml:"action" toml:"action"`
+	// Commands 命令名匹配（支持 glob），同时匹配 sudo/env 等包装命令
+	Commands []string `yaml:"commands" toml:"commands"`
+	// Args 参数 glob，每个模式都必须匹配至少一个参数
+	Args []string `yaml:"args" toml:"args"`
+	// Regex 对完整子命令文本的正则匹配
+	Regex string `yaml:"regex" toml:"regex"`
+	// Paths 目标路径 glob，匹配命令参数中的路径和重定向目标
+	Paths []string `yaml:"paths" toml:"paths"`
+	// Reason 规则说明，会展示给用户
+	Reason string `yaml:"reason" toml:"reason"`
+	// Timeout 命中规则的命令使用的超时时间，如 "10m"，不影响动作判定
+	Timeout string `yaml:"timeout" toml:"timeout"`
+
+	// Source 规则来源文件
+	Source string `yaml:"-" toml:"-"`
+
+	regex   *regexp.Regexp
+	timeout time.Duration
+	baseDir string
+}
+
+// policyFile 策略文件结构
+type policyFile struct {
+	Rules []*Rule `yaml:"rules" toml:"rules"`
+	// Writes 文件写入规则，只按 paths 匹配
+	Writes []*Rule `yaml:"writes" toml:"writes"`
+	// TrustedProjects 信任的项目目录（支持 glob），只在用户级策略中生效
+	TrustedProjects []string `yaml:"trusted_projects" toml:"trusted_projects"`
+}
+
+// Subject 待评估的子命令
+type Subject struct {
+	// Name 命令名（已去除路径）
+	Name string
+	// Wrappers 包装命令，如 sudo、env
+	Wrappers []string
+	// Args 命令参数
+	Args []string
+	// Paths 命令涉及的路径（参数中的路径和重定向目标）
+	Paths []string
+	// Text 子命令的完整文本
+	Text string
+}
+
+// Decision 策略评估结果
+type Decision struct {
+	// Action 命中的动作，未命中任何规则时为空
+	Action Action
+	// Rule 决定结果的规则
+	Rule *Rule
+	// Subject 被评估的子命令
+	Subject Subject
+}
+
+// Matched 是否命中了规则
+func (d Decision) Matched() bool {
+	return d.Rule != nil
+}
+
+// Explain 返回可读的命中说明
+func (d Decision) Explain() string {
+	if d.Rule == nil {
+		return "未命中任何策略规则"
+	}
+	explain := fmt.Sprintf("规则 '%s' (%s) -> %s", d.Rule.Name, d.Rule.Source, d.Action)
+	if d.Rule.Reason != "" {
+		explain += ": " + d.Rule.Reason
+	}
+	return explain
+}
+
+// Policy 命令策略，由一个或多个策略文件合并而成
+type Policy struct {
+	// Rules 按加载顺序排列的规则，用户级规则在前
+	Rules []*Rule
+	// Writes 文件写入规则，顺序同 Rules，内置规则在最后
+	Writes []*Rule
+	// Files 已加载的策略文件
+	Files []string
+	// TrustedProjects 用户级策略中信任的项目目录
+	TrustedProjects []string
+	// Warnings 加载时被忽略的规则和设置
+	Warnings []string
+}
+
+// Load 从默认位置加载策略：用户级 ~/.config/aishell/policy.* 和项目级 .aishell/policy.*。
+// 项目目录不在用户级 trusted_projects 中时，项目策略中的 allow 规则被忽略，只能让结果更严格。
+func Load() (*Policy, error) {
+	var files []string
+	if file := findPolicyFile(utils.ConfigDir()); file != "" {
+		files = append(files, file)
+	}
+	p, err := LoadFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+	if dir := utils.FindProjectConfigDir(); dir != "" {
+		if file := findPolicyFile(dir); file != "" {
+			if err := p.loadProject(file, filepath.Dir(dir)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p.Writes = append(p.Writes, BuiltinWriteRules(utils.FindProjectRoot())...)
+	return p, nil
+}
+
+// LoadFiles 按顺序加载指定的策略文件，全部视为用户级策略
+func LoadFiles(paths ...string) (*Policy, error) {
+	p := &Policy{}
+	for _, path := range paths {
+		file, err := readPolicyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p.Rules = append(p.Rules, file.Rules...)
+		p.Writes = append(p.Writes, file.Writes...)
+		p.TrustedProjects = append(p.TrustedProjects, file.TrustedProjects...)
+		p.Files = append(p.Files, path)
+	}
+	return p, nil
+}
+
+// loadProject 加载项目 root 的策略文件，追加在用户级规则之后
+func (p *Policy) loadProject(path, root string) error {
+	file, err := readPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	if len(file.TrustedProjects) > 0 {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s: trusted_projects 只能在用户级策略中设置，已忽略", path))
+	}
+	trusted := p.Trusts(root)
+	keep := func(rules []*Rule, kind string) []*Rule {
+		var kept []*Rule
+		for _, rule := range rules {
+			if rule.Action == ActionAllow && !trusted {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("%s: 项目未受信任，已忽略 allow %s '%s'", path, kind, rule.Name))
+				continue
+			}
+			kept = append(kept, rule)
+		}
+		return kept
+	}
+	p.Rules = append(p.Rules, keep(file.Rules, "规则")...)
+	p.Writes = append(p.Writes, keep(file.Writes, "写入规则")...)
+	p.Files = append(p.Files, path)
+	return nil
+}
+
+// Trusts 判断项目目录是否在用户级策略的 trusted_projects 中
+func (p *Policy) Trusts(root string) bool {
+	if p == nil || root == "" {
+		return false
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	for _, pattern := range p.TrustedProjects {
+		pattern = utils.ExpandHome(strings.TrimSpace(pattern))
+		if pattern == "" || !filepath.IsAbs(pattern) {
+			continue
+		}
+		if MatchGlob(filepath.Clean(pattern), root) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPolicyFile 读取并解析策略文件
+func readPolicyFile(path string) (*policyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取策略文件失败: %w", err)
+	}
+	return parse(data, filepath.Ext(path), path)
+}
+
+// Parse 解析策略内容中的命令规则，format 为文件扩展名（.yaml/.yml/.toml）
+func Parse(data []byte, format, source string) ([]*Rule, error) {
+	file, err := parse(data, format, source)
+	if err != nil {
+		return nil, err
+	}
+	return file.Rules, nil
+}
+
+// parse 解析策略内容中的命令规则和文件写入规则
+func parse(data []byte, format, source string) (*policyFile, error) {
+	var file policyFile
+	var err error
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "yaml", "yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case "toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	default:
+		return nil, fmt.Errorf("不支持的策略文件格式: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析策略文件 %s 失败: %w", source, err)
+	}
+
+	baseDir := ""
+	if source != "" {
+		// 项目级策略中的相对路径以项目根目录为基准
+		dir := filepath.Dir(source)
+		if filepath.Base(dir) == utils.ProjectConfigDirName {
+			dir = filepath.Dir(dir)
+		}
+		baseDir, _ = filepath.Abs(dir)
+	}
+
+	for i, rule := range file.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("%s: 第%d条规则为空", source, i+1)
+		}
+		rule.Source = source
+		rule.baseDir = baseDir
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule#%d", i+1)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("%s: 规则 '%s' 无效: %w", source, rule.Name, err)
+		}
+	}
+	for i, rule := range file.Writes {
+		if rule == nil {
+			return nil, fmt.Errorf("%s: 第%d条写入规则为空", source, i+1)
+		}
+		rule.Source = source
+		rule.baseDir = baseDir
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("write#%d", i+1)
+		}
+		if err := rule.compileWrite(); err != nil {
+			return nil, fmt.Errorf("%s: 写入规则 '%s' 无效: %w", source, rule.Name, err)
+		}
+	}
+
+	return &file, nil
+}
+
+// compile 校验规则并预编译正则
+func (r *Rule) compile() error {
+	r.Action = Action(strings.ToLower(string(r.Action)))
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("超时时间格式错误 '%s'，应为如 90s、10m 的正数时长", r.Timeout)
+		}
+		r.timeout = timeout
+	}
+	// 只设置超时的规则不改变动作判定
+	if r.Action == "" && r.timeout > 0 {
+		return r.compileMatchers()
+	}
+	if _, ok := actionPriority[r.Action]; !ok {
+		return fmt.Errorf("未知动作 '%s'，必须是 allow、confirm 或 deny", r.Action)
+	}
+	return r.compileMatchers()
+}
+
+// compileMatchers 校验匹配条件并预编译正则
+func (r *Rule) compileMatchers() error {
+	if len(r.Commands) == 0 && len(r.Args) == 0 && r.Regex == "" && len(r.Paths) == 0 {
+		return fmt.Errorf("至少需要 commands、args、regex、paths 中的一个匹配条件")
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("正则表达式错误: %w", err)
+		}
+		r.regex = re
+	}
+	return nil
+}
+
+// Matches 判断规则是否命中子命令
+func (r *Rule) Matches(sub Subject) bool {
+	if len(r.Commands) > 0 && !r.matchCommand(sub) {
+		return false
+	}
+	for _, pattern := range r.Args {
+		if !matchAny(pattern, sub.Args) {
+			return false
+		}
+	}
+	if r.regex != nil && !r.regex.MatchString(sub.Text) {
+		return false
+	}
+	if len(r.Paths) > 0 && !r.matchPaths(sub.Paths) {
+		return false
+	}
+	return true
+}
+
+// matchCommand 匹配命令名或包装命令名
+func (r *Rule) matchCommand(sub Subject) bool {
+	names := []string{sub.Name}
+	for _, w := range sub.Wrappers {
+		names = append(names, filepath.Base(w))
+	}
+	for _, pattern := range r.Commands {
+		if matchAny(pattern, names) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPaths 匹配目标路径
+func (r *Rule) matchPaths(paths []string) bool {
+	for _, pattern := range r.Paths {
+		pattern = r.resolvePattern(pattern)
+		for _, path := range paths {
+			if MatchGlob(pattern, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolvePattern 展开规则路径中的 ~ 和相对路径
+func (r *Rule) resolvePattern(pattern string) string {
+	pattern = utils.ExpandHome(pattern)
+	if !filepath.IsAbs(pattern) && r.baseDir != "" {
+		return filepath.Join(r.baseDir, pattern)
+	}
+	return pattern
+}
+
+// matchAny 判断任意一个值匹配 glob 模式
+func matchAny(pattern string, values []string) bool {
+	for _, v := range values {
+		if MatchGlob(pattern, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate 评估单个子命令。多条规则命中时取最严格的动作（deny > confirm > allow），
+// 同一动作取最先加载的规则（用户级优先于项目级）。
+func (p *Policy) Evaluate(sub Subject) Decision {
+	decision := Decision{Subject: sub}
+	if p == nil {
+		return decision
+	}
+	for _, rule := range p.Rules {
+		if rule.Action == "" || !rule.Matches(sub) {
+			continue
+		}
+		if decision.Rule == nil || actionPriority[rule.Action] > actionPriority[decision.Action] {
+			decision.Rule = rule
+			decision.Action = rule.Action
+		}
+	}
+	return decision
+}
+
+// TimeoutFor 返回命中规则中最长的超时时间，没有规则设置超时时返回 0
+func (p *Policy) TimeoutFor(sub Subject) time.Duration {
+	if p == nil {
+		return 0
+	}
+	var longest time.Duration
+	for _, rule := range p.Rules {
+		if rule.timeout > longest && rule.Matches(sub) {
+			longest = rule.timeout
+		}
+	}
+	return longest
+}
+
+// IsEmpty 策略是否没有任何规则
+func (p *Policy) IsEmpty() bool {
+	return p == nil || len(p.Rules) == 0
+}
+
+// findPolicyFile 在目录中查找策略文件
+func findPolicyFile(dir string) string {
+	for _, name := range policyFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// SourceBuiltin 内置规则的来源
+const SourceBuiltin = "内置"
+
+// compileWrite 校验文件写入规则：必须有动作和路径，不支持命令相关的匹配条件
+func (r *Rule) compileWrite() error {
+	if len(r.Commands) > 0 || len(r.Args) > 0 || r.Regex != "" || r.Timeout != "" {
+		return fmt.Errorf("写入规则只支持 paths，不支持 commands、args、regex、timeout")
+	}
+	if len(r.Paths) == 0 {
+		return fmt.Errorf("写入规则需要 paths")
+	}
+	return r.compile()
+}
+
+// BuiltinWriteRules 内置写入规则：/etc 下的文件总是确认，项目目录内的文件直接写入。
+// projectRoot 为空时不添加项目规则。
+func BuiltinWriteRules(projectRoot string) []*Rule {
+	rules := []*Rule{{
+		Name:   "system-config",
+		Action: ActionConfirm,
+		Paths:  []string{"/etc/**"},
+		Reason: "系统配置文件",
+		Source: SourceBuiltin,
+	}}
+	if projectRoot != "" {
+		rules = append(rules, &Rule{
+			Name:   "project",
+			Action: ActionAllow,
+			Paths:  []string{filepath.Join(projectRoot, "**")},
+			Reason: "项目目录 " + projectRoot,
+			Source: SourceBuiltin,
+		})
+	}
+	return rules
+}
+
+// EvaluateWrite 评估写入文件 path 是否需要确认。多条规则命中时取最严格的动作，
+// 因此任何 allow 规则都不能降低内置的 confirm；未命中任何规则时 Action 为空，由调用方决定默认行为。
+func (p *Policy) EvaluateWrite(path string) Decision {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sub := Subject{Paths: []string{path}, Text: path}
+	decision := Decision{Subject: sub}
+	if p == nil {
+		return decision
+	}
+	for _, rule := range p.Writes {
+		if !rule.Matches(sub) {
+			continue
+		}
+		if decision.Rule == nil || actionPriority[rule.Action] > actionPriority[decision.Action] {
+			decision.Rule = rule
+			decision.Action = rule.Action
+		}
+	}
+	return decision
+}
@@ -8,6 +8,26 @@ import (
 
 // CreateSystemPrompt 创建智能终端助手的专用系统提示
 func CreateSystemPrompt() string {
+	return basePrompt() + `
+
+工具列表：
+------
+
+你可以使用以下工具来帮助用户：
+
+{{.tool_descriptions}}`
+}
+
+// CreateFunctionCallingPrompt 创建函数调用代理使用的系统提示，工具定义通过函数调用接口提供
+func CreateFunctionCallingPrompt() string {
+	return basePrompt() + `
+- 通过函数调用使用工具，参数必须符合工具的参数定义
+- 互不依赖的操作（如同时读取多个文件）可以在一次回复中并行调用多个工具
+- 得到足够的信息后直接用自然语言回答用户，不要再调用工具`
+}
+
+// basePrompt 两种代理共用的系统提示主体
+func basePrompt() string {
 	// 获取完整的环境信息用于系统提示
 	currentDir, currentOS, currentArch, currentTime := utils.GetEnvironmentInfo()
 
@@ -30,14 +50,7 @@ func CreateSystemPrompt() string {
 - 使用计算器工具进行数学运算和数据分析
 - 如果有搜索工具可用，利用它获取最新的技术信息
 - 始终考虑用户的操作系统兼容性
-- 在处理文件和目录操作时考虑当前工作目录的上下文
-
-工具列表：
-------
-
-你可以使用以下工具来帮助用户：
-
-{{.tool_descriptions}}`, currentOS, currentArch, currentDir, currentTime)
+- 在处理文件和目录操作时考虑当前工作目录的上下文`, currentOS, currentArch, currentDir, currentTime)
 
 	return systemPromptPrefix
 }
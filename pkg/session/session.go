@@ -0,0 +1,180 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// 消息角色
+const (
+	RoleHuman = "human"
+	RoleAI    = "ai"
+	RoleTool  = "tool"
+)
+
+// Session 一次可恢复的对话：消息、工具调用及结果、工作目录和使用的模型
+type Session struct {
+	// ID 会话标识，按创建时间排序
+	ID string `json:"id"`
+	// Title 会话标题，取第一条用户输入
+	Title string `json:"title"`
+	// Created 创建时间
+	Created time.Time `json:"created"`
+	// Updated 最后更新时间
+	Updated time.Time `json:"updated"`
+	// Cwd 最后一次保存时的工作目录
+	Cwd string `json:"cwd"`
+	// Provider 模型提供方
+	Provider string `json:"provider"`
+	// Model 模型名称
+	Model string `json:"model"`
+	// Agent 代理类型
+	Agent string `json:"agent"`
+	// Messages 对话消息
+	Messages []Message `json:"messages"`
+}
+
+// Message 一条对话消息
+type Message struct {
+	// Role 角色：human、ai 或 tool
+	Role string `json:"role"`
+	// Content 文本内容；tool 消息为工具结果
+	Content string `json:"content,omitempty"`
+	// ToolCalls 模型发起的工具调用（ai 消息）
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID 对应的工具调用 ID（tool 消息）
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// ToolName 工具名称（tool 消息）
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// ToolCall 一次工具调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// New 创建新会话
+func New(provider, model, agent string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:       newID(now),
+		Created:  now,
+		Updated:  now,
+		Provider: provider,
+		Model:    model,
+		Agent:    agent,
+	}
+}
+
+// newID 生成会话 ID：时间戳加随机后缀，字典序即创建顺序
+func newID(now time.Time) string {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// SetMessages 用代理的对话历史更新会话，标题取第一条用户输入
+func (s *Session) SetMessages(history []llms.MessageContent) {
+	s.Messages = FromLLM(history)
+	if s.Title == "" {
+		for _, m := range s.Messages {
+			if m.Role == RoleHuman {
+				s.Title = titleOf(m.Content)
+				break
+			}
+		}
+	}
+}
+
+// titleOf 把用户输入压缩为一行标题
+func titleOf(text string) string {
+	title := strings.Join(strings.Fields(text), " ")
+	if runes := []rune(title); len(runes) > 60 {
+		title = string(runes[:60]) + "…"
+	}
+	return title
+}
+
+// Turns 返回用户输入的条数
+func (s *Session) Turns() int {
+	turns := 0
+	for _, m := range s.Messages {
+		if m.Role == RoleHuman {
+			turns++
+		}
+	}
+	return turns
+}
+
+// FromLLM 把 langchaingo 的消息转换为可保存的消息，系统消息不保存
+func FromLLM(history []llms.MessageContent) []Message {
+	messages := make([]Message, 0, len(history))
+	for _, mc := range history {
+		switch mc.Role {
+		case llms.ChatMessageTypeHuman, llms.ChatMessageTypeGeneric:
+			messages = append(messages, Message{Role: RoleHuman, Content: textOf(mc.Parts)})
+		case llms.ChatMessageTypeAI:
+			m := Message{Role: RoleAI, Content: textOf(mc.Parts)}
+			for _, part := range mc.Parts {
+				if call, ok := part.(llms.ToolCall); ok && call.FunctionCall != nil {
+					m.ToolCalls = append(m.ToolCalls, ToolCall{ID: call.ID, Name: call.FunctionCall.Name, Arguments: call.FunctionCall.Arguments})
+				}
+			}
+			messages = append(messages, m)
+		case llms.ChatMessageTypeTool:
+			for _, part := range mc.Parts {
+				if resp, ok := part.(llms.ToolCallResponse); ok {
+					messages = append(messages, Message{Role: RoleTool, Content: resp.Content, ToolCallID: resp.ToolCallID, ToolName: resp.Name})
+				}
+			}
+		}
+	}
+	return messages
+}
+
+// ToLLM 把保存的消息还原为 langchaingo 的消息
+func ToLLM(messages []Message) []llms.MessageContent {
+	history := make([]llms.MessageContent, 0, len(messages))
+	for _, m := range messages {
+		switch m.Role {
+		case RoleHuman:
+			history = append(history, llms.TextParts(llms.ChatMessageTypeHuman, m.Content))
+		case RoleAI:
+			mc := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+			if m.Content != "" || len(m.ToolCalls) == 0 {
+				mc.Parts = append(mc.Parts, llms.TextContent{Text: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				mc.Parts = append(mc.Parts, llms.ToolCall{
+					ID:           call.ID,
+					Type:         "function",
+					FunctionCall: &llms.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+				})
+			}
+			history = append(history, mc)
+		case RoleTool:
+			history = append(history, llms.MessageContent{
+				Role:  llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: m.ToolCallID, Name: m.ToolName, Content: m.Content}},
+			})
+		}
+	}
+	return history
+}
+
+// textOf 拼接消息中的文本部分
+func textOf(parts []llms.ContentPart) string {
+	var texts []string
+	for _, part := range parts {
+		if text, ok := part.(llms.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// sampleHistory 包含一次工具调用的对话
+func sampleHistory() []llms.MessageContent {
+	return []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, "磁盘还剩多少空间？"),
+		{
+			Role: llms.ChatMessageTypeAI,
+			Parts: []llms.ContentPart{llms.ToolCall{
+				ID:           "call_1",
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: "system_command", Arguments: `{"command":"df -h"}`},
+			}},
+		},
+		{
+			Role:  llms.ChatMessageTypeTool,
+			Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "call_1", Name: "system_command", Content: "/dev/sda1  100G  40G"}},
+		},
+		llms.TextParts(llms.ChatMessageTypeAI, "还剩 60G。"),
+	}
+}
+
+func TestMessages_RoundTrip(t *testing.T) {
+	history := sampleHistory()
+	messages := FromLLM(history)
+	if len(messages) != 4 {
+		t.Fatalf("期望 4 条消息，得到 %d", len(messages))
+	}
+	if call := messages[1].ToolCalls; len(call) != 1 || call[0].Name != "system_command" || call[0].Arguments != `{"command":"df -h"}` {
+		t.Errorf("工具调用未保存: %+v", messages[1])
+	}
+	if messages[2].Role != RoleTool || messages[2].ToolCallID != "call_1" {
+		t.Errorf("工具结果未保存: %+v", messages[2])
+	}
+
+	if restored := ToLLM(messages); !reflect.DeepEqual(restored, history) {
+		t.Errorf("还原结果不一致:\n%#v\n%#v", restored, history)
+	}
+}
+
+func TestSession_Title(t *testing.T) {
+	sess := New("openai", "gpt-4o", "functions")
+	sess.SetMessages(sampleHistory())
+	if sess.Title != "磁盘还剩多少空间？" || sess.Turns() != 1 {
+		t.Errorf("标题或轮数错误: %q %d", sess.Title, sess.Turns())
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "sessions"))
+
+	if _, err := store.Latest(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("空目录应返回 ErrNotFound，得到 %v", err)
+	}
+
+	older := New("openai", "gpt-4o", "functions")
+	older.ID = "20260101-090000-aaaa"
+	older.SetMessages(sampleHistory())
+	older.Updated = time.Now().Add(-time.Hour)
+	newer := New("anthropic", "claude", "functions")
+	newer.ID = "20260102-090000-bbbb"
+	newer.SetMessages(sampleHistory()[:1])
+	for _, sess := range []*Session{older, newer} {
+		if err := store.Save(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 损坏的文件和临时文件不影响列表
+	os.WriteFile(filepath.Join(store.Dir, "broken.json"), []byte("{"), 0600)
+	os.WriteFile(filepath.Join(store.Dir, ".x.tmp"), []byte("{}"), 0600)
+
+	summaries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].ID != newer.ID {
+		t.Fatalf("列表应按更新时间倒序: %+v", summaries)
+	}
+
+	latest, err := store.Latest()
+	if err != nil || latest.ID != newer.ID {
+		t.Fatalf("Latest 应返回最近的会话: %v %v", latest, err)
+	}
+
+	loaded, err := store.Load("20260101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Messages, older.Messages) || loaded.Model != "gpt-4o" {
+		t.Errorf("加载的会话不一致: %+v", loaded)
+	}
+
+	if _, err := store.Load("2026"); err == nil || !strings.Contains(err.Error(), "不唯一") {
+		t.Errorf("不唯一的前缀应报错，得到 %v", err)
+	}
+	if _, err := store.Load("../x"); err == nil {
+		t.Error("包含路径分隔符的 ID 应报错")
+	}
+
+	deleted, err := store.Delete("20260102")
+	if err != nil || deleted != newer.ID {
+		t.Fatalf("删除失败: %s %v", deleted, err)
+	}
+	if _, err := store.Load(newer.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后应找不到会话，得到 %v", err)
+	}
+}
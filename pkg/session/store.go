@@ -0,0 +1,180 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("会话不存在")
+
+// Store 会话存储，每个会话保存为目录下的一个 JSON 文件
+type Store struct {
+	Dir string
+}
+
+// NewStore 创建会话存储
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// DefaultDir 默认会话目录 (~/.local/share/aishell/sessions)
+func DefaultDir() string {
+	return filepath.Join(utils.DataDir(), "sessions")
+}
+
+// Summary 会话摘要，用于列表显示
+type Summary struct {
+	ID      string
+	Title   string
+	Updated time.Time
+	Cwd     string
+	Model   string
+	Turns   int
+}
+
+// path 会话文件路径
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+// Save 保存会话。先写临时文件再重命名，避免中途退出留下损坏的文件。
+func (s *Store) Save(sess *Session) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("创建会话目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化会话失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, "."+sess.ID+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("保存会话失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存会话失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存会话失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(sess.ID)); err != nil {
+		return fmt.Errorf("保存会话失败: %w", err)
+	}
+	return nil
+}
+
+// Load 按 ID 加载会话，ID 可以是唯一的前缀
+func (s *Store) Load(id string) (*Session, error) {
+	id, err := s.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("读取会话失败: %w", err)
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("解析会话 %s 失败: %w", id, err)
+	}
+	return &sess, nil
+}
+
+// Latest 加载最近更新的会话
+func (s *Store) Latest() (*Session, error) {
+	summaries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, ErrNotFound
+	}
+	return s.Load(summaries[0].ID)
+}
+
+// Delete 删除会话，ID 可以是唯一的前缀，返回实际删除的会话 ID
+func (s *Store) Delete(id string) (string, error) {
+	id, err := s.resolve(id)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(s.path(id)); err != nil {
+		return "", fmt.Errorf("删除会话失败: %w", err)
+	}
+	return id, nil
+}
+
+// List 列出全部会话，最近更新的在前。无法解析的文件会被跳过。
+func (s *Store) List() ([]Summary, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取会话目录失败: %w", err)
+	}
+
+	var summaries []Summary
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		sess, err := s.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, Summary{
+			ID:      sess.ID,
+			Title:   sess.Title,
+			Updated: sess.Updated,
+			Cwd:     sess.Cwd,
+			Model:   sess.Model,
+			Turns:   sess.Turns(),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Updated.After(summaries[j].Updated)
+	})
+	return summaries, nil
+}
+
+// resolve 把 ID 或唯一前缀解析为完整 ID
+func (s *Store) resolve(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("无效的会话 ID: %q", id)
+	}
+	if _, err := os.Stat(s.path(id)); err == nil {
+		return id, nil
+	}
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("读取会话目录失败: %w", err)
+	}
+	var matches []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if !strings.HasPrefix(entry.Name(), ".") && strings.HasPrefix(name, id) && name != entry.Name() {
+			matches = append(matches, name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("会话 ID 前缀 %s 不唯一，匹配: %s", id, strings.Join(matches, ", "))
+}
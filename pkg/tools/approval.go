@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/audit"
+)
+
+// ApprovalMode 危险操作的确认方式
+type ApprovalMode int
+
+const (
+	// ApprovalAsk 交互询问用户（默认）
+	ApprovalAsk ApprovalMode = iota
+	// ApprovalYes 自动同意（--yes）
+	ApprovalYes
+	// ApprovalNo 自动拒绝（--no，没有终端可询问时的默认值）
+	ApprovalNo
+)
+
+// Approver 危险操作的确认器，所有需要用户确认的工具共用同一个实例，
+// 交互模式下从终端读取回答，非交互模式下按 --yes/--no 自动决定。
+type Approver struct {
+	// Mode 确认方式
+	Mode ApprovalMode
+	// In 读取回答的位置，默认为标准输入
+	In io.Reader
+	// Out 显示警告和问题的位置，默认为标准输出
+	Out io.Writer
+
+	mu       sync.Mutex
+	declined int
+	// reader 在多次询问之间复用，避免预读的回答丢失
+	reader *bufio.Reader
+}
+
+// NewApprover 创建交互询问的确认器
+func NewApprover() *Approver {
+	return &Approver{Mode: ApprovalAsk, In: os.Stdin, Out: os.Stdout}
+}
+
+// Writer 返回显示警告的位置，nil 确认器使用标准输出
+func (a *Approver) Writer() io.Writer {
+	if a == nil || a.Out == nil {
+		return os.Stdout
+	}
+	return a.Out
+}
+
+// Confirm 询问是否继续，返回用户（或 --yes/--no 策略）的决定。nil 确认器按交互模式处理。
+func (a *Approver) Confirm(question string) bool {
+	if a == nil {
+		a = NewApprover()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := a.Writer()
+	red := color.New(color.FgRed)
+	yellow := color.New(color.FgYellow, color.Bold)
+
+	switch a.Mode {
+	case ApprovalYes:
+		yellow.Fprintf(out, "⚠️  %s 已通过 --yes 自动确认\n", question)
+		return true
+	case ApprovalNo:
+		red.Fprintf(out, "⛔ %s 非交互模式下自动拒绝（使用 --yes 允许）\n", question)
+		a.declined++
+		return false
+	}
+
+	for {
+		color.New(color.FgGreen).Fprintf(out, "%s [yes/no]: ", question)
+		answer, ok := a.readAnswer()
+		if !ok {
+			fmt.Fprintln(out)
+			a.declined++
+			return false
+		}
+		switch answer {
+		case "yes", "y", "是", "确定":
+			return true
+		case "no", "n", "否", "取消":
+			a.declined++
+			return false
+		default:
+			red.Fprintln(out, "❌ 请输入 'yes' 或 'no' (或 'y'/'n')")
+		}
+	}
+}
+
+// readAnswer 读取一行回答并转为小写，没有更多输入时返回 false
+func (a *Approver) readAnswer() (string, bool) {
+	if a.reader == nil {
+		in := a.In
+		if in == nil {
+			in = os.Stdin
+		}
+		a.reader = bufio.NewReader(in)
+	}
+	line, err := a.reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(line)), true
+}
+
+// ReviewChoice 对文件改动的决定
+type ReviewChoice int
+
+const (
+	// ReviewApprove 按当前内容写入
+	ReviewApprove ReviewChoice = iota
+	// ReviewReject 放弃写入
+	ReviewReject
+	// ReviewEdit 在编辑器中修改后再决定
+	ReviewEdit
+)
+
+// Review 询问是否接受文件改动，交互模式下可以选择在编辑器中修改。
+// --yes 时直接接受，--no 或没有输入时拒绝。
+func (a *Approver) Review(question string) ReviewChoice {
+	if a == nil {
+		a = NewApprover()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := a.Writer()
+	red := color.New(color.FgRed)
+
+	switch a.Mode {
+	case ApprovalYes:
+		color.New(color.FgYellow, color.Bold).Fprintf(out, "⚠️  %s 已通过 --yes 自动确认\n", question)
+		return ReviewApprove
+	case ApprovalNo:
+		red.Fprintf(out, "⛔ %s 非交互模式下自动拒绝（使用 --yes 允许）\n", question)
+		a.declined++
+		return ReviewReject
+	}
+
+	for {
+		color.New(color.FgGreen).Fprintf(out, "%s [yes/no/edit]: ", question)
+		answer, ok := a.readAnswer()
+		if !ok {
+			fmt.Fprintln(out)
+			a.declined++
+			return ReviewReject
+		}
+		switch answer {
+		case "yes", "y", "是", "确定":
+			return ReviewApprove
+		case "no", "n", "否", "取消":
+			a.declined++
+			return ReviewReject
+		case "edit", "e", "编辑":
+			return ReviewEdit
+		default:
+			red.Fprintln(out, "❌ 请输入 'yes'、'no' 或 'edit' (或 'y'/'n'/'e')")
+		}
+	}
+}
+
+// Decision 把确认结果转换为审计日志中的记录值
+func (a *Approver) Decision(approved bool) string {
+	mode := ApprovalAsk
+	if a != nil {
+		mode = a.Mode
+	}
+	switch {
+	case approved && mode == ApprovalYes:
+		return audit.ApprovalAutoApproved
+	case approved:
+		return audit.ApprovalApproved
+	case mode == ApprovalNo:
+		return audit.ApprovalAutoDeclined
+	}
+	return audit.ApprovalDeclined
+}
+
+// Declined 返回被拒绝的确认次数
+func (a *Approver) Declined() int {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.declined
+}
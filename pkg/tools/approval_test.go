@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dean2027/aishell/pkg/audit"
+)
+
+func TestApprover_Modes(t *testing.T) {
+	var out bytes.Buffer
+
+	yes := &Approver{Mode: ApprovalYes, Out: &out}
+	if !yes.Confirm("继续?") || yes.Declined() != 0 {
+		t.Error("ApprovalYes 应自动同意")
+	}
+
+	no := &Approver{Mode: ApprovalNo, Out: &out}
+	if no.Confirm("继续?") || no.Declined() != 1 {
+		t.Error("ApprovalNo 应自动拒绝并计数")
+	}
+
+	ask := &Approver{Mode: ApprovalAsk, In: strings.NewReader("maybe\ny\n"), Out: &out}
+	if !ask.Confirm("继续?") {
+		t.Error("回答 y 应同意")
+	}
+	if !strings.Contains(out.String(), "请输入 'yes' 或 'no'") {
+		t.Error("无效回答应提示重新输入")
+	}
+
+	eof := &Approver{Mode: ApprovalAsk, In: strings.NewReader(""), Out: &out}
+	if eof.Confirm("继续?") || eof.Declined() != 1 {
+		t.Error("没有输入时应视为拒绝")
+	}
+}
+
+func TestSystemCommand_ApprovalNoSkipsDangerousCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 POSIX shell")
+	}
+	target := filepath.Join(t.TempDir(), "keep.txt")
+	if err := os.WriteFile(target, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cmd := NewSystemCommand()
+	cmd.Output = &out
+	cmd.Approver = &Approver{Mode: ApprovalNo, Out: &out}
+
+	result, err := cmd.Call(context.Background(), "rm "+target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "已被取消") {
+		t.Errorf("危险命令应被取消，得到 %q", result)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Error("被拒绝的命令不应执行")
+	}
+	if cmd.Approver.Declined() != 1 {
+		t.Error("拒绝次数应为 1")
+	}
+}
+
+func TestSystemCommand_AuditRecordsDecision(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := audit.Open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	var out bytes.Buffer
+	cmd := NewSystemCommand()
+	cmd.Output = &out
+	cmd.Approver = &Approver{Mode: ApprovalNo, Out: &out}
+	cmd.Audit = logger
+
+	cmd.Call(context.Background(), "rm -rf "+filepath.Join(t.TempDir(), "x"))
+	cmd.Call(context.Background(), "echo hi")
+
+	records, err := audit.Read(path, audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("期望 2 条审计记录，得到 %d", len(records))
+	}
+	if r := records[0]; r.Status != audit.StatusDeclined || r.Approval != audit.ApprovalAutoDeclined || r.ExitCode != nil {
+		t.Errorf("被拒绝的命令记录错误: %+v", r)
+	}
+	if r := records[1]; r.Status != audit.StatusOK || r.ExitCode == nil || *r.ExitCode != 0 || r.Cwd == "" {
+		t.Errorf("成功的命令记录错误: %+v", r)
+	}
+}
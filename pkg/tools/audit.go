@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dean2027/aishell/pkg/audit"
+)
+
+// audited 执行工具调用并写入审计日志，logger 为 nil 时直接调用。
+// 工具通过上下文中的 CallInfo 报告确认结果、退出码和未执行的原因；调用方没有提供时在这里创建。
+// 审计日志写入失败只在标准错误给出警告，不影响工具调用本身。
+func audited(ctx context.Context, logger *audit.Logger, tool, cwd, input string, call func(context.Context) (string, error)) (string, error) {
+	if logger == nil {
+		return call(ctx)
+	}
+	info := CallInfoFrom(ctx)
+	if info == nil {
+		ctx, info = WithCallInfo(ctx)
+	}
+
+	start := time.Now()
+	output, err := call(ctx)
+
+	record := audit.Record{
+		Cwd:        cwd,
+		Tool:       tool,
+		Input:      input,
+		Approval:   info.Approval,
+		Status:     info.Status,
+		ExitCode:   info.ExitCode,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	record.SetOutput(output)
+	if record.Approval == "" {
+		record.Approval = audit.ApprovalNotRequired
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if record.Status == "" {
+		switch {
+		case err != nil:
+			record.Status = audit.StatusError
+		case info.ExitCode != nil && *info.ExitCode != 0:
+			record.Status = audit.StatusFailed
+		default:
+			record.Status = audit.StatusOK
+		}
+	}
+	if logErr := logger.Log(record); logErr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", logErr)
+	}
+	return output, err
+}
+
+// workingDir 返回当前工作目录，获取失败时返回空字符串
+func workingDir() string {
+	dir, _ := os.Getwd()
+	return dir
+}
@@ -0,0 +1,50 @@
+package tools
+
+import "context"
+
+// CallInfo 一次工具调用的附加信息。调用方通过 WithCallInfo 放入上下文，工具按需填写，
+// 用于在结构化输出中报告工具返回文本之外的结果（如命令退出码）。
+type CallInfo struct {
+	// ExitCode 系统命令的退出码，没有执行命令时为 nil
+	ExitCode *int
+	// Approval 危险操作的确认结果（audit.Approval*），不需要确认时为空
+	Approval string
+	// Status 未执行时的原因（audit.StatusDeclined 等），为空时按错误和退出码判断
+	Status string
+}
+
+// callInfoKey 上下文中 CallInfo 的键
+type callInfoKey struct{}
+
+// WithCallInfo 返回携带新 CallInfo 的上下文
+func WithCallInfo(ctx context.Context) (context.Context, *CallInfo) {
+	info := &CallInfo{}
+	return context.WithValue(ctx, callInfoKey{}, info), info
+}
+
+// CallInfoFrom 取出上下文中的 CallInfo，没有时返回 nil
+func CallInfoFrom(ctx context.Context) *CallInfo {
+	info, _ := ctx.Value(callInfoKey{}).(*CallInfo)
+	return info
+}
+
+// SetExitCode 记录命令退出码，info 为 nil 时忽略
+func (info *CallInfo) SetExitCode(code int) {
+	if info != nil {
+		info.ExitCode = &code
+	}
+}
+
+// SetApproval 记录确认结果，info 为 nil 时忽略
+func (info *CallInfo) SetApproval(approval string) {
+	if info != nil {
+		info.Approval = approval
+	}
+}
+
+// SetStatus 记录未执行的原因，info 为 nil 时忽略
+func (info *CallInfo) SetStatus(status string) {
+	if info != nil {
+		info.Status = status
+	}
+}
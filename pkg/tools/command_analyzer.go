@@ -0,0 +1,1197 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dean2027/aishell/pkg/policy"
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// Redirect 子命令中的一次重定向
+type Redirect struct {
+	// Op 重定向操作符，如 ">"、">>"、"2>"、"<"
+	Op string
+	// Target 重定向目标（已去除引号）
+	Target string
+	// Body here-document 的正文或 here-string 的内容，作为命令的标准输入
+	Body string
+}
+
+// stdinInput 判断是否为替换标准输入的重定向，返回去掉描述符 0 之后的操作符
+func (r Redirect) stdinInput() (string, bool) {
+	op := strings.TrimPrefix(r.Op, "0")
+	switch op {
+	case "<", "<<", "<<-", "<<<":
+		return op, true
+	}
+	return "", false
+}
+
+// IsOutput 是否为写入型重定向
+func (r Redirect) IsOutput() bool {
+	return strings.Contains(r.Op, ">")
+}
+
+// CommandSegment 从完整命令行中解析出的单个子命令
+type CommandSegment struct {
+	// Name 实际执行的命令名（已去除路径前缀和包装命令）
+	Name string
+	// Path 命令在命令行中的原始写法，如 "/bin/rm"
+	Path string
+	// Args 命令参数（已去除引号）
+	Args []string
+	// Wrappers 包裹在命令外层的包装命令，如 env、nice、xargs、sudo
+	Wrappers []string
+	// Redirects 子命令上的重定向
+	Redirects []Redirect
+	// Context 子命令所处的上下文，如 "命令替换"、"bash -c"，顶层命令为空
+	Context string
+	// Piped 标准输入是否来自前一个命令的管道
+	Piped bool
+}
+
+// String 返回子命令的可读形式
+func (c CommandSegment) String() string {
+	parts := append([]string{}, c.Wrappers...)
+	if c.Path != "" {
+		parts = append(parts, c.Path)
+	}
+	parts = append(parts, c.Args...)
+	for _, r := range c.Redirects {
+		parts = append(parts, r.Op+" "+r.Target)
+	}
+	return strings.Join(parts, " ")
+}
+
+// CommandRisk 分析出的一项风险
+type CommandRisk struct {
+	// Command 触发风险的命令名
+	Command string
+	// Segment 风险所在的子命令
+	Segment string
+	// Reason 风险原因
+	Reason string
+	// ByName 风险只来自命令名在危险命令列表中，命中该子命令的策略 allow 规则可以取消
+	ByName bool
+}
+
+// CommandAnalysis 命令行的结构化分析结果
+type CommandAnalysis struct {
+	// Command 原始命令行
+	Command string
+	// Segments 解析出的全部子命令
+	Segments []CommandSegment
+	// Risks 发现的风险项
+	Risks []CommandRisk
+	// Decisions 每个子命令的策略评估结果，与 Segments 一一对应
+	Decisions []policy.Decision
+	// Denied 被策略拒绝时的决定，为空表示未被拒绝
+	Denied *policy.Decision
+	// ParseError 解析失败时的错误信息
+	ParseError error
+	// Cwd 解析相对路径时使用的工作目录，持久会话中为会话的当前目录
+	Cwd string
+}
+
+// IsDangerous 是否存在需要用户确认的风险
+func (a *CommandAnalysis) IsDangerous() bool {
+	return len(a.Risks) > 0
+}
+
+// DangerousCommands 返回触发风险的命令名（去重，保持顺序）
+func (a *CommandAnalysis) DangerousCommands() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, risk := range a.Risks {
+		if risk.Command == "" || seen[risk.Command] {
+			continue
+		}
+		seen[risk.Command] = true
+		names = append(names, risk.Command)
+	}
+	return names
+}
+
+// systemPathPrefixes 写入即视为危险的系统路径
+var systemPathPrefixes = []string{
+	"/etc", "/boot", "/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64",
+	"/sys", "/proc", "/var/lib", "/var/log", "/var/spool", "/root", "/dev",
+	"C:\\Windows", "C:\\Program Files",
+}
+
+// harmlessDevices 可以安全写入的设备文件
+var harmlessDevices = []string{
+	"/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty", "/dev/zero",
+}
+
+// fileWritingCommands 以参数作为写入目标的命令
+var fileWritingCommands = map[string]bool{
+	"tee": true, "cp": true, "mv": true, "install": true, "ln": true,
+	"truncate": true, "shred": true, "touch": true, "sed": true,
+}
+
+// shellInterpreters 会把 -c 参数当作命令执行的解释器
+var shellInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+}
+
+// scriptInterpreters 没有给出脚本文件时从标准输入读取代码的解释器
+var scriptInterpreters = map[string]bool{
+	"python": true, "python2": true, "python3": true, "perl": true, "ruby": true,
+	"node": true, "php": true,
+}
+
+// inlineCodeOptions 解释器中以参数给出代码或模块、不再读取标准输入的选项
+var inlineCodeOptions = map[string]bool{
+	"-c": true, "-e": true, "-E": true, "-m": true, "-r": true,
+}
+
+// stdinPaths 指向标准输入的特殊文件
+var stdinPaths = []string{"/dev/stdin", "/dev/fd/0", "/proc/self/fd/0"}
+
+// shellBuiltins 常用的 shell 内置命令，定义同名别名或函数会改变它们的行为
+var shellBuiltins = map[string]bool{
+	"cd": true, "echo": true, "printf": true, "pwd": true, "test": true, "[": true,
+	"read": true, "export": true, "unset": true, "set": true, "source": true, ".": true,
+	"eval": true, "exec": true, "exit": true, "alias": true, "unalias": true, "type": true,
+	"command": true, "builtin": true, "kill": true, "trap": true, "true": true, "false": true,
+	"shopt": true, "hash": true, "umask": true, "wait": true,
+}
+
+// reservedWords 出现在子命令开头时应跳过的 shell 关键字
+var reservedWords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"do": true, "done": true, "while": true, "until": true, "!": true,
+	"{": true, "}": true, "time": true, "coproc": true,
+}
+
+// IsSystemPath 判断路径是否位于系统目录下
+func IsSystemPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, device := range harmlessDevices {
+		if path == device {
+			return false
+		}
+	}
+	clean := path
+	if strings.HasPrefix(path, "/") {
+		clean = filepath.Clean(path)
+	}
+	if clean == "/" {
+		return true
+	}
+	for _, prefix := range systemPathPrefixes {
+		if strings.EqualFold(clean, prefix) || strings.HasPrefix(strings.ToLower(clean), strings.ToLower(prefix)+"/") ||
+			strings.HasPrefix(strings.ToLower(clean), strings.ToLower(prefix)+"\\") {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzeCommand 对完整命令行做结构化风险分析
+func (s *SystemCommand) AnalyzeCommand(command string) *CommandAnalysis {
+	analysis := &CommandAnalysis{Command: command, Cwd: s.workDir()}
+
+	segments, err := ParseShellCommand(command)
+	analysis.Segments = segments
+	if err != nil {
+		analysis.ParseError = err
+		analysis.Risks = append(analysis.Risks, CommandRisk{
+			Segment: command,
+			Reason:  fmt.Sprintf("命令无法完整解析(%v)，无法确认其安全性", err),
+		})
+	}
+
+	for _, seg := range segments {
+		risks := s.segmentRisks(seg, analysis.Cwd)
+
+		decision := s.Policy.Evaluate(seg.Subject(analysis.Cwd))
+		analysis.Decisions = append(analysis.Decisions, decision)
+		switch decision.Action {
+		case policy.ActionDeny:
+			if analysis.Denied == nil {
+				denied := decision
+				analysis.Denied = &denied
+			}
+		case policy.ActionAllow:
+			// 策略显式允许时只取消按命令名判断的风险，写入系统路径、包装命令等其他风险照常确认。
+			// 未受信任项目的 allow 规则在加载策略时已被忽略
+			risks = slices.DeleteFunc(risks, func(r CommandRisk) bool { return r.ByName })
+		case policy.ActionConfirm:
+			risks = append(risks, CommandRisk{
+				Command: seg.Name,
+				Segment: seg.String(),
+				Reason:  "策略要求确认: " + decision.Explain(),
+			})
+		}
+
+		analysis.Risks = append(analysis.Risks, risks...)
+	}
+
+	return analysis
+}
+
+// Subject 转换为策略评估对象，相对路径按 cwd 解析，cwd 为空时使用进程的当前目录
+func (c CommandSegment) Subject(cwd string) policy.Subject {
+	sub := policy.Subject{
+		Name:     c.Name,
+		Wrappers: c.Wrappers,
+		Args:     c.Args,
+		Text:     c.String(),
+	}
+	for _, arg := range c.Args {
+		if arg != "" && !strings.HasPrefix(arg, "-") {
+			sub.Paths = append(sub.Paths, absCommandPath(arg, cwd))
+		}
+	}
+	for _, r := range c.Redirects {
+		if r.Target != "" {
+			sub.Paths = append(sub.Paths, absCommandPath(r.Target, cwd))
+		}
+	}
+	return sub
+}
+
+// absCommandPath 将命令中出现的路径按 cwd 转换为绝对路径
+func absCommandPath(path, cwd string) string {
+	path = utils.ExpandHome(path)
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	if cwd == "" {
+		cwd = workingDir()
+	}
+	if cwd == "" {
+		return path
+	}
+	return filepath.Join(cwd, path)
+}
+
+// isSystemTarget 判断命令中的路径按 cwd 解析后是否位于系统目录下
+func isSystemTarget(path, cwd string) bool {
+	if path == "" {
+		return false
+	}
+	if IsSystemPath(path) {
+		return true
+	}
+	abs := absCommandPath(path, cwd)
+	// root 用户的主目录 /root 也在系统目录中，相对路径落在自己的主目录下时不算写入系统路径
+	if home, err := os.UserHomeDir(); err == nil && home != "" && within(home, abs) {
+		return false
+	}
+	return IsSystemPath(abs)
+}
+
+// segmentRisks 分析单个子命令的风险，相对路径按 cwd 解析
+func (s *SystemCommand) segmentRisks(seg CommandSegment, cwd string) []CommandRisk {
+	var risks []CommandRisk
+	text := seg.String()
+	where := ""
+	if seg.Context != "" {
+		where = fmt.Sprintf("（位于%s中）", seg.Context)
+	}
+
+	for _, wrapper := range seg.Wrappers {
+		if s.isDangerousCommand(wrapper) {
+			risks = append(risks, CommandRisk{
+				Command: wrapper,
+				Segment: text,
+				Reason:  fmt.Sprintf("以 %s 提权或包装执行命令%s", wrapper, where),
+			})
+		}
+	}
+
+	if seg.Name != "" && s.isDangerousCommand(seg.Name) {
+		reason := fmt.Sprintf("危险命令 '%s'%s", seg.Name, where)
+		if seg.Path != seg.Name {
+			reason = fmt.Sprintf("以路径 '%s' 调用危险命令 '%s'%s", seg.Path, seg.Name, where)
+		}
+		risks = append(risks, CommandRisk{Command: seg.Name, Segment: text, Reason: reason, ByName: true})
+	}
+
+	if strings.ContainsAny(seg.Path, "$`") {
+		// 命令名来自变量或命令替换时，实际执行的命令要到运行时才能确定
+		risks = append(risks, CommandRisk{
+			Command: seg.Name,
+			Segment: text,
+			Reason:  fmt.Sprintf("命令名 '%s' 包含变量展开或命令替换，无法确定实际执行的命令%s", seg.Path, where),
+		})
+	}
+
+	for _, r := range seg.Redirects {
+		if r.IsOutput() && isSystemTarget(r.Target, cwd) {
+			risks = append(risks, CommandRisk{
+				Command: seg.Name,
+				Segment: text,
+				Reason:  fmt.Sprintf("重定向写入系统路径 '%s'%s", r.Target, where),
+			})
+		}
+	}
+
+	if fileWritingCommands[seg.Name] {
+		for _, arg := range seg.Args {
+			if !strings.HasPrefix(arg, "-") && isSystemTarget(arg, cwd) {
+				risks = append(risks, CommandRisk{
+					Command: seg.Name,
+					Segment: text,
+					Reason:  fmt.Sprintf("'%s' 可能写入系统路径 '%s'%s", seg.Name, arg, where),
+				})
+				break
+			}
+		}
+	}
+
+	for _, name := range definedNames(seg) {
+		if s.isKnownCommand(name) {
+			kind := "函数"
+			if seg.Name == "alias" {
+				kind = "别名"
+			}
+			risks = append(risks, CommandRisk{
+				Command: name,
+				Segment: text,
+				Reason:  fmt.Sprintf("定义与已有命令同名的%s '%s'，之后执行 %s 时运行的是自定义内容%s", kind, name, name, where),
+			})
+		}
+	}
+
+	if source := stdinScript(seg); source != "" {
+		risks = append(risks, CommandRisk{
+			Command: seg.Name,
+			Segment: text,
+			Reason:  fmt.Sprintf("'%s' 执行来自%s的代码，内容无法预先检查%s", seg.Name, source, where),
+		})
+	}
+
+	if seg.Name == "find" {
+		for _, arg := range seg.Args {
+			if arg == "-delete" {
+				risks = append(risks, CommandRisk{
+					Command: "find",
+					Segment: text,
+					Reason:  fmt.Sprintf("find -delete 会删除匹配的文件%s", where),
+				})
+				break
+			}
+		}
+	}
+
+	return risks
+}
+
+// definedNames 返回 alias 或函数定义引入的名称
+func definedNames(seg CommandSegment) []string {
+	switch seg.Name {
+	case "function":
+		return seg.Args
+	case "alias":
+		var names []string
+		for _, arg := range seg.Args {
+			if name, _, ok := strings.Cut(arg, "="); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// isKnownCommand 判断名称是否为 shell 内置命令、包装命令、危险命令或 PATH 中的可执行文件
+func (s *SystemCommand) isKnownCommand(name string) bool {
+	if shellBuiltins[name] || wrapperCommands[name] || s.isDangerousCommand(name) {
+		return true
+	}
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// stdinScript 判断子命令是否执行来自标准输入或进程替换的代码（如 curl ... | bash、
+// bash <<EOF、sh < /dev/stdin、source /dev/stdin），返回代码来源的描述，否则返回空字符串
+func stdinScript(seg CommandSegment) string {
+	isStdin := func(arg string) bool {
+		return slices.Contains(stdinPaths, arg)
+	}
+	if seg.Name == "source" || seg.Name == "." {
+		if len(seg.Args) > 0 && isStdin(seg.Args[0]) {
+			return "标准输入"
+		}
+		if len(seg.Args) > 0 && strings.HasPrefix(seg.Args[0], "<(") {
+			return "进程替换"
+		}
+		return ""
+	}
+	if !shellInterpreters[seg.Name] && !scriptInterpreters[seg.Name] {
+		return ""
+	}
+
+	for i := 0; i < len(seg.Args); i++ {
+		arg := seg.Args[i]
+		switch {
+		case arg == "-" || isStdin(arg) || shellInterpreters[seg.Name] && arg == "-s":
+			return stdinSource(seg)
+		case strings.HasPrefix(arg, "<("):
+			return "进程替换"
+		case arg == "--":
+			continue
+		case shellInterpreters[seg.Name] && isShellCommandOption(arg),
+			scriptInterpreters[seg.Name] && inlineCodeOptions[arg]:
+			return ""
+		case shellInterpreters[seg.Name] && (arg == "-o" || arg == "+o" || arg == "-O" || arg == "+O"):
+			i++
+		case strings.HasPrefix(arg, "-") || shellInterpreters[seg.Name] && strings.HasPrefix(arg, "+"):
+			continue
+		default:
+			// 执行脚本文件，标准输入只是数据
+			return ""
+		}
+	}
+	return stdinSource(seg)
+}
+
+// stdinSource 描述子命令标准输入的来源：输入重定向、here-document、here-string 或管道，
+// 标准输入来自终端时返回空字符串
+func stdinSource(seg CommandSegment) string {
+	if r := stdinRedirect(seg); r != nil {
+		switch op, _ := r.stdinInput(); op {
+		case "<<", "<<-":
+			return "here-document"
+		case "<<<":
+			return "here-string"
+		default:
+			return "输入重定向 " + r.Target
+		}
+	}
+	if seg.Piped {
+		return "管道"
+	}
+	return ""
+}
+
+// stdinRedirect 返回子命令最后一个替换标准输入的重定向，没有时返回 nil
+func stdinRedirect(seg CommandSegment) *Redirect {
+	for i := len(seg.Redirects) - 1; i >= 0; i-- {
+		if _, ok := seg.Redirects[i].stdinInput(); ok {
+			return &seg.Redirects[i]
+		}
+	}
+	return nil
+}
+
+// ParseShellCommand 将命令行解析为子命令列表。
+// 支持管道、&&、||、;、&、子shell、命令替换、进程替换、重定向，
+// 并会展开 env/nice/xargs/sudo 等包装命令以及 sh -c、eval、find -exec 中的内层命令。
+func ParseShellCommand(command string) ([]CommandSegment, error) {
+	return parseShellCommand(command, "", 0)
+}
+
+// maxParseDepth 嵌套解析的最大深度，防止恶意构造的输入导致无限递归
+const maxParseDepth = 8
+
+// parseShellCommand 递归解析命令行
+func parseShellCommand(command, context string, depth int) ([]CommandSegment, error) {
+	if depth > maxParseDepth {
+		return nil, fmt.Errorf("命令嵌套层级过深")
+	}
+
+	tokens, nested, err := lexShell(command)
+
+	var segments []CommandSegment
+	for _, group := range splitSegments(tokens) {
+		built, buildErr := buildSegments(group.tokens, context, depth)
+		if len(built) > 0 {
+			built[0].Piped = group.piped
+		}
+		segments = append(segments, built...)
+		if buildErr != nil && err == nil {
+			err = buildErr
+		}
+	}
+
+	for _, n := range nested {
+		inner, innerErr := parseShellCommand(n.body, n.kind, depth+1)
+		segments = append(segments, inner...)
+		if innerErr != nil && err == nil {
+			err = innerErr
+		}
+	}
+
+	return segments, err
+}
+
+// shellToken 词法分析产生的记号
+type shellToken struct {
+	value string
+	// op 为 true 时表示控制操作符或重定向操作符
+	op bool
+	// body here-document 结束标记对应的正文
+	body string
+}
+
+// nestedCommand 命令替换或进程替换中的内层命令
+type nestedCommand struct {
+	kind string
+	body string
+}
+
+// lexShell 对命令行做词法分析，返回记号列表和内嵌的命令替换
+func lexShell(input string) ([]shellToken, []nestedCommand, error) {
+	var tokens []shellToken
+	var nested []nestedCommand
+	var word strings.Builder
+	inWord := false
+	// quoted 当前单词中是否出现过引号或转义，决定 here-document 正文是否展开
+	quoted := false
+	// heredocOp 刚读到的 << 或 <<- 操作符，下一个单词是其结束标记
+	heredocOp := ""
+	var heredocs []heredoc
+
+	flush := func() {
+		if inWord {
+			if heredocOp != "" {
+				heredocs = append(heredocs, heredoc{delim: word.String(), stripTabs: heredocOp == "<<-", quoted: quoted, token: len(tokens)})
+				heredocOp = ""
+			}
+			tokens = append(tokens, shellToken{value: word.String()})
+			word.Reset()
+			inWord = false
+			quoted = false
+		}
+	}
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			inWord = true
+			quoted = true
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+			}
+		case r == '\'':
+			inWord = true
+			quoted = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return tokens, nested, fmt.Errorf("单引号未闭合")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+		case r == '"':
+			inWord = true
+			quoted = true
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				switch {
+				case runes[j] == '\\' && j+1 < len(runes):
+					j++
+					word.WriteRune(runes[j])
+				case runes[j] == '$' && j+1 < len(runes) && runes[j+1] == '(':
+					end, err := matchParen(runes, j+1)
+					if err != nil {
+						return tokens, nested, err
+					}
+					nested = append(nested, nestedCommand{kind: "命令替换", body: string(runes[j+2 : end])})
+					word.WriteString(string(runes[j : end+1]))
+					j = end
+				case runes[j] == '`':
+					end := indexRune(runes, j+1, '`')
+					if end < 0 {
+						return tokens, nested, fmt.Errorf("反引号未闭合")
+					}
+					nested = append(nested, nestedCommand{kind: "命令替换", body: string(runes[j+1 : end])})
+					word.WriteString(string(runes[j : end+1]))
+					j = end
+				default:
+					word.WriteRune(runes[j])
+				}
+			}
+			if j >= len(runes) {
+				return tokens, nested, fmt.Errorf("双引号未闭合")
+			}
+			i = j
+		case r == '`':
+			inWord = true
+			end := indexRune(runes, i+1, '`')
+			if end < 0 {
+				return tokens, nested, fmt.Errorf("反引号未闭合")
+			}
+			nested = append(nested, nestedCommand{kind: "命令替换", body: string(runes[i+1 : end])})
+			word.WriteString(string(runes[i : end+1]))
+			i = end
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			inWord = true
+			end, err := matchParen(runes, i+1)
+			if err != nil {
+				return tokens, nested, err
+			}
+			nested = append(nested, nestedCommand{kind: "命令替换", body: string(runes[i+2 : end])})
+			word.WriteString(string(runes[i : end+1]))
+			i = end
+		case (r == '<' || r == '>') && i+1 < len(runes) && runes[i+1] == '(':
+			flush()
+			end, err := matchParen(runes, i+1)
+			if err != nil {
+				return tokens, nested, err
+			}
+			nested = append(nested, nestedCommand{kind: "进程替换", body: string(runes[i+2 : end])})
+			tokens = append(tokens, shellToken{value: string(runes[i : end+1])})
+			i = end
+		case r == '#' && !inWord:
+			// 注释一直持续到行尾
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			i--
+		case r == ' ' || r == '\t':
+			flush()
+		case r == '\n':
+			flush()
+			tokens = append(tokens, shellToken{value: ";", op: true})
+			if len(heredocs) > 0 {
+				// here-document 正文不是这一行的 shell 代码，读取到结束标记为止，
+				// 正文记在结束标记上，由读取它的命令决定是否作为代码分析
+				end, bodyNested, err := skipHeredocs(runes, i+1, heredocs)
+				nested = append(nested, bodyNested...)
+				for _, h := range heredocs {
+					tokens[h.token].body = h.body
+				}
+				if err != nil {
+					return tokens, nested, err
+				}
+				heredocs = nil
+				i = end - 1
+			}
+		case r == ';' || r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, shellToken{value: string(r), op: true})
+		case r == '|' || r == '&':
+			// 数字紧跟 & 的情况（如 2>&1）由重定向分支处理
+			flush()
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == r || (r == '|' && runes[i+1] == '&') || (r == '&' && runes[i+1] == '>')) {
+				op += string(runes[i+1])
+				i++
+			}
+			if op == "&>" {
+				if i+1 < len(runes) && runes[i+1] == '>' {
+					op += ">"
+					i++
+				}
+			}
+			tokens = append(tokens, shellToken{value: op, op: true})
+		case r == '<' || r == '>':
+			// 单独的文件描述符数字属于重定向操作符
+			prefix := ""
+			if inWord && isAllDigits(word.String()) {
+				prefix = word.String()
+				word.Reset()
+				inWord = false
+			}
+			flush()
+			op := prefix + string(r)
+			for i+1 < len(runes) && (runes[i+1] == '>' || runes[i+1] == '<' || runes[i+1] == '|' || runes[i+1] == '&') {
+				op += string(runes[i+1])
+				i++
+				if runes[i] == '&' {
+					// 形如 2>&1 的描述符复制
+					for i+1 < len(runes) && (runes[i+1] >= '0' && runes[i+1] <= '9' || runes[i+1] == '-') {
+						op += string(runes[i+1])
+						i++
+					}
+					break
+				}
+			}
+			if strings.HasSuffix(op, "<<") && !strings.HasSuffix(op, "<<<") {
+				// here-document，下一个单词是结束标记
+				heredocOp = "<<"
+				if i+1 < len(runes) && runes[i+1] == '-' {
+					op += "-"
+					heredocOp = "<<-"
+					i++
+				}
+			}
+			tokens = append(tokens, shellToken{value: op, op: true})
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens, nested, nil
+}
+
+// heredoc 等待读取正文的 here-document
+type heredoc struct {
+	delim string
+	// stripTabs 对应 <<-，结束标记所在行可以以制表符开头
+	stripTabs bool
+	// quoted 结束标记带引号时正文不做任何展开
+	quoted bool
+	// token 结束标记在记号列表中的位置
+	token int
+	// body 读取到的正文
+	body string
+}
+
+// skipHeredocs 从 start 开始依次跳过各个 here-document 的正文，返回正文之后的位置，
+// 正文保存在 heredocs 的 body 中。未加引号的正文中的命令替换仍会执行，因此一并提取出来
+func skipHeredocs(runes []rune, start int, heredocs []heredoc) (int, []nestedCommand, error) {
+	var nested []nestedCommand
+	pos := start
+	for k := range heredocs {
+		h := &heredocs[k]
+		var body strings.Builder
+		for pos < len(runes) {
+			end := indexRune(runes, pos, '\n')
+			if end < 0 {
+				end = len(runes)
+			}
+			line := string(runes[pos:end])
+			pos = end + 1
+			if h.stripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == h.delim {
+				break
+			}
+			body.WriteString(line)
+			body.WriteByte('\n')
+			h.body = body.String()
+			if h.quoted {
+				continue
+			}
+			subs, err := heredocSubstitutions([]rune(line))
+			nested = append(nested, subs...)
+			if err != nil {
+				return pos, nested, err
+			}
+		}
+	}
+	return min(pos, len(runes)), nested, nil
+}
+
+// heredocSubstitutions 提取 here-document 正文一行中的命令替换，引号在正文中只是普通字符
+func heredocSubstitutions(line []rune) ([]nestedCommand, error) {
+	var nested []nestedCommand
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '$' && i+1 < len(line) && line[i+1] == '(':
+			end, err := matchParen(line, i+1)
+			if err != nil {
+				return nested, err
+			}
+			nested = append(nested, nestedCommand{kind: "命令替换", body: string(line[i+2 : end])})
+			i = end
+		case line[i] == '`':
+			end := indexRune(line, i+1, '`')
+			if end < 0 {
+				return nested, fmt.Errorf("反引号未闭合")
+			}
+			nested = append(nested, nestedCommand{kind: "命令替换", body: string(line[i+1 : end])})
+			i = end
+		}
+	}
+	return nested, nil
+}
+
+// tokenGroup 一个简单命令的记号
+type tokenGroup struct {
+	tokens []shellToken
+	// piped 前一个控制操作符是否为管道
+	piped bool
+}
+
+// splitSegments 按控制操作符切分记号，每一组对应一个简单命令
+func splitSegments(tokens []shellToken) []tokenGroup {
+	var groups []tokenGroup
+	var current tokenGroup
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		// NAME() 形式的函数定义统一改写为 function NAME，"function NAME()" 中的括号直接去掉
+		if tok.op && tok.value == "(" && i+1 < len(tokens) && tokens[i+1].op && tokens[i+1].value == ")" {
+			switch {
+			case len(current.tokens) == 1 && !current.tokens[0].op:
+				current.tokens = []shellToken{{value: "function"}, current.tokens[0]}
+				i++
+				continue
+			case len(current.tokens) == 2 && current.tokens[0].value == "function":
+				i++
+				continue
+			}
+		}
+		if tok.op && isControlOperator(tok.value) {
+			if len(current.tokens) > 0 {
+				groups = append(groups, current)
+			}
+			current = tokenGroup{piped: tok.value == "|" || tok.value == "|&"}
+			continue
+		}
+		current.tokens = append(current.tokens, tok)
+	}
+	if len(current.tokens) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// buildSegments 把一组记号构造成子命令，并展开其中的包装命令。
+// 内层命令（sh -c、eval、find -exec）无法解析时返回错误，已解析出的子命令照常返回。
+func buildSegments(tokens []shellToken, context string, depth int) ([]CommandSegment, error) {
+	seg := CommandSegment{Context: context}
+	var words []string
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.op {
+			target := ""
+			// 描述符复制（如 2>&1）没有文件目标
+			body := ""
+			if strings.Index(tok.value, "&") <= 0 && i+1 < len(tokens) && !tokens[i+1].op {
+				target, body = tokens[i+1].value, tokens[i+1].body
+				if strings.HasSuffix(tok.value, "<<<") {
+					body = target
+				}
+				i++
+			}
+			seg.Redirects = append(seg.Redirects, Redirect{Op: tok.value, Target: target, Body: body})
+			continue
+		}
+		words = append(words, tok.value)
+	}
+
+	// 跳过开头的 shell 关键字和变量赋值；function NAME { ... } 中函数体的第一条命令
+	// 与定义位于同一组记号中，定义单独作为一个子命令
+	var definitions []CommandSegment
+	for len(words) > 0 {
+		if words[0] == "function" && len(words) > 1 {
+			definitions = append(definitions, CommandSegment{
+				Name: "function", Path: "function", Args: words[1:2], Context: context,
+			})
+			words = words[2:]
+			continue
+		}
+		if !reservedWords[words[0]] && !isAssignment(words[0]) {
+			break
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		if len(seg.Redirects) > 0 {
+			return append(definitions, seg), nil
+		}
+		return definitions, nil
+	}
+
+	words, seg.Wrappers = unwrapCommand(words)
+	if len(words) == 0 {
+		seg.Name = commandBaseName(seg.Wrappers[len(seg.Wrappers)-1])
+		seg.Path = seg.Wrappers[len(seg.Wrappers)-1]
+		seg.Wrappers = seg.Wrappers[:len(seg.Wrappers)-1]
+		return append(definitions, seg), nil
+	}
+
+	seg.Path = words[0]
+	seg.Name = commandBaseName(words[0])
+	seg.Args = words[1:]
+	segments := append(definitions, seg)
+
+	// 展开把参数当作命令执行的情况
+	var inner []CommandSegment
+	var err error
+	switch {
+	case shellInterpreters[seg.Name]:
+		for i, arg := range seg.Args {
+			if isShellCommandOption(arg) && i+1 < len(seg.Args) {
+				inner, err = parseShellCommand(seg.Args[i+1], seg.Name+" -c", depth+1)
+				break
+			}
+		}
+	case seg.Name == "eval":
+		inner, err = parseShellCommand(strings.Join(seg.Args, " "), "eval", depth+1)
+	case seg.Name == "alias":
+		for _, arg := range seg.Args {
+			if _, value, ok := strings.Cut(arg, "="); ok && err == nil {
+				var body []CommandSegment
+				body, err = parseShellCommand(value, "alias", depth+1)
+				inner = append(inner, body...)
+			}
+		}
+	case seg.Name == "find":
+		inner, err = findExecSegments(seg.Args, depth)
+	}
+	// shell 从 here-document 或 here-string 读取脚本时，其内容同样是要执行的命令
+	if r := stdinRedirect(seg); err == nil && r != nil && r.Body != "" && shellInterpreters[seg.Name] && stdinScript(seg) != "" {
+		var body []CommandSegment
+		body, err = parseShellCommand(r.Body, seg.Name+" "+r.Op, depth+1)
+		inner = append(inner, body...)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s: %w", seg.Name, err)
+	}
+
+	return append(segments, inner...), err
+}
+
+// isShellCommandOption 判断是否为 shell 的 -c 选项（可能与其他单字母选项合写，如 -ec）
+func isShellCommandOption(arg string) bool {
+	return arg == "-c" || strings.HasPrefix(arg, "-") && strings.HasSuffix(arg, "c") && !strings.HasPrefix(arg, "--")
+}
+
+// findExecSegments 提取 find -exec/-execdir/-ok 中执行的命令
+func findExecSegments(args []string, depth int) ([]CommandSegment, error) {
+	var segments []CommandSegment
+	var err error
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-exec", "-execdir", "-ok", "-okdir":
+			j := i + 1
+			for ; j < len(args) && args[j] != ";" && args[j] != "+"; j++ {
+			}
+			if j > i+1 {
+				var toks []shellToken
+				for _, w := range args[i+1 : j] {
+					toks = append(toks, shellToken{value: w})
+				}
+				inner, innerErr := buildSegments(toks, "find "+args[i], depth+1)
+				segments = append(segments, inner...)
+				if innerErr != nil && err == nil {
+					err = innerErr
+				}
+			}
+			i = j
+		}
+	}
+	return segments, err
+}
+
+// wrapperOptionsWithValue 包装命令中需要带参数值的选项
+var wrapperOptionsWithValue = map[string]map[string]bool{
+	"sudo":    {"-u": true, "-g": true, "-C": true, "-D": true, "-h": true, "-p": true, "-r": true, "-t": true, "-U": true},
+	"doas":    {"-u": true, "-C": true},
+	"nice":    {"-n": true},
+	"ionice":  {"-c": true, "-n": true, "-p": true},
+	"xargs":   {"-I": true, "-n": true, "-P": true, "-d": true, "-L": true, "-s": true, "-E": true, "-a": true},
+	"env":     {"-u": true, "-C": true},
+	"stdbuf":  {"-i": true, "-o": true, "-e": true},
+	"timeout": {"-s": true, "-k": true},
+	"chroot":  {},
+	"runuser": {"-u": true, "-g": true},
+	"watch":   {"-n": true, "--interval": true, "-q": true, "--equexit": true},
+}
+
+// wrapperCommands 包装命令：真正执行的是其后的命令
+var wrapperCommands = map[string]bool{
+	"sudo": true, "doas": true, "env": true, "nice": true, "nohup": true,
+	"xargs": true, "time": true, "timeout": true, "stdbuf": true, "ionice": true,
+	"command": true, "builtin": true, "exec": true, "chroot": true, "runuser": true,
+	"setsid": true, "watch": true, "strace": true, "ltrace": true, "unbuffer": true,
+	"busybox": true,
+}
+
+// unwrapCommand 剥离包装命令，返回内层命令的单词列表和包装命令列表
+func unwrapCommand(words []string) ([]string, []string) {
+	var wrappers []string
+	for len(words) > 0 {
+		name := commandBaseName(words[0])
+		if !wrapperCommands[name] || (name == "command" && isCommandLookup(words[1:])) {
+			break
+		}
+		wrappers = append(wrappers, words[0])
+		words = words[1:]
+		valueOpts := wrapperOptionsWithValue[name]
+
+		// 跳过包装命令自身的选项
+		for len(words) > 0 {
+			w := words[0]
+			if w == "--" {
+				words = words[1:]
+				break
+			}
+			if name == "env" && isAssignment(w) {
+				words = words[1:]
+				continue
+			}
+			if name == "env" {
+				// env -S 会把参数重新拆分成命令行，需要按拆分后的单词继续分析
+				if script, rest, ok := envSplitString(words); ok {
+					words = append(splitWords(script), rest...)
+					continue
+				}
+			}
+			if !strings.HasPrefix(w, "-") || w == "-" {
+				break
+			}
+			words = words[1:]
+			if valueOpts[w] && len(words) > 0 {
+				words = words[1:]
+			}
+		}
+
+		// 部分包装命令的第一个位置参数不是命令
+		switch name {
+		case "timeout":
+			if len(words) > 0 {
+				words = words[1:]
+			}
+		case "chroot":
+			if len(words) > 0 {
+				words = words[1:]
+			}
+		}
+	}
+	return words, wrappers
+}
+
+// isCommandLookup 判断 command 的选项中是否有 -v/-V：这时只查找命令的位置，不执行它，
+// 与 type、which、hash 一样是只读操作
+func isCommandLookup(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			return false
+		}
+		if strings.ContainsAny(arg, "vV") {
+			return true
+		}
+	}
+	return false
+}
+
+// envSplitString 识别 env 的 -S/--split-string 选项，返回待拆分的字符串和其后的单词
+func envSplitString(words []string) (string, []string, bool) {
+	w := words[0]
+	switch {
+	case w == "-S" || w == "--split-string":
+		if len(words) < 2 {
+			return "", nil, false
+		}
+		return words[1], words[2:], true
+	case strings.HasPrefix(w, "--split-string="):
+		return strings.TrimPrefix(w, "--split-string="), words[1:], true
+	case strings.HasPrefix(w, "-S"):
+		return strings.TrimPrefix(w, "-S"), words[1:], true
+	}
+	return "", nil, false
+}
+
+// splitWords 按 shell 的引号规则把字符串拆分为单词
+func splitWords(s string) []string {
+	tokens, _, _ := lexShell(s)
+	words := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		words = append(words, tok.value)
+	}
+	return words
+}
+
+// commandBaseName 去掉命令的路径前缀和 Windows 可执行文件后缀
+func commandBaseName(word string) string {
+	name := word
+	if idx := strings.LastIndexAny(name, `/\`); idx >= 0 {
+		name = name[idx+1:]
+	}
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".exe", ".cmd", ".bat", ".com"} {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// isControlOperator 判断是否为分隔子命令的控制操作符
+func isControlOperator(op string) bool {
+	switch op {
+	case ";", "&", "&&", "||", "|", "|&", ";;", "(", ")":
+		return true
+	}
+	return false
+}
+
+// isAssignment 判断单词是否为变量赋值（VAR=value）
+func isAssignment(word string) bool {
+	idx := strings.Index(word, "=")
+	if idx <= 0 {
+		return false
+	}
+	for i, r := range word[:idx] {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isAllDigits 判断字符串是否全部为数字
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// indexRune 从 start 开始查找字符位置
+func indexRune(runes []rune, start int, target rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == target {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchParen 查找与 open 位置的左括号匹配的右括号，会跳过引号中的内容
+func matchParen(runes []rune, open int) (int, error) {
+	depth := 0
+	for i := open; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return -1, fmt.Errorf("单引号未闭合")
+			}
+			i = end
+		case '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return -1, fmt.Errorf("双引号未闭合")
+			}
+			i = j
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return -1, fmt.Errorf("括号未闭合")
+}
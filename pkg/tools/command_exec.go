@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// DefaultMaxCommandTimeout 调用参数中 timeout 的默认上限
+const DefaultMaxCommandTimeout = 10 * time.Minute
+
+// CommandRequest system_command 的结构化输入
+type CommandRequest struct {
+	// Command 要执行的命令
+	Command string `json:"command" desc:"要执行的完整shell命令"`
+	// Timeout 本次调用的超时时间（秒），0 表示使用策略或默认值
+	Timeout float64 `json:"timeout,omitempty" desc:"超时时间（秒），耗时较长的命令（编译、安装、测试）可以调大，默认30秒，最多600秒"`
+}
+
+// CommandResult 命令执行结果
+type CommandResult struct {
+	// Command 执行的命令
+	Command string
+	// Stdout 标准输出
+	Stdout string
+	// Stderr 标准错误
+	Stderr string
+	// ExitCode 退出码，命令未能启动时为 -1
+	ExitCode int
+	// Duration 执行耗时
+	Duration time.Duration
+	// Timeout 生效的超时时间
+	Timeout time.Duration
+	// TimedOut 是否因超时被终止
+	TimedOut bool
+	// Canceled 是否被用户中断（Ctrl+C）
+	Canceled bool
+	// OutputExceeded 是否因输出超过沙箱上限被终止
+	OutputExceeded bool
+	// Cwd 命令结束时的工作目录，仅持久 shell 会话中有效
+	Cwd string
+	// Err 执行错误（包括非零退出码）
+	Err error
+}
+
+// Success 命令是否执行成功
+func (r *CommandResult) Success() bool {
+	return r.Err == nil
+}
+
+// Format 格式化为返回给模型的文本
+func (r *CommandResult) Format() string {
+	var b strings.Builder
+	if r.Success() {
+		fmt.Fprintf(&b, "命令执行成功 (退出码: %d, 耗时: %s):\n", r.ExitCode, r.Duration.Round(time.Millisecond))
+	} else {
+		reason := r.Err.Error()
+		if r.TimedOut {
+			reason = fmt.Sprintf("执行超时（超过%s）已被终止", r.Timeout)
+		}
+		if r.Canceled {
+			reason = "已被用户中断"
+		}
+		if r.OutputExceeded {
+			reason = "输出超过沙箱上限，已被终止"
+		}
+		fmt.Fprintf(&b, "命令执行失败: %s (退出码: %d, 耗时: %s)\n", reason, r.ExitCode, r.Duration.Round(time.Millisecond))
+	}
+
+	if r.Cwd != "" {
+		fmt.Fprintf(&b, "当前目录: %s\n", r.Cwd)
+	}
+	if r.Stdout != "" {
+		if !r.Success() {
+			b.WriteString("输出: ")
+		}
+		b.WriteString(r.Stdout)
+		if !strings.HasSuffix(r.Stdout, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	if r.Stderr != "" {
+		b.WriteString("[stderr]:\n")
+		b.WriteString(r.Stderr)
+		if !strings.HasSuffix(r.Stderr, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// parseCommandInput 解析工具输入，支持纯命令字符串或 {"command": "...", "timeout": 秒} JSON
+func parseCommandInput(input string) CommandRequest {
+	input = strings.TrimSpace(input)
+	if strings.HasPrefix(input, "{") && strings.HasSuffix(input, "}") {
+		var req CommandRequest
+		// shell 的 { ...; } 语句块同样以花括号开头，JSON 解析失败时按普通命令处理
+		if err := json.Unmarshal([]byte(input), &req); err == nil && strings.TrimSpace(req.Command) != "" {
+			req.Command = strings.TrimSpace(req.Command)
+			return req
+		}
+	}
+	return CommandRequest{Command: input}
+}
+
+// resolveTimeout 计算本次调用的超时时间：调用参数 > 策略规则 > 默认 Timeout。
+// 调用参数不能超过 MaxTimeout 和策略规则中较长的一个，超出时按上限执行。
+func (s *SystemCommand) resolveTimeout(req CommandRequest, analysis *CommandAnalysis) (time.Duration, error) {
+	if math.IsNaN(req.Timeout) || math.IsInf(req.Timeout, 0) || req.Timeout < 0 {
+		return 0, fmt.Errorf("timeout 必须是正数（秒）")
+	}
+	var longest time.Duration
+	if s.Policy != nil {
+		for _, seg := range analysis.Segments {
+			if t := s.Policy.TimeoutFor(seg.Subject(analysis.Cwd)); t > longest {
+				longest = t
+			}
+		}
+	}
+	if req.Timeout > 0 {
+		ceiling := s.MaxTimeout
+		if ceiling <= 0 {
+			ceiling = DefaultMaxCommandTimeout
+		}
+		ceiling = max(ceiling, longest)
+		// 按秒比较，避免过大的值换算为 time.Duration 时溢出
+		if req.Timeout >= ceiling.Seconds() {
+			return ceiling, nil
+		}
+		return time.Duration(req.Timeout * float64(time.Second)), nil
+	}
+	if longest > 0 {
+		return longest, nil
+	}
+	return s.Timeout, nil
+}
+
+// execute 执行命令，实时输出到 Output 的同时分别捕获 stdout 和 stderr
+func (s *SystemCommand) execute(ctx context.Context, command string, timeout time.Duration) *CommandResult {
+	result := &CommandResult{Command: command, Timeout: timeout, ExitCode: -1}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// 根据操作系统执行命令
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		// Windows使用cmd /c执行命令
+		cmd = exec.CommandContext(ctx, "cmd", "/c", command)
+	default:
+		// Linux/macOS使用sh -c执行命令
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// 后台子进程继续持有管道时，不无限期等待
+	cmd.WaitDelay = 2 * time.Second
+	// 超时或中断时终止整个进程组，避免 sh 派生的子进程继续运行
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd.Process)
+	}
+
+	// 启用沙箱时在隔离环境中执行，并限制输出总量
+	sandboxed, err := s.Sandbox.Wrap(cmd)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	var limit *outputLimit
+	if sandboxed && s.Sandbox.MaxOutput > 0 {
+		limit = &outputLimit{max: s.Sandbox.MaxOutput, exceeded: func() { killProcessGroup(cmd.Process) }}
+	}
+	// 沙箱在新的 PID 命名空间中运行，无法把自己设为前台进程组
+	restoreForeground := func() {}
+	if !sandboxed {
+		restoreForeground = setForeground(cmd)
+	}
+
+	var stdout, stderr bytes.Buffer
+	live := newLiveOutput(s.Output)
+	cmd.Stdout = limit.writer(io.MultiWriter(&stdout, live.stdout()))
+	cmd.Stderr = limit.writer(io.MultiWriter(&stderr, live.stderr()))
+
+	start := time.Now()
+	err = cmd.Run()
+	restoreForeground()
+	result.Duration = time.Since(start)
+	live.finish()
+	result.OutputExceeded = limit.Exceeded()
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.TimedOut = true
+	case errors.Is(ctx.Err(), context.Canceled):
+		result.Canceled = true
+	}
+	result.Err = err
+	return result
+}
+
+// liveOutput 把命令输出实时写到终端，每行加上前缀以区分助手输出
+type liveOutput struct {
+	mu      sync.Mutex
+	w       io.Writer
+	started bool
+	atLine  bool
+}
+
+// newLiveOutput 创建实时输出器，w 为 nil 时丢弃输出
+func newLiveOutput(w io.Writer) *liveOutput {
+	return &liveOutput{w: w, atLine: true}
+}
+
+// stdout 返回标准输出的写入端
+func (l *liveOutput) stdout() io.Writer {
+	return liveStream{out: l}
+}
+
+// stderr 返回标准错误的写入端
+func (l *liveOutput) stderr() io.Writer {
+	return liveStream{out: l, isErr: true}
+}
+
+// write 按行加前缀写入
+func (l *liveOutput) write(p []byte, isErr bool) {
+	if l.w == nil || len(p) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.started {
+		l.started = true
+		fmt.Fprintln(l.w)
+	}
+	prefix := color.New(color.Faint).Sprint("│ ")
+	paint := fmt.Sprint
+	if isErr {
+		paint = color.New(color.FgRed).Sprint
+	}
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+		if l.atLine {
+			fmt.Fprint(l.w, prefix)
+		}
+		fmt.Fprint(l.w, paint(line))
+		l.atLine = strings.HasSuffix(line, "\n")
+	}
+}
+
+// finish 结束输出，补齐最后一行的换行
+func (l *liveOutput) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w != nil && l.started && !l.atLine {
+		fmt.Fprintln(l.w)
+	}
+}
+
+// liveStream 实现 io.Writer
+type liveStream struct {
+	out   *liveOutput
+	isErr bool
+}
+
+// Write 实现 io.Writer 接口
+func (s liveStream) Write(p []byte) (int, error) {
+	s.out.write(p, s.isErr)
+	return len(p), nil
+}
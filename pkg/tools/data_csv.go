@@ -0,0 +1,619 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvTable 读取的表格，第一行为表头
+type csvTable struct {
+	header []string
+	rows   [][]string
+}
+
+// parseCSV 解析 CSV/TSV 内容，允许各行的列数不同
+func parseCSV(data []byte, comma rune) (*csvTable, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV 解析失败: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("文件为空")
+	}
+	header := records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	return &csvTable{header: header, rows: records[1:]}, nil
+}
+
+// column 按列名查找列，大小写不同时也能匹配
+func (t *csvTable) column(name string) (int, error) {
+	for i, h := range t.header {
+		if h == name {
+			return i, nil
+		}
+	}
+	for i, h := range t.header {
+		if strings.EqualFold(h, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("没有列 %q，可用的列: %s", name, strings.Join(t.header, ", "))
+}
+
+// cell 返回一行中指定列的值，缺少的列为空字符串
+func cell(row []string, i int) string {
+	if i < len(row) {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+// outline 表格概览：行数、每列的类型和开头几行
+func (t *csvTable) outline(sample int) (string, [][]string) {
+	columns := make([]string, len(t.header))
+	for i, h := range t.header {
+		kind, values := "数字", 0
+		for _, row := range t.rows {
+			v := cell(row, i)
+			if v == "" {
+				continue
+			}
+			values++
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				kind = "文本"
+				break
+			}
+		}
+		if values == 0 {
+			kind = "空"
+		}
+		columns[i] = fmt.Sprintf("%s（%s）", h, kind)
+	}
+	rows := t.rows[:min(sample, len(t.rows))]
+	return "列: " + strings.Join(columns, ", "), rows
+}
+
+// csvItem select 中的一项：普通列或聚合函数
+type csvItem struct {
+	// agg count、sum、avg、min、max，普通列为空
+	agg string
+	// col 列序号，count(*) 为 -1
+	col int
+	// name 结果中的列名
+	name string
+}
+
+// csvCond where 条件
+type csvCond func(row []string) bool
+
+// csvQuery 解析后的表格查询：
+// [select 列|聚合(列), ...] [where 条件] [group by 列, ...] [order by 列 [asc|desc]] [limit N]
+type csvQuery struct {
+	items   []csvItem
+	where   csvCond
+	groupBy []int
+	orderBy string
+	desc    bool
+	limit   int
+}
+
+// csvAggregates 支持的聚合函数
+var csvAggregates = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+// csvToken 查询中的一个词
+type csvToken struct {
+	text string
+	// quoted 带引号，不作为关键字或运算符
+	quoted bool
+}
+
+// tokenizeCSVQuery 把查询拆分为词：带引号的字符串、运算符、逗号和括号，其余按空白分隔
+func tokenizeCSVQuery(query string) ([]csvToken, error) {
+	var tokens []csvToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(query) && query[j] != c; j++ {
+				if query[j] == '\\' && j+1 < len(query) {
+					j++
+				}
+				b.WriteByte(query[j])
+			}
+			if j >= len(query) {
+				return nil, fmt.Errorf("字符串缺少结尾的 %c", c)
+			}
+			tokens = append(tokens, csvToken{text: b.String(), quoted: true})
+			i = j + 1
+		case c == ',' || c == '(' || c == ')':
+			tokens = append(tokens, csvToken{text: string(c)})
+			i++
+		case strings.IndexByte("=!<>~", c) >= 0:
+			op := string(c)
+			for _, candidate := range []string{"==", "!=", "<>", "<=", ">=", "=~", "!~"} {
+				if strings.HasPrefix(query[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			tokens = append(tokens, csvToken{text: op})
+			i += len(op)
+		default:
+			j := i
+			for j < len(query) && strings.IndexByte(" \t\n\r,()=!<>~'\"`", query[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, csvToken{text: query[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// csvParser 表格查询的解析器
+type csvParser struct {
+	tokens []csvToken
+	pos    int
+	table  *csvTable
+}
+
+func (p *csvParser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *csvParser) next() csvToken {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+// keyword 下一个词是不带引号的关键字 kw 时跳过并返回 true
+func (p *csvParser) keyword(kw string) bool {
+	if !p.eof() && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// isClause 下一个词是否开始新的子句
+func (p *csvParser) isClause() bool {
+	if p.eof() || p.tokens[p.pos].quoted {
+		return false
+	}
+	switch strings.ToLower(p.tokens[p.pos].text) {
+	case "where", "group", "order", "limit":
+		return true
+	}
+	return false
+}
+
+// parseCSVQuery 解析表格查询
+func parseCSVQuery(query string, table *csvTable) (*csvQuery, error) {
+	tokens, err := tokenizeCSVQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &csvParser{tokens: tokens, table: table}
+	q := &csvQuery{}
+
+	if p.keyword("select") {
+		if q.items, err = p.items(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("where") {
+		if q.where, err = p.orExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("group") {
+		if !p.keyword("by") {
+			return nil, fmt.Errorf("group 之后应为 by")
+		}
+		for {
+			if p.eof() {
+				return nil, fmt.Errorf("group by 缺少列名")
+			}
+			col, err := table.column(p.next().text)
+			if err != nil {
+				return nil, err
+			}
+			q.groupBy = append(q.groupBy, col)
+			if p.eof() || p.tokens[p.pos].text != "," {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.keyword("order") {
+		if !p.keyword("by") || p.eof() {
+			return nil, fmt.Errorf("order 之后应为 by 列名")
+		}
+		q.orderBy = p.next().text
+		// 按聚合结果排序，如 order by count(*)
+		if !p.eof() && p.tokens[p.pos].text == "(" && p.pos+2 < len(p.tokens) && p.tokens[p.pos+2].text == ")" {
+			q.orderBy = strings.ToLower(q.orderBy) + "(" + p.tokens[p.pos+1].text + ")"
+			p.pos += 3
+		}
+		if p.keyword("desc") {
+			q.desc = true
+		} else {
+			p.keyword("asc")
+		}
+	}
+	if p.keyword("limit") {
+		if p.eof() {
+			return nil, fmt.Errorf("limit 缺少行数")
+		}
+		n, err := strconv.Atoi(p.next().text)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("limit 应为正整数")
+		}
+		q.limit = n
+	}
+	if !p.eof() {
+		return nil, fmt.Errorf("无法识别 %q，查询格式为 [select 列, ...] [where 条件] [group by 列] [order by 列 [desc]] [limit N]", p.tokens[p.pos].text)
+	}
+	return q, nil
+}
+
+// items 解析 select 列表，* 表示全部列
+func (p *csvParser) items() ([]csvItem, error) {
+	var items []csvItem
+	for {
+		if p.eof() || p.isClause() {
+			return nil, fmt.Errorf("select 缺少列名")
+		}
+		tok := p.next()
+		name := strings.ToLower(tok.text)
+		switch {
+		case !tok.quoted && tok.text == "*":
+			for i, h := range p.table.header {
+				items = append(items, csvItem{col: i, name: h})
+			}
+		case !tok.quoted && csvAggregates[name] && !p.eof() && p.tokens[p.pos].text == "(":
+			if p.pos+2 >= len(p.tokens) || p.tokens[p.pos+2].text != ")" {
+				return nil, fmt.Errorf("%s 的格式应为 %s(列名)", name, name)
+			}
+			arg := p.tokens[p.pos+1]
+			p.pos += 3
+			item := csvItem{agg: name, col: -1, name: name + "(" + arg.text + ")"}
+			if arg.quoted || arg.text != "*" {
+				col, err := p.table.column(arg.text)
+				if err != nil {
+					return nil, err
+				}
+				item.col = col
+			} else if name != "count" {
+				return nil, fmt.Errorf("只有 count 可以使用 *")
+			}
+			items = append(items, item)
+		default:
+			col, err := p.table.column(tok.text)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, csvItem{col: col, name: p.table.header[col]})
+		}
+		if p.eof() || p.tokens[p.pos].text != "," {
+			return items, nil
+		}
+		p.pos++
+	}
+}
+
+// orExpr 解析 or 连接的条件
+func (p *csvParser) orExpr() (csvCond, error) {
+	left, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row []string) bool { return l(row) || right(row) }
+	}
+	return left, nil
+}
+
+// andExpr 解析 and 连接的条件
+func (p *csvParser) andExpr() (csvCond, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row []string) bool { return l(row) && right(row) }
+	}
+	return left, nil
+}
+
+// unary 解析 not 条件、(条件) 或 列 运算符 值
+func (p *csvParser) unary() (csvCond, error) {
+	if p.keyword("not") {
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(row []string) bool { return !inner(row) }, nil
+	}
+	if p.eof() {
+		return nil, fmt.Errorf("where 缺少条件")
+	}
+	if tok := p.tokens[p.pos]; !tok.quoted && tok.text == "(" {
+		p.pos++
+		cond, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.eof() || p.next().text != ")" {
+			return nil, fmt.Errorf("条件缺少 )")
+		}
+		return cond, nil
+	}
+
+	col, err := p.table.column(p.next().text)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos+1 >= len(p.tokens) {
+		return nil, fmt.Errorf("条件格式应为 列 运算符 值，如 status == 'failed'")
+	}
+	op := strings.ToLower(p.next().text)
+	want := p.next().text
+
+	switch op {
+	case "=~", "!~":
+		re, err := regexp.Compile(want)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式无效: %w", err)
+		}
+		negate := op == "!~"
+		return func(row []string) bool { return re.MatchString(cell(row, col)) != negate }, nil
+	case "contains":
+		return func(row []string) bool { return strings.Contains(cell(row, col), want) }, nil
+	case "=", "==", "!=", "<>", "<", "<=", ">", ">=":
+		return func(row []string) bool {
+			c := compareCells(cell(row, col), want)
+			switch op {
+			case "=", "==":
+				return c == 0
+			case "!=", "<>":
+				return c != 0
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			}
+			return c >= 0
+		}, nil
+	}
+	return nil, fmt.Errorf("不支持的运算符 %q，可用: = != < <= > >= =~ !~ contains", op)
+}
+
+// compareCells 比较两个单元格：都是数字时按大小，否则按字符串
+func compareCells(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// run 执行查询，返回结果的表头、行和满足条件的行数
+func (q *csvQuery) run(t *csvTable) (header []string, rows [][]string, matched int, err error) {
+	type sourceRow struct {
+		line int
+		row  []string
+	}
+	var source []sourceRow
+	for i, row := range t.rows {
+		if q.where == nil || q.where(row) {
+			// 文件中的行号，表头为第 1 行
+			source = append(source, sourceRow{line: i + 2, row: row})
+		}
+	}
+	matched = len(source)
+
+	aggregated := len(q.groupBy) > 0
+	for _, item := range q.items {
+		aggregated = aggregated || item.agg != ""
+	}
+	items := q.items
+
+	if !aggregated {
+		if len(items) == 0 {
+			for i, h := range t.header {
+				items = append(items, csvItem{col: i, name: h})
+			}
+		}
+		// 明细查询可以按任意列排序
+		if q.orderBy != "" {
+			col, err := t.column(q.orderBy)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			sort.SliceStable(source, func(i, j int) bool {
+				c := compareCells(cell(source[i].row, col), cell(source[j].row, col))
+				if q.desc {
+					return c > 0
+				}
+				return c < 0
+			})
+		}
+		for _, item := range items {
+			header = append(header, item.name)
+		}
+		for _, s := range source {
+			out := make([]string, len(items))
+			for i, item := range items {
+				out[i] = cell(s.row, item.col)
+			}
+			rows = append(rows, out)
+		}
+		return header, rows, matched, nil
+	}
+
+	if len(items) == 0 {
+		for _, col := range q.groupBy {
+			items = append(items, csvItem{col: col, name: t.header[col]})
+		}
+		items = append(items, csvItem{agg: "count", col: -1, name: "count(*)"})
+	}
+	for _, item := range items {
+		if item.agg == "" && !containsInt(q.groupBy, item.col) {
+			return nil, nil, 0, fmt.Errorf("列 %s 不是聚合函数，需要出现在 group by 中", item.name)
+		}
+		header = append(header, item.name)
+	}
+
+	// 按分组列的值分组，保持首次出现的顺序
+	var keys []string
+	groups := make(map[string][]sourceRow)
+	for _, s := range source {
+		var values []string
+		for _, col := range q.groupBy {
+			values = append(values, cell(s.row, col))
+		}
+		key := strings.Join(values, "\x00")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], s)
+	}
+	if len(q.groupBy) == 0 && len(keys) == 0 {
+		keys = append(keys, "")
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		out := make([]string, len(items))
+		for i, item := range items {
+			if item.agg == "" {
+				out[i] = cell(group[0].row, item.col)
+				continue
+			}
+			values := make([]string, 0, len(group))
+			lines := make([]int, 0, len(group))
+			for _, s := range group {
+				if item.col < 0 {
+					values = append(values, "*")
+				} else if v := cell(s.row, item.col); v != "" {
+					values = append(values, v)
+				} else {
+					continue
+				}
+				lines = append(lines, s.line)
+			}
+			if out[i], err = aggregate(item, values, lines); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+		rows = append(rows, out)
+	}
+
+	if q.orderBy != "" {
+		col := -1
+		for i, h := range header {
+			if strings.EqualFold(h, q.orderBy) {
+				col = i
+			}
+		}
+		if col < 0 {
+			return nil, nil, 0, fmt.Errorf("分组查询只能按结果中的列排序: %s", strings.Join(header, ", "))
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			c := compareCells(rows[i][col], rows[j][col])
+			if q.desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	return header, rows, matched, nil
+}
+
+// aggregate 计算一组非空值的聚合结果，lines 为各值所在的行号
+func aggregate(item csvItem, values []string, lines []int) (string, error) {
+	if item.agg == "count" {
+		return strconv.Itoa(len(values)), nil
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	if item.agg == "min" || item.agg == "max" {
+		best := values[0]
+		for _, v := range values[1:] {
+			c := compareCells(v, best)
+			if (item.agg == "min" && c < 0) || (item.agg == "max" && c > 0) {
+				best = v
+			}
+		}
+		return best, nil
+	}
+
+	sum := 0.0
+	for i, v := range values {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s: 第 %d 行的值 %q 不是数字", item.name, lines[i], v)
+		}
+		sum += n
+	}
+	if item.agg == "avg" {
+		sum /= float64(len(values))
+	}
+	return strconv.FormatFloat(sum, 'f', -1, 64), nil
+}
+
+// containsInt 判断切片中是否包含 n
+func containsInt(s []int, n int) bool {
+	for _, v := range s {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// formatCSV 把表头和行格式化为 CSV 文本
+func formatCSV(header []string, rows [][]string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(header)
+	w.WriteAll(rows)
+	return strings.TrimRight(b.String(), "\n")
+}
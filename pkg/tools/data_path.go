@@ -0,0 +1,643 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dataNode 路径查询匹配到的一个值
+type dataNode struct {
+	// path 从根开始的完整路径，如 $.spec.containers[0].image
+	path  string
+	value any
+}
+
+// pathStep 路径中的一段，把一组节点映射为下一组节点
+type pathStep func(nodes []dataNode) []dataNode
+
+// pathCond 过滤表达式，判断节点的值是否保留
+type pathCond func(value any) bool
+
+// identPattern 可以用 . 连接的键名，其余键名显示为 ['键名']
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// queryPath 在文档中执行类 JSONPath 查询，返回按文档顺序匹配的节点
+func queryPath(root any, expr string) ([]dataNode, error) {
+	steps, err := compilePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return runSteps(steps, []dataNode{{path: "$", value: root}}), nil
+}
+
+// runSteps 依次执行路径段
+func runSteps(steps []pathStep, nodes []dataNode) []dataNode {
+	for _, step := range steps {
+		nodes = step(nodes)
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+// compilePath 解析路径表达式。
+// 支持 $、.键名、['键名']、[下标]、[起:止:步长]、[a,b]、*、..（递归查找）和 [?(@.键 运算符 值)] 过滤，
+// 开头的 $ 可以省略。
+func compilePath(expr string) ([]pathStep, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		expr = expr[1:]
+	case expr != "" && expr[0] != '.' && expr[0] != '[':
+		expr = "." + expr
+	}
+	p := &pathParser{src: expr}
+	steps, err := p.steps()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("无法识别的字符 %q", p.peek())
+	}
+	return steps, nil
+}
+
+// pathParser 路径表达式的解析器
+type pathParser struct {
+	src string
+	pos int
+	// filter 正在解析过滤表达式，键名在空格、运算符和括号处结束
+	filter bool
+}
+
+func (p *pathParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *pathParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *pathParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// consume 下一段文本为 s 时跳过并返回 true
+func (p *pathParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("路径第 %d 个字符处: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// steps 解析连续的路径段，遇到不能开始路径段的字符时返回
+func (p *pathParser) steps() ([]pathStep, error) {
+	var steps []pathStep
+	for !p.eof() {
+		var step pathStep
+		var err error
+		switch {
+		case p.consume(".."):
+			if p.peek() == '[' {
+				step, err = p.bracket()
+			} else {
+				step, err = p.dotName()
+			}
+			step = descendStep(step)
+		case p.consume("."):
+			step, err = p.dotName()
+		case p.peek() == '[':
+			step, err = p.bracket()
+		default:
+			return steps, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// dotName 解析 . 之后的键名或 *
+func (p *pathParser) dotName() (pathStep, error) {
+	if p.consume("*") {
+		return wildcardStep, nil
+	}
+	stop := ".["
+	if p.filter {
+		stop = ".[] \t()=!<>&|~,"
+	}
+	start := p.pos
+	for !p.eof() && strings.IndexByte(stop, p.peek()) < 0 {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("缺少键名")
+	}
+	return childStep([]string{p.src[start:p.pos]}), nil
+}
+
+// bracket 解析 [...]：*、带引号的键名、下标、切片或过滤表达式
+func (p *pathParser) bracket() (pathStep, error) {
+	p.pos++
+	p.skipSpace()
+	var step pathStep
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		step = wildcardStep
+	case c == '?':
+		p.pos++
+		filter := p.filter
+		p.filter = true
+		cond, err := p.orExpr()
+		p.filter = filter
+		if err != nil {
+			return nil, err
+		}
+		step = filterStep(cond)
+	case c == '\'' || c == '"':
+		var keys []string
+		for {
+			key, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			p.skipSpace()
+			if !p.consume(",") {
+				break
+			}
+			p.skipSpace()
+		}
+		step = childStep(keys)
+	default:
+		end := strings.IndexByte(p.src[p.pos:], ']')
+		if end < 0 {
+			return nil, p.errorf("缺少 ]")
+		}
+		var err error
+		if step, err = parseIndexes(strings.TrimSpace(p.src[p.pos : p.pos+end])); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos += end
+	}
+	p.skipSpace()
+	if !p.consume("]") {
+		return nil, p.errorf("缺少 ]")
+	}
+	return step, nil
+}
+
+// quoted 解析单引号或双引号括起的字符串，支持反斜杠转义
+func (p *pathParser) quoted() (string, error) {
+	quote := p.peek()
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && !p.eof():
+			b.WriteByte(p.peek())
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("字符串缺少结尾的 %c", quote)
+}
+
+// parseIndexes 解析方括号中的下标列表或切片
+func parseIndexes(s string) (pathStep, error) {
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("切片格式应为 [起:止:步长]")
+		}
+		bounds := make([]*int, 3)
+		for i, part := range parts {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("切片中的 %q 不是整数", part)
+			}
+			bounds[i] = &n
+		}
+		if bounds[2] != nil && *bounds[2] <= 0 {
+			return nil, fmt.Errorf("切片步长必须大于 0")
+		}
+		return sliceStep(bounds[0], bounds[1], bounds[2]), nil
+	}
+
+	var indexes []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是下标，键名需要加引号，如 ['%s']", s, s)
+		}
+		indexes = append(indexes, n)
+	}
+	return indexStep(indexes), nil
+}
+
+// orExpr 解析 || 连接的条件
+func (p *pathParser) orExpr() (pathCond, error) {
+	left, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.consume("||"); p.skipSpace() {
+		right, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v any) bool { return l(v) || right(v) }
+	}
+	return left, nil
+}
+
+// andExpr 解析 && 连接的条件
+func (p *pathParser) andExpr() (pathCond, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.consume("&&"); p.skipSpace() {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v any) bool { return l(v) && right(v) }
+	}
+	return left, nil
+}
+
+// unary 解析 !条件、(条件) 或 @路径 [运算符 值]
+func (p *pathParser) unary() (pathCond, error) {
+	p.skipSpace()
+	switch {
+	case p.consume("!"):
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v any) bool { return !inner(v) }, nil
+	case p.consume("("):
+		cond, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("缺少 )")
+		}
+		return cond, nil
+	case p.consume("@"):
+		return p.comparison()
+	}
+	return nil, p.errorf("过滤条件应以 @ 开头，如 ?(@.name == 'web')")
+}
+
+// comparison 解析 @ 之后的相对路径和比较
+func (p *pathParser) comparison() (pathCond, error) {
+	steps, err := p.steps()
+	if err != nil {
+		return nil, err
+	}
+	lookup := func(v any) (any, bool) {
+		nodes := runSteps(steps, []dataNode{{path: "@", value: v}})
+		if len(nodes) == 0 {
+			return nil, false
+		}
+		return nodes[0].value, true
+	}
+
+	p.skipSpace()
+	op := ""
+	for _, candidate := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if p.consume(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		// 只有路径时判断是否存在
+		return func(v any) bool {
+			_, ok := lookup(v)
+			return ok
+		}, nil
+	}
+
+	p.skipSpace()
+	want, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	if op == "=~" {
+		pattern, ok := want.(string)
+		if !ok {
+			return nil, p.errorf("=~ 之后应为带引号的正则表达式")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, p.errorf("正则表达式无效: %v", err)
+		}
+		return func(v any) bool {
+			got, ok := lookup(v)
+			if !ok || got == nil {
+				return false
+			}
+			if s, ok := got.(string); ok {
+				return re.MatchString(s)
+			}
+			return re.MatchString(fmt.Sprint(got))
+		}, nil
+	}
+	return func(v any) bool {
+		got, ok := lookup(v)
+		return ok && compareValues(got, op, want)
+	}, nil
+}
+
+// literal 解析比较的值：字符串、数字、true、false 或 null
+func (p *pathParser) literal() (any, error) {
+	if c := p.peek(); c == '\'' || c == '"' {
+		return p.quoted()
+	}
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t)]&|,", p.peek()) < 0 {
+		p.pos++
+	}
+	token := p.src[start:p.pos]
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		return nil, p.errorf("缺少比较的值")
+	}
+	if n, err := strconv.ParseFloat(token, 64); err == nil {
+		return n, nil
+	}
+	return nil, p.errorf("无法识别的值 %q，字符串需要加引号", token)
+}
+
+// compareValues 比较文档中的值和过滤条件中的值：数字按大小，字符串按字典序，其余只比较是否相等
+func compareValues(got any, op string, want any) bool {
+	if a, ok := toFloat(got); ok {
+		if b, ok := toFloat(want); ok {
+			switch op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
+			case "<":
+				return a < b
+			case "<=":
+				return a <= b
+			case ">":
+				return a > b
+			case ">=":
+				return a >= b
+			}
+		}
+	}
+	if a, ok := got.(string); ok {
+		if b, ok := want.(string); ok {
+			switch op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
+			case "<":
+				return a < b
+			case "<=":
+				return a <= b
+			case ">":
+				return a > b
+			case ">=":
+				return a >= b
+			}
+		}
+	}
+	switch op {
+	case "==":
+		return scalarEqual(got, want)
+	case "!=":
+		return !scalarEqual(got, want)
+	}
+	return false
+}
+
+// scalarEqual 判断 null、布尔值是否相等，类型不同时不相等
+func scalarEqual(a, b any) bool {
+	switch b := b.(type) {
+	case nil:
+		return a == nil
+	case bool:
+		v, ok := a.(bool)
+		return ok && v == b
+	}
+	return false
+}
+
+// toFloat 把各种解析器产生的数字类型转换为 float64
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// children 返回对象和数组的子节点：对象按键名排序，数组按顺序
+func children(n dataNode) []dataNode {
+	switch v := n.value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		nodes := make([]dataNode, len(keys))
+		for i, k := range keys {
+			nodes[i] = dataNode{path: joinKey(n.path, k), value: v[k]}
+		}
+		return nodes
+	case []any:
+		nodes := make([]dataNode, len(v))
+		for i, item := range v {
+			nodes[i] = dataNode{path: joinIndex(n.path, i), value: item}
+		}
+		return nodes
+	}
+	return nil
+}
+
+// childStep 取对象中指定的键
+func childStep(keys []string) pathStep {
+	return func(nodes []dataNode) []dataNode {
+		var out []dataNode
+		for _, n := range nodes {
+			m, ok := n.value.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, k := range keys {
+				if v, ok := m[k]; ok {
+					out = append(out, dataNode{path: joinKey(n.path, k), value: v})
+				}
+			}
+		}
+		return out
+	}
+}
+
+// wildcardStep 取对象和数组的全部子节点
+func wildcardStep(nodes []dataNode) []dataNode {
+	var out []dataNode
+	for _, n := range nodes {
+		out = append(out, children(n)...)
+	}
+	return out
+}
+
+// descendStep 对节点自身及其全部后代执行 inner，即 ..
+func descendStep(inner pathStep) pathStep {
+	return func(nodes []dataNode) []dataNode {
+		var all []dataNode
+		var walk func(n dataNode)
+		walk = func(n dataNode) {
+			all = append(all, n)
+			for _, c := range children(n) {
+				walk(c)
+			}
+		}
+		for _, n := range nodes {
+			walk(n)
+		}
+		return inner(all)
+	}
+}
+
+// indexStep 取数组中指定下标的元素，负数从末尾开始
+func indexStep(indexes []int) pathStep {
+	return func(nodes []dataNode) []dataNode {
+		var out []dataNode
+		for _, n := range nodes {
+			arr, ok := n.value.([]any)
+			if !ok {
+				continue
+			}
+			for _, i := range indexes {
+				if i < 0 {
+					i += len(arr)
+				}
+				if i >= 0 && i < len(arr) {
+					out = append(out, dataNode{path: joinIndex(n.path, i), value: arr[i]})
+				}
+			}
+		}
+		return out
+	}
+}
+
+// sliceStep 取数组的切片，规则与 Python 相同
+func sliceStep(start, end, step *int) pathStep {
+	return func(nodes []dataNode) []dataNode {
+		var out []dataNode
+		for _, n := range nodes {
+			arr, ok := n.value.([]any)
+			if !ok {
+				continue
+			}
+			bound := func(p *int, def int) int {
+				if p == nil {
+					return def
+				}
+				i := *p
+				if i < 0 {
+					i += len(arr)
+				}
+				return max(0, min(i, len(arr)))
+			}
+			stride := 1
+			if step != nil {
+				stride = *step
+			}
+			// 先比较剩余长度再前进，步长很大时 i += stride 会溢出
+			for i, hi := bound(start, 0), bound(end, len(arr)); i < hi; i += stride {
+				out = append(out, dataNode{path: joinIndex(n.path, i), value: arr[i]})
+				if hi-i <= stride {
+					break
+				}
+			}
+		}
+		return out
+	}
+}
+
+// filterStep 取满足条件的子节点
+func filterStep(cond pathCond) pathStep {
+	return func(nodes []dataNode) []dataNode {
+		var out []dataNode
+		for _, n := range nodes {
+			for _, c := range children(n) {
+				if cond(c.value) {
+					out = append(out, c)
+				}
+			}
+		}
+		return out
+	}
+}
+
+// joinKey 拼接对象键名的路径
+func joinKey(path, key string) string {
+	if identPattern.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "['" + strings.ReplaceAll(strings.ReplaceAll(key, `\`, `\\`), "'", `\'`) + "']"
+}
+
+// joinIndex 拼接数组下标的路径
+func joinIndex(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
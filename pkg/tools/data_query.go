@@ -0,0 +1,479 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/tmc/langchaingo/callbacks"
+	"gopkg.in/yaml.v3"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// 结构化数据查询的默认值和上限
+const (
+	// defaultDataResults 默认最多返回的结果数（表格为行数）
+	defaultDataResults = 100
+	// maxDataResults max_results 的上限
+	maxDataResults = 1000
+	// maxDataFileSize 可以查询的文件大小上限
+	maxDataFileSize = 50 << 20
+	// dataOutlineSample 概览中显示的数组元素数和表格行数
+	dataOutlineSample = 3
+	// dataOutlineKeys 概览中每个对象最多显示的键数
+	dataOutlineKeys = 50
+	// dataOutlineRunes 概览中标量值最多显示的字符数
+	dataOutlineRunes = 80
+)
+
+// dataFormats 扩展名对应的数据格式
+var dataFormats = map[string]string{
+	".json": "json", ".jsonl": "jsonl", ".ndjson": "jsonl",
+	".yaml": "yaml", ".yml": "yaml", ".toml": "toml",
+	".csv": "csv", ".tsv": "tsv",
+}
+
+// DataQueryParams 查询结构化数据的参数结构
+type DataQueryParams struct {
+	FilePath   string `json:"file_path" desc:"要查询的 JSON、JSON Lines、YAML、TOML、CSV 或 TSV 文件"`
+	Query      string `json:"query,omitempty" desc:"查询表达式：JSON/YAML/TOML 使用类 JSONPath 路径，如 $.spec.replicas；CSV/TSV 使用 select ... where ... group by ... order by ... limit N；为空时返回文件结构概览"`
+	Format     string `json:"format,omitempty" desc:"文件格式：json、jsonl、yaml、toml、csv、tsv，默认按扩展名判断"`
+	MaxResults int    `json:"max_results,omitempty" desc:"最多返回的结果数（表格为行数），默认100，最多1000"`
+}
+
+// DataQuery 查询 JSON、YAML、TOML、CSV 等结构化数据文件，只返回匹配的值
+type DataQuery struct {
+	CallbacksHandler callbacks.Handler
+	// Budget 返回给模型的输出预算，为空时不限制
+	Budget *OutputBudget
+	// Audit 审计日志，为空时不记录
+	Audit *audit.Logger
+	// Workspace 访问范围，为空时不限制
+	Workspace *Workspace
+}
+
+// NewDataQuery 创建新的结构化数据查询工具
+func NewDataQuery() *DataQuery {
+	return &DataQuery{
+		Budget: NewOutputBudget(DefaultFileOutputTokens, false),
+	}
+}
+
+// Name 返回工具名称
+func (d *DataQuery) Name() string {
+	return "data_query"
+}
+
+// Description 返回工具描述
+func (d *DataQuery) Description() string {
+	return `查询 JSON、JSON Lines、YAML、TOML 和 CSV/TSV 文件的工具，只返回匹配的值。
+查看配置文件、清单或数据文件中的具体字段时优先使用本工具，不要用 file_reader 逐行阅读。
+输入格式：JSON字符串，也可以直接输入"文件路径 查询表达式"
+{
+  "file_path": "文件路径",
+  "query": "查询表达式（为空时返回结构概览）",
+  "format": "json|jsonl|yaml|toml|csv|tsv（默认按扩展名判断）",
+  "max_results": 100
+}
+
+JSON/YAML/TOML 使用类 JSONPath 路径，开头的 $ 可以省略：
+- .键名 或 ['键名']，[0] 下标（负数从末尾开始），[1:3] 切片，[0,2] 多个下标，* 全部子项，..键名 递归查找
+- [?(@.键 == 'x')] 过滤，运算符 == != < <= > >= =~（正则），条件用 && || ! 组合，只写 @.键 表示存在
+- 多文档 YAML 和 JSON Lines 的根是数组，用 $[0] 或 $[*] 选择文档
+结果每行为"路径 = 值"，对象和数组以 JSON 显示。
+
+CSV/TSV 第一行为表头，查询格式（子句可省略，关键字不区分大小写）：
+select 列, count(*), sum(列), avg(列), min(列), max(列) where 条件 group by 列 order by 列 [desc] limit N
+条件为"列 运算符 值"，运算符 = != < <= > >= =~ !~ contains，用 and、or、not 和括号组合；
+都是数字时按大小比较；含空格的列名或值需要加引号。结果以 CSV 格式返回。
+
+示例：
+- "deploy.yaml $.spec.replicas" - 查询副本数
+- {"file_path": "deploy.yaml", "query": "..containers[?(@.name == 'web')].image"} - 查询 web 容器的镜像
+- {"file_path": "package.json", "query": "dependencies"} - 查询依赖
+- {"file_path": "access.csv", "query": "select status, count(*) where path contains '/api' group by status order by count(*) desc"}
+- "config.toml" - 查看文件结构`
+}
+
+// Call 执行查询，每次调用都写入审计日志
+func (d *DataQuery) Call(ctx context.Context, input string) (string, error) {
+	return audited(ctx, d.Audit, d.Name(), d.Workspace.Dir(), input, func(ctx context.Context) (string, error) {
+		return d.call(ctx, input)
+	})
+}
+
+// call 执行查询
+func (d *DataQuery) call(ctx context.Context, input string) (string, error) {
+	if d.CallbacksHandler != nil {
+		d.CallbacksHandler.HandleToolStart(ctx, input)
+	}
+
+	params, err := d.parseInput(input)
+	if err != nil {
+		return "", fmt.Errorf("参数解析失败: %w", err)
+	}
+
+	absPath, err := d.Workspace.Authorize(ctx, params.FilePath, "读取")
+	if err != nil {
+		return "", err
+	}
+	data, err := readDataFile(absPath)
+	if err != nil {
+		return "", err
+	}
+	format, err := dataFormat(params.Format, absPath, data)
+	if err != nil {
+		return "", err
+	}
+
+	var result string
+	if format == "csv" || format == "tsv" {
+		result, err = queryTable(params, format, data)
+	} else {
+		result, err = queryDocument(params, format, data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	report := d.Budget.Apply(d.Name(), result)
+	result = report.Text
+	if report.Truncated {
+		result += "\n[结果过长，请使用更具体的查询或减少 max_results]"
+	}
+
+	if d.CallbacksHandler != nil {
+		d.CallbacksHandler.HandleToolEnd(ctx, result)
+	}
+	return result, nil
+}
+
+// parseInput 解析输入参数：JSON 参数，或者"文件路径 查询表达式"
+func (d *DataQuery) parseInput(input string) (DataQueryParams, error) {
+	input = strings.TrimSpace(input)
+	var params DataQueryParams
+	if strings.HasPrefix(input, "{") && strings.HasSuffix(input, "}") {
+		if err := json.Unmarshal([]byte(input), &params); err != nil {
+			return params, fmt.Errorf("JSON解析失败: %w", err)
+		}
+	} else if i := strings.IndexFunc(input, unicode.IsSpace); i >= 0 {
+		params.FilePath, params.Query = input[:i], strings.TrimSpace(input[i:])
+	} else {
+		params.FilePath = input
+	}
+
+	params.FilePath = strings.TrimSpace(params.FilePath)
+	if params.FilePath == "" {
+		return params, fmt.Errorf("文件路径不能为空")
+	}
+	params.Format = strings.ToLower(strings.TrimSpace(params.Format))
+	switch {
+	case params.MaxResults < 0:
+		return params, fmt.Errorf("max_results 不能为负数")
+	case params.MaxResults == 0:
+		params.MaxResults = defaultDataResults
+	case params.MaxResults > maxDataResults:
+		params.MaxResults = maxDataResults
+	}
+	return params, nil
+}
+
+// Parameters 返回参数的 JSON Schema
+func (d *DataQuery) Parameters() map[string]any {
+	return SchemaFor(DataQueryParams{})
+}
+
+// Concurrent 查询是只读操作，可以并行执行
+func (d *DataQuery) Concurrent() bool {
+	return true
+}
+
+// readDataFile 读取文件内容，其他编码的文本转换为 UTF-8
+func readDataFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("文件不存在: %s", path)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s 是目录", path)
+	}
+	if info.Size() > maxDataFileSize {
+		return nil, fmt.Errorf("文件大小 %s 超过 %s 的上限，请先用 file_search 定位", utils.FormatBytes(info.Size()), utils.FormatBytes(maxDataFileSize))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文件: %w", err)
+	}
+
+	enc, ok := detectEncoding(data[:min(len(data), binarySniffSize)])
+	if !ok {
+		return nil, fmt.Errorf("%s 是二进制文件，不能作为结构化数据读取", path)
+	}
+	data = data[enc.bom:]
+	if enc.decoder != nil {
+		if data, err = enc.decoder.NewDecoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("%s 编码转换失败: %w", enc.name, err)
+		}
+	}
+	return data, nil
+}
+
+// dataFormat 确定文件格式：参数指定的格式，其次按扩展名，以 { 或 [ 开头的内容按 JSON
+func dataFormat(format, path string, data []byte) (string, error) {
+	if format != "" {
+		for _, f := range dataFormats {
+			if f == format {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("不支持的格式 %q，可用: json、jsonl、yaml、toml、csv、tsv", format)
+	}
+	if f, ok := dataFormats[strings.ToLower(filepath.Ext(path))]; ok {
+		return f, nil
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "json", nil
+	}
+	return "", fmt.Errorf("无法根据扩展名判断 %s 的格式，请指定 format", filepath.Base(path))
+}
+
+// parseDocument 解析 JSON、JSON Lines、YAML 或 TOML 文档。
+// 多文档 YAML 和 JSON Lines 的根为数组；对象的键统一为字符串。
+func parseDocument(format string, data []byte) (any, error) {
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("JSON 解析失败: %w", err)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, fmt.Errorf("JSON 解析失败: 文件包含多个值，JSON Lines 请指定 format 为 jsonl")
+		}
+		return v, nil
+	case "jsonl":
+		docs := []any{}
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return nil, fmt.Errorf("JSON Lines 第 %d 行解析失败: %w", i+1, err)
+			}
+			docs = append(docs, v)
+		}
+		return docs, nil
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		docs := []any{}
+		for {
+			var v any
+			err := dec.Decode(&v)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("YAML 解析失败: %w", err)
+			}
+			docs = append(docs, normalizeData(v))
+		}
+		switch len(docs) {
+		case 0:
+			return nil, nil
+		case 1:
+			return docs[0], nil
+		}
+		return docs, nil
+	case "toml":
+		var v map[string]any
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("TOML 解析失败: %w", err)
+		}
+		return normalizeData(v), nil
+	}
+	return nil, fmt.Errorf("不支持的格式 %q", format)
+}
+
+// normalizeData 把解析器产生的各种映射和切片统一为 map[string]any 和 []any
+func normalizeData(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = normalizeData(item)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalizeData(item)
+		}
+		return m
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeData(item)
+		}
+		return v
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = normalizeData(item)
+		}
+		return items
+	}
+	return v
+}
+
+// queryDocument 在文档中执行路径查询，查询为空时返回结构概览
+func queryDocument(params DataQueryParams, format string, data []byte) (string, error) {
+	root, err := parseDocument(format, data)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if strings.TrimSpace(params.Query) == "" {
+		fmt.Fprintf(&b, "文件: %s（%s）结构概览，可以用 query 查询具体的值:\n", params.FilePath, strings.ToUpper(format))
+		writeOutline(&b, "$", root, "", 2)
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+
+	nodes, err := queryPath(root, params.Query)
+	if err != nil {
+		return "", fmt.Errorf("查询表达式无效: %w", err)
+	}
+	if len(nodes) == 0 {
+		fmt.Fprintf(&b, "文件: %s 中没有匹配 %s 的值", params.FilePath, params.Query)
+		switch v := root.(type) {
+		case map[string]any:
+			var keys []string
+			for _, n := range children(dataNode{path: "$", value: v}) {
+				keys = append(keys, strings.TrimPrefix(n.path, "$."))
+			}
+			fmt.Fprintf(&b, "\n顶层的键: %s", strings.Join(keys, ", "))
+		case []any:
+			fmt.Fprintf(&b, "\n根是数组（%d 项），请用 $[0] 或 $[*] 选择元素", len(v))
+		}
+		return b.String(), nil
+	}
+
+	fmt.Fprintf(&b, "文件: %s 中 %s 匹配 %d 个值:\n", params.FilePath, params.Query, len(nodes))
+	for i, n := range nodes {
+		if i >= params.MaxResults {
+			fmt.Fprintf(&b, "[已达到 %d 个结果的上限，请使用更具体的查询或提高 max_results]\n", params.MaxResults)
+			break
+		}
+		fmt.Fprintf(&b, "%s = %s\n", n.path, formatDataValue(n.value, true))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// formatDataValue 把值格式化为 JSON，indent 为 false 时输出单行
+func formatDataValue(v any, indent bool) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// writeOutline 输出文档结构：对象和数组显示大小并展开 depth 层，标量显示截断后的值
+func writeOutline(b *strings.Builder, name string, v any, indent string, depth int) {
+	switch v := v.(type) {
+	case map[string]any:
+		fmt.Fprintf(b, "%s%s: 对象（%d 个键）\n", indent, name, len(v))
+		if depth == 0 {
+			return
+		}
+		nodes := children(dataNode{value: v})
+		for i, n := range nodes {
+			if i >= dataOutlineKeys {
+				fmt.Fprintf(b, "%s  … 还有 %d 个键\n", indent, len(nodes)-i)
+				break
+			}
+			writeOutline(b, strings.TrimPrefix(n.path, "."), n.value, indent+"  ", depth-1)
+		}
+	case []any:
+		fmt.Fprintf(b, "%s%s: 数组（%d 项）\n", indent, name, len(v))
+		if depth == 0 {
+			return
+		}
+		for i, item := range v {
+			if i >= dataOutlineSample {
+				fmt.Fprintf(b, "%s  … 还有 %d 项\n", indent, len(v)-i)
+				break
+			}
+			writeOutline(b, joinIndex("", i), item, indent+"  ", depth-1)
+		}
+	default:
+		value := formatDataValue(v, false)
+		if clipped := truncateRunes(value, dataOutlineRunes); clipped != value {
+			value = clipped + "…"
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", indent, name, value)
+	}
+}
+
+// queryTable 在 CSV/TSV 表格中执行查询，查询为空时返回列信息和开头几行
+func queryTable(params DataQueryParams, format string, data []byte) (string, error) {
+	comma := ','
+	if format == "tsv" {
+		comma = '\t'
+	}
+	table, err := parseCSV(data, comma)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if strings.TrimSpace(params.Query) == "" {
+		columns, sample := table.outline(dataOutlineSample)
+		fmt.Fprintf(&b, "文件: %s（%s，%d 行数据，%d 列）结构概览，可以用 query 查询:\n%s\n",
+			params.FilePath, strings.ToUpper(format), len(table.rows), len(table.header), columns)
+		fmt.Fprintf(&b, "前 %d 行:\n%s", len(sample), formatCSV(table.header, sample))
+		return b.String(), nil
+	}
+
+	query, err := parseCSVQuery(params.Query, table)
+	if err != nil {
+		return "", fmt.Errorf("查询表达式无效: %w", err)
+	}
+	header, rows, matched, err := query.run(table)
+	if err != nil {
+		return "", err
+	}
+
+	limit := params.MaxResults
+	if query.limit > 0 {
+		limit = min(query.limit, maxDataResults)
+	}
+	total := len(rows)
+	rows = rows[:min(limit, total)]
+	fmt.Fprintf(&b, "文件: %s（%s，%d 行数据，满足条件 %d 行），结果 %d 行",
+		params.FilePath, strings.ToUpper(format), len(table.rows), matched, len(rows))
+	if total > len(rows) {
+		fmt.Fprintf(&b, "（共 %d 行）", total)
+	}
+	fmt.Fprintf(&b, ":\n%s", formatCSV(header, rows))
+	if total > len(rows) && query.limit == 0 {
+		fmt.Fprintf(&b, "\n[只显示前 %d 行，请使用更具体的条件、limit 或提高 max_results]", len(rows))
+	}
+	return b.String(), nil
+}
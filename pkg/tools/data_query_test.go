@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// newDataFiles 创建用于查询测试的数据文件
+func newDataFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String("城市,人口\n北京,2189\n上海,2487\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: {app: web, "app.kubernetes.io/name": web}
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25
+          ports: [{containerPort: 80}]
+        - name: sidecar
+          image: envoy:1.30
+---
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+`,
+		"config.toml":  "title = \"demo\"\n\n[server]\nport = 8080\nhosts = [\"a\", \"b\"]\n",
+		"events.jsonl": "{\"level\": \"info\", \"msg\": \"start\"}\n\n{\"level\": \"error\", \"msg\": \"boom\"}\n",
+		"access.csv": "time,path,status,bytes\n" +
+			"1,/api/users,200,512\n" +
+			"2,/api/users,500,10\n" +
+			"3,/index.html,200,2048\n" +
+			"4,/api/orders,200,300\n" +
+			"5,/api/orders,404,\n",
+		"cities.csv": gbk,
+		"data.txt":   `{"a": 1}`,
+		"notes.txt":  "hello",
+		"image.png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestQueryPath(t *testing.T) {
+	var root any
+	doc := `{
+		"store": {
+			"book": [
+				{"title": "A", "price": 8.95, "tags": ["x"]},
+				{"title": "B", "price": 12.99, "isbn": "0-553"},
+				{"title": "C", "price": 22.99, "isbn": "0-395"}
+			],
+			"bicycle": {"color": "red", "price": 19.95},
+			"odd key": true
+		}
+	}`
+	if err := json.Unmarshal([]byte(doc), &root); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"$.store.bicycle.color", []string{`$.store.bicycle.color="red"`}},
+		{"store.bicycle.color", []string{`$.store.bicycle.color="red"`}},
+		{"$['store']['odd key']", []string{`$.store['odd key']=true`}},
+		{"$.store.book[-1].title", []string{`$.store.book[2].title="C"`}},
+		{"$.store.book[0,2].title", []string{`$.store.book[0].title="A"`, `$.store.book[2].title="C"`}},
+		{"$.store.book[1:].title", []string{`$.store.book[1].title="B"`, `$.store.book[2].title="C"`}},
+		{"$.store.book[::2].title", []string{`$.store.book[0].title="A"`, `$.store.book[2].title="C"`}},
+		{"$.store.book[*].price", []string{`$.store.book[0].price=8.95`, `$.store.book[1].price=12.99`, `$.store.book[2].price=22.99`}},
+		{"$..price", []string{`$.store.bicycle.price=19.95`, `$.store.book[0].price=8.95`, `$.store.book[1].price=12.99`, `$.store.book[2].price=22.99`}},
+		{"$.store.book[?(@.price < 10)].title", []string{`$.store.book[0].title="A"`}},
+		{"$.store.book[?(@.isbn)].title", []string{`$.store.book[1].title="B"`, `$.store.book[2].title="C"`}},
+		{"$.store.book[?(!@.isbn)].title", []string{`$.store.book[0].title="A"`}},
+		{"$.store.book[?(@.price > 10 && @.isbn =~ '^0-3')].title", []string{`$.store.book[2].title="C"`}},
+		{"$.store.book[?(@.title == 'A' || @.title == \"B\")].title", []string{`$.store.book[0].title="A"`, `$.store.book[1].title="B"`}},
+		{"$..[?(@.color == 'red')].price", []string{`$.store.bicycle.price=19.95`}},
+		{"$.store.book[1::9223372036854775807].title", []string{`$.store.book[1].title="B"`}},
+		{"$.store.book[-9223372036854775808:9223372036854775807:9223372036854775807].title", []string{`$.store.book[0].title="A"`}},
+		{"$.store.missing", nil},
+		{"$", []string{"$="}},
+	}
+	for _, tt := range tests {
+		nodes, err := queryPath(root, tt.expr)
+		if err != nil {
+			t.Errorf("queryPath(%s) 出现错误: %v", tt.expr, err)
+			continue
+		}
+		var got []string
+		for _, n := range nodes {
+			value := formatDataValue(n.value, false)
+			if n.path == "$" {
+				value = ""
+			}
+			got = append(got, n.path+"="+value)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("queryPath(%s) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"$.store[", "$.store.book[x]", "$.store.book[::0]", "$..book[?(price > 1)]", "$.a[?(@.b == x)]", "$.a[?(@.b =~ '(')]", "$.a[?(@.b == 'x]"} {
+		if _, err := queryPath(root, expr); err == nil {
+			t.Errorf("queryPath(%s) 应返回错误", expr)
+		}
+	}
+}
+
+func TestDataQuery_Documents(t *testing.T) {
+	dir := newDataFiles(t)
+	t.Chdir(dir)
+	dq := NewDataQuery()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		not   []string
+	}{
+		{"YAML 多文档", "deploy.yaml $[0].spec.replicas", []string{"$[0].spec.replicas = 3", "匹配 1 个值"}, nil},
+		{"过滤和递归", `{"file_path": "deploy.yaml", "query": "..containers[?(@.name == 'web')].image"}`,
+			[]string{`$[0].spec.template.spec.containers[0].image = "nginx:1.25"`}, []string{"envoy"}},
+		{"对象结果", "deploy.yaml $[0].metadata.labels", []string{"$[0].metadata.labels = {\n  \"app\": \"web\",\n  \"app.kubernetes.io/name\": \"web\"\n}"}, nil},
+		{"TOML", "config.toml server.hosts[-1]", []string{`$.server.hosts[1] = "b"`}, nil},
+		{"JSON Lines", `events.jsonl $[?(@.level == "error")].msg`, []string{`$[1].msg = "boom"`}, nil},
+		{"按内容识别 JSON", "data.txt a", []string{"$.a = 1"}, nil},
+		{"没有匹配", "config.toml $.missing", []string{"没有匹配", "顶层的键: server, title"}, nil},
+		{"根为数组时提示", "deploy.yaml kind", []string{"根是数组（2 项）"}, nil},
+		{"结果上限", `{"file_path": "deploy.yaml", "query": "$..name", "max_results": 2}`, []string{"匹配 4 个值", "已达到 2 个结果的上限"}, nil},
+		{"结构概览", "config.toml", []string{"结构概览", "$: 对象（2 个键）", "  server: 对象（2 个键）", "    hosts: 数组（2 项）", "    port: 8080", `  title: "demo"`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := dq.Call(context.Background(), tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(result, s) {
+					t.Errorf("结果应包含 %q:\n%s", s, result)
+				}
+			}
+			for _, s := range tt.not {
+				if strings.Contains(result, s) {
+					t.Errorf("结果不应包含 %q:\n%s", s, result)
+				}
+			}
+		})
+	}
+}
+
+func TestDataQuery_Table(t *testing.T) {
+	dir := newDataFiles(t)
+	t.Chdir(dir)
+	dq := NewDataQuery()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"结构概览", "", []string{"5 行数据，4 列", "列: time（数字）, path（文本）, status（数字）, bytes（数字）", "前 3 行:\ntime,path,status,bytes\n1,/api/users,200,512"}},
+		{"过滤和选择列", "select path, bytes where status = 200 and bytes >= 512",
+			[]string{"满足条件 2 行", "path,bytes\n/api/users,512\n/index.html,2048"}},
+		{"排序和限制", "where status != 200 or bytes > 1000 order by bytes desc limit 2",
+			[]string{"满足条件 3 行），结果 2 行（共 3 行）:", "3,/index.html,200,2048\n2,/api/users,500,10"}},
+		{"限制行数", "select time where path contains '/api' order by time limit 2",
+			[]string{"满足条件 4 行），结果 2 行（共 4 行）:\ntime\n1\n2"}},
+		{"限制大于结果", "select time where status = 200 limit 10", []string{"满足条件 3 行），结果 3 行:\n"}},
+		{"分组聚合", "select status, count(*), sum(bytes), avg(bytes), max(path) where path contains '/api' group by status order by count(*) desc",
+			[]string{"status,count(*),sum(bytes),avg(bytes),max(path)\n200,2,812,406,/api/users\n500,1,10,10,/api/users\n404,1,,,/api/orders"}},
+		{"默认分组结果", "group by path", []string{"path,count(*)\n/api/users,2\n/index.html,1\n/api/orders,2"}},
+		{"整体聚合", "select count(*), count(bytes), min(bytes) where path =~ '^/api/(users|orders)$'", []string{"count(*),count(bytes),min(bytes)\n4,3,10"}},
+		{"没有匹配的聚合", "select count(*) where status > 600", []string{"count(*)\n0"}},
+		{"not 和括号", "select time where not (status = 200 or path !~ 'orders')", []string{"time\n5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, _ := json.Marshal(DataQueryParams{FilePath: "access.csv", Query: tt.query})
+			result, err := dq.Call(context.Background(), string(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(result, s) {
+					t.Errorf("结果应包含 %q:\n%s", s, result)
+				}
+			}
+		})
+	}
+
+	// GBK 编码的 CSV 转换为 UTF-8 后查询
+	result, err := dq.Call(context.Background(), "cities.csv select 城市 where 人口 > 2200")
+	if err != nil || !strings.Contains(result, "城市\n上海") {
+		t.Errorf("GBK 文件的查询结果不正确: %q (%v)", result, err)
+	}
+
+	result, _ = dq.Call(context.Background(), `{"file_path": "access.csv", "max_results": 2}`)
+	if strings.Contains(result, "只显示") {
+		t.Errorf("结构概览不应受 max_results 影响:\n%s", result)
+	}
+	result, _ = dq.Call(context.Background(), `{"file_path": "access.csv", "query": "select time", "max_results": 2}`)
+	if !strings.Contains(result, "time\n1\n2\n[只显示前 2 行") {
+		t.Errorf("应在达到上限时提示:\n%s", result)
+	}
+}
+
+func TestDataQuery_Errors(t *testing.T) {
+	dir := newDataFiles(t)
+	t.Chdir(dir)
+	dq := NewDataQuery()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "文件路径不能为空"},
+		{"missing.json", "文件不存在"},
+		{"notes.txt", "请指定 format"},
+		{"image.png", "二进制文件"},
+		{`{"file_path": "data.txt", "format": "xml"}`, "不支持的格式"},
+		{`{"file_path": "events.jsonl", "format": "json"}`, "文件包含多个值"},
+		{"config.toml $.server[", "查询表达式无效"},
+		{"access.csv select nope", `没有列 "nope"`},
+		{"access.csv select path, count(*)", "需要出现在 group by 中"},
+		{"access.csv select sum(path)", `第 2 行的值 "/api/users" 不是数字`},
+		{"access.csv where status", "条件格式应为"},
+		{"access.csv where status like 2", "不支持的运算符"},
+		{"access.csv limit 0", "limit 应为正整数"},
+		{"access.csv select path order by time desc extra", `无法识别 "extra"`},
+		{"access.csv group by status order by time", "只能按结果中的列排序"},
+	}
+	for _, tt := range tests {
+		_, err := dq.Call(context.Background(), tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("输入 %q 应返回包含 %q 的错误, got %v", tt.input, tt.want, err)
+		}
+	}
+
+	dq.Workspace = NewWorkspace([]string{dir}, []string{filepath.Join(dir, "*.toml")})
+	if _, err := dq.Call(context.Background(), "config.toml title"); err == nil {
+		t.Error("不应允许读取禁止访问的文件")
+	}
+}
@@ -0,0 +1,265 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+const (
+	// diffContext 差异块前后保留的上下文行数
+	diffContext = 3
+	// maxDiffCells 比较过程中保存的状态上限，超过时不再逐行比较，按整体替换显示
+	maxDiffCells = 1 << 22
+	// maxDiffDisplayLines 终端中最多显示的差异行数
+	maxDiffDisplayLines = 400
+)
+
+// diffOp 行级编辑操作
+type diffOp struct {
+	// kind 为 ' '、'-' 或 '+'
+	kind byte
+	line string
+}
+
+// UnifiedDiff 生成 old 到 new 的统一格式差异（与 diff -u 相同），内容相同时返回空字符串
+func UnifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range diffHunks(ops) {
+		out.WriteString(h)
+	}
+	return out.String()
+}
+
+// splitLines 按行切分，保留"文件末尾没有换行"的信息
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines 计算 a 到 b 的编辑序列，相同的开头和结尾不参与比较
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff 用 Myers 算法计算最短编辑序列
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	total := n + m
+	offset := total + 1
+	v := make([]int, 2*total+2)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= total && (d+1)*len(v) <= maxDiffCells; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		// 差异过大，按整体替换处理
+		ops := make([]diffOp, 0, n+m)
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+
+	// 从终点回溯每一步的选择
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, diffOp{'+', b[y]})
+			} else {
+				x--
+				ops = append(ops, diffOp{'-', a[x]})
+			}
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// diffHunks 把编辑序列按上下文行数分组为差异块
+func diffHunks(ops []diffOp) []string {
+	var hunks []string
+	for i := 0; i < len(ops); {
+		// 找到下一处改动
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// 向后扩展，直到连续的未改动行超过两倍上下文
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			same := end
+			for same < len(ops) && ops[same].kind == ' ' {
+				same++
+			}
+			if same == len(ops) || same-end > 2*diffContext {
+				end += min(diffContext, same-end)
+				break
+			}
+			end = same
+		}
+		hunks = append(hunks, formatHunk(ops, start, end))
+		i = end
+	}
+	return hunks
+}
+
+// formatHunk 输出 ops[start:end] 对应的差异块
+func formatHunk(ops []diffOp, start, end int) string {
+	// 计算差异块在新旧文件中的起始行号
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+	var body strings.Builder
+	oldCount, newCount := 0, 0
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+		body.WriteByte(op.kind)
+		body.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	// 与 diff -u 一致：空范围的起始行号为前一行
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+	return fmt.Sprintf("@@ -%s +%s @@\n%s", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount), body.String())
+}
+
+// hunkRange 格式化差异块的行范围
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// FprintDiff 按行着色显示差异，超过 maxDiffDisplayLines 行时截断
+func FprintDiff(w io.Writer, diff string) {
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+	cyan := color.New(color.FgCyan)
+	bold := color.New(color.Bold)
+
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		if i == maxDiffDisplayLines {
+			color.New(color.Faint).Fprintf(w, "... 还有 %d 行差异未显示\n", len(lines)-i)
+			return
+		}
+		switch {
+		case i < 2 && (strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++")):
+			bold.Fprintln(w, line)
+		case strings.HasPrefix(line, "@@"):
+			cyan.Fprintln(w, line)
+		case strings.HasPrefix(line, "-"):
+			red.Fprintln(w, line)
+		case strings.HasPrefix(line, "+"):
+			green.Fprintln(w, line)
+		default:
+			fmt.Fprintln(w, line)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tmc/langchaingo/callbacks"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// 目录列表的默认值和上限
+const (
+	// defaultListDepth 默认展开的层数
+	defaultListDepth = 2
+	// maxListDepth depth 的上限
+	maxListDepth = 10
+	// defaultListEntries 默认最多列出的条目数
+	defaultListEntries = 200
+	// maxListEntries max_entries 的上限
+	maxListEntries = 2000
+	// fileTypeSniffSize 识别文件类型时读取的开头字节数
+	fileTypeSniffSize = 512
+)
+
+// fileTypesByExt 按扩展名识别的文件类型
+var fileTypesByExt = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".mjs": "JavaScript", ".ts": "TypeScript", ".tsx": "TypeScript",
+	".jsx": "JavaScript", ".java": "Java", ".kt": "Kotlin", ".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".hpp": "C++",
+	".rs": "Rust", ".rb": "Ruby", ".php": "PHP", ".swift": "Swift", ".cs": "C#", ".lua": "Lua",
+	".sh": "Shell", ".bash": "Shell", ".zsh": "Shell", ".ps1": "PowerShell", ".bat": "批处理",
+	".md": "Markdown", ".txt": "文本", ".log": "日志", ".rst": "reStructuredText",
+	".json": "JSON", ".yaml": "YAML", ".yml": "YAML", ".toml": "TOML", ".ini": "INI", ".conf": "配置", ".env": "环境变量",
+	".xml": "XML", ".html": "HTML", ".css": "CSS", ".sql": "SQL", ".csv": "CSV", ".proto": "Protobuf",
+	".mod": "Go 模块", ".sum": "Go 校验和", ".lock": "锁文件",
+}
+
+// fileTypesByName 按文件名识别的文件类型
+var fileTypesByName = map[string]string{
+	"Makefile": "Makefile", "Dockerfile": "Dockerfile", "Containerfile": "Dockerfile", "Jenkinsfile": "Jenkinsfile",
+	"LICENSE": "许可证", "README": "文本", ".gitignore": "gitignore", ".dockerignore": "dockerignore", ".editorconfig": "EditorConfig",
+}
+
+// DirListParams 列出目录的参数结构
+type DirListParams struct {
+	Path           string `json:"path,omitempty" desc:"要列出的目录，默认为当前目录"`
+	Depth          int    `json:"depth,omitempty" desc:"展开的层数，1 表示只列出直接子项，默认2，最多10"`
+	ShowHidden     bool   `json:"show_hidden,omitempty" desc:"显示以 . 开头的隐藏文件和目录"`
+	IncludeIgnored bool   `json:"include_ignored,omitempty" desc:"显示被 .gitignore 忽略的文件和目录"`
+	MaxEntries     int    `json:"max_entries,omitempty" desc:"最多列出的条目数，默认200，最多2000"`
+}
+
+// DirList 以树形列出目录内容及每项的大小、权限、修改时间和文件类型的工具，遵循 .gitignore
+type DirList struct {
+	CallbacksHandler callbacks.Handler
+	// Audit 审计日志，为空时不记录
+	Audit *audit.Logger
+	// Workspace 访问范围，为空时不限制
+	Workspace *Workspace
+}
+
+// NewDirList 创建新的目录列表工具
+func NewDirList() *DirList {
+	return &DirList{}
+}
+
+// Name 返回工具名称
+func (d *DirList) Name() string {
+	return "dir_list"
+}
+
+// Description 返回工具描述
+func (d *DirList) Description() string {
+	return `以树形列出目录内容的工具，用于了解项目结构。需要查看目录时优先使用本工具，不要通过 system_command 执行 ls/tree。
+输入格式：JSON字符串，也可以直接输入目录路径
+{
+  "path": "目录（默认为当前目录）",
+  "depth": 2,
+  "show_hidden": false,
+  "include_ignored": false,
+  "max_entries": 200
+}
+
+每行一项，按层级缩进，目录以 / 结尾并排在文件前面：名称、大小、权限、修改时间、文件类型；
+未展开的目录显示其中的条目数。默认跳过隐藏文件和 .gitignore 忽略的路径，最后给出目录数、文件数和总大小。
+
+示例：
+- "." - 列出当前目录两层
+- {"path": "pkg", "depth": 1} - 只列出 pkg 的直接子项
+- {"depth": 4, "max_entries": 500} - 展开更深的层级`
+}
+
+// Call 列出目录，每次调用都写入审计日志
+func (d *DirList) Call(ctx context.Context, input string) (string, error) {
+	return audited(ctx, d.Audit, d.Name(), d.Workspace.Dir(), input, func(ctx context.Context) (string, error) {
+		return d.call(ctx, input)
+	})
+}
+
+// call 列出目录
+func (d *DirList) call(ctx context.Context, input string) (string, error) {
+	if d.CallbacksHandler != nil {
+		d.CallbacksHandler.HandleToolStart(ctx, input)
+	}
+
+	params, err := d.parseInput(input)
+	if err != nil {
+		return "", fmt.Errorf("参数解析失败: %w", err)
+	}
+
+	root, err := d.Workspace.Authorize(ctx, params.Path, "列出")
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("目录不存在: %s", params.Path)
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("%s 不是目录:\n%s", params.Path, formatListEntry(root, info, "")), nil
+	}
+
+	l := &dirLister{params: params, workspace: d.Workspace}
+	if !params.IncludeIgnored {
+		l.ignore = newGitignore(root)
+	}
+	if err := l.list(ctx, root, 1); err != nil {
+		return "", err
+	}
+	result := l.result()
+
+	if d.CallbacksHandler != nil {
+		d.CallbacksHandler.HandleToolEnd(ctx, result)
+	}
+	return result, nil
+}
+
+// parseInput 解析输入参数：JSON 参数，或者直接作为目录路径
+func (d *DirList) parseInput(input string) (DirListParams, error) {
+	input = strings.TrimSpace(input)
+	var params DirListParams
+	if strings.HasPrefix(input, "{") && strings.HasSuffix(input, "}") {
+		if err := json.Unmarshal([]byte(input), &params); err != nil {
+			return params, fmt.Errorf("JSON解析失败: %w", err)
+		}
+	} else {
+		params.Path = input
+	}
+
+	if strings.TrimSpace(params.Path) == "" {
+		params.Path = "."
+	}
+	switch {
+	case params.Depth < 0:
+		return params, fmt.Errorf("depth 不能为负数")
+	case params.Depth == 0:
+		params.Depth = defaultListDepth
+	case params.Depth > maxListDepth:
+		params.Depth = maxListDepth
+	}
+	switch {
+	case params.MaxEntries < 0:
+		return params, fmt.Errorf("max_entries 不能为负数")
+	case params.MaxEntries == 0:
+		params.MaxEntries = defaultListEntries
+	case params.MaxEntries > maxListEntries:
+		params.MaxEntries = maxListEntries
+	}
+	return params, nil
+}
+
+// Parameters 返回参数的 JSON Schema
+func (d *DirList) Parameters() map[string]any {
+	return SchemaFor(DirListParams{})
+}
+
+// Concurrent 列出目录是只读操作，可以并行执行
+func (d *DirList) Concurrent() bool {
+	return true
+}
+
+// dirLister 一次目录列表的遍历状态
+type dirLister struct {
+	params    DirListParams
+	workspace *Workspace
+	ignore    *gitignore
+
+	out       strings.Builder
+	entries   int
+	dirs      int
+	files     int
+	size      int64
+	hidden    int
+	ignored   int
+	truncated bool
+}
+
+// list 列出目录的子项，level 为子项所在的层级（从 1 开始）
+func (l *dirLister) list(ctx context.Context, dir string, level int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(&l.out, "%s[无法读取: %v]\n", indent(level), err)
+		return nil
+	}
+	// 起点目录的规则在创建 gitignore 时已经加载
+	if level > 1 {
+		l.ignore.load(dir)
+	}
+
+	// 目录排在文件前面，同类按名称排序
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].IsDir() && !children[j].IsDir()
+	})
+
+	for _, child := range children {
+		path := filepath.Join(dir, child.Name())
+		if !l.visible(path, child) {
+			continue
+		}
+		if l.entries >= l.params.MaxEntries {
+			l.truncated = true
+			return nil
+		}
+		info, err := child.Info()
+		if err != nil {
+			continue
+		}
+		l.entries++
+
+		if !child.IsDir() {
+			l.files++
+			if info.Mode().IsRegular() {
+				l.size += info.Size()
+			}
+			l.out.WriteString(formatListEntry(path, info, indent(level)) + "\n")
+			continue
+		}
+
+		l.dirs++
+		// .git 目录只列出不展开
+		if level >= l.params.Depth || child.Name() == ".git" {
+			l.out.WriteString(formatListEntry(path, info, indent(level)) + fmt.Sprintf("（%d 项）\n", countEntries(path)))
+			continue
+		}
+		l.out.WriteString(formatListEntry(path, info, indent(level)) + "\n")
+		if err := l.list(ctx, path, level+1); err != nil {
+			return err
+		}
+		if l.truncated {
+			return nil
+		}
+	}
+	return nil
+}
+
+// visible 判断条目是否列出，并统计被隐藏和忽略的条目
+func (l *dirLister) visible(path string, entry fs.DirEntry) bool {
+	if l.workspace.denies(path) {
+		return false
+	}
+	if !l.params.ShowHidden && strings.HasPrefix(entry.Name(), ".") {
+		l.hidden++
+		return false
+	}
+	if l.ignore.Ignored(path, entry.IsDir()) {
+		l.ignored++
+		return false
+	}
+	return true
+}
+
+// result 生成列表和汇总信息
+func (l *dirLister) result() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "目录: %s（展开 %d 层）\n", l.params.Path, l.params.Depth)
+	if l.entries == 0 {
+		b.WriteString("（空目录）\n")
+	}
+	b.WriteString(l.out.String())
+
+	fmt.Fprintf(&b, "共 %d 个目录、%d 个文件，文件总大小 %s", l.dirs, l.files, utils.FormatBytes(l.size))
+	var skipped []string
+	if l.hidden > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d 个隐藏项（show_hidden）", l.hidden))
+	}
+	if l.ignored > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d 个被 .gitignore 忽略的项（include_ignored）", l.ignored))
+	}
+	if len(skipped) > 0 {
+		b.WriteString("；未显示 " + strings.Join(skipped, "、"))
+	}
+	if l.truncated {
+		fmt.Fprintf(&b, "\n[已达到 %d 项的上限，请指定子目录、减小 depth 或提高 max_entries]", l.params.MaxEntries)
+	}
+	return b.String()
+}
+
+// indent 返回层级对应的缩进
+func indent(level int) string {
+	return strings.Repeat("  ", level-1)
+}
+
+// countEntries 统计目录中的条目数
+func countEntries(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// formatListEntry 格式化一项：名称、大小、权限、修改时间和文件类型
+func formatListEntry(path string, info fs.FileInfo, prefix string) string {
+	name := info.Name()
+	size := utils.FormatBytes(info.Size())
+	if info.IsDir() {
+		name += "/"
+		size = "-"
+	}
+	return fmt.Sprintf("%s%s  %s  %s  %s  %s", prefix, name, size, info.Mode().String(),
+		info.ModTime().Format("2006-01-02 15:04"), detectFileType(path, info))
+}
+
+// detectFileType 识别文件类型：目录、符号链接、按名称或扩展名识别，其余按文件开头的内容判断
+func detectFileType(path string, info fs.FileInfo) string {
+	mode := info.Mode()
+	switch {
+	case mode.IsDir():
+		return "目录"
+	case mode&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "符号链接"
+		}
+		return "符号链接 -> " + target
+	case mode&fs.ModeNamedPipe != 0:
+		return "命名管道"
+	case mode&fs.ModeSocket != 0:
+		return "套接字"
+	case mode&fs.ModeDevice != 0:
+		return "设备"
+	case !mode.IsRegular():
+		return "特殊文件"
+	}
+
+	name := info.Name()
+	if t, ok := fileTypesByName[name]; ok {
+		return t
+	}
+	if t, ok := fileTypesByExt[strings.ToLower(filepath.Ext(name))]; ok {
+		return t
+	}
+	if info.Size() == 0 {
+		return "空文件"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "未知"
+	}
+	defer f.Close()
+	head := make([]byte, fileTypeSniffSize)
+	n, _ := f.Read(head)
+	return sniffFileType(head[:n], mode&0111 != 0)
+}
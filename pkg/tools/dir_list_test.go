@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// listedNames 提取目录列表中每一行的缩进和名称
+func listedNames(result string) []string {
+	var names []string
+	for _, line := range strings.Split(result, "\n")[1:] {
+		if line == "" || strings.HasPrefix(line, "共 ") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		name, _, _ := strings.Cut(trimmed, "  ")
+		names = append(names, line[:len(line)-len(trimmed)]+name)
+	}
+	return names
+}
+
+func TestDirList_Tree(t *testing.T) {
+	dir := newSearchTree(t)
+	t.Chdir(dir)
+	dl := NewDirList()
+
+	result, err := dl.Call(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"bin/", "  tool",
+		"docs/", "  regex.md", "  secret.txt",
+		"nested/", "  deep/",
+		"pkg/", "  keep.log", "  util.go", "  util_test.go",
+		"context.txt", "main.go",
+	}
+	if got := listedNames(result); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("列出的条目 = %v\nwant %v\n%s", got, want, result)
+	}
+	for _, s := range []string{
+		"main.go  40B  -rw-r--r--  ",
+		"  Go\n",
+		"  deep/  -  drwxr-xr-x  ",
+		"目录（1 项）",
+		"共 5 个目录、8 个文件，文件总大小 249B",
+		"未显示 3 个隐藏项（show_hidden）、4 个被 .gitignore 忽略的项（include_ignored）",
+	} {
+		if !strings.Contains(result, s) {
+			t.Errorf("结果应包含 %q:\n%s", s, result)
+		}
+	}
+
+	result, err = dl.Call(context.Background(), `{"depth": 1, "show_hidden": true, "include_ignored": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{".git/", ".gitignore  ", "build/", "debug.log", "secret.txt"} {
+		if !strings.Contains(result, s) {
+			t.Errorf("应列出隐藏和被忽略的项 %q:\n%s", s, result)
+		}
+	}
+	if strings.Contains(result, "HEAD") || strings.Contains(result, "util.go") {
+		t.Errorf("depth 1 不应展开子目录:\n%s", result)
+	}
+}
+
+func TestDirList_Limits(t *testing.T) {
+	dir := newSearchTree(t)
+	dl := NewDirList()
+	dl.Workspace = NewWorkspace([]string{dir}, []string{filepath.Join(dir, "docs")})
+
+	result, err := dl.Call(context.Background(), `{"path": "`+dir+`", "max_entries": 3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listedNames(result)) != 3 || !strings.Contains(result, "已达到 3 项的上限") {
+		t.Errorf("应在达到上限时停止并提示:\n%s", result)
+	}
+
+	result, _ = dl.Call(context.Background(), dir)
+	if strings.Contains(result, "docs") {
+		t.Errorf("不应列出禁止访问的路径:\n%s", result)
+	}
+
+	result, err = dl.Call(context.Background(), filepath.Join(dir, "main.go"))
+	if err != nil || !strings.Contains(result, "不是目录") || !strings.Contains(result, "main.go  40B") {
+		t.Errorf("文件路径应返回该文件的信息, got %q (%v)", result, err)
+	}
+
+	for _, input := range []string{filepath.Join(dir, "missing"), `{"depth": -1}`, `{"path": "` + filepath.Join(dir, "docs") + `"}`} {
+		if _, err := dl.Call(context.Background(), input); err == nil {
+			t.Errorf("输入 %q 应返回错误", input)
+		}
+	}
+}
+
+func TestDetectFileType(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		want    string
+	}{
+		{"main.go", "package main", 0644, "Go"},
+		{"Makefile", "all:", 0644, "Makefile"},
+		{"empty", "", 0644, "空文件"},
+		{"notes", "hello\n世界\n", 0644, "文本"},
+		{"run", "#!/usr/bin/env python3\nprint(1)\n", 0755, "脚本 (python3)"},
+		{"tool", "\x7fELF\x02\x01\x01\x00", 0755, "ELF 可执行文件"},
+		{"logo", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", 0644, "图片 (png)"},
+		{"bundle", "PK\x03\x04\x14\x00\x00\x00", 0644, "压缩包 (zip)"},
+		{"data", "\x00\x01\x02\x03\xff\xfe", 0644, "二进制"},
+		{"hook", "echo hi\n", 0755, "可执行文本"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.content), tt.mode); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := detectFileType(path, info); got != tt.want {
+			t.Errorf("detectFileType(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("main.go", link); err == nil {
+		info, _ := os.Lstat(link)
+		if got := detectFileType(link, info); got != "符号链接 -> main.go" {
+			t.Errorf("detectFileType(link) = %q", got)
+		}
+	}
+}
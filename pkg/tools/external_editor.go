@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// controllingTerminal 控制终端的设备路径，Windows 上不存在，使用标准输入和 out
+const controllingTerminal = "/dev/tty"
+
+// editorCommand 返回用户的编辑器命令：$VISUAL、$EDITOR，都未设置时使用 vi（Windows 为 notepad）
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// openInEditor 把内容写入临时文件并用编辑器打开，返回保存后的内容。
+// 临时文件沿用 path 的扩展名，便于编辑器识别语法。编辑器连接到控制终端，
+// 没有控制终端时输出写入 out，不占用标准输出（-c 模式下标准输出是回答或 JSON）。
+func openInEditor(path, content string, out io.Writer) (string, error) {
+	tmp, err := os.CreateTemp("", "aishell-*"+filepath.Ext(path))
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("写入临时文件失败: %w", err)
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], tmp.Name())...)
+	if tty, err := os.OpenFile(controllingTerminal, os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	} else {
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, out, os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("编辑器 %s 异常退出: %w", editor[0], err)
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("读取编辑后的内容失败: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,258 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tmc/langchaingo/callbacks"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/backup"
+	"github.com/dean2027/aishell/pkg/policy"
+)
+
+// FileEdit 一处查找替换
+type FileEdit struct {
+	Search  string `json:"search" desc:"要替换的原文，必须与文件内容逐字符一致（包括缩进和空白），并且在文件中只出现一次"`
+	Replace string `json:"replace" desc:"替换后的内容，为空表示删除原文"`
+}
+
+// FileEditParams 编辑文件的参数结构，edits 和 diff 二选一
+type FileEditParams struct {
+	FilePath string     `json:"file_path" desc:"要编辑的文件路径，支持相对路径和绝对路径，文件必须已存在"`
+	Edits    []FileEdit `json:"edits,omitempty" desc:"查找替换列表，全部在原文件上定位后一次性应用"`
+	Diff     string     `json:"diff,omitempty" desc:"统一格式差异（diff -u），与 edits 二选一"`
+}
+
+// FileEditor 按查找替换或统一差异局部修改文件的工具，修改全部定位成功后才原子写入
+type FileEditor struct {
+	CallbacksHandler callbacks.Handler
+	// Planner 演练模式状态，启用时只记录修改不执行
+	Planner *Planner
+	// Policy 写入策略，决定哪些路径写入前需要展示差异并确认，为空时直接写入
+	Policy *policy.Policy
+	// Workspace 访问范围，为空时不限制
+	Workspace *Workspace
+	// Approver 写入确认器，为空时按交互模式询问
+	Approver *Approver
+	// Audit 审计日志，为空时不记录
+	Audit *audit.Logger
+	// Backups 备份存储，设置后每次修改前保存原内容，可以撤销
+	Backups *backup.Store
+	// BackupSession 备份所属的会话 ID
+	BackupSession string
+}
+
+// NewFileEditor 创建新的文件编辑工具
+func NewFileEditor() *FileEditor {
+	return &FileEditor{}
+}
+
+// Name 返回工具名称
+func (f *FileEditor) Name() string {
+	return "file_editor"
+}
+
+// Description 返回工具描述
+func (f *FileEditor) Description() string {
+	return `局部修改已有文件的工具。修改已有文件时优先使用本工具，不需要像 file_writer 那样重新发送整个文件。
+输入格式：JSON字符串，edits 和 diff 二选一
+{
+  "file_path": "文件路径（必需）",
+  "edits": [{"search": "要替换的原文", "replace": "替换后的内容"}],
+  "diff": "统一格式差异"
+}
+
+参数说明：
+- file_path (必需): 要编辑的文件路径，文件必须已存在
+- edits (可选): 查找替换列表。search 必须与文件内容逐字符一致（包括缩进），且在文件中只出现一次；
+  出现多次时请包含更多上下文行
+- diff (可选): 统一格式差异（diff -u），差异块中的上下文和删除行必须与文件内容一致
+
+所有修改都在原文件上定位，任何一处定位失败时文件保持不变。成功后返回每处修改后的内容，
+行号格式与 file_reader 相同。
+
+示例：
+{"file_path": "main.go", "edits": [{"search": "port := 8080", "replace": "port := 9090"}]}
+{"file_path": "config.yaml", "diff": "@@ -3,3 +3,3 @@\n server:\n-  port: 80\n+  port: 8080\n   host: 0.0.0.0\n"}`
+}
+
+// Parameters 返回参数的 JSON Schema
+func (f *FileEditor) Parameters() map[string]any {
+	return SchemaFor(FileEditParams{})
+}
+
+// Call 执行文件编辑，每次调用都写入审计日志
+func (f *FileEditor) Call(ctx context.Context, input string) (string, error) {
+	return audited(ctx, f.Audit, f.Name(), f.Workspace.Dir(), input, func(ctx context.Context) (string, error) {
+		return f.call(ctx, input)
+	})
+}
+
+// call 执行文件编辑
+func (f *FileEditor) call(ctx context.Context, input string) (string, error) {
+	if f.CallbacksHandler != nil {
+		f.CallbacksHandler.HandleToolStart(ctx, input)
+	}
+
+	params, err := f.parseInput(input)
+	if err != nil {
+		return "", fmt.Errorf("参数解析失败: %w", err)
+	}
+	edits, err := params.textEdits()
+	if err != nil {
+		return "", fmt.Errorf("参数验证失败: %w", err)
+	}
+	if err := validateWritePath(params.FilePath); err != nil {
+		return "", fmt.Errorf("参数验证失败: %w", err)
+	}
+
+	// 检查访问范围：敏感路径直接拒绝，工作区之外的文件在执行时需要确认
+	absPath, inside, err := f.Workspace.Check(params.FilePath)
+	if err != nil {
+		CallInfoFrom(ctx).SetStatus(audit.StatusDenied)
+		return "", err
+	}
+	if !inside && f.Planner.Active(ctx) {
+		// 演练模式不询问，也不读取工作区之外的文件，修改能否应用在执行这一步时再检查
+		CallInfoFrom(ctx).SetStatus(audit.StatusPlanned)
+		result := f.recordPlan(input, absPath, len(edits), inside)
+		if f.CallbacksHandler != nil {
+			f.CallbacksHandler.HandleToolEnd(ctx, result)
+		}
+		return result, nil
+	}
+	if !inside {
+		if _, err := f.Workspace.Authorize(ctx, absPath, "编辑"); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	original := string(data)
+
+	content, regions, err := applyEdits(original, edits)
+	if err != nil {
+		return "", fmt.Errorf("修改未应用，文件保持不变: %w", err)
+	}
+
+	// 演练模式：记录到计划中，返回模拟结果
+	if f.Planner.Active(ctx) {
+		CallInfoFrom(ctx).SetStatus(audit.StatusPlanned)
+		result := f.recordPlan(input, absPath, len(regions), inside)
+		if f.CallbacksHandler != nil {
+			f.CallbacksHandler.HandleToolEnd(ctx, result)
+		}
+		return result, nil
+	}
+
+	// 写入策略检查，与 file_writer 相同
+	note, ok := reviewWrite(ctx, f.Policy, f.Approver, absPath, &content)
+	if !ok {
+		if f.CallbacksHandler != nil {
+			f.CallbacksHandler.HandleToolEnd(ctx, note)
+		}
+		return note, nil
+	}
+
+	if f.Backups != nil {
+		if _, err := f.Backups.Snapshot(f.BackupSession, absPath); err != nil {
+			return "", fmt.Errorf("备份原文件失败: %w", err)
+		}
+	}
+	// 写入符号链接指向的真实文件，重命名不会替换链接本身
+	if err := writeFileAtomic(realPath(absPath), []byte(content)); err != nil {
+		return "", fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	result := fmt.Sprintf("成功编辑文件: %s\n应用了 %d 处修改", absPath, len(regions))
+	if note != "" {
+		// 用户在编辑器中改过内容，修改位置已不可靠
+		result += "\n" + note
+	} else {
+		result += "，修改后的内容:\n" + formatEditedHunks(content, regions)
+	}
+	if f.Backups != nil {
+		result += "\n原文件已备份，用户可以输入 /undo 撤销这次修改"
+	}
+
+	if f.CallbacksHandler != nil {
+		f.CallbacksHandler.HandleToolEnd(ctx, result)
+	}
+	return result, nil
+}
+
+// parseInput 解析 JSON 输入
+func (f *FileEditor) parseInput(input string) (*FileEditParams, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, fmt.Errorf("输入不能为空")
+	}
+	var params FileEditParams
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return nil, fmt.Errorf("JSON解析失败: %w", err)
+	}
+	return &params, nil
+}
+
+// textEdits 把参数转换为待应用的替换
+func (p *FileEditParams) textEdits() ([]textEdit, error) {
+	switch {
+	case len(p.Edits) > 0 && p.Diff != "":
+		return nil, fmt.Errorf("edits 和 diff 只能提供一个")
+	case p.Diff != "":
+		return parseUnifiedDiff(p.Diff)
+	case len(p.Edits) > 0:
+		edits := make([]textEdit, 0, len(p.Edits))
+		for _, e := range p.Edits {
+			edits = append(edits, textEdit{search: e.Search, replace: e.Replace})
+		}
+		return edits, nil
+	}
+	return nil, fmt.Errorf("需要提供 edits 或 diff")
+}
+
+// recordPlan 把编辑操作记录为计划步骤，返回给模型的模拟结果
+func (f *FileEditor) recordPlan(input, absPath string, edits int, inside bool) string {
+	step := PlanStep{
+		Tool:    f.Name(),
+		Input:   input,
+		Summary: fmt.Sprintf("编辑文件: %s (%d 处修改)", absPath, edits),
+	}
+	if !inside {
+		step.Risks = append(step.Risks, "文件位于工作区之外，执行时需要确认")
+	}
+	n := f.Planner.Record(step)
+	return fmt.Sprintf("[演练模式] 文件未修改，已记录为计划第 %d 步: %s", n, step.Summary)
+}
+
+// writeFileAtomic 先写入同目录的临时文件再重命名，保留原文件权限，避免写到一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
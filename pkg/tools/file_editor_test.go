@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dean2027/aishell/pkg/backup"
+)
+
+const editorTestFile = `package main
+
+import "fmt"
+
+func main() {
+	port := 8080
+	fmt.Println("listening on", port)
+}
+`
+
+// callEditor 在临时文件上调用 file_editor，返回结果和修改后的文件内容
+func callEditor(t *testing.T, fe *FileEditor, content string, params FileEditParams) (string, string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+	params.FilePath = path
+	input, _ := json.Marshal(params)
+	result, err := fe.Call(context.Background(), string(input))
+	data, _ := os.ReadFile(path)
+	return result, string(data), err
+}
+
+func TestFileEditor_SearchReplace(t *testing.T) {
+	result, content, err := callEditor(t, NewFileEditor(), editorTestFile, FileEditParams{
+		Edits: []FileEdit{
+			{Search: "port := 8080", Replace: "port := 9090"},
+			{Search: `import "fmt"`, Replace: "import (\n\t\"fmt\"\n\t\"os\"\n)"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(strings.Replace(editorTestFile, "8080", "9090", 1), `import "fmt"`, "import (\n\t\"fmt\"\n\t\"os\"\n)", 1)
+	if content != want {
+		t.Errorf("unexpected content:\n%s", content)
+	}
+	if !strings.Contains(result, "     9|\tport := 9090") {
+		t.Errorf("结果应包含带行号的修改后内容: %s", result)
+	}
+	if !strings.Contains(result, "     3|import (") {
+		t.Errorf("结果应包含第一处修改: %s", result)
+	}
+}
+
+func TestFileEditor_UnifiedDiff(t *testing.T) {
+	diff := `--- a/main.go
++++ b/main.go
+@@ -5,4 +5,5 @@
+ func main() {
+-	port := 8080
++	port := 9090
++	host := "localhost"
+ 	fmt.Println("listening on", port)
+`
+	result, content, err := callEditor(t, NewFileEditor(), editorTestFile, FileEditParams{Diff: diff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "\tport := 9090\n\thost := \"localhost\"\n") {
+		t.Errorf("unexpected content:\n%s", content)
+	}
+	if !strings.Contains(result, "     7|\thost := \"localhost\"") {
+		t.Errorf("unexpected result: %s", result)
+	}
+}
+
+func TestFileEditor_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		params  FileEditParams
+		want    string
+	}{
+		{
+			name:    "原文不存在",
+			content: editorTestFile,
+			params:  FileEditParams{Edits: []FileEdit{{Search: "port := 80\n", Replace: "x"}}},
+			want:    "不存在",
+		},
+		{
+			name:    "原文出现多次",
+			content: "a = 1\nb = 1\n",
+			params:  FileEditParams{Edits: []FileEdit{{Search: " = 1", Replace: " = 2"}}},
+			want:    "出现了 2 次（第 1、2 行）",
+		},
+		{
+			name:    "修改相互重叠",
+			content: editorTestFile,
+			params: FileEditParams{Edits: []FileEdit{
+				{Search: "func main() {\n\tport", Replace: "x"},
+				{Search: "port := 8080", Replace: "y"},
+			}},
+			want: "重叠",
+		},
+		{
+			name:    "差异上下文不按行对齐",
+			content: "xport := 8080\n",
+			params:  FileEditParams{Diff: "@@ -1 +1 @@\n-port := 8080\n+port := 9090\n"},
+			want:    "不存在",
+		},
+		{
+			name:    "同时提供 edits 和 diff",
+			content: editorTestFile,
+			params:  FileEditParams{Edits: []FileEdit{{Search: "a", Replace: "b"}}, Diff: "@@ -1 +1 @@\n-a\n+b\n"},
+			want:    "只能提供一个",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, content, err := callEditor(t, NewFileEditor(), tt.content, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want containing %q", err, tt.want)
+			}
+			if content != tt.content {
+				t.Errorf("失败时文件不应改变，得到:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestFileEditor_PreservesModeAndLineEndings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(path, []byte("echo one\r\necho two\r\n"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	fe := NewFileEditor()
+	fe.Backups = backup.NewStore(filepath.Join(t.TempDir(), "backups"))
+	fe.BackupSession = "s1"
+
+	input := `{"file_path": "` + filepath.ToSlash(path) + `", "edits": [{"search": "echo two\n", "replace": "echo 2\necho 3\n"}]}`
+	if _, err := fe.Call(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "echo one\r\necho 2\r\necho 3\r\n" {
+		t.Errorf("应沿用 CRLF 换行，得到 %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0750 && os.PathSeparator == '/' {
+		t.Errorf("应保留文件权限，得到 %v", info.Mode().Perm())
+	}
+
+	if _, err := fe.Backups.Undo("s1", path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "echo one\r\necho two\r\n" {
+		t.Errorf("撤销后应恢复原内容，得到 %q", data)
+	}
+}
+
+func TestFileEditor_DryRunOutsideWorkspace(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(outside, []byte(editorTestFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fe := NewFileEditor()
+	fe.Planner = NewPlanner(true)
+	fe.Workspace = NewWorkspace([]string{root}, []string{filepath.Join(root, "secret.go")})
+	fe.Workspace.Approver = &Approver{Mode: ApprovalNo}
+
+	// 工作区之外的文件不读取：不存在的搜索内容也不会报错，只记录计划
+	input, _ := json.Marshal(FileEditParams{FilePath: outside, Edits: []FileEdit{{Search: "not in file", Replace: "x"}}})
+	result, err := fe.Call(context.Background(), string(input))
+	if err != nil || !strings.Contains(result, "[演练模式]") {
+		t.Fatalf("演练模式应只记录计划, got %q (%v)", result, err)
+	}
+	if steps := fe.Planner.Take(); len(steps) != 1 || len(steps[0].Risks) != 1 {
+		t.Errorf("应记录 1 个带工作区提示的步骤, got %+v", steps)
+	}
+
+	// 敏感路径在演练模式下同样拒绝
+	input, _ = json.Marshal(FileEditParams{FilePath: filepath.Join(root, "secret.go"), Edits: []FileEdit{{Search: "a", Replace: "b"}}})
+	if _, err := fe.Call(context.Background(), string(input)); err == nil {
+		t.Error("演练模式下也应拒绝编辑敏感路径")
+	}
+}
+
+func TestParseUnifiedDiff_NoNewlineAtEOF(t *testing.T) {
+	edits, err := parseUnifiedDiff("@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _, err := applyEdits("a\nb", edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "a\nb\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
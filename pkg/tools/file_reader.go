@@ -2,24 +2,57 @@ package tools
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/tmc/langchaingo/callbacks"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+const (
+	// maxReadLineBytes 每行保留的最大字节数，超出部分只计入长度
+	maxReadLineBytes = 64 * 1024
+	// maxReadLineRunes 每行显示的最大字符数，超长的行截断并注明整行长度
+	maxReadLineRunes = 2000
+	// maxLineCountSize 统计总行数的文件大小上限，更大的文件读到结束行就停止
+	maxLineCountSize = 256 * 1024 * 1024
+	// binaryDumpSize 二进制文件摘要中十六进制显示的开头字节数
+	binaryDumpSize = 256
 )
 
+// FileReadParams 读文件的参数结构
+type FileReadParams struct {
+	FilePath  string `json:"file_path" desc:"要读取的文件路径，支持相对路径和绝对路径"`
+	StartLine int    `json:"start_line,omitempty" desc:"起始行号，从1开始，默认为1"`
+	EndLine   int    `json:"end_line,omitempty" desc:"结束行号，必须大于等于start_line，默认为start_line+99"`
+}
+
 // FileReader 文件读取工具
 type FileReader struct {
 	CallbacksHandler callbacks.Handler
+	// Budget 返回给模型的输出预算，为空时不限制
+	Budget *OutputBudget
+	// Audit 审计日志，为空时不记录
+	Audit *audit.Logger
+	// Workspace 访问范围，为空时不限制
+	Workspace *Workspace
 }
 
 // NewFileReader 创建新的文件读取工具
 func NewFileReader() *FileReader {
-	return &FileReader{}
+	return &FileReader{
+		// 文件本身就在磁盘上，不需要额外保存溢出文件
+		Budget: NewOutputBudget(DefaultFileOutputTokens, false),
+	}
 }
 
 // Name 返回工具名称
@@ -36,14 +69,25 @@ func (f *FileReader) Description() string {
 - start_line (可选): 起始行号，从1开始计数，默认为1
 - end_line (可选): 结束行号，必须大于等于start_line，默认为100
 
+结果第一行给出实际读取的范围和文件总行数，没读完时提示从哪一行继续。
+UTF-16、GBK 等编码的文件自动转换为 UTF-8，超过 2000 个字符的行截断显示；
+二进制文件不按行读取，只返回文件类型、大小和开头字节的十六进制。
+
 示例：
 - "main.go" - 读取main.go文件的前100行
 - "main.go,1,50" - 读取main.go文件的第1-50行
 - "/path/to/file.txt,10,20" - 读取文件的第10-20行`
 }
 
-// Call 执行文件读取
+// Call 执行文件读取，每次调用都写入审计日志
 func (f *FileReader) Call(ctx context.Context, input string) (string, error) {
+	return audited(ctx, f.Audit, f.Name(), f.Workspace.Dir(), input, func(ctx context.Context) (string, error) {
+		return f.call(ctx, input)
+	})
+}
+
+// call 执行文件读取
+func (f *FileReader) call(ctx context.Context, input string) (string, error) {
 	if f.CallbacksHandler != nil {
 		f.CallbacksHandler.HandleToolStart(ctx, input)
 	}
@@ -54,13 +98,35 @@ func (f *FileReader) Call(ctx context.Context, input string) (string, error) {
 		return "", fmt.Errorf("参数解析失败: %w", err)
 	}
 
+	absPath, err := f.Workspace.Authorize(ctx, filePath, "读取")
+	if err != nil {
+		return "", err
+	}
+
 	// 读取文件内容
-	content, err := f.readFileLines(filePath, startLine, endLine)
+	lines, err := f.readFileLines(absPath, startLine, endLine)
 	if err != nil {
 		return "", fmt.Errorf("读取文件失败: %w", err)
 	}
+	if lines.binary {
+		result := fmt.Sprintf("文件: %s 是%s", filePath, lines.text)
+		if f.CallbacksHandler != nil {
+			f.CallbacksHandler.HandleToolEnd(ctx, result)
+		}
+		return result, nil
+	}
 
-	result := fmt.Sprintf("文件: %s (第%d-%d行)\n%s", filePath, startLine, endLine, content)
+	// 按预算裁剪，行号前缀保留了被省略的范围
+	report := f.Budget.Apply(f.Name(), lines.text)
+	content := report.Text
+	if report.Truncated {
+		content += "\n[请缩小行号范围分段读取被省略的部分]"
+	}
+
+	result := fmt.Sprintf("文件: %s (%s)\n%s", filePath, lines.header(startLine), content)
+	if lines.total > lines.last && lines.last > 0 {
+		result += fmt.Sprintf("\n[还有 %d 行未读取，可以从第 %d 行继续]", lines.total-lines.last, lines.last+1)
+	}
 
 	if f.CallbacksHandler != nil {
 		f.CallbacksHandler.HandleToolEnd(ctx, result)
@@ -76,6 +142,15 @@ func (f *FileReader) parseInput(input string) (filePath string, startLine, endLi
 		return "", 0, 0, fmt.Errorf("文件路径不能为空")
 	}
 
+	// 函数调用代理传入的 JSON 参数
+	if strings.HasPrefix(input, "{") && strings.HasSuffix(input, "}") {
+		var params FileReadParams
+		if err := json.Unmarshal([]byte(input), &params); err != nil {
+			return "", 0, 0, fmt.Errorf("JSON解析失败: %w", err)
+		}
+		return params.resolve()
+	}
+
 	parts := strings.Split(input, ",")
 
 	// 解析文件路径
@@ -121,74 +196,195 @@ func (f *FileReader) parseInput(input string) (filePath string, startLine, endLi
 	return filePath, startLine, endLine, nil
 }
 
-// readFileLines 读取文件指定行号范围的内容
-func (f *FileReader) readFileLines(filePath string, startLine, endLine int) (string, error) {
+// resolve 补齐默认值并校验参数
+func (p FileReadParams) resolve() (string, int, int, error) {
+	filePath := strings.TrimSpace(p.FilePath)
+	if filePath == "" {
+		return "", 0, 0, fmt.Errorf("文件路径不能为空")
+	}
+	startLine, endLine := p.StartLine, p.EndLine
+	if startLine == 0 {
+		startLine = 1
+	}
+	if startLine < 1 {
+		return "", 0, 0, fmt.Errorf("起始行号必须大于0")
+	}
+	if endLine == 0 {
+		endLine = startLine + 99
+	}
+	if endLine < startLine {
+		return "", 0, 0, fmt.Errorf("结束行号(%d)不能小于起始行号(%d)", endLine, startLine)
+	}
+	return filePath, startLine, endLine, nil
+}
+
+// Parameters 返回参数的 JSON Schema
+func (f *FileReader) Parameters() map[string]any {
+	return SchemaFor(FileReadParams{})
+}
+
+// Concurrent 文件读取是只读操作，可以并行执行
+func (f *FileReader) Concurrent() bool {
+	return true
+}
+
+// fileLines 按行号范围读取的结果
+type fileLines struct {
+	// text 按 "行号|内容" 排版的内容；二进制文件为类型、大小和开头字节的十六进制摘要
+	text string
+	// binary 文件不是文本，text 为摘要
+	binary bool
+	// last 实际读取到的最后一行
+	last int
+	// total 文件总行数，文件超过 maxLineCountSize 时不统计，为 0
+	total int
+	// encoding 转换为 UTF-8 之前的编码，UTF-8 文件为空
+	encoding string
+}
+
+// readFileLines 读取文件指定行号范围的内容。
+// 非 UTF-8 文本转换为 UTF-8，超长的行截断显示，二进制文件只返回摘要。
+func (f *FileReader) readFileLines(filePath string, startLine, endLine int) (fileLines, error) {
 	// 处理相对路径
 	absPath, err := f.getAbsolutePath(filePath)
 	if err != nil {
-		return "", err
+		return fileLines{}, err
 	}
 
 	// 检查文件是否存在
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("文件不存在: %s", absPath)
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return fileLines{}, fmt.Errorf("文件不存在: %s", absPath)
+	}
+	if err == nil && info.IsDir() {
+		return fileLines{}, fmt.Errorf("%s 是目录，请使用 dir_list 查看", absPath)
 	}
 
 	// 打开文件
 	file, err := os.Open(absPath)
 	if err != nil {
-		return "", fmt.Errorf("无法打开文件: %w", err)
+		return fileLines{}, fmt.Errorf("无法打开文件: %w", err)
 	}
 	defer file.Close()
 
-	// 逐行读取
-	scanner := bufio.NewScanner(file)
-	var lines []string
-	currentLine := 1
+	// 根据内容判断编码，二进制文件不按行读取
+	src, enc, head, ok := openText(file)
+	if !ok {
+		return fileLines{text: binarySummary(head, info), binary: true}, nil
+	}
+	result := fileLines{}
+	if enc.name != "UTF-8" {
+		result.encoding = enc.name
+	}
+	reader := bufio.NewReaderSize(src, maxReadLineBytes)
 
-	for scanner.Scan() {
-		if currentLine >= startLine && currentLine <= endLine {
-			// 格式化输出：行号|内容
-			lines = append(lines, fmt.Sprintf("%6d|%s", currentLine, scanner.Text()))
+	// 逐行读取，超过结束行后继续统计总行数
+	var lines []string
+	currentLine := 0
+	for {
+		limit := 0
+		if currentLine+1 >= startLine && currentLine+1 <= endLine {
+			limit = maxReadLineBytes
+		} else if currentLine >= endLine && info.Size() > maxLineCountSize {
+			break
 		}
-
-		// 如果超过结束行，提前退出
-		if currentLine > endLine {
+		text, size, err := readLine(reader, limit)
+		if err != nil && err != io.EOF {
+			return fileLines{}, fmt.Errorf("读取文件时出错: %w", err)
+		}
+		if size == 0 && err == io.EOF {
 			break
 		}
-
 		currentLine++
+		if limit > 0 {
+			// 格式化输出：行号|内容
+			lines = append(lines, fmt.Sprintf("%6d|%s", currentLine, clipLine(text, size)))
+			result.last = currentLine
+		}
+		if err == io.EOF {
+			break
+		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("读取文件时出错: %w", err)
+	if info.Size() <= maxLineCountSize {
+		result.total = currentLine
 	}
 
 	// 检查是否读取到内容
 	if len(lines) == 0 {
-		if currentLine-1 < startLine {
-			return "", fmt.Errorf("文件只有%d行，起始行号%d超出范围", currentLine-1, startLine)
+		if currentLine < startLine {
+			return fileLines{}, fmt.Errorf("文件只有%d行，起始行号%d超出范围", currentLine, startLine)
 		}
-		return "指定范围内没有内容", nil
+		result.text = "指定范围内没有内容"
+		return result, nil
 	}
 
-	return strings.Join(lines, "\n"), nil
+	result.text = strings.Join(lines, "\n")
+	return result, nil
 }
 
-// getAbsolutePath 获取绝对路径
-func (f *FileReader) getAbsolutePath(filePath string) (string, error) {
-	// 如果已经是绝对路径，直接返回
-	if filepath.IsAbs(filePath) {
-		return filePath, nil
+// readLine 读取一行（不含换行符），最多保留 limit 字节，返回保留的内容和整行的字节数。
+// 最后一行没有换行符时和 io.EOF 一起返回。
+func readLine(reader *bufio.Reader, limit int) (string, int, error) {
+	var line []byte
+	size := 0
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		size += len(chunk)
+		if keep := limit - len(line); keep > 0 {
+			line = append(line, chunk[:min(keep, len(chunk))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+			size--
+			line = bytes.TrimSuffix(line, []byte("\n"))
+		}
+		if size > 0 && len(line) == size && line[size-1] == '\r' {
+			line = line[:size-1]
+			size--
+		}
+		return string(line), size, err
+	}
+}
+
+// clipLine 截断超长的行，并注明整行的长度
+func clipLine(text string, size int) string {
+	clipped := truncateRunes(text, maxReadLineRunes)
+	if len(clipped) < len(text) || size > len(text) {
+		return clipped + fmt.Sprintf(" …[该行共 %s，已截断]", utils.FormatBytes(int64(size)))
 	}
+	return text
+}
+
+// binarySummary 二进制文件的摘要：类型、大小和开头字节的十六进制
+func binarySummary(head []byte, info os.FileInfo) string {
+	dump := head[:min(len(head), binaryDumpSize)]
+	return fmt.Sprintf("二进制文件（%s，%s），不能按文本读取。开头 %d 字节:\n%s",
+		sniffFileType(head, info.Mode()&0111 != 0), utils.FormatBytes(info.Size()), len(dump),
+		strings.TrimRight(hex.Dump(dump), "\n"))
+}
 
-	// 获取当前工作目录
-	pwd, err := os.Getwd()
+// header 结果第一行括号中的说明：读取范围、总行数和原始编码
+func (l fileLines) header(startLine int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "第%d-%d行", startLine, max(l.last, startLine))
+	if l.total > 0 {
+		fmt.Fprintf(&b, "，共%d行", l.total)
+	} else {
+		b.WriteString("，文件较大，未统计总行数")
+	}
+	if l.encoding != "" {
+		fmt.Fprintf(&b, "，%s 编码已转换为 UTF-8", l.encoding)
+	}
+	return b.String()
+}
+
+// getAbsolutePath 获取绝对路径，相对路径基于工作区的当前目录
+func (f *FileReader) getAbsolutePath(filePath string) (string, error) {
+	absPath, err := f.Workspace.Abs(filePath)
 	if err != nil {
 		return "", fmt.Errorf("无法获取当前工作目录: %w", err)
 	}
-
-	// 构建绝对路径
-	absPath := filepath.Join(pwd, filePath)
 	return absPath, nil
 }
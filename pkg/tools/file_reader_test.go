@@ -2,9 +2,14 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func TestFileReader_parseInput(t *testing.T) {
@@ -70,6 +75,27 @@ func TestFileReader_parseInput(t *testing.T) {
 			input:   "test.txt,abc,10",
 			wantErr: true,
 		},
+		{
+			name:      "JSON参数",
+			input:     `{"file_path": "main.go", "start_line": 5, "end_line": 15}`,
+			wantPath:  "main.go",
+			wantStart: 5,
+			wantEnd:   15,
+			wantErr:   false,
+		},
+		{
+			name:      "JSON参数默认行号",
+			input:     `{"file_path": "main.go", "start_line": 201}`,
+			wantPath:  "main.go",
+			wantStart: 201,
+			wantEnd:   300,
+			wantErr:   false,
+		},
+		{
+			name:    "JSON参数缺少路径",
+			input:   `{"start_line": 1}`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -197,6 +223,148 @@ func TestFileReader_ToolInterface(t *testing.T) {
 	}
 }
 
+func TestFileReader_Encodings(t *testing.T) {
+	dir := t.TempDir()
+	text := "第一行 hello\r\n第二行：中文日志\r\n第三行\r\n"
+	encode := func(enc interface{ String(string) (string, error) }) string {
+		s, err := enc.String(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	tests := []struct {
+		name     string
+		content  string
+		encoding string
+	}{
+		{"utf8", text, ""},
+		{"utf8-bom", "\xef\xbb\xbf" + text, "UTF-8 (BOM)"},
+		{"utf16le-bom", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()), "UTF-16LE"},
+		{"utf16be-bom", encode(unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder()), "UTF-16BE"},
+		{"utf16le", encode(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()), "UTF-16LE"},
+		{"gbk", encode(simplifiedchinese.GBK.NewEncoder()), "GBK"},
+		{"latin1", "caf\xe9 cr\xe8me\r\n", "Windows-1252"},
+	}
+	fr := NewFileReader()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			result, err := fr.Call(context.Background(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"     1|第一行 hello\n     2|第二行：中文日志\n     3|第三行", "共3行"}
+			if tt.name == "latin1" {
+				want = []string{"     1|café crème", "共1行"}
+			}
+			if tt.encoding != "" {
+				want = append(want, tt.encoding+" 编码已转换为 UTF-8")
+			} else if strings.Contains(result, "编码") {
+				t.Errorf("UTF-8 文件不应注明编码:\n%s", result)
+			}
+			for _, s := range want {
+				if !strings.Contains(result, s) {
+					t.Errorf("结果应包含 %q:\n%s", s, result)
+				}
+			}
+			if strings.Contains(result, "\r") {
+				t.Errorf("结果不应包含回车符:\n%q", result)
+			}
+		})
+	}
+}
+
+func TestFileReader_LateNonASCII(t *testing.T) {
+	// 开头几百行都是 ASCII，超过 binarySniffSize 之后才出现 GBK 中文
+	var b strings.Builder
+	for i := 1; i <= 600; i++ {
+		fmt.Fprintf(&b, "line %03d: plain ascii text\n", i)
+	}
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String("第601行：中文日志\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.WriteString(gbk)
+	path := filepath.Join(t.TempDir(), "late.log")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := fmt.Sprintf(`{"file_path": %q, "start_line": 600, "end_line": 601}`, path)
+	result, err := NewFileReader().Call(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"   601|第601行：中文日志", "GBK 编码已转换为 UTF-8"} {
+		if !strings.Contains(result, s) {
+			t.Errorf("结果应包含 %q:\n%s", s, result)
+		}
+	}
+}
+
+func TestFileReader_Binary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.bin")
+	if err := os.WriteFile(path, []byte("\x7fELF\x02\x01\x01\x00"+strings.Repeat("\x00\xff", 500)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	result, err := NewFileReader().Call(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"二进制文件（ELF 可执行文件，1008B）", "开头 256 字节", "00000000  7f 45 4c 46 02 01 01 00", "|.ELF"} {
+		if !strings.Contains(result, s) {
+			t.Errorf("结果应包含 %q:\n%s", s, result)
+		}
+	}
+	if strings.Contains(result, "     1|") {
+		t.Errorf("二进制文件不应按行输出:\n%s", result)
+	}
+}
+
+func TestFileReader_Lines(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("长", 100*1024)
+	path := filepath.Join(dir, "long.txt")
+	content := "short\n" + long + "\nafter\n" + strings.Repeat("x\n", 200)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fr := NewFileReader()
+	fr.Budget = nil
+
+	result, err := fr.Call(context.Background(), path+",1,3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"(第1-3行，共203行)",
+		"     2|" + strings.Repeat("长", maxReadLineRunes) + " …[该行共 300.0KB，已截断]",
+		"     3|after",
+		"[还有 200 行未读取，可以从第 4 行继续]",
+	} {
+		if !strings.Contains(result, s) {
+			t.Errorf("结果应包含 %q:\n%.500s", s, result)
+		}
+	}
+
+	// 读到文件末尾时不再提示剩余行数，结束行超出时显示实际读到的行
+	result, err = fr.Call(context.Background(), path+",200,300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "(第200-203行，共203行)") || strings.Contains(result, "还有") {
+		t.Errorf("结果不正确:\n%s", result)
+	}
+
+	if result, err := fr.Call(context.Background(), dir); err == nil {
+		t.Errorf("读取目录应返回错误, got %q", result)
+	}
+}
+
 // contains 检查字符串是否包含子字符串
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) &&
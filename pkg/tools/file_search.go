@@ -0,0 +1,410 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tmc/langchaingo/callbacks"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/policy"
+)
+
+// 搜索的默认值和上限
+const (
+	// defaultSearchResults 默认最多返回的匹配行数或文件数
+	defaultSearchResults = 100
+	// maxSearchResults max_results 的上限
+	maxSearchResults = 1000
+	// maxSearchContext 上下文行数的上限
+	maxSearchContext = 10
+	// maxSearchFileSize 超过该大小的文件不搜索内容
+	maxSearchFileSize = 10 << 20
+	// maxSearchLineRunes 结果中每行最多显示的字符数
+	maxSearchLineRunes = 300
+)
+
+// errSearchLimit 结果达到上限，停止遍历
+var errSearchLimit = errors.New("达到结果上限")
+
+// FileSearchParams 搜索文件的参数结构
+type FileSearchParams struct {
+	Pattern        string `json:"pattern,omitempty" desc:"要搜索的内容，默认为正则表达式（RE2 语法）；为空时只按 glob 查找文件"`
+	Path           string `json:"path,omitempty" desc:"搜索的目录或文件，默认为当前目录"`
+	Glob           string `json:"glob,omitempty" desc:"只搜索路径匹配的文件，如 *.go、cmd/**/*.go；不含 / 时匹配文件名"`
+	Literal        bool   `json:"literal,omitempty" desc:"把 pattern 当作普通字符串而不是正则表达式"`
+	IgnoreCase     bool   `json:"ignore_case,omitempty" desc:"忽略大小写"`
+	Context        int    `json:"context,omitempty" desc:"每处匹配前后显示的上下文行数，最多10"`
+	MaxResults     int    `json:"max_results,omitempty" desc:"最多返回的匹配行数（只查找文件时为文件数），默认100，最多1000"`
+	IncludeIgnored bool   `json:"include_ignored,omitempty" desc:"同时搜索被 .gitignore 忽略的文件"`
+}
+
+// FileSearch 按正则表达式或字符串搜索文件内容、按 glob 查找文件的工具，遵循 .gitignore
+type FileSearch struct {
+	CallbacksHandler callbacks.Handler
+	// Budget 返回给模型的输出预算，为空时不限制
+	Budget *OutputBudget
+	// Audit 审计日志，为空时不记录
+	Audit *audit.Logger
+	// Workspace 访问范围，为空时不限制
+	Workspace *Workspace
+}
+
+// NewFileSearch 创建新的文件搜索工具
+func NewFileSearch() *FileSearch {
+	return &FileSearch{
+		Budget: NewOutputBudget(DefaultFileOutputTokens, false),
+	}
+}
+
+// Name 返回工具名称
+func (f *FileSearch) Name() string {
+	return "file_search"
+}
+
+// Description 返回工具描述
+func (f *FileSearch) Description() string {
+	return `在目录中递归搜索文件内容或按名称查找文件的工具，自动跳过 .gitignore 忽略的文件、.git 目录和二进制文件。
+需要查找代码、配置或文件时优先使用本工具，不要通过 system_command 执行 grep/find。
+输入格式：JSON字符串，也可以直接输入要搜索的正则表达式（在当前目录中搜索）
+{
+  "pattern": "正则表达式（为空时只查找文件）",
+  "path": "目录或文件（默认为当前目录）",
+  "glob": "*.go",
+  "literal": false,
+  "ignore_case": false,
+  "context": 0,
+  "max_results": 100
+}
+
+搜索内容时按文件分组返回匹配行，行号格式与 file_reader 相同（"行号|内容"），上下文行为"行号-内容"；
+只指定 glob 时返回匹配的文件路径。返回的路径可以直接传给 file_reader。
+
+示例：
+- "func main" - 在当前目录中搜索 func main
+- {"pattern": "TODO", "glob": "*.go", "context": 2} - 搜索 Go 文件中的 TODO 并显示前后两行
+- {"glob": "**/*_test.go", "path": "pkg"} - 查找 pkg 下的测试文件`
+}
+
+// Call 执行搜索，每次调用都写入审计日志
+func (f *FileSearch) Call(ctx context.Context, input string) (string, error) {
+	return audited(ctx, f.Audit, f.Name(), f.Workspace.Dir(), input, func(ctx context.Context) (string, error) {
+		return f.call(ctx, input)
+	})
+}
+
+// call 执行搜索
+func (f *FileSearch) call(ctx context.Context, input string) (string, error) {
+	if f.CallbacksHandler != nil {
+		f.CallbacksHandler.HandleToolStart(ctx, input)
+	}
+
+	params, err := f.parseInput(input)
+	if err != nil {
+		return "", fmt.Errorf("参数解析失败: %w", err)
+	}
+
+	root, err := f.Workspace.Authorize(ctx, params.Path, "搜索")
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(root); err != nil {
+		return "", fmt.Errorf("搜索路径不存在: %s", params.Path)
+	}
+
+	var result string
+	if params.Pattern == "" {
+		result, err = f.findFiles(ctx, root, params)
+	} else {
+		result, err = f.searchContent(ctx, root, params)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	report := f.Budget.Apply(f.Name(), result)
+	result = report.Text
+	if report.Truncated {
+		result += "\n[结果过多，请用 glob、path 缩小搜索范围]"
+	}
+
+	if f.CallbacksHandler != nil {
+		f.CallbacksHandler.HandleToolEnd(ctx, result)
+	}
+	return result, nil
+}
+
+// parseInput 解析输入参数：JSON 参数，或者直接作为正则表达式
+func (f *FileSearch) parseInput(input string) (FileSearchParams, error) {
+	input = strings.TrimSpace(input)
+	var params FileSearchParams
+	if strings.HasPrefix(input, "{") && strings.HasSuffix(input, "}") {
+		if err := json.Unmarshal([]byte(input), &params); err != nil {
+			return params, fmt.Errorf("JSON解析失败: %w", err)
+		}
+	} else {
+		params.Pattern = input
+	}
+
+	if params.Pattern == "" && params.Glob == "" {
+		return params, fmt.Errorf("pattern 和 glob 不能同时为空")
+	}
+	if strings.TrimSpace(params.Path) == "" {
+		params.Path = "."
+	}
+	if params.Context < 0 {
+		return params, fmt.Errorf("context 不能为负数")
+	}
+	params.Context = min(params.Context, maxSearchContext)
+	switch {
+	case params.MaxResults < 0:
+		return params, fmt.Errorf("max_results 不能为负数")
+	case params.MaxResults == 0:
+		params.MaxResults = defaultSearchResults
+	case params.MaxResults > maxSearchResults:
+		params.MaxResults = maxSearchResults
+	}
+	return params, nil
+}
+
+// Parameters 返回参数的 JSON Schema
+func (f *FileSearch) Parameters() map[string]any {
+	return SchemaFor(FileSearchParams{})
+}
+
+// Concurrent 搜索是只读操作，可以并行执行
+func (f *FileSearch) Concurrent() bool {
+	return true
+}
+
+// compile 编译搜索表达式
+func (p FileSearchParams) compile() (*regexp.Regexp, error) {
+	expr := p.Pattern
+	if p.Literal {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if p.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("正则表达式无效（可以设置 literal 按普通字符串搜索）: %w", err)
+	}
+	return re, nil
+}
+
+// findFiles 查找路径匹配 glob 的文件
+func (f *FileSearch) findFiles(ctx context.Context, root string, params FileSearchParams) (string, error) {
+	var files []string
+	truncated := false
+	err := f.walk(ctx, root, params, func(path, display string) error {
+		if len(files) >= params.MaxResults {
+			truncated = true
+			return errSearchLimit
+		}
+		files = append(files, display)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) == 0 {
+		return fmt.Sprintf("在 %s 中没有找到匹配 %s 的文件", params.Path, params.Glob), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "在 %s 中找到 %d 个匹配 %s 的文件:\n", params.Path, len(files), params.Glob)
+	b.WriteString(strings.Join(files, "\n"))
+	if truncated {
+		fmt.Fprintf(&b, "\n[已达到 %d 个文件的上限，请缩小搜索范围或提高 max_results]", params.MaxResults)
+	}
+	return b.String(), nil
+}
+
+// searchContent 搜索文件内容，按文件分组输出匹配行
+func (f *FileSearch) searchContent(ctx context.Context, root string, params FileSearchParams) (string, error) {
+	re, err := params.compile()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	matches, files := 0, 0
+	truncated := false
+	err = f.walk(ctx, root, params, func(path, display string) error {
+		// 二进制、过大或无法读取的文件直接跳过
+		block, n, more, err := searchFile(path, re, params.Context, params.MaxResults-matches)
+		if err != nil {
+			return nil
+		}
+		if n > 0 {
+			if files > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(display + "\n" + block)
+			matches += n
+			files++
+		}
+		if more {
+			truncated = true
+			return errSearchLimit
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if matches == 0 {
+		return fmt.Sprintf("在 %s 中没有找到匹配 %q 的内容", params.Path, params.Pattern), nil
+	}
+	header := fmt.Sprintf("在 %s 中找到 %d 处匹配（%d 个文件）:\n", params.Path, matches, files)
+	result := header + strings.TrimSuffix(b.String(), "\n")
+	if truncated {
+		result += fmt.Sprintf("\n[已达到 %d 处匹配的上限，请缩小搜索范围或提高 max_results]", params.MaxResults)
+	}
+	return result, nil
+}
+
+// walk 遍历要搜索的文件，跳过 .gitignore 忽略的路径、.git 目录、符号链接和敏感路径。
+// visit 收到文件的绝对路径和显示给模型的路径，返回 errSearchLimit 时停止遍历。
+func (f *FileSearch) walk(ctx context.Context, root string, params FileSearchParams, visit func(path, display string) error) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		err := visit(root, params.Path)
+		if err == errSearchLimit {
+			return nil
+		}
+		return err
+	}
+
+	var ignore *gitignore
+	if !params.IncludeIgnored {
+		ignore = newGitignore(root)
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil || path == root {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" || ignore.Ignored(path, true) || f.Workspace.denies(path) {
+				return filepath.SkipDir
+			}
+			ignore.load(path)
+			return nil
+		}
+		if !d.Type().IsRegular() || ignore.Ignored(path, false) || f.Workspace.denies(path) {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		if params.Glob != "" && !matchFileGlob(params.Glob, filepath.ToSlash(rel)) {
+			return nil
+		}
+		return visit(path, filepath.Join(params.Path, rel))
+	})
+	if err == errSearchLimit {
+		return nil
+	}
+	return err
+}
+
+// matchFileGlob 判断相对路径是否匹配 glob：模式不含 "/" 时只匹配文件名
+func matchFileGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if !strings.Contains(pattern, "/") {
+		return policy.MatchGlob(pattern, filepath.Base(rel))
+	}
+	return policy.MatchGlob(pattern, rel)
+}
+
+// searchFile 搜索单个文件，最多返回 limit 处匹配。
+// 返回按 file_reader 行号格式排版的匹配行和上下文、匹配数，以及达到上限后是否还有未返回的匹配。
+// 二进制文件和过大的文件返回错误。
+func searchFile(path string, re *regexp.Regexp, context, limit int) (block string, matches int, more bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || info.Size() > maxSearchFileSize {
+		return "", 0, false, fmt.Errorf("文件过大")
+	}
+
+	// 与 file_reader 相同的编码识别，UTF-16、GBK 等文件转换为 UTF-8 后再匹配
+	text, _, _, ok := openText(file)
+	if !ok {
+		return "", 0, false, fmt.Errorf("二进制文件")
+	}
+	reader := bufio.NewReader(text)
+
+	type line struct {
+		no   int
+		text string
+	}
+	var b strings.Builder
+	var before []line
+	after, lastPrinted := 0, 0
+	emit := func(no int, text string, sep byte) {
+		if lastPrinted > 0 && no > lastPrinted+1 {
+			b.WriteString("    --\n")
+		}
+		fmt.Fprintf(&b, "%6d%c%s\n", no, sep, truncateRunes(text, maxSearchLineRunes))
+		lastPrinted = no
+	}
+
+	for no := 1; ; no++ {
+		raw, readErr := reader.ReadString('\n')
+		if raw == "" && readErr != nil {
+			if readErr != io.EOF {
+				return "", 0, false, readErr
+			}
+			break
+		}
+		text := strings.TrimRight(raw, "\r\n")
+		switch {
+		case re.MatchString(text):
+			if matches >= limit {
+				return b.String(), matches, true, nil
+			}
+			for _, l := range before {
+				emit(l.no, l.text, '-')
+			}
+			before = before[:0]
+			emit(no, text, '|')
+			matches++
+			after = context
+		case after > 0:
+			emit(no, text, '-')
+			after--
+		case context > 0:
+			before = append(before, line{no, text})
+			if len(before) > context {
+				before = before[1:]
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	return b.String(), matches, false, nil
+}
@@ -0,0 +1,235 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// newSearchTree 创建用于搜索测试的目录树
+func newSearchTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		".git/HEAD":             "ref: refs/heads/main\nneedle in git\n",
+		".gitignore":            "*.log\nbuild/\n/secret.txt\n",
+		"main.go":               "package main\n\nfunc main() {\n\tneedle()\n}\n",
+		"pkg/util.go":           "package pkg\n\n// Needle 大小写不同\nfunc Needle() {}\n",
+		"pkg/util_test.go":      "package pkg\n\nfunc TestNeedle() { needle() }\n",
+		"pkg/.gitignore":        "generated.go\n!keep.log\n",
+		"pkg/generated.go":      "needle\n",
+		"pkg/keep.log":          "needle kept\n",
+		"debug.log":             "needle\n",
+		"build/out.go":          "needle\n",
+		"secret.txt":            "needle\n",
+		"docs/secret.txt":       "needle in docs\n",
+		"docs/regex.md":         "a.b needle(x)\n",
+		"bin/tool":              "needle\x00\x01\x02",
+		"context.txt":           "one\ntwo\nthree needle\nfour\nfive\nsix\nseven\neight needle\nnine\n",
+		"nested/deep/dir/x.txt": "needle deep\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// matchedFiles 提取搜索结果中的文件路径（不带行号的行）
+func matchedFiles(result string) []string {
+	var files []string
+	for _, line := range strings.Split(result, "\n")[1:] {
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		files = append(files, filepath.ToSlash(line))
+	}
+	return files
+}
+
+func TestGitignore(t *testing.T) {
+	dir := newSearchTree(t)
+	g := newGitignore(filepath.Join(dir, "pkg"))
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"pkg/generated.go", false, true},
+		{"pkg/util.go", false, false},
+		{"pkg/debug.log", false, true},
+		{"pkg/keep.log", false, false},
+		{"pkg/build", true, true},
+		{"pkg/build", false, false},
+		{"pkg/secret.txt", false, false},
+		{".git", true, true},
+	}
+	for _, tt := range tests {
+		if got := g.Ignored(filepath.Join(dir, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%s, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	// 仓库根目录的锚定规则只匹配根目录下的文件
+	root := newGitignore(dir)
+	if !root.Ignored(filepath.Join(dir, "secret.txt"), false) || root.Ignored(filepath.Join(dir, "docs/secret.txt"), false) {
+		t.Error("/secret.txt 只应忽略根目录下的文件")
+	}
+}
+
+func TestFileSearch_Content(t *testing.T) {
+	dir := newSearchTree(t)
+	fs := NewFileSearch()
+
+	result, err := fs.Call(context.Background(), `{"pattern": "needle", "path": "`+dir+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := matchedFiles(result)
+	want := []string{"context.txt", "docs/regex.md", "docs/secret.txt", "main.go", "nested/deep/dir/x.txt", "pkg/keep.log", "pkg/util_test.go"}
+	if len(got) != len(want) {
+		t.Fatalf("匹配的文件 = %v, want %v\n%s", got, want, result)
+	}
+	for i := range want {
+		if !strings.HasSuffix(got[i], want[i]) {
+			t.Errorf("第 %d 个文件 = %s, want %s", i, got[i], want[i])
+		}
+	}
+	if !strings.Contains(result, "     4|\tneedle()") {
+		t.Errorf("匹配行应使用 file_reader 的行号格式:\n%s", result)
+	}
+
+	// 直接输入正则表达式，在当前目录中搜索
+	t.Chdir(dir)
+	result, err = fs.Call(context.Background(), `func (main|Needle)\(`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "找到 2 处匹配（2 个文件）") {
+		t.Errorf("正则搜索结果不正确:\n%s", result)
+	}
+}
+
+func TestFileSearch_Encodings(t *testing.T) {
+	dir := t.TempDir()
+	text := "第一行\r\n第二行：中文日志\r\n"
+	encoders := map[string]interface{ String(string) (string, error) }{
+		"gbk.log":     simplifiedchinese.GBK.NewEncoder(),
+		"utf16le.log": unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder(),
+		"utf16be.log": unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder(),
+	}
+	for name, enc := range encoders {
+		content, err := enc.String(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := NewFileSearch().Call(context.Background(), `{"pattern": "中文日志", "path": "`+dir+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "找到 3 处匹配（3 个文件）") {
+		t.Fatalf("应在各种编码的文件中找到匹配:\n%s", result)
+	}
+	if strings.Count(result, "     2|第二行：中文日志") != 3 || strings.Contains(result, "\r") {
+		t.Errorf("匹配行应转换为 UTF-8 并去掉回车符:\n%s", result)
+	}
+}
+
+func TestFileSearch_Options(t *testing.T) {
+	dir := newSearchTree(t)
+	t.Chdir(dir)
+	fs := NewFileSearch()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		not   []string
+	}{
+		{"普通字符串", `{"pattern": "a.b needle(", "literal": true}`, []string{"docs/regex.md", "     1|a.b needle(x)"}, nil},
+		{"忽略大小写", `{"pattern": "^// NEEDLE", "ignore_case": true}`, []string{"pkg/util.go", "     3|// Needle"}, nil},
+		{"文件过滤", `{"pattern": "needle", "glob": "*_test.go"}`, []string{"pkg/util_test.go"}, []string{"main.go"}},
+		{"路径过滤", `{"pattern": "needle", "glob": "docs/*.md"}`, []string{"docs/regex.md"}, []string{"docs/secret.txt"}},
+		{"包含忽略的文件", `{"pattern": "needle", "glob": "*.go", "include_ignored": true}`, []string{"build/out.go", "pkg/generated.go"}, []string{".git"}},
+		{"上下文", `{"pattern": "needle", "path": "context.txt", "context": 1}`,
+			[]string{"     2-two\n     3|three needle\n     4-four\n    --\n     7-seven\n     8|eight needle\n     9-nine"}, nil},
+		{"查找文件", `{"glob": "**/*.go", "path": "pkg"}`, []string{"找到 2 个", "pkg/util.go", "pkg/util_test.go"}, []string{"generated.go", "|"}},
+		{"没有匹配", `{"pattern": "haystack"}`, []string{"没有找到匹配"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fs.Call(context.Background(), tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(result, s) {
+					t.Errorf("结果应包含 %q:\n%s", s, result)
+				}
+			}
+			for _, s := range tt.not {
+				if strings.Contains(result, s) {
+					t.Errorf("结果不应包含 %q:\n%s", s, result)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSearch_Limits(t *testing.T) {
+	dir := newSearchTree(t)
+	t.Chdir(dir)
+	fs := NewFileSearch()
+
+	result, err := fs.Call(context.Background(), `{"pattern": "needle", "max_results": 3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "找到 3 处匹配") || !strings.Contains(result, "已达到 3 处匹配的上限") {
+		t.Errorf("应在达到上限时停止并提示:\n%s", result)
+	}
+
+	// 恰好达到上限时不提示
+	result, _ = fs.Call(context.Background(), `{"pattern": "needle", "path": "context.txt", "max_results": 2}`)
+	if strings.Contains(result, "上限") {
+		t.Errorf("没有更多匹配时不应提示上限:\n%s", result)
+	}
+
+	for _, input := range []string{"", `{"path": "pkg"}`, `{"pattern": "(", "path": "pkg"}`, `{"pattern": "x", "context": -1}`, `{"pattern": "x", "path": "missing"}`} {
+		if _, err := fs.Call(context.Background(), input); err == nil {
+			t.Errorf("输入 %q 应返回错误", input)
+		}
+	}
+}
+
+func TestFileSearch_Workspace(t *testing.T) {
+	dir := newSearchTree(t)
+	fs := NewFileSearch()
+	fs.Workspace = NewWorkspace([]string{dir}, []string{filepath.Join(dir, "docs")})
+
+	result, err := fs.Call(context.Background(), `{"pattern": "needle", "path": "`+dir+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(result, "docs") {
+		t.Errorf("遍历时应跳过禁止访问的路径:\n%s", result)
+	}
+	if _, err := fs.Call(context.Background(), `{"pattern": "needle", "path": "`+filepath.Join(dir, "docs")+`"}`); err == nil {
+		t.Error("不应允许搜索禁止访问的路径")
+	}
+}
@@ -9,18 +9,36 @@ import (
 	"strings"
 
 	"github.com/tmc/langchaingo/callbacks"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/backup"
+	"github.com/dean2027/aishell/pkg/policy"
 )
 
 // FileWriteParams 写文件的参数结构
 type FileWriteParams struct {
-	FilePath   string `json:"file_path"`
-	Content    string `json:"content"`
-	CreateDirs bool   `json:"create_dirs,omitempty"`
+	FilePath   string `json:"file_path" desc:"要写入的文件路径，支持相对路径和绝对路径，仅支持文本文件"`
+	Content    string `json:"content" desc:"要写入的完整文件内容"`
+	CreateDirs bool   `json:"create_dirs,omitempty" desc:"是否自动创建不存在的目录，默认false"`
 }
 
 // FileWriter 文件写入工具
 type FileWriter struct {
 	CallbacksHandler callbacks.Handler
+	// Planner 演练模式状态，启用时只记录写入操作不执行
+	Planner *Planner
+	// Policy 写入策略，决定哪些路径写入前需要展示差异并确认，为空时直接写入
+	Policy *policy.Policy
+	// Workspace 访问范围，为空时不限制
+	Workspace *Workspace
+	// Approver 写入确认器，为空时按交互模式询问
+	Approver *Approver
+	// Audit 审计日志，为空时不记录
+	Audit *audit.Logger
+	// Backups 备份存储，设置后每次写入前保存原内容，可以撤销
+	Backups *backup.Store
+	// BackupSession 备份所属的会话 ID
+	BackupSession string
 }
 
 // NewFileWriter 创建新的文件写入工具
@@ -36,6 +54,7 @@ func (f *FileWriter) Name() string {
 // Description 返回工具描述
 func (f *FileWriter) Description() string {
 	return `写入文件内容的工具。支持创建新文件或覆盖现有文件，可选择是否自动创建目录。
+只修改已有文件的一部分时请使用 file_editor，不要重新发送整个文件。
 输入格式：JSON字符串
 {
   "file_path": "文件路径（必需）",
@@ -54,8 +73,15 @@ func (f *FileWriter) Description() string {
 {"file_path": "src/main.go", "content": "package main\n\nfunc main() {\n\tfmt.Println(\"Hello\")\n}", "create_dirs": true}`
 }
 
-// Call 执行文件写入
+// Call 执行文件写入，每次调用都写入审计日志
 func (f *FileWriter) Call(ctx context.Context, input string) (string, error) {
+	return audited(ctx, f.Audit, f.Name(), f.Workspace.Dir(), input, func(ctx context.Context) (string, error) {
+		return f.call(ctx, input)
+	})
+}
+
+// call 执行文件写入
+func (f *FileWriter) call(ctx context.Context, input string) (string, error) {
 	if f.CallbacksHandler != nil {
 		f.CallbacksHandler.HandleToolStart(ctx, input)
 	}
@@ -71,7 +97,41 @@ func (f *FileWriter) Call(ctx context.Context, input string) (string, error) {
 		return "", fmt.Errorf("参数验证失败: %w", err)
 	}
 
+	// 检查访问范围：敏感路径直接拒绝
+	_, inside, err := f.Workspace.Check(params.FilePath)
+	if err != nil {
+		CallInfoFrom(ctx).SetStatus(audit.StatusDenied)
+		return "", err
+	}
+
+	// 演练模式：记录到计划中，返回模拟结果
+	if f.Planner.Active(ctx) {
+		CallInfoFrom(ctx).SetStatus(audit.StatusPlanned)
+		result := f.recordPlan(input, params, inside)
+		if f.CallbacksHandler != nil {
+			f.CallbacksHandler.HandleToolEnd(ctx, result)
+		}
+		return result, nil
+	}
+
+	// 工作区之外的写入需要用户确认
+	if !inside {
+		if _, err := f.Workspace.Authorize(ctx, params.FilePath, "写入"); err != nil {
+			return "", err
+		}
+	}
+
+	// 写入策略检查：按路径决定拒绝、直接写入，或展示差异由用户确认
+	note, ok := reviewWrite(ctx, f.Policy, f.Approver, f.getAbsolutePath(params.FilePath), &params.Content)
+	if !ok {
+		if f.CallbacksHandler != nil {
+			f.CallbacksHandler.HandleToolEnd(ctx, note)
+		}
+		return note, nil
+	}
+
 	// 写入文件
+	existed := f.checkFileExists(params.FilePath)
 	bytesWritten, err := f.writeFile(params)
 	if err != nil {
 		return "", fmt.Errorf("写入文件失败: %w", err)
@@ -79,6 +139,12 @@ func (f *FileWriter) Call(ctx context.Context, input string) (string, error) {
 
 	result := fmt.Sprintf("成功写入文件: %s\n写入内容: %d 字节\n路径: %s", 
 		params.FilePath, bytesWritten, f.getAbsolutePath(params.FilePath))
+	if note != "" {
+		result += "\n" + note
+	}
+	if existed && f.Backups != nil {
+		result += "\n原文件已备份，用户可以输入 /undo 撤销这次写入"
+	}
 
 	if f.CallbacksHandler != nil {
 		f.CallbacksHandler.HandleToolEnd(ctx, result)
@@ -87,6 +153,32 @@ func (f *FileWriter) Call(ctx context.Context, input string) (string, error) {
 	return result, nil
 }
 
+// Parameters 返回参数的 JSON Schema
+func (f *FileWriter) Parameters() map[string]any {
+	return SchemaFor(FileWriteParams{})
+}
+
+// recordPlan 把写入操作记录为计划步骤，返回给模型的模拟结果
+func (f *FileWriter) recordPlan(input string, params *FileWriteParams, inside bool) string {
+	absPath := f.getAbsolutePath(params.FilePath)
+	step := PlanStep{
+		Tool:    f.Name(),
+		Input:   input,
+		Summary: fmt.Sprintf("写入文件: %s (%d 字节)", absPath, len(params.Content)),
+	}
+	if exists, size, _ := f.getFileInfo(params.FilePath); exists {
+		step.Risks = append(step.Risks, fmt.Sprintf("将覆盖已有文件（当前 %d 字节）", size))
+	}
+	if !inside {
+		step.Risks = append(step.Risks, "文件位于工作区之外，执行时需要确认")
+	}
+	if _, err := os.Stat(filepath.Dir(absPath)); os.IsNotExist(err) && params.CreateDirs {
+		step.Summary += "，并创建目录 " + filepath.Dir(absPath)
+	}
+	n := f.Planner.Record(step)
+	return fmt.Sprintf("[演练模式] 文件未写入，已记录为计划第 %d 步: %s", n, step.Summary)
+}
+
 // parseInput 解析输入参数
 func (f *FileWriter) parseInput(input string) (*FileWriteParams, error) {
 	input = strings.TrimSpace(input)
@@ -124,18 +216,23 @@ func (f *FileWriter) parseInput(input string) (*FileWriteParams, error) {
 
 // validateParams 验证参数
 func (f *FileWriter) validateParams(params *FileWriteParams) error {
-	if params.FilePath == "" {
+	return validateWritePath(params.FilePath)
+}
+
+// validateWritePath 验证可写入的文件路径，file_writer 和 file_editor 共用
+func validateWritePath(filePath string) error {
+	if filePath == "" {
 		return fmt.Errorf("文件路径不能为空")
 	}
 
 	// 安全检查：防止路径遍历攻击
-	cleanPath := filepath.Clean(params.FilePath)
+	cleanPath := filepath.Clean(filePath)
 	if strings.Contains(cleanPath, "..") {
 		return fmt.Errorf("不允许使用相对路径符号 '..'")
 	}
 
 	// 检查文件扩展名，确保是文本文件
-	ext := strings.ToLower(filepath.Ext(params.FilePath))
+	ext := strings.ToLower(filepath.Ext(filePath))
 	textExtensions := []string{
 		".txt", ".log", ".md", ".json", ".xml", ".yaml", ".yml", 
 		".go", ".py", ".js", ".html", ".css", ".sql", ".sh", ".bat",
@@ -179,6 +276,13 @@ func (f *FileWriter) writeFile(params *FileWriteParams) (int, error) {
 		}
 	}
 
+	// 覆盖前保存原内容，备份失败时不写入
+	if f.Backups != nil {
+		if _, err := f.Backups.Snapshot(f.BackupSession, absPath); err != nil {
+			return 0, fmt.Errorf("备份原文件失败: %w", err)
+		}
+	}
+
 	// 写入文件
 	err := os.WriteFile(absPath, []byte(params.Content), 0644)
 	if err != nil {
@@ -188,22 +292,13 @@ func (f *FileWriter) writeFile(params *FileWriteParams) (int, error) {
 	return len(params.Content), nil
 }
 
-// getAbsolutePath 获取绝对路径
+// getAbsolutePath 获取绝对路径，相对路径基于工作区的当前目录
 func (f *FileWriter) getAbsolutePath(filePath string) string {
-	// 如果已经是绝对路径，直接返回
-	if filepath.IsAbs(filePath) {
-		return filepath.Clean(filePath)
-	}
-
-	// 获取当前工作目录
-	pwd, err := os.Getwd()
+	absPath, err := f.Workspace.Abs(filePath)
 	if err != nil {
 		return filePath // fallback
 	}
-
-	// 构建绝对路径
-	absPath := filepath.Join(pwd, filePath)
-	return filepath.Clean(absPath)
+	return absPath
 }
 
 // checkFileExists 检查文件是否存在
@@ -1,11 +1,18 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+
+	"github.com/dean2027/aishell/pkg/backup"
+	"github.com/dean2027/aishell/pkg/policy"
 )
 
 func TestFileWriter_parseInput(t *testing.T) {
@@ -404,3 +411,114 @@ func TestFileWriter_writeFile(t *testing.T) {
 		})
 	}
 }
+
+func TestFileWriter_Backups(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(target, []byte("原内容"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := NewFileWriter()
+	fw.Backups = backup.NewStore(filepath.Join(dir, "backups"))
+	fw.BackupSession = "test-session"
+
+	input := fmt.Sprintf(`{"file_path": %q, "content": "新内容"}`, target)
+	result, err := fw.Call(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "/undo") {
+		t.Errorf("覆盖已有文件时应提示可以撤销: %s", result)
+	}
+
+	if _, err := fw.Backups.Undo("test-session", target); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "原内容" {
+		t.Errorf("撤销后应恢复原内容，得到 %q", data)
+	}
+}
+
+func TestFileWriter_ReviewBeforeOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app.conf")
+	write := func(content string) {
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	call := func(fw *FileWriter, content string) string {
+		t.Helper()
+		input := fmt.Sprintf(`{"file_path": %q, "content": %q}`, target, content)
+		result, err := fw.Call(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	t.Run("拒绝时不写入", func(t *testing.T) {
+		write("port=80\n")
+		var out bytes.Buffer
+		fw := NewFileWriter()
+		fw.Policy = &policy.Policy{}
+		fw.Approver = &Approver{In: strings.NewReader("n\n"), Out: &out}
+
+		result := call(fw, "port=8080\n")
+		if !strings.Contains(result, "用户拒绝写入") {
+			t.Errorf("unexpected result: %s", result)
+		}
+		if data, _ := os.ReadFile(target); string(data) != "port=80\n" {
+			t.Errorf("拒绝后文件不应改变，得到 %q", data)
+		}
+		if !strings.Contains(out.String(), "-port=80") || !strings.Contains(out.String(), "+port=8080") {
+			t.Errorf("应展示差异: %s", out.String())
+		}
+	})
+
+	t.Run("同意后写入", func(t *testing.T) {
+		write("port=80\n")
+		fw := NewFileWriter()
+		fw.Policy = &policy.Policy{}
+		fw.Approver = &Approver{In: strings.NewReader("y\n"), Out: io.Discard}
+
+		call(fw, "port=8080\n")
+		if data, _ := os.ReadFile(target); string(data) != "port=8080\n" {
+			t.Errorf("同意后应写入新内容，得到 %q", data)
+		}
+	})
+
+	t.Run("allow 规则不询问", func(t *testing.T) {
+		write("port=80\n")
+		fw := NewFileWriter()
+		fw.Policy = &policy.Policy{Writes: policy.BuiltinWriteRules(dir)}
+		fw.Approver = &Approver{Mode: ApprovalNo, Out: io.Discard}
+
+		call(fw, "port=8080\n")
+		if data, _ := os.ReadFile(target); string(data) != "port=8080\n" {
+			t.Errorf("项目目录内应直接写入，得到 %q", data)
+		}
+	})
+
+	t.Run("在编辑器中修改", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("依赖 sed")
+		}
+		t.Setenv("VISUAL", "")
+		t.Setenv("EDITOR", "sed -i.orig s/8080/9090/")
+		t.Setenv("TMPDIR", t.TempDir())
+		write("port=80\n")
+		fw := NewFileWriter()
+		fw.Policy = &policy.Policy{}
+		fw.Approver = &Approver{In: strings.NewReader("e\ny\n"), Out: io.Discard}
+
+		result := call(fw, "port=8080\n")
+		if data, _ := os.ReadFile(target); string(data) != "port=9090\n" {
+			t.Errorf("应写入编辑后的内容，得到 %q", data)
+		}
+		if !strings.Contains(result, "编辑器中修改") {
+			t.Errorf("应告知模型内容被修改: %s", result)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dean2027/aishell/pkg/policy"
+)
+
+// ignoreRule .gitignore 中的一条规则
+type ignoreRule struct {
+	// base 规则所在的目录，相对于 gitignore.root，斜杠分隔，根目录为空字符串
+	base string
+	// pattern 去掉前缀 "!"、"/" 和后缀 "/" 之后的模式
+	pattern string
+	// negate 以 "!" 开头，重新包含被忽略的路径
+	negate bool
+	// dirOnly 以 "/" 结尾，只匹配目录
+	dirOnly bool
+	// anchored 模式中包含 "/"，相对于 base 匹配完整路径，否则匹配任意层级的名称
+	anchored bool
+}
+
+// gitignore 遍历目录时逐层加载的 .gitignore 规则。
+// 位于 git 仓库中时从仓库根目录开始加载，同时读取 .git/info/exclude。
+type gitignore struct {
+	// root 规则路径的基准目录：所在仓库的根目录，不在仓库中时为遍历的起点
+	root  string
+	rules []ignoreRule
+}
+
+// newGitignore 为从 dir 开始的遍历加载规则：dir 及其所在仓库中上级目录的 .gitignore
+func newGitignore(dir string) *gitignore {
+	g := &gitignore{root: dir}
+	if repo := findRepoRoot(dir); repo != "" {
+		g.root = repo
+		g.loadFile(filepath.Join(repo, ".git", "info", "exclude"), "")
+		// 从仓库根目录到 dir 的每一级目录
+		rel, _ := filepath.Rel(repo, dir)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		for i := range parts {
+			if parts[i] == "." {
+				continue
+			}
+			g.load(filepath.Join(repo, filepath.Join(parts[:i]...)))
+		}
+	}
+	g.load(dir)
+	return g
+}
+
+// findRepoRoot 向上查找包含 .git 的目录，找不到时返回空字符串
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// rel 返回相对于规则基准目录的斜杠路径
+func (g *gitignore) rel(p string) string {
+	rel, err := filepath.Rel(g.root, p)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// load 加载目录中的 .gitignore，遍历进入每个目录时调用，g 为 nil 时忽略
+func (g *gitignore) load(dir string) {
+	if g == nil {
+		return
+	}
+	g.loadFile(filepath.Join(dir, ".gitignore"), g.rel(dir))
+}
+
+// loadFile 解析规则文件，base 为规则所在目录
+func (g *gitignore) loadFile(file, base string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		g.rules = append(g.rules, rule)
+	}
+}
+
+// Ignored 判断路径是否被忽略，后面的规则优先；.git 目录总是被忽略
+func (g *gitignore) Ignored(p string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	if isDir && filepath.Base(p) == ".git" {
+		return true
+	}
+	rel := g.rel(p)
+	if rel == "" || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	for i := len(g.rules) - 1; i >= 0; i-- {
+		if g.rules[i].match(rel, isDir) {
+			return !g.rules[i].negate
+		}
+	}
+	return false
+}
+
+// match 判断规则是否匹配相对于基准目录的路径
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if r.anchored {
+		return policy.MatchGlob(r.pattern, rel)
+	}
+	return policy.MatchGlob(r.pattern, path.Base(rel))
+}
@@ -0,0 +1,292 @@
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// 默认的输出预算
+const (
+	// DefaultCommandOutputTokens system_command 返回给模型的最大 token 数
+	DefaultCommandOutputTokens = 4000
+	// DefaultFileOutputTokens file_reader 返回给模型的最大 token 数
+	DefaultFileOutputTokens = 8000
+)
+
+// 溢出文件的清理条件：写入新的溢出文件时删除超过保留时间的文件，剩余文件数超过上限时再删除最旧的
+const (
+	spillRetention = 7 * 24 * time.Hour
+	maxSpillFiles  = 200
+)
+
+// tokenEncoding 计算 token 使用的编码，与 GPT-3.5/GPT-4 系列一致
+const tokenEncoding = "cl100k_base"
+
+var (
+	encoderOnce sync.Once
+	encoder     atomic.Pointer[tiktoken.Tiktoken]
+)
+
+// WarmupTokenizer 在后台加载 tiktoken 编码表。
+// 编码表随程序内置，不访问网络；加载完成前 CountTokens 使用近似估算，不会阻塞工具调用。
+func WarmupTokenizer() {
+	encoderOnce.Do(func() {
+		go func() {
+			tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+			e, err := tiktoken.GetEncoding(tokenEncoding)
+			if err == nil {
+				encoder.Store(e)
+			}
+		}()
+	})
+}
+
+// CountTokens 计算文本的 token 数，编码表不可用时使用近似估算
+func CountTokens(text string) int {
+	if e := encoder.Load(); e != nil {
+		return len(e.Encode(text, nil, nil))
+	}
+	return approximateTokens(text)
+}
+
+// approximateTokens 近似估算 token 数：ASCII 约4字节一个 token，其他字符（如中文）约一字一个 token
+func approximateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// OutputBudget 工具输出的 token 预算。
+// 超出预算时保留开头和结尾，省略中间部分，并可把完整输出保存到溢出文件供模型分页查看。
+type OutputBudget struct {
+	// MaxTokens 返回给模型的最大 token 数
+	MaxTokens int
+	// HeadRatio 保留给开头部分的预算比例，其余留给结尾
+	HeadRatio float64
+	// SpillDir 溢出文件目录，为空时不保存完整输出
+	SpillDir string
+	// CountTokens token 计数函数，为空时使用 CountTokens
+	CountTokens func(string) int
+}
+
+// BudgetReport 预算裁剪结果
+type BudgetReport struct {
+	// Text 裁剪后的文本
+	Text string
+	// Truncated 是否发生了裁剪
+	Truncated bool
+	// TotalTokens 原始 token 数
+	TotalTokens int
+	// KeptTokens 保留的 token 数
+	KeptTokens int
+	// TotalLines 原始行数
+	TotalLines int
+	// OmittedLines 省略的行数
+	OmittedLines int
+	// SpillFile 完整输出的保存位置
+	SpillFile string
+}
+
+// SpillDir 返回溢出文件目录，位于用户级数据目录下，只有当前用户可以访问
+func SpillDir() string {
+	return filepath.Join(utils.DataDir(), "spill")
+}
+
+// NewOutputBudget 创建输出预算，spill 为 true 时把完整输出保存到 SpillDir
+func NewOutputBudget(maxTokens int, spill bool) *OutputBudget {
+	WarmupTokenizer()
+	budget := &OutputBudget{
+		MaxTokens: maxTokens,
+		HeadRatio: 0.6,
+	}
+	if spill {
+		budget.SpillDir = SpillDir()
+	}
+	return budget
+}
+
+// Apply 按预算裁剪输出，name 用于命名溢出文件
+func (b *OutputBudget) Apply(name, output string) BudgetReport {
+	report := BudgetReport{Text: output}
+	if b == nil || b.MaxTokens <= 0 {
+		return report
+	}
+
+	count := b.CountTokens
+	if count == nil {
+		count = CountTokens
+	}
+
+	report.TotalTokens = count(output)
+	if report.TotalTokens <= b.MaxTokens {
+		report.KeptTokens = report.TotalTokens
+		return report
+	}
+
+	lines := strings.SplitAfter(output, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	report.TotalLines = len(lines)
+	report.Truncated = true
+
+	headRatio := b.HeadRatio
+	if headRatio <= 0 || headRatio >= 1 {
+		headRatio = 0.6
+	}
+	headBudget := int(float64(b.MaxTokens) * headRatio)
+	tailBudget := b.MaxTokens - headBudget
+
+	// 从开头取行
+	var head []string
+	used := 0
+	i := 0
+	for ; i < len(lines); i++ {
+		t := count(lines[i])
+		if used+t > headBudget {
+			// 单行过长时截取其开头部分
+			if len(head) == 0 {
+				head = append(head, truncateRunes(lines[i], (headBudget-used)*3)+" …[行过长已截断]\n")
+				used = headBudget
+				i++
+			}
+			break
+		}
+		head = append(head, lines[i])
+		used += t
+	}
+
+	// 从结尾取行，不与开头重叠
+	var tail []string
+	tailUsed := 0
+	j := len(lines) - 1
+	for ; j >= i; j-- {
+		t := count(lines[j])
+		if tailUsed+t > tailBudget {
+			break
+		}
+		tail = append([]string{lines[j]}, tail...)
+		tailUsed += t
+	}
+
+	report.OmittedLines = j - i + 1
+	report.KeptTokens = used + tailUsed
+
+	if b.SpillDir != "" {
+		if path, err := b.spill(name, output); err == nil {
+			report.SpillFile = path
+		}
+	}
+
+	var text strings.Builder
+	for _, line := range head {
+		text.WriteString(line)
+	}
+	if !strings.HasSuffix(text.String(), "\n") && text.Len() > 0 {
+		text.WriteString("\n")
+	}
+	if report.OmittedLines > 0 {
+		fmt.Fprintf(&text, "\n... [已省略中间 %d 行，约 %d tokens] ...\n\n", report.OmittedLines, report.TotalTokens-report.KeptTokens)
+	} else {
+		fmt.Fprintf(&text, "\n... [已省略约 %d tokens] ...\n\n", report.TotalTokens-report.KeptTokens)
+	}
+	for _, line := range tail {
+		text.WriteString(line)
+	}
+	if !strings.HasSuffix(text.String(), "\n") {
+		text.WriteString("\n")
+	}
+	fmt.Fprintf(&text, "[输出过长已截断: 原始共 %d 行、约 %d tokens，保留约 %d tokens]",
+		report.TotalLines, report.TotalTokens, report.KeptTokens)
+	if report.SpillFile != "" {
+		fmt.Fprintf(&text, "\n[完整输出已保存到: %s，可使用 file_reader 工具分页查看，例如 \"%s,1,200\"]",
+			report.SpillFile, report.SpillFile)
+	}
+
+	report.Text = text.String()
+	return report
+}
+
+// spill 保存完整输出到溢出文件
+func (b *OutputBudget) spill(name, output string) (string, error) {
+	if err := os.MkdirAll(b.SpillDir, 0700); err != nil {
+		return "", err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	fileName := fmt.Sprintf("%s-%s-%s.txt", name, time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+	path := filepath.Join(b.SpillDir, fileName)
+	if err := os.WriteFile(path, []byte(output), 0600); err != nil {
+		return "", err
+	}
+	pruneSpillFiles(b.SpillDir, path)
+	return path, nil
+}
+
+// pruneSpillFiles 删除过期和超出数量上限的溢出文件，keep 为刚写入的文件。清理失败不影响本次输出
+func pruneSpillFiles(dir, keep string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type spillFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []spillFile
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.Type().IsRegular() || filepath.Ext(path) != ".txt" || path == keep {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > spillRetention {
+			os.Remove(path)
+			continue
+		}
+		files = append(files, spillFile{path, info.ModTime()})
+	}
+	if excess := len(files) + 1 - maxSpillFiles; excess > 0 {
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+		for _, f := range files[:excess] {
+			os.Remove(f.path)
+		}
+	}
+}
+
+// truncateRunes 按字符数截断字符串
+func truncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return strings.TrimRight(s, "\n")
+	}
+	return string(runes[:n])
+}
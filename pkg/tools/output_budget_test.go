@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wordCount 测试用的确定性计数：每个单词一个 token
+func wordCount(s string) int {
+	return len(strings.Fields(s))
+}
+
+func TestOutputBudget_WithinBudget(t *testing.T) {
+	budget := &OutputBudget{MaxTokens: 100, CountTokens: wordCount}
+	report := budget.Apply("test", "hello world\n")
+	if report.Truncated || report.Text != "hello world\n" {
+		t.Errorf("output within budget should be unchanged, got %+v", report)
+	}
+}
+
+func TestOutputBudget_KeepsHeadAndTail(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	output := b.String()
+
+	budget := &OutputBudget{MaxTokens: 100, HeadRatio: 0.5, SpillDir: t.TempDir(), CountTokens: wordCount}
+	report := budget.Apply("system_command", output)
+
+	if !report.Truncated {
+		t.Fatal("output over budget should be truncated")
+	}
+	if !strings.HasPrefix(report.Text, "line 1\n") {
+		t.Errorf("should keep the head, got: %q", report.Text[:40])
+	}
+	if !strings.Contains(report.Text, "line 1000\n") {
+		t.Error("should keep the tail")
+	}
+	if strings.Contains(report.Text, "line 500\n") {
+		t.Error("should elide the middle")
+	}
+	if report.TotalLines != 1000 || report.OmittedLines != 1000-50 {
+		t.Errorf("unexpected line accounting: total=%d omitted=%d", report.TotalLines, report.OmittedLines)
+	}
+	if report.KeptTokens > budget.MaxTokens {
+		t.Errorf("kept %d tokens, budget is %d", report.KeptTokens, budget.MaxTokens)
+	}
+	if !strings.Contains(report.Text, "已省略中间 950 行") || !strings.Contains(report.Text, "输出过长已截断") {
+		t.Errorf("should report how much was cut, got: %s", report.Text)
+	}
+
+	// 完整输出保存在溢出文件中
+	if report.SpillFile == "" {
+		t.Fatal("should spill full output to a file")
+	}
+	data, err := os.ReadFile(report.SpillFile)
+	if err != nil {
+		t.Fatalf("read spill file: %v", err)
+	}
+	if string(data) != output {
+		t.Error("spill file should contain the full output")
+	}
+	if !strings.Contains(report.Text, report.SpillFile) {
+		t.Error("truncated text should reference the spill file")
+	}
+}
+
+func TestOutputBudget_PrunesSpillFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-spillRetention - time.Hour)
+	for i := 0; i < maxSpillFiles+15; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("system_command-%03d.txt", i))
+		if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// 前 10 个已过期，其余按序号由旧到新
+		modTime := time.Now().Add(time.Duration(i-maxSpillFiles) * time.Minute)
+		if i < 10 {
+			modTime = old
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	budget := &OutputBudget{MaxTokens: 10, SpillDir: dir, CountTokens: wordCount}
+	report := budget.Apply("system_command", strings.Repeat("word\n", 100))
+	if report.SpillFile == "" {
+		t.Fatal("expected a spill file")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxSpillFiles {
+		t.Errorf("expected %d spill files after pruning, got %d", maxSpillFiles, len(entries))
+	}
+	if _, err := os.Stat(report.SpillFile); err != nil {
+		t.Errorf("new spill file should be kept: %v", err)
+	}
+	// 过期的 10 个和剩余最旧的 6 个被删除
+	for _, name := range []string{"system_command-000.txt", "system_command-009.txt", "system_command-015.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("spill file %s should be removed", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "system_command-214.txt")); err != nil {
+		t.Errorf("newest spill file should be kept: %v", err)
+	}
+}
+
+func TestOutputBudget_SingleHugeLine(t *testing.T) {
+	budget := &OutputBudget{MaxTokens: 10, CountTokens: approximateTokens}
+	report := budget.Apply("test", strings.Repeat("x", 10000))
+	if !report.Truncated {
+		t.Fatal("huge line should be truncated")
+	}
+	if len(report.Text) > 1000 {
+		t.Errorf("truncated text too long: %d bytes", len(report.Text))
+	}
+	if report.SpillFile != "" {
+		t.Error("should not spill without SpillDir")
+	}
+}
+
+func TestOutputBudget_Nil(t *testing.T) {
+	var budget *OutputBudget
+	if got := budget.Apply("test", "abc").Text; got != "abc" {
+		t.Errorf("nil budget should not change output, got %q", got)
+	}
+}
+
+func TestApproximateTokens(t *testing.T) {
+	if got := approximateTokens("abcdefgh"); got != 2 {
+		t.Errorf("approximateTokens(ascii) = %d, want 2", got)
+	}
+	if got := approximateTokens("你好世界"); got != 4 {
+		t.Errorf("approximateTokens(cjk) = %d, want 4", got)
+	}
+}
@@ -0,0 +1,247 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// editContextLines 编辑结果中每处修改前后显示的上下文行数
+const editContextLines = 2
+
+// textEdit 一处文本替换
+type textEdit struct {
+	// search 要替换的原文，必须在文件中恰好出现一次
+	search string
+	// replace 替换后的内容
+	replace string
+	// lineAnchored 原文必须从行首开始、到行尾结束（来自统一差异的差异块）
+	lineAnchored bool
+}
+
+// editRegion 修改后的内容在新文件中的位置（字节偏移）
+type editRegion struct {
+	start, end int
+}
+
+// applyEdits 在原内容上定位全部修改并一次性应用。每处原文都必须恰好出现一次且互不重叠，
+// 任何一处不满足时返回错误，不做部分修改。
+func applyEdits(content string, edits []textEdit) (string, []editRegion, error) {
+	if len(edits) == 0 {
+		return "", nil, fmt.Errorf("没有要应用的修改")
+	}
+
+	// 文件使用 CRLF 换行时，按同样的换行符匹配和替换
+	crlf := strings.Contains(content, "\r\n")
+
+	type match struct {
+		start, end int
+		replace    string
+		index      int
+	}
+	matches := make([]match, 0, len(edits))
+	for i, edit := range edits {
+		search, replace := edit.search, edit.replace
+		if crlf {
+			search, replace = toCRLF(search), toCRLF(replace)
+		}
+		if search == "" {
+			if content == "" && len(edits) == 1 {
+				matches = append(matches, match{0, 0, replace, i})
+				continue
+			}
+			return "", nil, fmt.Errorf("第%d处修改的原文为空，请提供要替换的原文作为定位锚点", i+1)
+		}
+
+		positions := findAnchor(content, search, edit.lineAnchored)
+		switch len(positions) {
+		case 0:
+			return "", nil, fmt.Errorf("第%d处修改的原文在文件中不存在，请先用 file_reader 确认原文（包括缩进和空白）:\n%s",
+				i+1, quoteSnippet(edit.search))
+		case 1:
+			matches = append(matches, match{positions[0], positions[0] + len(search), replace, i})
+		default:
+			lines := make([]string, 0, len(positions))
+			for _, pos := range positions {
+				lines = append(lines, fmt.Sprint(lineAt(content, pos)))
+			}
+			return "", nil, fmt.Errorf("第%d处修改的原文在文件中出现了 %d 次（第 %s 行），请包含更多上下文使其唯一",
+				i+1, len(positions), strings.Join(lines, "、"))
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	for i := 1; i < len(matches); i++ {
+		if matches[i].start < matches[i-1].end {
+			return "", nil, fmt.Errorf("第%d处和第%d处修改的原文相互重叠，请合并为一处修改",
+				matches[i-1].index+1, matches[i].index+1)
+		}
+	}
+
+	var out strings.Builder
+	regions := make([]editRegion, 0, len(matches))
+	last := 0
+	for _, m := range matches {
+		out.WriteString(content[last:m.start])
+		start := out.Len()
+		out.WriteString(m.replace)
+		regions = append(regions, editRegion{start, out.Len()})
+		last = m.end
+	}
+	out.WriteString(content[last:])
+	return out.String(), regions, nil
+}
+
+// findAnchor 返回 search 在 content 中出现的全部位置（包括相互重叠的出现）
+func findAnchor(content, search string, lineAnchored bool) []int {
+	var positions []int
+	for offset := 0; offset <= len(content)-len(search); {
+		i := strings.Index(content[offset:], search)
+		if i < 0 {
+			break
+		}
+		pos := offset + i
+		offset = pos + 1
+		if lineAnchored {
+			if pos > 0 && content[pos-1] != '\n' {
+				continue
+			}
+			// 原文最后一行没有换行时，只能匹配文件末尾
+			if !strings.HasSuffix(search, "\n") && pos+len(search) != len(content) {
+				continue
+			}
+		}
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+// toCRLF 把 LF 换行转换为 CRLF
+func toCRLF(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// lineAt 返回字节偏移所在的行号，从 1 开始
+func lineAt(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// quoteSnippet 截取原文的前几行用于错误提示
+func quoteSnippet(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > 5 {
+		lines = append(lines[:5], fmt.Sprintf("...（共 %d 行）", len(lines)))
+	}
+	return "  " + strings.Join(lines, "\n  ")
+}
+
+// formatEditedHunks 以 file_reader 相同的 "%6d|" 行号格式显示每处修改后的内容及上下文，相邻的修改合并显示
+func formatEditedHunks(content string, regions []editRegion) string {
+	lines := strings.Split(content, "\n")
+	if n := len(lines); n > 1 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+
+	type span struct{ first, last int }
+	var spans []span
+	for _, r := range regions {
+		first := min(lineAt(content, r.start), len(lines))
+		last := first
+		if r.end > r.start {
+			last = lineAt(content, r.end-1)
+		}
+		first = max(1, first-editContextLines)
+		last = min(len(lines), last+editContextLines)
+		if n := len(spans); n > 0 && first <= spans[n-1].last+1 {
+			spans[n-1].last = max(spans[n-1].last, last)
+			continue
+		}
+		spans = append(spans, span{first, last})
+	}
+
+	var b strings.Builder
+	for i, s := range spans {
+		if i > 0 {
+			b.WriteString("   ...\n")
+		}
+		for n := s.first; n <= s.last; n++ {
+			fmt.Fprintf(&b, "%6d|%s\n", n, strings.TrimSuffix(lines[n-1], "\r"))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// parseUnifiedDiff 把统一格式差异（diff -u）的每个差异块转换为按行锚定的替换。
+// 差异块头中的行号和行数只作参考，定位以差异块中的上下文和删除行为准。
+func parseUnifiedDiff(diff string) ([]textEdit, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+
+	var edits []textEdit
+	var oldSide, newSide *strings.Builder
+	// lastKind 上一行的类型，用于处理 "\ No newline at end of file"
+	var lastKind byte
+	flush := func() {
+		if oldSide != nil && (oldSide.Len() > 0 || newSide.Len() > 0) {
+			edits = append(edits, textEdit{search: oldSide.String(), replace: newSide.String(), lineAnchored: true})
+		}
+	}
+
+	for i, line := range lines {
+		// 差异块之后再出现 ---/+++ 文件头，说明差异涉及多个文件
+		if oldSide != nil && strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			return nil, fmt.Errorf("差异中包含多个文件，每次只能编辑一个文件")
+		}
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			oldSide, newSide = &strings.Builder{}, &strings.Builder{}
+			lastKind = 0
+			continue
+		case oldSide == nil:
+			// 第一个差异块之前的文件头（diff/index/---/+++）
+			continue
+		case strings.HasPrefix(line, `\`):
+			// 上一行没有结尾换行
+			if lastKind != '+' {
+				trimNewline(oldSide)
+			}
+			if lastKind != '-' {
+				trimNewline(newSide)
+			}
+			continue
+		}
+
+		kind, text := byte(' '), line
+		if line != "" {
+			kind, text = line[0], line[1:]
+		}
+		switch kind {
+		case ' ':
+			oldSide.WriteString(text + "\n")
+			newSide.WriteString(text + "\n")
+		case '-':
+			oldSide.WriteString(text + "\n")
+		case '+':
+			newSide.WriteString(text + "\n")
+		default:
+			return nil, fmt.Errorf("无法解析的差异行: %q，差异块中的每行必须以空格、'-' 或 '+' 开头", line)
+		}
+		lastKind = kind
+	}
+	flush()
+
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("差异中没有差异块（以 @@ 开头）")
+	}
+	return edits, nil
+}
+
+// trimNewline 去掉末尾的换行
+func trimNewline(b *strings.Builder) {
+	s := strings.TrimSuffix(b.String(), "\n")
+	b.Reset()
+	b.WriteString(s)
+}
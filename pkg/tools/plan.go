@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"sync"
+)
+
+// PlanStep 演练模式下记录的一个待执行操作
+type PlanStep struct {
+	// Tool 工具名称
+	Tool string
+	// Input 原始工具输入，逐步执行时原样传回工具
+	Input string
+	// Summary 操作说明，例如要执行的命令或要写入的文件
+	Summary string
+	// Risks 风险提示，例如危险子命令、策略确认规则
+	Risks []string
+}
+
+// Planner 演练（dry-run）模式的状态。
+// 启用后 SystemCommand 和 FileWriter 不执行任何操作，只把将要执行的操作记录为计划步骤，
+// 一轮对话结束后由界面展示完整计划，并可逐步执行。
+type Planner struct {
+	mu      sync.Mutex
+	enabled bool
+	steps   []PlanStep
+}
+
+// NewPlanner 创建演练模式状态
+func NewPlanner(enabled bool) *Planner {
+	return &Planner{enabled: enabled}
+}
+
+// Enabled 是否处于演练模式
+func (p *Planner) Enabled() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.enabled
+}
+
+// SetEnabled 开启或关闭演练模式
+func (p *Planner) SetEnabled(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enabled = enabled
+}
+
+// Active 本次工具调用是否只记录不执行。逐步执行计划时 ctx 会带上执行标记。
+func (p *Planner) Active(ctx context.Context) bool {
+	return p.Enabled() && !isPlanExecution(ctx)
+}
+
+// Record 记录一个计划步骤，返回其序号（从1开始）
+func (p *Planner) Record(step PlanStep) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.steps = append(p.steps, step)
+	return len(p.steps)
+}
+
+// Steps 返回当前记录的全部步骤
+func (p *Planner) Steps() []PlanStep {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PlanStep(nil), p.steps...)
+}
+
+// Take 取出并清空当前记录的步骤
+func (p *Planner) Take() []PlanStep {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	steps := p.steps
+	p.steps = nil
+	return steps
+}
+
+// planExecutionKey 标记工具调用来自计划的逐步执行
+type planExecutionKey struct{}
+
+// WithPlanExecution 返回带执行标记的 ctx，工具在演练模式下也会真正执行。
+// 危险命令确认、策略检查等安全机制仍然生效。
+func WithPlanExecution(ctx context.Context) context.Context {
+	return context.WithValue(ctx, planExecutionKey{}, true)
+}
+
+// isPlanExecution ctx 是否带有执行标记
+func isPlanExecution(ctx context.Context) bool {
+	v, _ := ctx.Value(planExecutionKey{}).(bool)
+	return v
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanner_SystemCommandNotExecuted(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker.txt")
+
+	cmd := NewSystemCommand()
+	cmd.Output = nil
+	cmd.Planner = NewPlanner(true)
+
+	result, err := cmd.Call(context.Background(), "touch "+marker+" && rm -f "+marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "演练模式") {
+		t.Errorf("应返回演练模式的模拟结果, got %q", result)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("演练模式下命令不应被执行")
+	}
+
+	steps := cmd.Planner.Take()
+	if len(steps) != 1 {
+		t.Fatalf("应记录 1 个计划步骤, got %d", len(steps))
+	}
+	if steps[0].Tool != "system_command" || len(steps[0].Risks) == 0 {
+		t.Errorf("计划步骤应包含工具名和 rm 的风险提示, got %+v", steps[0])
+	}
+	if len(cmd.Planner.Steps()) != 0 {
+		t.Error("Take 之后计划应为空")
+	}
+}
+
+func TestPlanner_FileWriterNotExecuted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plan.txt")
+	os.WriteFile(path, []byte("old"), 0644)
+
+	writer := NewFileWriter()
+	writer.Planner = NewPlanner(true)
+
+	input, _ := json.Marshal(FileWriteParams{FilePath: path, Content: "new content"})
+	if _, err := writer.Call(context.Background(), string(input)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("演练模式下文件不应被修改, got %q", data)
+	}
+
+	steps := writer.Planner.Take()
+	if len(steps) != 1 || len(steps[0].Risks) != 1 {
+		t.Fatalf("应记录 1 个带覆盖提示的步骤, got %+v", steps)
+	}
+
+	// 逐步执行时真正写入
+	if _, err := writer.Call(WithPlanExecution(context.Background()), steps[0].Input); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new content" {
+		t.Errorf("执行计划步骤后文件应被写入, got %q", data)
+	}
+	if len(writer.Planner.Steps()) != 0 {
+		t.Error("执行计划步骤不应再次记录")
+	}
+}
+
+func TestPlanner_Toggle(t *testing.T) {
+	var nilPlanner *Planner
+	if nilPlanner.Active(context.Background()) {
+		t.Error("未设置 Planner 时不应处于演练模式")
+	}
+
+	p := NewPlanner(false)
+	if p.Active(context.Background()) {
+		t.Error("关闭时不应处于演练模式")
+	}
+	p.SetEnabled(true)
+	if !p.Active(context.Background()) {
+		t.Error("开启后应处于演练模式")
+	}
+	if p.Active(WithPlanExecution(context.Background())) {
+		t.Error("执行计划时不应再记录")
+	}
+}
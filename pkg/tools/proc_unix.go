@@ -0,0 +1,59 @@
+//go:build !windows
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setProcessGroup 让子进程成为独立进程组的组长，便于整体终止
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if !cmd.SysProcAttr.Setsid {
+		cmd.SysProcAttr.Setpgid = true
+	}
+}
+
+// setForeground 标准输入是终端且 aishell 位于前台时，让子进程的进程组成为终端的前台进程组，
+// sudo、ssh 等从终端读取密码的命令不会因 SIGTTIN 挂起。返回的函数在命令结束后把前台交还给 aishell。
+// 子进程在前台期间按下的 Ctrl+C 只会发给子进程，若它因 SIGINT 退出，交还前台后向 aishell 自身
+// 重发一次 SIGINT，由正常的中断处理取消当前这一轮。需要在 setProcessGroup 之后调用。
+func setForeground(cmd *exec.Cmd) (restore func()) {
+	fd := int(os.Stdin.Fd())
+	pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	if err != nil || pgrp != syscall.Getpgrp() || cmd.SysProcAttr.Setsid {
+		return func() {}
+	}
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = fd
+	return func() {
+		// 此时 aishell 位于后台进程组，修改前台进程组需要忽略 SIGTTOU
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, pgrp)
+		if cmd.ProcessState == nil {
+			return
+		}
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGINT {
+			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+		}
+	}
+}
+
+// killProcessGroup 终止进程及其所在进程组中的全部子进程
+func killProcessGroup(p *os.Process) error {
+	if p == nil {
+		return nil
+	}
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup Windows 下通过 taskkill /T 终止进程树，无需额外设置
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// setForeground Windows 下没有前台进程组，无需处理
+func setForeground(cmd *exec.Cmd) (restore func()) {
+	return func() {}
+}
+
+// killProcessGroup 终止进程及其子进程树
+func killProcessGroup(p *os.Process) error {
+	if p == nil {
+		return nil
+	}
+	if err := exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(p.Pid)).Run(); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
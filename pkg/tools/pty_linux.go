@@ -0,0 +1,60 @@
+//go:build linux
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY 打开一对伪终端，返回主端和从端。
+// 从端关闭回显并禁用输出换行转换，使会话输出与管道模式一致。
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开 /dev/ptmx 失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			master.Close()
+		}
+	}()
+
+	fd := int(master.Fd())
+	if err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		return nil, nil, fmt.Errorf("解锁伪终端失败: %w", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取伪终端编号失败: %w", err)
+	}
+
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开伪终端从端失败: %w", err)
+	}
+
+	termios, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS)
+	if err != nil {
+		slave.Close()
+		return nil, nil, fmt.Errorf("读取终端属性失败: %w", err)
+	}
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON
+	termios.Oflag &^= unix.OPOST
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err = unix.IoctlSetTermios(int(slave.Fd()), unix.TCSETS, termios); err != nil {
+		slave.Close()
+		return nil, nil, fmt.Errorf("设置终端属性失败: %w", err)
+	}
+
+	return master, slave, nil
+}
+
+// ptySysProcAttr 让子进程成为新会话的首进程，并以伪终端作为控制终端
+func ptySysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errPTYUnsupported 当前平台不支持伪终端，会话退回到管道模式
+var errPTYUnsupported = errors.New("当前平台不支持伪终端")
+
+// openPTY 非 Linux 平台不提供伪终端
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, errPTYUnsupported
+}
+
+// ptySysProcAttr 非 Linux 平台不需要额外的进程属性
+func ptySysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,235 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 沙箱模式
+const (
+	// SandboxOff 不使用沙箱（默认）
+	SandboxOff = "off"
+	// SandboxOn 系统支持时在沙箱中执行，不支持时提示一次后照常执行
+	SandboxOn = "on"
+	// SandboxRequired 必须在沙箱中执行，系统不支持时拒绝执行命令
+	SandboxRequired = "required"
+)
+
+// 默认的资源限制
+const (
+	// DefaultSandboxCPU 每个进程的 CPU 时间上限
+	DefaultSandboxCPU = 5 * time.Minute
+	// DefaultSandboxMemory 每个进程的虚拟内存上限（字节）
+	DefaultSandboxMemory = 4 << 30
+	// DefaultSandboxOutput 命令输出和写入单个文件的大小上限（字节）
+	DefaultSandboxOutput = 64 << 20
+)
+
+// Sandbox 系统命令的隔离环境（仅 Linux）：在新的用户、挂载、PID（可选网络）命名空间中运行命令，
+// 除可写目录外整个文件系统只读挂载，并限制 CPU 时间、内存和输出大小。
+// 不支持非特权命名空间的系统上按 Mode 决定照常执行还是拒绝执行。
+type Sandbox struct {
+	// Mode 沙箱模式：SandboxOff、SandboxOn 或 SandboxRequired
+	Mode string
+	// Writable 沙箱内可写的目录，通常为工作区根目录
+	Writable []string
+	// Network 是否允许访问网络，为 false 时命令只能使用回环网络
+	Network bool
+	// CPUTime 每个进程的 CPU 时间上限，0 表示不限制
+	CPUTime time.Duration
+	// Memory 每个进程的虚拟内存上限（字节），0 表示不限制
+	Memory int64
+	// MaxOutput 命令输出和写入单个文件的大小上限（字节），0 表示不限制
+	MaxOutput int64
+	// Warn 沙箱不可用时的提示输出位置，默认为标准错误
+	Warn io.Writer
+
+	// probeMu 保护探测结果，探测过程中会用到 mu
+	probeMu  sync.Mutex
+	probed   bool
+	probeErr error
+	mu       sync.Mutex
+	warned   bool
+	tmpDir   string
+}
+
+// NewSandbox 创建指定模式的沙箱，资源限制使用默认值
+func NewSandbox(mode string) *Sandbox {
+	return &Sandbox{
+		Mode:      mode,
+		CPUTime:   DefaultSandboxCPU,
+		Memory:    DefaultSandboxMemory,
+		MaxOutput: DefaultSandboxOutput,
+	}
+}
+
+// ValidSandboxMode 检查沙箱模式是否有效
+func ValidSandboxMode(mode string) bool {
+	switch mode {
+	case "", SandboxOff, SandboxOn, SandboxRequired:
+		return true
+	}
+	return false
+}
+
+// Enabled 是否配置了沙箱
+func (s *Sandbox) Enabled() bool {
+	return s != nil && s.Mode != "" && s.Mode != SandboxOff
+}
+
+// Available 检查当前系统能否创建沙箱。第一次调用时在沙箱中试运行一次，结果会被缓存。
+func (s *Sandbox) Available() error {
+	s.probeMu.Lock()
+	defer s.probeMu.Unlock()
+	if !s.probed {
+		s.probed = true
+		s.probeErr = s.probe()
+	}
+	return s.probeErr
+}
+
+// Active 命令是否会在沙箱中执行
+func (s *Sandbox) Active() bool {
+	return s.Enabled() && s.Available() == nil
+}
+
+// Wrap 改写命令使其在沙箱中执行，返回命令是否被放入沙箱。
+// 沙箱不可用时，SandboxRequired 模式返回错误，SandboxOn 模式提示一次后保持命令不变。
+func (s *Sandbox) Wrap(cmd *exec.Cmd) (bool, error) {
+	if !s.Enabled() {
+		return false, nil
+	}
+	if err := s.Available(); err != nil {
+		if s.Mode == SandboxRequired {
+			return false, fmt.Errorf("沙箱不可用，命令未执行: %w", err)
+		}
+		s.warnOnce(err)
+		return false, nil
+	}
+	if err := s.wrap(cmd); err != nil {
+		return false, fmt.Errorf("创建沙箱失败: %w", err)
+	}
+	return true, nil
+}
+
+// Description 返回给模型的沙箱说明，沙箱未启用时为空
+func (s *Sandbox) Description() string {
+	if !s.Active() {
+		return ""
+	}
+	network := "网络已禁用（只能访问回环地址）"
+	if s.Network {
+		network = "可以访问网络"
+	}
+	return fmt.Sprintf(`
+命令运行在沙箱中：除 %s 外整个文件系统只读，临时文件请写到 $TMPDIR；%s；CPU 时间、内存和输出大小受限。
+写入只读位置会失败（Read-only file system），这类操作需要请用户在沙箱之外执行。`,
+		strings.Join(s.Writable, "、"), network)
+}
+
+// Close 删除沙箱的临时目录
+func (s *Sandbox) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tmpDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(s.tmpDir)
+	s.tmpDir = ""
+	return err
+}
+
+// tempDir 返回沙箱内可写的临时目录，首次调用时创建
+func (s *Sandbox) tempDir() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tmpDir == "" {
+		dir, err := os.MkdirTemp("", "aishell-sandbox-*")
+		if err != nil {
+			return "", err
+		}
+		s.tmpDir = dir
+	}
+	return s.tmpDir, nil
+}
+
+// warnOnce 沙箱不可用时提示一次
+func (s *Sandbox) warnOnce(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.warned {
+		return
+	}
+	s.warned = true
+	w := s.Warn
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, "⚠️  沙箱不可用，命令将在沙箱之外执行: %v\n", err)
+}
+
+// outputLimit 限制命令的输出总量，超出后丢弃后续输出并调用 exceeded
+type outputLimit struct {
+	mu       sync.Mutex
+	max      int64
+	written  int64
+	hit      bool
+	exceeded func()
+}
+
+// writer 包装输出写入端
+func (l *outputLimit) writer(w io.Writer) io.Writer {
+	if l == nil {
+		return w
+	}
+	return limitedWriter{limit: l, w: w}
+}
+
+// Exceeded 输出是否超过了上限
+func (l *outputLimit) Exceeded() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hit
+}
+
+// limitedWriter 计入输出总量的写入端
+type limitedWriter struct {
+	limit *outputLimit
+	w     io.Writer
+}
+
+// Write 实现 io.Writer 接口，超过上限的部分被丢弃
+func (lw limitedWriter) Write(p []byte) (int, error) {
+	l := lw.limit
+	l.mu.Lock()
+	remaining := l.max - l.written
+	n := int64(len(p))
+	if n > remaining {
+		n = max(remaining, 0)
+	}
+	l.written += n
+	first := !l.hit && n < int64(len(p))
+	if first {
+		l.hit = true
+	}
+	l.mu.Unlock()
+
+	if n > 0 {
+		lw.w.Write(p[:n])
+	}
+	if first && l.exceeded != nil {
+		l.exceeded()
+	}
+	return len(p), nil
+}
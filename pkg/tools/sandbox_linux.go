@@ -0,0 +1,381 @@
+//go:build linux
+
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxEnv 传递沙箱配置的环境变量。带有该变量启动的 aishell 进程不进入主程序，
+// 而是在新的命名空间中完成挂载和资源限制后执行目标命令。
+const sandboxEnv = "AISHELL_SANDBOX_SPEC"
+
+// sandboxInitFailed 沙箱初始化失败时的退出码
+const sandboxInitFailed = 125
+
+// securebits 让 uid 0 执行程序时不再获得能力，并锁定该设置（linux/securebits.h）
+const (
+	secbitNoRoot             = 1 << 0
+	secbitNoRootLocked       = 1 << 1
+	secbitNoSetuidFixup      = 1 << 2
+	secbitNoSetuidFixupLock  = 1 << 3
+	secbitKeepCapsLocked     = 1 << 5
+	secbitNoCapAmbientRaise  = 1 << 6
+	secbitNoCapAmbientLocked = 1 << 7
+)
+
+// sandboxSpec 沙箱内初始化进程的配置
+type sandboxSpec struct {
+	// Writable 可写目录（已解析符号链接）
+	Writable []string `json:"writable"`
+	// TmpDir 沙箱内的临时目录，同时设置为 TMPDIR
+	TmpDir string `json:"tmpdir"`
+	// Network 是否保留宿主网络
+	Network bool `json:"network"`
+	// CPUSeconds、Memory、FileSize 资源限制，0 表示不限制
+	CPUSeconds uint64 `json:"cpu_seconds,omitempty"`
+	Memory     uint64 `json:"memory,omitempty"`
+	FileSize   uint64 `json:"file_size,omitempty"`
+	// Probe 只检查沙箱能否建立，完成初始化后直接退出
+	Probe bool `json:"probe,omitempty"`
+}
+
+func init() {
+	if spec := os.Getenv(sandboxEnv); spec != "" {
+		sandboxInit(spec)
+	}
+}
+
+// spec 根据沙箱配置生成初始化进程的配置
+func (s *Sandbox) spec() (*sandboxSpec, error) {
+	tmp, err := s.tempDir()
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	spec := &sandboxSpec{TmpDir: realPath(tmp), Network: s.Network}
+	for _, dir := range append(append([]string{}, s.Writable...), tmp) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			spec.Writable = append(spec.Writable, realPath(abs))
+		}
+	}
+	if s.CPUTime > 0 {
+		spec.CPUSeconds = uint64(max(1, s.CPUTime.Seconds()))
+	}
+	if s.Memory > 0 {
+		spec.Memory = uint64(s.Memory)
+	}
+	if s.MaxOutput > 0 {
+		spec.FileSize = uint64(s.MaxOutput)
+	}
+	return spec, nil
+}
+
+// wrap 把命令改写为由 aishell 自身在新命名空间中启动：
+// /proc/self/exe <目标程序> <原参数...>，配置通过 sandboxEnv 传递
+func (s *Sandbox) wrap(cmd *exec.Cmd) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	spec, err := s.spec()
+	if err != nil {
+		return err
+	}
+	return applySandbox(cmd, spec)
+}
+
+// probe 在沙箱中试运行一次初始化，检查系统是否支持非特权命名空间
+func (s *Sandbox) probe() error {
+	spec, err := s.spec()
+	if err != nil {
+		return err
+	}
+	spec.Probe = true
+	cmd := &exec.Cmd{Path: "/proc/self/exe", Args: []string{"aishell-sandbox"}}
+	if err := applySandbox(cmd, spec); err != nil {
+		return err
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return fmt.Errorf("无法创建命名空间（可能未开启非特权用户命名空间）: %w", err)
+	}
+	return nil
+}
+
+// applySandbox 设置命名空间和传递给初始化进程的配置
+func applySandbox(cmd *exec.Cmd, spec *sandboxSpec) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if !spec.Probe {
+		cmd.Args = append([]string{"aishell-sandbox", cmd.Path}, cmd.Args...)
+		cmd.Path = "/proc/self/exe"
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, sandboxEnv+"="+string(data))
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+	if !spec.Network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	// 沙箱内保持原来的用户身份，命令创建的文件属主不变
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	// 非 root 用户 exec 后会失去新命名空间中的能力，初始化进程需要的能力通过 ambient 集合保留，
+	// 执行目标命令前由 dropCapabilities 全部清除
+	attr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_NET_ADMIN, unix.CAP_SETPCAP}
+	return nil
+}
+
+// sandboxInit 沙箱内的初始化进程：完成挂载、网络和资源限制后执行目标命令，不会返回
+func sandboxInit(specJSON string) {
+	// 能力和 securebits 属于线程，设置和 exec 必须在同一个线程上完成
+	runtime.LockOSThread()
+
+	fail := func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "沙箱初始化失败: "+format+"\n", args...)
+		os.Exit(sandboxInitFailed)
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(specJSON), &spec); err != nil {
+		fail("解析配置失败: %v", err)
+	}
+	if err := setupMounts(&spec); err != nil {
+		fail("%v", err)
+	}
+	if !spec.Network {
+		// 新的网络命名空间中只有未启用的回环接口，启用后本地服务之间仍可通信
+		_ = loopbackUp()
+	}
+	// 虚拟内存上限可能低于运行时已占用的地址空间，设置资源限制之后不能再分配内存，exec 的参数提前准备好
+	var argv0 *byte
+	var argv, envv []*byte
+	if !spec.Probe {
+		if len(os.Args) < 3 {
+			fail("缺少要执行的命令")
+		}
+		env := make([]string, 0, len(os.Environ())+1)
+		for _, kv := range os.Environ() {
+			if !strings.HasPrefix(kv, sandboxEnv+"=") && !strings.HasPrefix(kv, "TMPDIR=") {
+				env = append(env, kv)
+			}
+		}
+		if spec.TmpDir != "" {
+			env = append(env, "TMPDIR="+spec.TmpDir)
+		}
+		var err error
+		if argv0, err = syscall.BytePtrFromString(os.Args[1]); err != nil {
+			fail("无效的命令: %v", err)
+		}
+		if argv, err = syscall.SlicePtrFromStrings(os.Args[2:]); err != nil {
+			fail("无效的参数: %v", err)
+		}
+		if envv, err = syscall.SlicePtrFromStrings(env); err != nil {
+			fail("无效的环境变量: %v", err)
+		}
+	}
+
+	if err := setLimits(&spec); err != nil {
+		fail("设置资源限制失败: %v", err)
+	}
+	if err := dropCapabilities(); err != nil {
+		fail("放弃特权失败: %v", err)
+	}
+	if spec.Probe {
+		os.Exit(0)
+	}
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(argv0)), uintptr(unsafe.Pointer(&argv[0])), uintptr(unsafe.Pointer(&envv[0])))
+	fail("执行 %s 失败: %v", os.Args[1], errno)
+}
+
+// setupMounts 可写目录绑定挂载到自身，其余全部挂载点重新挂载为只读，并为新的 PID 命名空间挂载 /proc
+func setupMounts(spec *sandboxSpec) error {
+	// 挂载变化不传播到宿主
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败: %w", err)
+	}
+
+	// 绑定挂载产生独立的挂载点，后面把上级挂载点改为只读时不受影响
+	for _, dir := range spec.Writable {
+		if dir == "/" {
+			continue
+		}
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := unix.Mount(dir, dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("挂载可写目录 %s 失败: %w", dir, err)
+		}
+	}
+
+	// 只显示沙箱内的进程；/proc 被部分遮盖等情况下内核不允许挂载，此时保留原来的 /proc
+	_ = unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+
+	mounts, err := mountPoints()
+	if err != nil {
+		return err
+	}
+	for _, mp := range mounts {
+		if isWritable(spec.Writable, mp) {
+			continue
+		}
+		if err := remountReadOnly(mp); err != nil {
+			// 被上层挂载遮盖、无法通过路径访问的挂载点不影响沙箱内可见的文件
+			if mp != "/" && (err == unix.ENOENT || err == unix.EACCES) {
+				continue
+			}
+			return fmt.Errorf("把 %s 重新挂载为只读失败: %w", mp, err)
+		}
+	}
+	return nil
+}
+
+// isWritable 挂载点是否位于可写目录内
+func isWritable(writable []string, mp string) bool {
+	for _, dir := range writable {
+		if within(dir, mp) {
+			return true
+		}
+	}
+	return false
+}
+
+// remountReadOnly 把挂载点重新挂载为只读。用户命名空间中必须保留原有的 nosuid/nodev/noexec 等标志，否则内核拒绝修改。
+func remountReadOnly(mp string) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(mp, &st); err != nil {
+		return err
+	}
+	// statfs 的 ST_* 标志与 MS_* 取值相同
+	const kept = unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC | unix.MS_NOATIME | unix.MS_NODIRATIME | unix.MS_RELATIME | unix.MS_NOSYMFOLLOW
+	flags := uintptr(st.Flags) & kept
+	return unix.Mount("", mp, "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY|flags, "")
+}
+
+// mountPoints 读取当前挂载命名空间中的全部挂载点
+func mountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("读取挂载信息失败: %w", err)
+	}
+	defer f.Close()
+
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mounts = append(mounts, unescapeMountPath(fields[4]))
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath 还原 mountinfo 中八进制转义的空白和反斜杠（如 \040）
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// loopbackUp 启用回环接口
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// setLimits 设置 CPU 时间、虚拟内存和文件大小上限
+func setLimits(spec *sandboxSpec) error {
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{unix.RLIMIT_CPU, spec.CPUSeconds},
+		{unix.RLIMIT_AS, spec.Memory},
+		{unix.RLIMIT_FSIZE, spec.FileSize},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: l.value, Max: l.value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropCapabilities 放弃用户命名空间中的全部能力，使命令无法撤销只读挂载。
+// 以 root 身份运行时同样有效：securebits 阻止 uid 0 在 exec 时重新获得能力。
+func dropCapabilities() error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return err
+	}
+	bits := secbitNoRoot | secbitNoRootLocked | secbitNoSetuidFixup | secbitNoSetuidFixupLock |
+		secbitKeepCapsLocked | secbitNoCapAmbientRaise | secbitNoCapAmbientLocked
+	if err := unix.Prctl(unix.PR_SET_SECUREBITS, uintptr(bits), 0, 0, 0); err != nil {
+		return err
+	}
+	for c := 0; c <= unix.CAP_LAST_CAP; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil && err != unix.EINVAL {
+			return err
+		}
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	return unix.Capset(&hdr, &data[0])
+}
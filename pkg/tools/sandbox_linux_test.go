@@ -0,0 +1,165 @@
+//go:build linux
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSandbox 创建以 dir 为可写目录的沙箱，系统不支持非特权命名空间时跳过测试
+func newTestSandbox(t *testing.T, dir string) *Sandbox {
+	t.Helper()
+	s := NewSandbox(SandboxRequired)
+	s.Writable = []string{dir}
+	t.Cleanup(func() { s.Close() })
+	if err := s.Available(); err != nil {
+		t.Skipf("系统不支持沙箱: %v", err)
+	}
+	return s
+}
+
+// runSandboxed 在沙箱中执行命令
+func runSandboxed(t *testing.T, s *Sandbox, command string) *CommandResult {
+	t.Helper()
+	cmd := NewSystemCommand()
+	cmd.Output = nil
+	cmd.Sandbox = s
+	return cmd.execute(context.Background(), command, 10*time.Second)
+}
+
+func TestSandbox_Filesystem(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	s := newTestSandbox(t, workspace)
+
+	if r := runSandboxed(t, s, "echo ok > "+filepath.Join(workspace, "a.txt")); !r.Success() {
+		t.Fatalf("工作区内应可写: %v %q", r.Err, r.Stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "a.txt")); string(data) != "ok\n" {
+		t.Errorf("写入的内容应在沙箱外可见, got %q", data)
+	}
+
+	r := runSandboxed(t, s, "echo no > "+filepath.Join(outside, "b.txt"))
+	if r.Success() || !strings.Contains(r.Stderr, "Read-only file system") {
+		t.Errorf("工作区外应只读, got %v %q", r.Err, r.Stderr)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "b.txt")); err == nil {
+		t.Error("工作区外不应创建文件")
+	}
+
+	if r := runSandboxed(t, s, `echo tmp > "$TMPDIR/c.txt" && cat "$TMPDIR/c.txt"`); !r.Success() || strings.TrimSpace(r.Stdout) != "tmp" {
+		t.Errorf("$TMPDIR 应可写: %v %q", r.Err, r.Stderr)
+	}
+
+	// 命令不保留任何能力，只读挂载不能在沙箱内撤销
+	if r := runSandboxed(t, s, "grep -E '^Cap(Inh|Prm|Eff|Bnd|Amb)' /proc/self/status"); strings.Count(r.Stdout, "0000000000000000") != 5 {
+		t.Errorf("命令不应保留能力, got %q", r.Stdout)
+	}
+	runSandboxed(t, s, "mount -o remount,rw "+outside+"; mount -o remount,rw /; touch "+filepath.Join(outside, "d.txt"))
+	if _, err := os.Stat(filepath.Join(outside, "d.txt")); err == nil {
+		t.Error("沙箱内不应能重新挂载为可写")
+	}
+}
+
+func TestSandbox_NetworkAndPID(t *testing.T) {
+	s := newTestSandbox(t, t.TempDir())
+
+	r := runSandboxed(t, s, "tail -n +3 /proc/net/dev | cut -d: -f1")
+	if !r.Success() {
+		t.Fatalf("读取网络接口失败: %v %q", r.Err, r.Stderr)
+	}
+	if got := strings.Fields(r.Stdout); len(got) != 1 || got[0] != "lo" {
+		t.Errorf("未允许网络时只应有回环接口, got %q", got)
+	}
+
+	if r := runSandboxed(t, s, "echo $$"); strings.TrimSpace(r.Stdout) != "1" {
+		t.Errorf("命令应运行在新的 PID 命名空间中, got %q (%q)", r.Stdout, r.Stderr)
+	}
+
+	s.Network = true
+	r = runSandboxed(t, s, "tail -n +3 /proc/net/dev | cut -d: -f1")
+	host, _ := os.ReadFile("/proc/net/dev")
+	if len(strings.Fields(r.Stdout)) != strings.Count(string(host), "\n")-2 {
+		t.Errorf("允许网络时应看到宿主的网络接口, got %q", r.Stdout)
+	}
+}
+
+func TestSandbox_Limits(t *testing.T) {
+	workspace := t.TempDir()
+	s := newTestSandbox(t, workspace)
+	s.CPUTime = 7 * time.Second
+	s.Memory = 512 << 20
+	s.MaxOutput = 4096
+
+	r := runSandboxed(t, s, "ulimit -t; ulimit -v")
+	if got := strings.Fields(r.Stdout); len(got) != 2 || got[0] != "7" || got[1] != "524288" {
+		t.Errorf("应设置 CPU 和内存上限, got %q (%q)", r.Stdout, r.Stderr)
+	}
+
+	r = runSandboxed(t, s, "head -c 8192 /dev/zero > "+filepath.Join(workspace, "big"))
+	if r.Success() {
+		t.Error("写入超过上限的文件应失败")
+	}
+
+	r = runSandboxed(t, s, "yes")
+	if !r.OutputExceeded || !strings.Contains(r.Format(), "输出超过沙箱上限") {
+		t.Errorf("输出超过上限时应终止命令, got %q", r.Format())
+	}
+	if len(r.Stdout) > 4096 {
+		t.Errorf("输出应截断到上限, got %d 字节", len(r.Stdout))
+	}
+}
+
+func TestSandbox_ShellSession(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	s := newTestSandbox(t, workspace)
+
+	for _, usePTY := range []bool{false, true} {
+		sh := NewShellSession()
+		sh.UsePTY = usePTY
+		sh.Sandbox = s
+		r := sh.Run(context.Background(), "cd "+workspace+" && echo $$ && touch "+filepath.Join(outside, "x"), 5*time.Second, nil)
+		sh.Close()
+		if r.Success() || !strings.HasPrefix(strings.TrimSpace(r.Stdout), "1") {
+			t.Errorf("pty=%v: 持久 shell 应运行在沙箱中, got %q %q", usePTY, r.Stdout, r.Stderr)
+		}
+	}
+}
+
+func TestSandbox_Unavailable(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	unavailable := errors.New("不支持")
+
+	var warn bytes.Buffer
+	s := &Sandbox{Mode: SandboxOn, Warn: &warn, probed: true, probeErr: unavailable}
+	for i := 0; i < 2; i++ {
+		if r := runSandboxed(t, s, "touch "+marker); !r.Success() {
+			t.Fatalf("on 模式下沙箱不可用时应照常执行: %v", r.Err)
+		}
+	}
+	if strings.Count(warn.String(), "沙箱不可用") != 1 {
+		t.Errorf("应只提示一次, got %q", warn.String())
+	}
+	if s.Description() != "" {
+		t.Error("沙箱不可用时不应向模型说明沙箱")
+	}
+
+	os.Remove(marker)
+	s = &Sandbox{Mode: SandboxRequired, probed: true, probeErr: unavailable}
+	r := runSandboxed(t, s, "touch "+marker)
+	if r.Success() || r.Err == nil || !strings.Contains(r.Err.Error(), "沙箱不可用") {
+		t.Errorf("required 模式下沙箱不可用时应拒绝执行, got %v", r.Err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("required 模式下不应执行命令")
+	}
+}
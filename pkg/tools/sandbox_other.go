@@ -0,0 +1,21 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// errSandboxUnsupported 当前平台不支持命名空间沙箱
+var errSandboxUnsupported = errors.New("沙箱仅支持 Linux")
+
+// wrap 非 Linux 平台不提供沙箱
+func (s *Sandbox) wrap(cmd *exec.Cmd) error {
+	return errSandboxUnsupported
+}
+
+// probe 非 Linux 平台不提供沙箱
+func (s *Sandbox) probe() error {
+	return errSandboxUnsupported
+}
@@ -0,0 +1,222 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SchemaTool 提供参数 JSON Schema 的工具。
+// 函数调用代理把模型生成的 JSON 参数校验后原样作为 Call 的输入。
+type SchemaTool interface {
+	// Parameters 返回参数的 JSON Schema（type 为 object）
+	Parameters() map[string]any
+}
+
+// ConcurrentTool 可以与其他调用并行执行的工具（只读、不与用户交互）
+type ConcurrentTool interface {
+	// Concurrent 是否可以并行执行
+	Concurrent() bool
+}
+
+// SchemaFor 根据结构体定义生成 JSON Schema。
+// 字段名取自 json 标签，说明取自 desc 标签，没有 omitempty 的字段为必填。
+func SchemaFor(v any) map[string]any {
+	return schemaForType(reflect.TypeOf(v))
+}
+
+// schemaForType 生成单个类型的 JSON Schema
+func schemaForType(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			prop := schemaForType(field.Type)
+			if desc := field.Tag.Get("desc"); desc != "" {
+				prop["description"] = desc
+			}
+			properties[name] = prop
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]any{}
+}
+
+// ValidateArguments 按 JSON Schema 严格校验函数调用参数：
+// 必填字段、未知字段、类型和枚举值，校验失败的错误信息会返回给模型以便修正。
+func ValidateArguments(schema map[string]any, arguments string) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	dec := json.NewDecoder(strings.NewReader(arguments))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("参数不是合法的JSON: %w", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("参数不是合法的JSON: 包含多余内容")
+	}
+	return validateValue(schema, value, "参数")
+}
+
+// validateValue 递归校验单个值
+func validateValue(schema map[string]any, value any, path string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	if value == nil {
+		return fmt.Errorf("%s 不能为 null", path)
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s 的取值必须是 %v 之一", path, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s 应为对象", path)
+		}
+		return validateObject(schema, obj, path)
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s 应为数组", path)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range arr {
+			if err := validateValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s 应为字符串", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s 应为布尔值", path)
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s 应为整数", path)
+		}
+		if _, err := n.Int64(); err != nil {
+			return fmt.Errorf("%s 应为整数", path)
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("%s 应为数字", path)
+		}
+	}
+	return nil
+}
+
+// validateObject 校验对象的必填字段、未知字段和各字段的值
+func validateObject(schema map[string]any, obj map[string]any, path string) error {
+	properties, _ := schema["properties"].(map[string]any)
+
+	for _, name := range schemaRequired(schema) {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s 缺少必填字段 '%s'", path, name)
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, known := properties[name].(map[string]any)
+		if !known {
+			if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+				prop, known = additional, true
+			} else if schema["additionalProperties"] == false {
+				return fmt.Errorf("%s 包含未知字段 '%s'，可用字段: %s", path, name, strings.Join(sortedKeys(properties), ", "))
+			}
+		}
+		if known {
+			if err := validateValue(prop, obj[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// schemaRequired 读取 required 列表，兼容 []string 和 []any
+func schemaRequired(schema map[string]any) []string {
+	switch r := schema["required"].(type) {
+	case []string:
+		return r
+	case []any:
+		names := make([]string, 0, len(r))
+		for _, v := range r {
+			names = append(names, fmt.Sprint(v))
+		}
+		return names
+	}
+	return nil
+}
+
+// sortedKeys 返回排序后的键
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaFor(t *testing.T) {
+	schema := SchemaFor(FileWriteParams{})
+	if schema["type"] != "object" || schema["additionalProperties"] != false {
+		t.Fatalf("应生成不允许额外字段的 object schema, got %v", schema)
+	}
+
+	props := schema["properties"].(map[string]any)
+	if props["file_path"].(map[string]any)["type"] != "string" {
+		t.Error("file_path 应为 string")
+	}
+	if props["create_dirs"].(map[string]any)["type"] != "boolean" {
+		t.Error("create_dirs 应为 boolean")
+	}
+	if props["content"].(map[string]any)["description"] == nil {
+		t.Error("应包含 desc 标签中的说明")
+	}
+
+	required := strings.Join(schema["required"].([]string), ",")
+	if required != "file_path,content" {
+		t.Errorf("必填字段应为 file_path,content, got %s", required)
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	schema := SchemaFor(CommandRequest{})
+
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{`{"command": "ls"}`, ""},
+		{`{"command": "go test", "timeout": 300}`, ""},
+		{``, "缺少必填字段 'command'"},
+		{`{"cmd": "ls"}`, "缺少必填字段 'command'"},
+		{`{"command": "ls", "cwd": "/"}`, "未知字段 'cwd'"},
+		{`{"command": ["ls"]}`, "应为字符串"},
+		{`{"command": "ls", "timeout": "10"}`, "应为数字"},
+		{`{"command": null}`, "不能为 null"},
+		{`{"command": "ls"} extra`, "不是合法的JSON"},
+		{`[1]`, "应为对象"},
+	}
+	for _, tt := range tests {
+		err := ValidateArguments(schema, tt.args)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: 不应出错, got %v", tt.args, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: 错误应包含 %q, got %v", tt.args, tt.wantErr, err)
+		}
+	}
+
+	intSchema := SchemaFor(FileReadParams{})
+	if err := ValidateArguments(intSchema, `{"file_path": "a", "start_line": 1.5}`); err == nil {
+		t.Error("start_line 为小数时应校验失败")
+	}
+}
@@ -0,0 +1,379 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ShellSession 长期存在的 shell 会话，在多次命令调用之间保留工作目录、环境变量和 shell 函数。
+// Linux 下使用伪终端运行，其他平台退回到管道模式。
+// 每条命令后追加带随机标记的哨兵行，据此切分输出并取得退出码和当前目录。
+type ShellSession struct {
+	mu sync.Mutex
+
+	// Shell 使用的 shell 程序，默认优先 bash，其次 sh
+	Shell string
+	// UsePTY 是否使用伪终端，仅 Linux 有效
+	UsePTY bool
+	// Sandbox 启用后 shell 进程在沙箱中运行，为空时不隔离
+	Sandbox *Sandbox
+
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdoutCh chan []byte
+	stderrCh chan []byte
+	closers  []io.Closer
+	pty      bool
+	cwd      string
+	exited   chan struct{}
+}
+
+// NewShellSession 创建 shell 会话，首次执行命令时才启动 shell 进程
+func NewShellSession() *ShellSession {
+	return &ShellSession{UsePTY: true}
+}
+
+// errSessionExited shell 进程已经退出（例如执行了 exit）
+var errSessionExited = errors.New("shell 会话已退出")
+
+// Cwd 返回会话最近一次命令结束时的工作目录
+func (sh *ShellSession) Cwd() string {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.cwd
+}
+
+// Run 在会话中执行命令。超时或取消时会终止整个会话，下次调用时重新启动。
+func (sh *ShellSession) Run(ctx context.Context, command string, timeout time.Duration, live io.Writer) *CommandResult {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	result := &CommandResult{Command: command, Timeout: timeout, ExitCode: -1}
+	if err := sh.ensureStarted(); err != nil {
+		result.Err = fmt.Errorf("启动 shell 会话失败: %w", err)
+		return result
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		result.Err = err
+		return result
+	}
+	marker := "__AISHELL_" + hex.EncodeToString(nonce)
+
+	// 命令放在语句块中执行以保留 cd/export 的效果，stdin 重定向到 /dev/null 防止命令读走后续输入
+	script := fmt.Sprintf("{ %s\n} </dev/null\n__aishell_rc=$?; printf '\\n%s_%%d_%%s__\\n' \"$__aishell_rc\" \"$PWD\"; printf '\\n%s__\\n' >&2\n",
+		command, marker, marker)
+
+	start := time.Now()
+	if _, err := io.WriteString(sh.stdin, script); err != nil {
+		sh.terminate()
+		result.Err = fmt.Errorf("向 shell 会话写入命令失败: %w", err)
+		return result
+	}
+
+	out := newLiveOutput(live)
+	stdout := newFrameScanner(marker, `_(\d+)_([^\n]*)__\n`, out.stdout())
+	stderr := newFrameScanner(marker, `__\n`, out.stderr())
+	stdoutCh, stderrCh := sh.stdoutCh, sh.stderrCh
+	if sh.pty {
+		// 伪终端中 stderr 与 stdout 合并
+		stderr.done = true
+		stderrCh = nil
+	}
+
+	var err error
+	for err == nil && !(stdout.done && stderr.done) {
+		select {
+		case chunk, ok := <-stdoutCh:
+			if !ok {
+				err = errSessionExited
+				continue
+			}
+			stdout.feed(chunk)
+		case chunk, ok := <-stderrCh:
+			if !ok {
+				stderr.done = true
+				stderrCh = nil
+				continue
+			}
+			stderr.feed(chunk)
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	result.Duration = time.Since(start)
+	stdout.flush()
+	stderr.flush()
+	out.finish()
+
+	result.Stdout = stdout.buf.String()
+	result.Stderr = stderr.buf.String()
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result.TimedOut = true
+		result.Err = fmt.Errorf("执行超时，shell 会话已重置")
+		sh.terminate()
+	case errors.Is(err, context.Canceled):
+		result.Canceled = true
+		result.Err = fmt.Errorf("已被用户中断，shell 会话已重置")
+		sh.terminate()
+	case err != nil:
+		cmd := sh.cmd
+		sh.terminate()
+		result.Err = fmt.Errorf("%v，shell 会话已重置", err)
+		if cmd.ProcessState != nil {
+			result.ExitCode = cmd.ProcessState.ExitCode()
+		}
+	default:
+		result.ExitCode, _ = strconv.Atoi(stdout.match[1])
+		sh.cwd = stdout.match[2]
+		result.Cwd = sh.cwd
+		if result.ExitCode != 0 {
+			result.Err = fmt.Errorf("exit status %d", result.ExitCode)
+		}
+	}
+	return result
+}
+
+// Reset 终止当前 shell 进程，下次执行命令时以初始状态重新启动
+func (sh *ShellSession) Reset() error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.terminate()
+	sh.cwd = ""
+	return nil
+}
+
+// Close 关闭会话
+func (sh *ShellSession) Close() error {
+	return sh.Reset()
+}
+
+// ensureStarted 启动 shell 进程（如尚未启动或已退出）
+func (sh *ShellSession) ensureStarted() error {
+	if sh.cmd != nil {
+		select {
+		case <-sh.exited:
+			sh.terminate()
+		default:
+			return nil
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("Windows 暂不支持持久 shell 会话")
+	}
+
+	shell, args := sh.shellCommand()
+	cmd := exec.Command(shell, args...)
+	cmd.Env = append(os.Environ(), "PS1=", "PS2=", "PAGER=cat", "GIT_PAGER=cat", "TERM=dumb")
+	if sh.cwd != "" {
+		cmd.Dir = sh.cwd
+	}
+
+	sh.stdoutCh = make(chan []byte, 64)
+	sh.stderrCh = make(chan []byte, 64)
+	sh.pty = false
+
+	var master, slave *os.File
+	var err error
+	if sh.UsePTY {
+		master, slave, err = openPTY()
+	}
+	if sh.UsePTY && err == nil {
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+		cmd.SysProcAttr = ptySysProcAttr()
+		if _, err := sh.Sandbox.Wrap(cmd); err != nil {
+			master.Close()
+			slave.Close()
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			master.Close()
+			slave.Close()
+			return err
+		}
+		slave.Close()
+		sh.pty = true
+		sh.stdin = master
+		// 关闭作业控制（保证子进程与 shell 同属一个进程组）、历史展开（避免 "!" 被替换）
+		// 和别名展开（交互式 bash 默认展开别名，分析的命令与实际执行的会不一致）
+		io.WriteString(master, "set +m +H; shopt -u expand_aliases 2>/dev/null; unset HISTFILE PROMPT_COMMAND MAILCHECK\n")
+		sh.closers = []io.Closer{master}
+		go pumpOutput(master, sh.stdoutCh)
+		close(sh.stderrCh)
+	} else {
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		stderrPipe, err := cmd.StderrPipe()
+		if err != nil {
+			return err
+		}
+		setProcessGroup(cmd)
+		if _, err := sh.Sandbox.Wrap(cmd); err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		sh.stdin = stdin
+		sh.closers = []io.Closer{stdin}
+		go pumpOutput(stdout, sh.stdoutCh)
+		go pumpOutput(stderrPipe, sh.stderrCh)
+	}
+
+	sh.cmd = cmd
+	sh.exited = make(chan struct{})
+	go func(cmd *exec.Cmd, exited chan struct{}) {
+		cmd.Wait()
+		close(exited)
+	}(cmd, sh.exited)
+
+	return nil
+}
+
+// shellCommand 选择会话使用的 shell 及其参数
+func (sh *ShellSession) shellCommand() (string, []string) {
+	shell := sh.Shell
+	if shell == "" {
+		if path, err := exec.LookPath("bash"); err == nil {
+			shell = path
+		} else {
+			shell = "sh"
+		}
+	}
+	if filepath.Base(shell) == "bash" {
+		return shell, []string{"--noprofile", "--norc", "--noediting", "+o", "history"}
+	}
+	return shell, nil
+}
+
+// terminate 终止 shell 进程组并释放资源
+func (sh *ShellSession) terminate() {
+	if sh.cmd == nil {
+		return
+	}
+	killProcessGroup(sh.cmd.Process)
+	for _, c := range sh.closers {
+		c.Close()
+	}
+	if sh.exited != nil {
+		select {
+		case <-sh.exited:
+		case <-time.After(2 * time.Second):
+		}
+	}
+	sh.cmd = nil
+	sh.stdin = nil
+	sh.closers = nil
+}
+
+// pumpOutput 持续读取输出并发送到通道，读到 EOF 后关闭通道
+func pumpOutput(r io.Reader, ch chan<- []byte) {
+	defer close(ch)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			ch <- chunk
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// frameScanner 在输出流中查找哨兵行，哨兵之前的内容为命令输出
+type frameScanner struct {
+	prefix  []byte
+	end     *regexp.Regexp
+	live    io.Writer
+	pending []byte
+	buf     bytes.Buffer
+	match   []string
+	done    bool
+}
+
+// newFrameScanner 创建哨兵扫描器，哨兵行为 "\n" + marker + suffix
+func newFrameScanner(marker, suffix string, live io.Writer) *frameScanner {
+	return &frameScanner{
+		prefix: []byte("\n" + marker),
+		end:    regexp.MustCompile("\n" + regexp.QuoteMeta(marker) + suffix),
+		live:   live,
+	}
+}
+
+// feed 处理新读到的数据
+func (f *frameScanner) feed(chunk []byte) {
+	if f.done {
+		return
+	}
+	f.pending = append(f.pending, chunk...)
+	if loc := f.end.FindSubmatchIndex(f.pending); loc != nil {
+		f.emit(f.pending[:loc[0]])
+		f.match = make([]string, len(loc)/2)
+		for i := range f.match {
+			if loc[2*i] >= 0 {
+				f.match[i] = string(f.pending[loc[2*i]:loc[2*i+1]])
+			}
+		}
+		f.pending = nil
+		f.done = true
+		return
+	}
+	// 保留末尾可能属于哨兵行的部分，其余立即输出
+	cut := len(f.pending)
+	if nl := bytes.LastIndexByte(f.pending, '\n'); nl >= 0 {
+		tail := f.pending[nl:]
+		if bytes.HasPrefix(f.prefix, tail) || bytes.HasPrefix(tail, f.prefix) {
+			cut = nl
+		}
+	}
+	f.emit(f.pending[:cut])
+	f.pending = append([]byte(nil), f.pending[cut:]...)
+}
+
+// flush 输出剩余内容（会话异常结束时）
+func (f *frameScanner) flush() {
+	if len(f.pending) > 0 {
+		f.emit(f.pending)
+		f.pending = nil
+	}
+}
+
+// emit 记录并实时显示输出
+func (f *frameScanner) emit(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	f.buf.Write(p)
+	f.live.Write(p)
+}
@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSession(t *testing.T, usePTY bool) *ShellSession {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持持久 shell 会话")
+	}
+	sh := NewShellSession()
+	sh.UsePTY = usePTY
+	t.Cleanup(func() { sh.Close() })
+	return sh
+}
+
+func TestShellSession_PersistsState(t *testing.T) {
+	for _, usePTY := range []bool{false, true} {
+		sh := newTestSession(t, usePTY)
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		if r := sh.Run(ctx, "cd "+dir+" && export AISHELL_TEST_VAR=hello", 5*time.Second, nil); !r.Success() {
+			t.Fatalf("pty=%v: 设置状态失败: %v %q", usePTY, r.Err, r.Stdout)
+		}
+		r := sh.Run(ctx, "pwd; echo $AISHELL_TEST_VAR", 5*time.Second, nil)
+		if !r.Success() {
+			t.Fatalf("pty=%v: 执行失败: %v", usePTY, r.Err)
+		}
+		if !strings.Contains(r.Stdout, "hello") {
+			t.Errorf("pty=%v: 环境变量应在调用之间保留, got %q", usePTY, r.Stdout)
+		}
+		if !strings.HasSuffix(r.Cwd, strings.TrimPrefix(dir, "/private")) {
+			t.Errorf("pty=%v: 工作目录应为 %s, got %q", usePTY, dir, r.Cwd)
+		}
+	}
+}
+
+func TestShellSession_ExitCodeAndStderr(t *testing.T) {
+	sh := newTestSession(t, false)
+	r := sh.Run(context.Background(), "echo out; echo err >&2; false", 5*time.Second, nil)
+	if r.Success() || r.ExitCode != 1 {
+		t.Errorf("退出码应为 1, got %d (%v)", r.ExitCode, r.Err)
+	}
+	if strings.TrimSpace(r.Stdout) != "out" {
+		t.Errorf("stdout 应为 'out', got %q", r.Stdout)
+	}
+	if strings.TrimSpace(r.Stderr) != "err" {
+		t.Errorf("stderr 应为 'err', got %q", r.Stderr)
+	}
+}
+
+func TestShellSession_OutputWithoutTrailingNewline(t *testing.T) {
+	sh := newTestSession(t, true)
+	r := sh.Run(context.Background(), "printf abc", 5*time.Second, nil)
+	if r.Stdout != "abc" {
+		t.Errorf("输出应为 'abc', got %q", r.Stdout)
+	}
+}
+
+func TestShellSession_TimeoutResetsSession(t *testing.T) {
+	sh := newTestSession(t, true)
+	ctx := context.Background()
+
+	sh.Run(ctx, "export AISHELL_TEST_VAR=before", 5*time.Second, nil)
+	r := sh.Run(ctx, "sleep 10", 200*time.Millisecond, nil)
+	if !r.TimedOut {
+		t.Fatalf("命令应超时, got %v", r.Err)
+	}
+	if r.Duration > 5*time.Second {
+		t.Errorf("超时后应立即返回, 耗时 %s", r.Duration)
+	}
+
+	r = sh.Run(ctx, "echo ${AISHELL_TEST_VAR:-reset}", 5*time.Second, nil)
+	if strings.TrimSpace(r.Stdout) != "reset" {
+		t.Errorf("超时后会话应重新启动, got %q", r.Stdout)
+	}
+}
+
+func TestShellSession_ExitRestarts(t *testing.T) {
+	sh := newTestSession(t, false)
+	ctx := context.Background()
+
+	r := sh.Run(ctx, "exit 3", 5*time.Second, nil)
+	if r.Success() || r.ExitCode != 3 {
+		t.Errorf("exit 3 应返回退出码 3, got %d (%v)", r.ExitCode, r.Err)
+	}
+	r = sh.Run(ctx, "echo alive", 5*time.Second, nil)
+	if strings.TrimSpace(r.Stdout) != "alive" {
+		t.Errorf("会话应自动重启, got %q (%v)", r.Stdout, r.Err)
+	}
+}
+
+func TestShellSession_Reset(t *testing.T) {
+	sh := newTestSession(t, true)
+	ctx := context.Background()
+
+	sh.Run(ctx, "cd / && AISHELL_TEST_VAR=1", 5*time.Second, nil)
+	if sh.Cwd() != "/" {
+		t.Errorf("工作目录应为 /, got %q", sh.Cwd())
+	}
+	sh.Reset()
+	if sh.Cwd() != "" {
+		t.Errorf("重置后工作目录应清空, got %q", sh.Cwd())
+	}
+	r := sh.Run(ctx, "echo ${AISHELL_TEST_VAR:-empty}", 5*time.Second, nil)
+	if strings.TrimSpace(r.Stdout) != "empty" {
+		t.Errorf("重置后 shell 变量应清空, got %q", r.Stdout)
+	}
+}
+
+func TestSystemCommand_SessionMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持持久 shell 会话")
+	}
+	cmd := NewSystemCommand()
+	cmd.Output = nil
+	cmd.Session = NewShellSession()
+	defer cmd.Session.Close()
+	ctx := context.Background()
+
+	if _, err := cmd.Call(ctx, "cd /"); err != nil {
+		t.Fatal(err)
+	}
+	result, _ := cmd.Call(ctx, "pwd")
+	if !strings.Contains(result, "当前目录: /\n") {
+		t.Errorf("会话模式应保留工作目录, got %q", result)
+	}
+}
+
+func TestFileTools_FollowSessionCwd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持持久 shell 会话")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "main.go"), []byte("package sub\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewSystemCommand()
+	cmd.Output = nil
+	cmd.Session = NewShellSession()
+	defer cmd.Session.Close()
+	workspace := NewWorkspace([]string{dir}, nil)
+	workspace.Cwd = cmd.Session.Cwd
+	ctx := context.Background()
+
+	if _, err := cmd.Call(ctx, "cd "+sub); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewFileReader()
+	reader.Workspace = workspace
+	result, err := reader.Call(ctx, "main.go")
+	if err != nil || !strings.Contains(result, "package sub") {
+		t.Errorf("相对路径应基于会话的当前目录读取, got %q (%v)", result, err)
+	}
+
+	writer := NewFileWriter()
+	writer.Workspace = workspace
+	if _, err := writer.Call(ctx, `{"file_path": "out.txt", "content": "ok"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(sub, "out.txt")); err != nil {
+		t.Errorf("相对路径应基于会话的当前目录写入: %v", err)
+	}
+}
@@ -1,32 +1,54 @@
 package tools
 
 import (
-	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"runtime"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/tmc/langchaingo/callbacks"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/policy"
 )
 
 // SystemCommand 是一个可以执行系统命令的工具
 type SystemCommand struct {
 	CallbacksHandler callbacks.Handler
-	// Timeout 命令执行超时时间，默认30秒
+	// Timeout 命令执行超时时间，默认30秒，可被策略规则或调用参数覆盖
 	Timeout time.Duration
+	// MaxTimeout 调用参数中 timeout 的上限，策略规则的超时更长时以策略为准，为 0 时使用 DefaultMaxCommandTimeout
+	MaxTimeout time.Duration
+	// Output 命令输出的实时显示位置，默认为标准输出，为空时不显示
+	Output io.Writer
+	// Budget 返回给模型的输出预算，超出部分保存到溢出文件，为空时不限制
+	Budget *OutputBudget
 	// DangerousCommands 危险命令列表，需要用户确认才能执行
 	DangerousCommands []string
+	// Policy 声明式命令策略（allow/confirm/deny），为空时只使用内置危险命令列表
+	Policy *policy.Policy
+	// Session 持久 shell 会话，设置后命令在同一个 shell 中执行，cd/export 等状态在调用之间保留
+	Session *ShellSession
+	// Sandbox 命令的隔离环境，为空时直接执行
+	Sandbox *Sandbox
+	// Planner 演练模式状态，启用时只记录命令不执行
+	Planner *Planner
+	// Approver 危险命令的确认器，为空时在终端交互询问
+	Approver *Approver
+	// Audit 审计日志，为空时不记录
+	Audit *audit.Logger
 }
 
 // NewSystemCommand 创建一个新的系统命令工具
 func NewSystemCommand() *SystemCommand {
 	return &SystemCommand{
-		Timeout: 30 * time.Second,
+		Timeout:    30 * time.Second,
+		MaxTimeout: DefaultMaxCommandTimeout,
+		Output:     os.Stdout,
+		Budget:     NewOutputBudget(DefaultCommandOutputTokens, true),
 		// 危险命令列表，需要用户确认才能执行
 		DangerousCommands: []string{
 			// 文件删除命令
@@ -66,68 +88,111 @@ func (s *SystemCommand) Description() string {
 输入格式：要执行的完整命令，例如：
 - Linux/macOS: "apt install python3", "brew install node", "ls -la"
 - Windows: "choco install nodejs", "dir", "systeminfo"
-安全机制：大部分命令可直接执行，危险命令(如rm删除、shutdown关机等)需要用户确认。`
+耗时较长的命令（如编译、安装、测试）可使用JSON指定超时秒数：{"command": "go test ./...", "timeout": 300}
+返回结果包含退出码、耗时，以及分开的标准输出和标准错误([stderr])。
+安全机制：大部分命令可直接执行，会解析管道、&&、;、子shell、命令替换、sudo/xargs等包装命令中的每一个子命令，
+任何子命令为危险命令(如rm删除、shutdown关机等)或写入系统路径时都需要用户确认。` + s.sessionDescription() + s.Sandbox.Description()
+}
+
+// Parameters 返回参数的 JSON Schema
+func (s *SystemCommand) Parameters() map[string]any {
+	return SchemaFor(CommandRequest{})
+}
+
+// sessionDescription 持久会话模式下追加的说明
+func (s *SystemCommand) sessionDescription() string {
+	if s.Session == nil {
+		return ""
+	}
+	return `
+当前运行在持久shell会话中：cd、export、shell变量和函数在多次调用之间保留，结果中的"当前目录"为命令结束时的工作目录。
+命令的标准输入为空，不要执行需要交互输入的命令。`
 }
 
-// Call 执行系统命令
+// run 执行命令：启用会话时在持久 shell 中执行，否则每次启动独立的 shell。
+// 无法解析的命令（如引号未闭合）会让会话中的 shell 一直等待输入，因此总是单独执行。
+func (s *SystemCommand) run(ctx context.Context, analysis *CommandAnalysis, timeout time.Duration) *CommandResult {
+	if s.Session != nil && analysis.ParseError == nil {
+		return s.Session.Run(ctx, analysis.Command, timeout, s.Output)
+	}
+	return s.execute(ctx, analysis.Command, timeout)
+}
+
+// Call 执行系统命令，每次调用都写入审计日志
 func (s *SystemCommand) Call(ctx context.Context, input string) (string, error) {
+	return audited(ctx, s.Audit, s.Name(), s.workDir(), input, func(ctx context.Context) (string, error) {
+		return s.call(ctx, input)
+	})
+}
+
+// workDir 命令执行的工作目录，持久会话中为会话的当前目录
+func (s *SystemCommand) workDir() string {
+	if s.Session != nil && s.Session.Cwd() != "" {
+		return s.Session.Cwd()
+	}
+	return workingDir()
+}
+
+// call 分析、确认并执行命令
+func (s *SystemCommand) call(ctx context.Context, input string) (string, error) {
+	info := CallInfoFrom(ctx)
 	if s.CallbacksHandler != nil {
 		s.CallbacksHandler.HandleToolStart(ctx, input)
 	}
 
-	// 清理输入
-	command := strings.TrimSpace(input)
+	// 解析输入，支持纯命令或带 timeout 的 JSON
+	req := parseCommandInput(input)
+	command := req.Command
 	if command == "" {
 		return "错误：命令不能为空", nil
 	}
 
-	// 解析命令
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
+	// 结构化分析整条命令行，覆盖管道、&&、子shell、命令替换、包装命令等所有子命令
+	analysis := s.AnalyzeCommand(command)
+	if analysis.ParseError == nil && len(analysis.Segments) == 0 {
 		return "错误：无效的命令格式", nil
 	}
+	timeout, err := s.resolveTimeout(req, analysis)
+	if err != nil {
+		return "错误：" + err.Error(), nil
+	}
 
-	baseCommand := parts[0]
-
-	// 安全检查：检查是否是危险命令
-	if s.isDangerousCommand(baseCommand) {
-		shouldExecute := s.askUserPermission(baseCommand)
-		if !shouldExecute {
-			return fmt.Sprintf("危险命令 '%s' 执行已被取消", baseCommand), nil
-		}
-		// 用户选择执行，显示警告信息
-		fmt.Printf("\n⚠️  警告：正在执行危险命令: %s\n", baseCommand)
+	// 策略检查：命中 deny 规则的命令直接拒绝
+	if analysis.Denied != nil {
+		info.SetStatus(audit.StatusDenied)
+		return fmt.Sprintf("命令 '%s' 被策略拒绝执行\n子命令: %s\n%s",
+			command, analysis.Denied.Subject.Text, analysis.Denied.Explain()), nil
 	}
 
-	// 设置超时上下文
-	if s.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
-		defer cancel()
+	// 演练模式：记录到计划中，返回模拟结果
+	if s.Planner.Active(ctx) {
+		info.SetStatus(audit.StatusPlanned)
+		result := s.recordPlan(input, analysis)
+		if s.CallbacksHandler != nil {
+			s.CallbacksHandler.HandleToolEnd(ctx, result)
+		}
+		return result, nil
 	}
 
-	// 根据操作系统执行命令
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		// Windows使用cmd /c执行命令
-		cmd = exec.CommandContext(ctx, "cmd", "/c", command)
-	default:
-		// Linux/macOS使用sh -c执行命令
-		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	// 安全检查：任何子命令存在风险都需要用户确认
+	if analysis.IsDangerous() {
+		shouldExecute := s.askUserPermission(analysis)
+		info.SetApproval(s.Approver.Decision(shouldExecute))
+		if !shouldExecute || ctx.Err() != nil {
+			info.SetStatus(audit.StatusDeclined)
+			return fmt.Sprintf("危险命令 '%s' 执行已被取消", command), nil
+		}
+		// 用户选择执行，显示警告信息
+		fmt.Fprintf(s.Approver.Writer(), "\n⚠️  警告：正在执行危险命令: %s\n", command)
 	}
 
-	// 执行命令并获取输出
-	output, err := cmd.CombinedOutput()
+	// 执行命令，输出实时显示在终端并同时捕获给模型
+	commandResult := s.run(ctx, analysis, timeout)
+	CallInfoFrom(ctx).SetExitCode(commandResult.ExitCode)
+	result := commandResult.Format()
 
-	result := ""
-	if err != nil {
-		// 如果命令执行失败，返回错误信息和输出
-		result = fmt.Sprintf("命令执行失败: %v\n输出: %s", err, string(output))
-	} else {
-		// 命令执行成功
-		result = fmt.Sprintf("命令执行成功:\n%s", string(output))
-	}
+	// 按预算裁剪输出，避免超大输出撑爆上下文
+	result = s.Budget.Apply(s.Name(), result).Text
 
 	if s.CallbacksHandler != nil {
 		s.CallbacksHandler.HandleToolEnd(ctx, result)
@@ -136,6 +201,21 @@ func (s *SystemCommand) Call(ctx context.Context, input string) (string, error)
 	return result, nil
 }
 
+// recordPlan 把命令记录为计划步骤，返回给模型的模拟结果
+func (s *SystemCommand) recordPlan(input string, analysis *CommandAnalysis) string {
+	step := PlanStep{Tool: s.Name(), Input: input, Summary: "执行命令: " + analysis.Command}
+	for _, risk := range analysis.Risks {
+		reason := risk.Reason
+		if risk.Segment != "" && risk.Segment != analysis.Command {
+			reason += " (" + risk.Segment + ")"
+		}
+		step.Risks = append(step.Risks, reason)
+	}
+	n := s.Planner.Record(step)
+	return fmt.Sprintf("[演练模式] 命令未执行，已记录为计划第 %d 步: %s\n"+
+		"请按命令的预期效果继续规划后续步骤，不要假设已获得实际输出。", n, analysis.Command)
+}
+
 // isDangerousCommand 检查命令是否在危险命令列表中
 func (s *SystemCommand) isDangerousCommand(command string) bool {
 	command = strings.ToLower(command)
@@ -147,75 +227,79 @@ func (s *SystemCommand) isDangerousCommand(command string) bool {
 	return false
 }
 
-// askUserPermission 询问用户是否允许执行危险命令
-func (s *SystemCommand) askUserPermission(command string) bool {
+// askUserPermission 询问用户是否允许执行危险命令，并列出分析出的全部风险
+func (s *SystemCommand) askUserPermission(analysis *CommandAnalysis) bool {
+	out := s.Approver.Writer()
 	red := color.New(color.FgRed, color.Bold)
 	yellow := color.New(color.FgYellow, color.Bold)
-	green := color.New(color.FgGreen)
 
-	fmt.Println()
-	red.Printf("🚨 危险命令警告: '%s' 是潜在危险命令!\n", command)
-	yellow.Println("执行此命令可能对系统造成不可逆损害。")
-	fmt.Println()
-
-	// 显示具体风险提示
-	s.showCommandRisks(command)
-	fmt.Println()
+	fmt.Fprintln(out)
+	red.Fprintf(out, "🚨 危险命令警告: '%s' 包含潜在危险操作!\n", analysis.Command)
+	yellow.Fprintln(out, "执行此命令可能对系统造成不可逆损害。")
+	fmt.Fprintln(out)
 
-	for {
-		green.Print("确定要执行这个危险命令吗? [yes/no]: ")
-
-		scanner := bufio.NewScanner(os.Stdin)
-		if !scanner.Scan() {
-			return false
+	// 列出每一个有风险的子命令
+	fmt.Fprintln(out, "🔎 风险子命令:")
+	for i, risk := range analysis.Risks {
+		fmt.Fprintf(out, "  %d. %s\n", i+1, risk.Reason)
+		if risk.Segment != "" && risk.Segment != analysis.Command {
+			fmt.Fprintf(out, "     └─ %s\n", risk.Segment)
 		}
+	}
+	fmt.Fprintln(out)
 
-		response := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	// 显示具体风险提示
+	names := analysis.DangerousCommands()
+	if len(names) == 0 {
+		s.showCommandRisks(out, "")
+	}
+	for _, name := range names {
+		s.showCommandRisks(out, name)
+	}
+	fmt.Fprintln(out)
 
-		switch response {
-		case "yes", "y", "是", "确定":
-			yellow.Println("⚠️  用户确认执行危险命令")
-			return true
-		case "no", "n", "否", "取消":
-			fmt.Println("✅ 危险命令已取消，系统安全得到保护")
-			return false
-		default:
-			red.Println("❌ 请输入 'yes' 或 'no' (或 'y'/'n')")
-			continue
-		}
+	if s.Approver.Confirm("确定要执行这个危险命令吗?") {
+		yellow.Fprintln(out, "⚠️  用户确认执行危险命令")
+		return true
 	}
+	fmt.Fprintln(out, "✅ 危险命令已取消，系统安全得到保护")
+	return false
 }
 
 // showCommandRisks 显示特定命令的风险提示
-func (s *SystemCommand) showCommandRisks(command string) {
+func (s *SystemCommand) showCommandRisks(out io.Writer, command string) {
 	command = strings.ToLower(command)
 
-	fmt.Println("⚠️  具体风险:")
+	if command != "" {
+		fmt.Fprintf(out, "⚠️  具体风险 (%s):\n", command)
+	} else {
+		fmt.Fprintln(out, "⚠️  具体风险:")
+	}
 	switch command {
 	case "rm", "del", "erase":
-		fmt.Println("  • 可能永久删除重要文件和数据")
-		fmt.Println("  • 删除操作通常无法撤销")
-		fmt.Println("  • 建议先备份重要数据")
+		fmt.Fprintln(out, "  • 可能永久删除重要文件和数据")
+		fmt.Fprintln(out, "  • 删除操作通常无法撤销")
+		fmt.Fprintln(out, "  • 建议先备份重要数据")
 	case "shutdown", "reboot", "halt":
-		fmt.Println("  • 将关闭或重启系统")
-		fmt.Println("  • 可能导致正在运行的程序丢失数据")
-		fmt.Println("  • 建议保存所有工作后再执行")
+		fmt.Fprintln(out, "  • 将关闭或重启系统")
+		fmt.Fprintln(out, "  • 可能导致正在运行的程序丢失数据")
+		fmt.Fprintln(out, "  • 建议保存所有工作后再执行")
 	case "chmod", "chown":
-		fmt.Println("  • 将修改文件或目录权限")
-		fmt.Println("  • 错误的权限设置可能导致系统无法正常运行")
-		fmt.Println("  • 可能影响系统安全性")
+		fmt.Fprintln(out, "  • 将修改文件或目录权限")
+		fmt.Fprintln(out, "  • 错误的权限设置可能导致系统无法正常运行")
+		fmt.Fprintln(out, "  • 可能影响系统安全性")
 	case "dd", "fdisk", "mkfs":
-		fmt.Println("  • 可能覆盖或破坏磁盘数据")
-		fmt.Println("  • 错误使用可能导致整个系统无法启动")
-		fmt.Println("  • 强烈建议备份重要数据")
+		fmt.Fprintln(out, "  • 可能覆盖或破坏磁盘数据")
+		fmt.Fprintln(out, "  • 错误使用可能导致整个系统无法启动")
+		fmt.Fprintln(out, "  • 强烈建议备份重要数据")
 	case "kill", "killall", "taskkill":
-		fmt.Println("  • 将强制终止进程")
-		fmt.Println("  • 可能导致数据丢失或系统不稳定")
-		fmt.Println("  • 建议先尝试优雅关闭进程")
+		fmt.Fprintln(out, "  • 将强制终止进程")
+		fmt.Fprintln(out, "  • 可能导致数据丢失或系统不稳定")
+		fmt.Fprintln(out, "  • 建议先尝试优雅关闭进程")
 	default:
-		fmt.Println("  • 此命令可能对系统造成意外影响")
-		fmt.Println("  • 请确保您了解此命令的具体作用")
-		fmt.Println("  • 建议在非生产环境中先行测试")
+		fmt.Fprintln(out, "  • 此命令可能对系统造成意外影响")
+		fmt.Fprintln(out, "  • 请确保您了解此命令的具体作用")
+		fmt.Fprintln(out, "  • 建议在非生产环境中先行测试")
 	}
 }
 
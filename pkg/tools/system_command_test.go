@@ -1,11 +1,15 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"os"
 	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/dean2027/aishell/pkg/policy"
 )
 
 func TestSystemCommand_Name(t *testing.T) {
@@ -180,3 +184,136 @@ func TestSystemCommand_SetDangerousCommands(t *testing.T) {
 		}
 	}
 }
+
+func TestSystemCommand_Call_SeparateStreams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell syntax")
+	}
+	var live bytes.Buffer
+	cmd := NewSystemCommand()
+	cmd.Output = &live
+
+	result, err := cmd.Call(context.Background(), "echo out; echo err 1>&2; exit 3")
+	if err != nil {
+		t.Fatalf("Call should not return error, got: %v", err)
+	}
+	if !strings.Contains(result, "命令执行失败") || !strings.Contains(result, "退出码: 3") {
+		t.Errorf("Should report failure with exit code, got: %s", result)
+	}
+	if !strings.Contains(result, "out") || !strings.Contains(result, "[stderr]:\nerr") {
+		t.Errorf("Should capture stdout and stderr separately, got: %s", result)
+	}
+	if !strings.Contains(live.String(), "out") || !strings.Contains(live.String(), "err") {
+		t.Errorf("Should stream output live, got: %q", live.String())
+	}
+}
+
+func TestSystemCommand_Call_TimeoutOverride(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	cmd := NewSystemCommand()
+	cmd.Output = nil
+	cmd.Timeout = 100 * time.Millisecond
+
+	// 调用参数中的超时覆盖默认超时
+	result, err := cmd.Call(context.Background(), `{"command": "sleep 0.3 && echo done", "timeout": 5}`)
+	if err != nil {
+		t.Fatalf("Call should not return error, got: %v", err)
+	}
+	if !strings.Contains(result, "命令执行成功") || !strings.Contains(result, "done") {
+		t.Errorf("Per-call timeout should override default, got: %s", result)
+	}
+
+	// 策略规则中的超时同样覆盖默认超时
+	rules, err := policy.Parse([]byte("rules:\n  - commands: [sleep]\n    timeout: 5s\n"), ".yaml", "test")
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	cmd.Policy = &policy.Policy{Rules: rules}
+	result, _ = cmd.Call(context.Background(), "sleep 0.3")
+	if !strings.Contains(result, "命令执行成功") {
+		t.Errorf("Policy timeout should override default, got: %s", result)
+	}
+
+	cmd.Policy = nil
+	result, _ = cmd.Call(context.Background(), "sleep 0.3")
+	if !strings.Contains(result, "执行超时") {
+		t.Errorf("Should report timeout, got: %s", result)
+	}
+
+	// 超出上限的超时按上限执行，不会溢出
+	cmd.MaxTimeout = 100 * time.Millisecond
+	result, _ = cmd.Call(context.Background(), `{"command": "sleep 0.3", "timeout": 1e300}`)
+	if !strings.Contains(result, "执行超时") {
+		t.Errorf("Per-call timeout should be capped, got: %s", result)
+	}
+
+	result, _ = cmd.Call(context.Background(), `{"command": "echo hi", "timeout": -1}`)
+	if !strings.Contains(result, "timeout 必须是正数") {
+		t.Errorf("Negative timeout should be rejected, got: %s", result)
+	}
+}
+
+func TestParseCommandInput(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantCommand string
+		wantTimeout float64
+	}{
+		{"ls -la", "ls -la", 0},
+		{`{"command": "make", "timeout": 600}`, "make", 600},
+		{"{ ls; pwd; }", "{ ls; pwd; }", 0},
+		{`  {"command": "  go test ./...  "}  `, "go test ./...", 0},
+	}
+	for _, tt := range tests {
+		req := parseCommandInput(tt.input)
+		if req.Command != tt.wantCommand || req.Timeout != tt.wantTimeout {
+			t.Errorf("parseCommandInput(%q) = %+v, want command %q timeout %v", tt.input, req, tt.wantCommand, tt.wantTimeout)
+		}
+	}
+}
+
+func TestSystemCommand_CancelKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	dir := t.TempDir()
+	marker := dir + "/marker"
+
+	cmd := NewSystemCommand()
+	cmd.Output = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	result := cmd.execute(ctx, "(sleep 1; touch "+marker+") & sleep 30", 0)
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("中断后应立即返回, 耗时 %s", time.Since(start))
+	}
+	if !result.Canceled || !strings.Contains(result.Format(), "已被用户中断") {
+		t.Errorf("结果应标记为用户中断, got %q", result.Format())
+	}
+
+	// 后台子进程与 sh 同属一个进程组，应一并被终止
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("中断后后台子进程不应继续运行")
+	}
+}
+
+func TestSystemCommand_ReportsExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 POSIX shell")
+	}
+	cmd := NewSystemCommand()
+	cmd.Output = &bytes.Buffer{}
+
+	ctx, info := WithCallInfo(context.Background())
+	if _, err := cmd.Call(ctx, "exit 3"); err != nil {
+		t.Fatal(err)
+	}
+	if info.ExitCode == nil || *info.ExitCode != 3 {
+		t.Errorf("应记录退出码 3, got %v", info.ExitCode)
+	}
+}
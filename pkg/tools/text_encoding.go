@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	// binarySniffSize 判断二进制文件和编码时检查的开头字节数
+	binarySniffSize = 8000
+	// encodingSniffSize 开头是 UTF-8（包括纯 ASCII）时继续检查的最大字节数，
+	// 前面几百行都是 ASCII、后面才出现 GBK 等内容的文件按整段内容重新判断编码
+	encodingSniffSize = 4 << 20
+)
+
+// textEncoding 检测到的文本编码
+type textEncoding struct {
+	// name 显示给模型的编码名称
+	name string
+	// bom 文件开头字节顺序标记的长度，读取时跳过
+	bom int
+	// decoder 转换为 UTF-8 的编码，UTF-8 文件为 nil
+	decoder encoding.Encoding
+}
+
+// detectEncoding 根据文件开头的内容判断编码，二进制文件返回 false。
+// 依次检查 BOM、没有 BOM 的 UTF-16、NUL 和控制字符、UTF-8、GBK，都不符合时按 Windows-1252 处理。
+func detectEncoding(head []byte) (textEncoding, bool) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xef, 0xbb, 0xbf}):
+		return textEncoding{name: "UTF-8 (BOM)", bom: 3}, true
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return textEncoding{name: "UTF-16LE", bom: 2, decoder: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)}, true
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return textEncoding{name: "UTF-16BE", bom: 2, decoder: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)}, true
+	}
+	if order, ok := guessUTF16(head); ok {
+		name := "UTF-16LE"
+		if order == unicode.BigEndian {
+			name = "UTF-16BE"
+		}
+		return textEncoding{name: name, decoder: unicode.UTF16(order, unicode.IgnoreBOM)}, true
+	}
+	if looksBinary(head) {
+		return textEncoding{}, false
+	}
+	return detectCharset(head), true
+}
+
+// detectCharset 判断不含 BOM 的 8 位文本的编码：UTF-8、GBK，都不符合时按 Windows-1252 处理
+func detectCharset(data []byte) textEncoding {
+	switch {
+	case utf8.Valid(trimPartialRune(data)):
+		return textEncoding{name: "UTF-8"}
+	case looksGBK(data):
+		// GB18030 兼容 GBK，能解码 GBK 中没有的少量字符
+		return textEncoding{name: "GBK", decoder: simplifiedchinese.GB18030}
+	}
+	return textEncoding{name: "Windows-1252", decoder: charmap.Windows1252}
+}
+
+// openText 根据 r 开头的内容判断编码，返回转换为 UTF-8 并跳过 BOM 的文本和检测到的编码。
+// 开头按 UTF-8 处理时，会在 encodingSniffSize 范围内确认后面的内容同样是 UTF-8，否则按整段内容重新判断。
+// 二进制内容返回 false，head 为用于判断的开头字节。
+func openText(r io.Reader) (text io.Reader, enc textEncoding, head []byte, ok bool) {
+	head = make([]byte, binarySniffSize)
+	n, err := io.ReadFull(r, head)
+	head = head[:n]
+	enc, ok = detectEncoding(head)
+	if !ok {
+		return nil, enc, head, false
+	}
+
+	sniffed := head
+	if enc.name == "UTF-8" && err == nil {
+		rest, _ := io.ReadAll(io.LimitReader(r, encodingSniffSize-binarySniffSize))
+		sniffed = append(head[:n:n], rest...)
+		if !utf8.Valid(trimPartialRune(sniffed)) {
+			enc = detectCharset(sniffed)
+		}
+	}
+	text = io.MultiReader(bytes.NewReader(sniffed[enc.bom:]), r)
+	if enc.decoder != nil {
+		text = transform.NewReader(text, enc.decoder.NewDecoder())
+	}
+	return text, enc, head, true
+}
+
+// guessUTF16 识别没有 BOM 的 UTF-16：较多字符是高字节为 0 的可打印 ASCII 字符，
+// 且高字节为 0 的位置基本一致、没有 U+0000
+func guessUTF16(head []byte) (unicode.Endianness, bool) {
+	pairs := len(head) / 2
+	if pairs < 2 {
+		return unicode.LittleEndian, false
+	}
+	var le, be int
+	for i := 0; i+1 < len(head); i += 2 {
+		lo, hi := head[i], head[i+1]
+		switch {
+		case lo == 0 && hi == 0:
+			return unicode.LittleEndian, false
+		case hi == 0 && printableASCII(lo):
+			le++
+		case lo == 0 && printableASCII(hi):
+			be++
+		}
+	}
+	switch {
+	case le*10 >= pairs*3 && be*5 < le:
+		return unicode.LittleEndian, true
+	case be*10 >= pairs*3 && le*5 < be:
+		return unicode.BigEndian, true
+	}
+	return unicode.LittleEndian, false
+}
+
+// printableASCII 可打印的 ASCII 字符和常见空白
+func printableASCII(c byte) bool {
+	return (c >= 0x20 && c < 0x7f) || c == '\t' || c == '\n' || c == '\r'
+}
+
+// looksBinary 包含 NUL，或者制表、换行、换页、转义之外的控制字符超过一成时视为二进制
+func looksBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	control := 0
+	for _, c := range head {
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != '\v' && c != 0x1b) || c == 0x7f {
+			control++
+		}
+	}
+	return control*10 > len(head)
+}
+
+// trimPartialRune 去掉开头片段末尾被截断的多字节字符
+func trimPartialRune(head []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+		c := head[len(head)-i]
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(head[len(head)-i:]) {
+				return head[:len(head)-i]
+			}
+			break
+		}
+	}
+	return head
+}
+
+// looksGBK 按 GBK 解码没有错误，且非 ASCII 字符大多是中文汉字和标点
+func looksGBK(head []byte) bool {
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(head)
+	if err != nil {
+		return false
+	}
+	var cjk, other int
+	for len(decoded) > 0 {
+		r, size := utf8.DecodeRune(decoded)
+		decoded = decoded[size:]
+		switch {
+		case r < utf8.RuneSelf:
+		case r == utf8.RuneError:
+			// 开头片段末尾被截断的双字节字符
+			if len(decoded) > 0 {
+				return false
+			}
+		case r >= 0x3000 && r <= 0x9fff, r >= 0xff00 && r <= 0xffef:
+			cjk++
+		default:
+			other++
+		}
+	}
+	return cjk > 0 && other*5 <= cjk
+}
+
+// sniffFileType 根据文件开头的内容判断类型
+func sniffFileType(head []byte, executable bool) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return "ELF 可执行文件"
+	case bytes.HasPrefix(head, []byte("#!")):
+		line, _, _ := bytes.Cut(head[2:], []byte("\n"))
+		interpreter := "sh"
+		if fields := strings.Fields(string(line)); len(fields) > 0 {
+			interpreter = filepath.Base(fields[0])
+			if interpreter == "env" && len(fields) > 1 {
+				interpreter = fields[1]
+			}
+		}
+		return "脚本 (" + interpreter + ")"
+	case bytes.HasPrefix(head, []byte("MZ")):
+		return "Windows 可执行文件"
+	case bytes.HasPrefix(head, []byte{0xfe, 0xed, 0xfa}) || bytes.HasPrefix(head, []byte{0xcf, 0xfa, 0xed, 0xfe}):
+		return "Mach-O 可执行文件"
+	}
+
+	contentType := http.DetectContentType(head)
+	kind, _, _ := strings.Cut(contentType, ";")
+	switch {
+	case strings.HasPrefix(kind, "text/"):
+		if executable {
+			return "可执行文本"
+		}
+		return "文本"
+	case strings.HasPrefix(kind, "image/"):
+		return "图片 (" + strings.TrimPrefix(kind, "image/") + ")"
+	case strings.HasPrefix(kind, "audio/"), strings.HasPrefix(kind, "video/"):
+		return "音视频 (" + kind + ")"
+	case kind == "application/zip", kind == "application/x-gzip", kind == "application/x-rar-compressed":
+		return "压缩包 (" + strings.TrimPrefix(strings.TrimPrefix(kind, "application/"), "x-") + ")"
+	case kind == "application/pdf":
+		return "PDF"
+	case bytes.IndexByte(head, 0) < 0:
+		return "文本"
+	}
+	return "二进制"
+}
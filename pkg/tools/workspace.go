@@ -0,0 +1,276 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/policy"
+	"github.com/dean2027/aishell/pkg/utils"
+)
+
+// DefaultDeniedPaths 内置的敏感路径，文件工具始终不能访问。
+// 每一项匹配路径本身及其下的全部文件，支持 glob。
+var DefaultDeniedPaths = []string{
+	"~/.ssh",
+	"~/.gnupg",
+	"~/.aws",
+	"~/.azure",
+	"~/.config/gcloud",
+	"~/.kube",
+	"~/.docker/config.json",
+	"~/.netrc",
+	"~/.git-credentials",
+	"~/.password-store",
+	"/etc/shadow",
+	"/etc/gshadow",
+	"/etc/sudoers",
+	"/etc/sudoers.d",
+}
+
+// Workspace 文件工具的访问范围：允许的根目录内可以直接访问，根目录之外需要用户确认，
+// 敏感路径始终拒绝。所有判断都基于解析符号链接之后的真实路径。
+type Workspace struct {
+	// Approver 访问根目录之外的文件时的确认器
+	Approver *Approver
+	// Cwd 解析相对路径时的当前目录，启用持久 shell 时为会话的当前目录。
+	// 为空或返回空字符串时使用进程的当前目录。
+	Cwd func() string
+
+	mu       sync.Mutex
+	prompt   sync.Mutex
+	roots    []string
+	internal []string
+	denied   []string
+	approved map[approval]bool
+}
+
+// approval 用户同意过的一次访问：同意读取不代表同意写入同一个文件
+type approval struct {
+	op   string
+	path string
+}
+
+// NewWorkspace 创建访问范围。roots 为空时使用当前目录；denied 追加在内置敏感路径之后。
+// aishell 自己的配置和数据目录（策略、审计日志、备份）也不允许访问，避免代理放宽自己的限制。
+func NewWorkspace(roots, denied []string) *Workspace {
+	w := &Workspace{approved: map[approval]bool{}}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	for _, root := range roots {
+		w.AddRoot(root)
+	}
+
+	patterns := append([]string{}, DefaultDeniedPaths...)
+	patterns = append(patterns, utils.ConfigDir(), utils.DataDir())
+	if dir := utils.FindProjectConfigDir(); dir != "" {
+		patterns = append(patterns, dir)
+	}
+	patterns = append(patterns, denied...)
+	for _, pattern := range patterns {
+		pattern = utils.ExpandHome(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(pattern); err == nil {
+			pattern = abs
+		}
+		w.denied = append(w.denied, filepath.ToSlash(pattern))
+		// 敏感目录本身是符号链接时，同时拦截真实路径
+		if real := realPath(pattern); real != pattern {
+			w.denied = append(w.denied, filepath.ToSlash(real))
+		}
+	}
+	return w
+}
+
+// AddRoot 添加允许直接访问的根目录
+func (w *Workspace) AddRoot(root string) {
+	root = utils.ExpandHome(strings.TrimSpace(root))
+	if root == "" {
+		return
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.roots = append(w.roots, realPath(abs))
+}
+
+// AddInternalRoot 添加 aishell 自己写入、需要模型读取的运行数据目录（如命令输出溢出文件），
+// 这个目录位于被拒绝的数据目录之下，因此同时从敏感路径检查中排除
+func (w *Workspace) AddInternalRoot(root string) {
+	w.AddRoot(root)
+	abs, err := filepath.Abs(utils.ExpandHome(strings.TrimSpace(root)))
+	if err != nil || strings.TrimSpace(root) == "" {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.internal = append(w.internal, realPath(abs))
+}
+
+// isInternal 判断真实路径是否位于 AddInternalRoot 添加的目录内
+func (w *Workspace) isInternal(real string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, root := range w.internal {
+		if within(root, real) {
+			return true
+		}
+	}
+	return false
+}
+
+// Roots 返回允许直接访问的根目录
+func (w *Workspace) Roots() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.roots...)
+}
+
+// Dir 返回解析相对路径时的当前目录，获取失败时返回空字符串
+func (w *Workspace) Dir() string {
+	if w != nil && w.Cwd != nil {
+		if dir := w.Cwd(); dir != "" {
+			return dir
+		}
+	}
+	return workingDir()
+}
+
+// Abs 把相对路径解析为基于 Dir 的绝对路径
+func (w *Workspace) Abs(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	dir := w.Dir()
+	if dir == "" {
+		return "", fmt.Errorf("无法获取当前工作目录")
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// Check 解析路径并检查访问范围，返回绝对路径和它是否位于允许的根目录内。
+// 命中敏感路径时返回错误。
+func (w *Workspace) Check(path string) (absPath string, inside bool, err error) {
+	absPath, err = w.Abs(path)
+	if err != nil {
+		return "", false, fmt.Errorf("解析路径失败: %w", err)
+	}
+	if w == nil {
+		return absPath, true, nil
+	}
+
+	real := realPath(absPath)
+	if w.isInternal(real) {
+		return absPath, true, nil
+	}
+	for _, candidate := range []string{absPath, real} {
+		if pattern := w.deniedBy(candidate); pattern != "" {
+			return "", false, fmt.Errorf("禁止访问敏感路径 %s（匹配 %s）", candidate, pattern)
+		}
+	}
+	for _, root := range w.Roots() {
+		if within(root, real) {
+			return absPath, true, nil
+		}
+	}
+	return absPath, false, nil
+}
+
+// Authorize 检查路径的访问范围，根目录之外的路径需要用户确认（同一路径在本次运行中只询问一次）。
+// op 为操作名称，如"读取"、"写入"。返回解析后的绝对路径。
+func (w *Workspace) Authorize(ctx context.Context, path, op string) (string, error) {
+	info := CallInfoFrom(ctx)
+	absPath, inside, err := w.Check(path)
+	if err != nil {
+		info.SetStatus(audit.StatusDenied)
+		return "", err
+	}
+	if inside {
+		return absPath, nil
+	}
+
+	// 并行的工具调用依次询问，前一次已经同意的同类操作不再询问
+	w.prompt.Lock()
+	defer w.prompt.Unlock()
+	real := realPath(absPath)
+	if w.isApproved(op, real) {
+		return absPath, nil
+	}
+
+	out := w.Approver.Writer()
+	fmt.Fprintln(out)
+	color.New(color.FgYellow, color.Bold).Fprintf(out, "📂 %s工作区之外的文件: %s\n", op, absPath)
+	if real != absPath {
+		fmt.Fprintf(out, "   真实路径: %s\n", real)
+	}
+	fmt.Fprintf(out, "   允许的目录: %s\n", strings.Join(w.Roots(), ", "))
+
+	ok := w.Approver.Confirm(fmt.Sprintf("允许%s这个文件吗?", op))
+	info.SetApproval(w.Approver.Decision(ok))
+	if !ok || ctx.Err() != nil {
+		info.SetStatus(audit.StatusDeclined)
+		return "", fmt.Errorf("用户拒绝%s工作区之外的文件 %s", op, absPath)
+	}
+	w.mu.Lock()
+	w.approved[approval{op, real}] = true
+	w.mu.Unlock()
+	return absPath, nil
+}
+
+// isApproved 路径是否已经被用户同意以 op 操作访问
+func (w *Workspace) isApproved(op, real string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.approved[approval{op, real}]
+}
+
+// denies 路径是否为敏感路径，用于遍历目录时跳过，w 为 nil 时不限制
+func (w *Workspace) denies(path string) bool {
+	return w != nil && w.deniedBy(path) != ""
+}
+
+// deniedBy 返回路径命中的敏感路径模式，未命中时返回空字符串
+func (w *Workspace) deniedBy(path string) string {
+	path = filepath.ToSlash(path)
+	for _, pattern := range w.denied {
+		if policy.MatchGlob(pattern, path) || policy.MatchGlob(strings.TrimSuffix(pattern, "/")+"/**", path) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// within 判断 path 是否位于 root 目录内（包括 root 本身）
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel))
+}
+
+// realPath 解析路径中的符号链接。路径不存在时解析最近的已存在上级目录，再拼接剩余部分。
+func realPath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(realPath(parent), filepath.Base(path))
+}
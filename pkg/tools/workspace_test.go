@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspace_Check(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// 根目录内指向外部的符号链接
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWorkspace([]string{root}, []string{filepath.Join(root, "secrets")})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantInside bool
+		wantErr    bool
+	}{
+		{"根目录内", filepath.Join(root, "main.go"), true, false},
+		{"根目录本身", root, true, false},
+		{"目录穿越", filepath.Join(root, "..", filepath.Base(outside), "a.txt"), false, false},
+		{"符号链接指向外部", filepath.Join(root, "escape", "a.txt"), false, false},
+		{"内置敏感路径", filepath.Join(home, ".ssh", "id_rsa"), false, true},
+		{"敏感路径本身", filepath.Join(home, ".ssh"), false, true},
+		{"配置的拒绝路径", filepath.Join(root, "secrets", "token"), false, true},
+		{"前缀相同的兄弟目录", root + "-other/a.txt", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, inside, err := w.Check(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check(%s) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if inside != tt.wantInside {
+				t.Errorf("Check(%s) inside = %v, want %v", tt.path, inside, tt.wantInside)
+			}
+		})
+	}
+
+	// 符号链接指向敏感目录时按真实路径拒绝
+	if err := os.Symlink(filepath.Join(home, ".ssh"), filepath.Join(root, "keys")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := w.Check(filepath.Join(root, "keys", "id_rsa")); err == nil {
+		t.Error("通过符号链接访问敏感目录应该被拒绝")
+	}
+
+	// 数据目录整体拒绝，只有溢出文件目录可以读取
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	w = NewWorkspace([]string{root}, nil)
+	w.AddInternalRoot(SpillDir())
+	if _, inside, err := w.Check(filepath.Join(SpillDir(), "out.txt")); err != nil || !inside {
+		t.Errorf("溢出文件应该可以访问, inside = %v, err = %v", inside, err)
+	}
+	if _, _, err := w.Check(filepath.Join(home, "data", "aishell", "audit.log")); err == nil {
+		t.Error("数据目录中的其他文件应该被拒绝")
+	}
+
+	// 未配置访问范围时不限制
+	var none *Workspace
+	if _, inside, err := none.Check(filepath.Join(outside, "a.txt")); err != nil || !inside {
+		t.Errorf("nil Workspace 应该不限制访问, inside = %v, err = %v", inside, err)
+	}
+}
+
+func TestWorkspace_Authorize(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "notes.txt")
+
+	t.Run("拒绝", func(t *testing.T) {
+		w := NewWorkspace([]string{root}, nil)
+		w.Approver = &Approver{In: strings.NewReader("n\n"), Out: io.Discard}
+		if _, err := w.Authorize(context.Background(), outside, "读取"); err == nil {
+			t.Fatal("用户拒绝后应该返回错误")
+		}
+	})
+
+	t.Run("同意后不再询问", func(t *testing.T) {
+		w := NewWorkspace([]string{root}, nil)
+		w.Approver = &Approver{In: strings.NewReader("y\n"), Out: io.Discard}
+		for i := 0; i < 2; i++ {
+			abs, err := w.Authorize(context.Background(), outside, "读取")
+			if err != nil {
+				t.Fatalf("第 %d 次访问失败: %v", i+1, err)
+			}
+			if abs != outside {
+				t.Errorf("Authorize() = %s, want %s", abs, outside)
+			}
+		}
+	})
+
+	t.Run("同意读取不代表同意写入", func(t *testing.T) {
+		w := NewWorkspace([]string{root}, nil)
+		w.Approver = &Approver{In: strings.NewReader("y\nn\n"), Out: io.Discard}
+		if _, err := w.Authorize(context.Background(), outside, "读取"); err != nil {
+			t.Fatalf("读取失败: %v", err)
+		}
+		if _, err := w.Authorize(context.Background(), outside, "写入"); err == nil {
+			t.Fatal("写入应该重新询问，用户拒绝后返回错误")
+		}
+	})
+
+	t.Run("非交互模式拒绝", func(t *testing.T) {
+		w := NewWorkspace([]string{root}, nil)
+		w.Approver = &Approver{Mode: ApprovalNo, Out: io.Discard}
+		if _, err := w.Authorize(context.Background(), outside, "写入"); err == nil {
+			t.Fatal("--no 模式下访问工作区之外的文件应该被拒绝")
+		}
+	})
+
+	t.Run("根目录内不询问", func(t *testing.T) {
+		w := NewWorkspace([]string{root}, nil)
+		w.Approver = &Approver{Mode: ApprovalNo, Out: io.Discard}
+		if _, err := w.Authorize(context.Background(), filepath.Join(root, "a.txt"), "写入"); err != nil {
+			t.Fatalf("根目录内的文件不应该需要确认: %v", err)
+		}
+	})
+}
+
+func TestFileTools_Workspace(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	root := t.TempDir()
+	outsideDir := t.TempDir()
+
+	secret := filepath.Join(home, ".ssh", "id_rsa")
+	if err := os.MkdirAll(filepath.Dir(secret), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secret, []byte("PRIVATE KEY"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWorkspace([]string{root}, nil)
+	w.Approver = &Approver{Mode: ApprovalNo, Out: io.Discard}
+
+	fr := NewFileReader()
+	fr.Workspace = w
+	if _, err := fr.Call(context.Background(), secret); err == nil {
+		t.Error("file_reader 不应该读取敏感路径")
+	}
+
+	fw := NewFileWriter()
+	fw.Workspace = w
+	target := filepath.Join(outsideDir, "out.txt")
+	input := `{"file_path": "` + target + `", "content": "hello"}`
+	if _, err := fw.Call(context.Background(), input); err == nil {
+		t.Error("未经确认不应该写入工作区之外的文件")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("被拒绝的写入不应该创建文件")
+	}
+
+	inside := filepath.Join(root, "in.txt")
+	input = `{"file_path": "` + inside + `", "content": "hello"}`
+	if _, err := fw.Call(context.Background(), input); err != nil {
+		t.Fatalf("写入工作区内的文件失败: %v", err)
+	}
+}
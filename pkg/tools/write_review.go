@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/audit"
+	"github.com/dean2027/aishell/pkg/policy"
+)
+
+// reviewWrite 按写入策略检查对 absPath 的写入：deny 规则直接拒绝；confirm 规则，或未命中规则且会覆盖已有文件时，
+// 展示差异并询问用户，用户可以在编辑器中修改内容（修改结果写回 content）。策略为空时直接写入。
+// ok 为 false 时不写入，note 为返回给模型的说明；ok 为 true 时 note 为附加说明。
+func reviewWrite(ctx context.Context, p *policy.Policy, approver *Approver, absPath string, content *string) (note string, ok bool) {
+	if p == nil {
+		return "", true
+	}
+	info := CallInfoFrom(ctx)
+	decision := p.EvaluateWrite(absPath)
+	if decision.Action == policy.ActionDeny {
+		info.SetStatus(audit.StatusDenied)
+		return fmt.Sprintf("写入文件 '%s' 被策略拒绝\n%s", absPath, decision.Explain()), false
+	}
+
+	_, err := os.Stat(absPath)
+	existed := err == nil
+	if decision.Action == policy.ActionAllow || (!decision.Matched() && !existed) {
+		return "", true
+	}
+	current := ""
+	if existed {
+		data, err := os.ReadFile(absPath)
+		if err != nil {
+			return "", true // 读不到原文件时交给写入步骤报告错误
+		}
+		current = string(data)
+	}
+	if existed && current == *content {
+		return "", true
+	}
+
+	out := approver.Writer()
+	yellow := color.New(color.FgYellow, color.Bold)
+	oldName := absPath
+	action := "覆盖"
+	if !existed {
+		oldName = "/dev/null"
+		action = "创建"
+	}
+
+	edited := false
+	for {
+		fmt.Fprintln(out)
+		yellow.Fprintf(out, "📝 即将%s文件: %s\n", action, absPath)
+		if decision.Matched() {
+			fmt.Fprintf(out, "   %s\n", decision.Explain())
+		}
+		if diff := UnifiedDiff(oldName, absPath, current, *content); diff != "" {
+			FprintDiff(out, diff)
+		} else {
+			fmt.Fprintln(out, "（内容没有变化）")
+		}
+		fmt.Fprintln(out)
+
+		choice := approver.Review("确定要写入这个文件吗?")
+		if ctx.Err() != nil {
+			choice = ReviewReject
+		}
+		switch choice {
+		case ReviewApprove:
+			info.SetApproval(approver.Decision(true))
+			if edited {
+				return "写入前用户在编辑器中修改了内容，请以文件的实际内容为准", true
+			}
+			return "", true
+		case ReviewReject:
+			info.SetApproval(approver.Decision(false))
+			info.SetStatus(audit.StatusDeclined)
+			fmt.Fprintln(out, "✅ 已取消写入，文件未修改")
+			return fmt.Sprintf("用户拒绝写入文件 '%s'，文件未修改", absPath), false
+		case ReviewEdit:
+			revised, err := openInEditor(absPath, *content, out)
+			if err != nil {
+				color.New(color.FgRed).Fprintf(out, "❌ %v\n", err)
+				continue
+			}
+			edited = edited || revised != *content
+			*content = revised
+		}
+	}
+}
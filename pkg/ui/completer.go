@@ -150,5 +150,12 @@ func getControlCommands() []readline.PrefixCompleterInterface {
 		readline.PcItem("quit"),
 		readline.PcItem("clear"),
 		readline.PcItem("cls"),
+		readline.PcItem("reset-shell"),
+		readline.PcItem("/sessions"),
+		readline.PcItem("/undo"),
+		readline.PcItem("plan",
+			readline.PcItem("on"),
+			readline.PcItem("off"),
+		),
 	}
 }
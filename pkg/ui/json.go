@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/dean2027/aishell/pkg/agent"
+	"github.com/dean2027/aishell/pkg/redact"
+	"github.com/dean2027/aishell/pkg/tools"
+)
+
+// 非交互模式的结果状态
+const (
+	StatusOK          = "ok"
+	StatusError       = "error"
+	StatusDeclined    = "declined"
+	StatusInterrupted = "interrupted"
+)
+
+// JSONStep 一次工具调用
+type JSONStep struct {
+	Tool       string `json:"tool"`
+	Input      string `json:"input"`
+	Output     string `json:"output"`
+	DurationMs int64  `json:"duration_ms"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// JSONRedaction 发送给模型之前被遮盖的密钥，Tool 为空表示用户输入
+type JSONRedaction struct {
+	Tool     string           `json:"tool,omitempty"`
+	Findings []redact.Finding `json:"findings"`
+}
+
+// JSONPlanStep 演练模式生成的计划步骤
+type JSONPlanStep struct {
+	Tool    string   `json:"tool"`
+	Summary string   `json:"summary"`
+	Risks   []string `json:"risks,omitempty"`
+}
+
+// JSONResult 一次请求的完整结果
+type JSONResult struct {
+	Answer   string          `json:"answer"`
+	Status   string          `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Steps    []JSONStep      `json:"steps"`
+	Plan     []JSONPlanStep  `json:"plan,omitempty"`
+	Usage    agent.Usage     `json:"usage"`
+	Redacted []JSONRedaction `json:"redacted,omitempty"`
+}
+
+// JSONPrinter 以 JSON 输出代理的回答和每个步骤，供脚本解析。
+// json 格式在结束时输出一个 JSONResult；jsonl 格式每个事件输出一行，最后一行为 type 为 result 的结果。
+type JSONPrinter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	stream bool
+	result JSONResult
+}
+
+// NewJSONPrinter 创建 JSON 输出器，stream 为 true 时输出 JSONL 事件流
+func NewJSONPrinter(w io.Writer, stream bool) *JSONPrinter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if !stream {
+		enc.SetIndent("", "  ")
+	}
+	return &JSONPrinter{enc: enc, stream: stream, result: JSONResult{Steps: []JSONStep{}}}
+}
+
+// Handle 处理代理事件
+func (p *JSONPrinter) Handle(event agent.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch event.Type {
+	case agent.EventToken:
+		p.emit(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{"token", event.Text})
+
+	case agent.EventToolStart:
+		p.emit(struct {
+			Type  string `json:"type"`
+			Tool  string `json:"tool"`
+			Input string `json:"input"`
+		}{"tool_start", event.Tool, event.Input})
+
+	case agent.EventToolEnd:
+		step := JSONStep{
+			Tool:       event.Tool,
+			Input:      event.Input,
+			Output:     event.Output,
+			DurationMs: event.Duration.Milliseconds(),
+			ExitCode:   event.ExitCode,
+		}
+		if event.Err != nil {
+			step.Error = event.Err.Error()
+		}
+		p.result.Steps = append(p.result.Steps, step)
+		p.emit(struct {
+			Type string `json:"type"`
+			JSONStep
+		}{"tool_end", step})
+
+	case agent.EventRedacted:
+		redaction := JSONRedaction{Tool: event.Tool, Findings: event.Redacted}
+		p.result.Redacted = append(p.result.Redacted, redaction)
+		p.emit(struct {
+			Type string `json:"type"`
+			JSONRedaction
+		}{"redacted", redaction})
+
+	case agent.EventUsage:
+		p.result.Usage.Add(event.Usage)
+		p.emit(struct {
+			Type string `json:"type"`
+			agent.Usage
+		}{"usage", event.Usage})
+	}
+}
+
+// Finish 输出最终结果
+func (p *JSONPrinter) Finish(answer string, err error, status string, plan []tools.PlanStep) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.result.Answer = answer
+	p.result.Status = status
+	if err != nil {
+		p.result.Error = err.Error()
+	}
+	for _, step := range plan {
+		p.result.Plan = append(p.result.Plan, JSONPlanStep{Tool: step.Tool, Summary: step.Summary, Risks: step.Risks})
+	}
+
+	if !p.stream {
+		p.enc.Encode(p.result)
+		return
+	}
+	p.emit(struct {
+		Type string `json:"type"`
+		JSONResult
+	}{"result", p.result})
+}
+
+// emit 在 jsonl 格式下输出一行事件
+func (p *JSONPrinter) emit(event any) {
+	if p.stream {
+		p.enc.Encode(event)
+	}
+}
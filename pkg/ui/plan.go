@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/tools"
+)
+
+// PrintPlan 打印演练模式下生成的完整计划
+func PrintPlan(steps []tools.PlanStep) {
+	FprintPlan(color.Output, steps)
+}
+
+// FprintPlan 把演练模式下生成的完整计划写入 w
+func FprintPlan(w io.Writer, steps []tools.PlanStep) {
+	cyan := color.New(color.FgCyan, color.Bold)
+	yellow := color.New(color.FgYellow)
+
+	cyan.Fprintf(w, "📋 演练模式计划（共 %d 步，均未执行）:\n", len(steps))
+	for i, step := range steps {
+		fmt.Fprintf(w, "  %d. [%s] %s\n", i+1, step.Tool, step.Summary)
+		for _, risk := range step.Risks {
+			yellow.Fprintf(w, "     ⚠️  %s\n", risk)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// PrintPlanStep 打印逐步执行时的当前步骤
+func PrintPlanStep(index, total int, step tools.PlanStep) {
+	cyan := color.New(color.FgCyan)
+	yellow := color.New(color.FgYellow)
+
+	cyan.Printf("▶ 第 %d/%d 步 [%s]: %s\n", index, total, step.Tool, step.Summary)
+	for _, risk := range step.Risks {
+		yellow.Printf("  ⚠️  %s\n", risk)
+	}
+}
+
+// PrintStepResult 打印计划步骤的执行结果。命令输出已实时显示，这里只显示结果摘要行。
+func PrintStepResult(result string) {
+	summary, _, _ := strings.Cut(result, "\n")
+	color.New(color.FgGreen).Printf("  ✓ %s\n\n", summary)
+}
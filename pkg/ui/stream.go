@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/dean2027/aishell/pkg/agent"
+)
+
+// thinkingText 等待模型响应时显示的提示
+const thinkingText = "🤔 思考中..."
+
+// StreamPrinter 实时显示代理的输出：逐段打印模型回复，并显示正在调用的工具及其输入。
+// 所有输出都以换行结束，保证 readline 重新绘制提示符时不会与回复混在同一行。
+type StreamPrinter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	activity io.Writer
+	plain    bool
+	thinking bool
+	inReply  bool
+	streamed bool
+	atLine   bool
+}
+
+// NewStreamPrinter 创建流式输出器
+func NewStreamPrinter(w io.Writer) *StreamPrinter {
+	return &StreamPrinter{w: w, activity: w, atLine: true}
+}
+
+// NewOneShotPrinter 创建非交互模式的输出器：回复原样写入 out，不加标题和思考提示，
+// 工具调用等过程信息写入 activity，便于把回复通过管道交给其他程序处理。
+func NewOneShotPrinter(out, activity io.Writer) *StreamPrinter {
+	return &StreamPrinter{w: out, activity: activity, plain: true, atLine: true}
+}
+
+// Start 开始一轮对话，显示思考提示
+func (p *StreamPrinter) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.plain {
+		return
+	}
+	fmt.Fprintln(p.w)
+	p.showThinking()
+}
+
+// Handle 处理代理事件
+func (p *StreamPrinter) Handle(event agent.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch event.Type {
+	case agent.EventToken:
+		p.clearThinking()
+		if !p.inReply && !p.plain {
+			color.New(color.FgBlue).Fprintln(p.w, "🤖 终端助手:")
+			p.inReply = true
+		}
+		fmt.Fprint(p.w, event.Text)
+		p.streamed = true
+		p.atLine = strings.HasSuffix(event.Text, "\n")
+
+	case agent.EventToolStart:
+		p.clearThinking()
+		p.endActivityLine()
+		p.inReply = false
+		color.New(color.FgCyan).Fprintf(p.activity, "🔧 %s", event.Tool)
+		if summary := SummarizeToolInput(event.Input); summary != "" {
+			color.New(color.Faint).Fprintf(p.activity, " %s", summary)
+		}
+		fmt.Fprintln(p.activity)
+
+	case agent.EventToolEnd:
+		p.endActivityLine()
+		duration := event.Duration.Round(time.Millisecond)
+		if event.Err != nil {
+			color.New(color.FgRed).Fprintf(p.activity, "   ✗ %s 失败: %v\n", event.Tool, event.Err)
+		} else {
+			color.New(color.FgGreen).Fprintf(p.activity, "   ✓ %s 完成 (%s)\n", event.Tool, duration)
+		}
+		p.showThinking()
+
+	case agent.EventRedacted:
+		p.clearThinking()
+		p.endActivityLine()
+		source := "输入"
+		if event.Tool != "" {
+			source = " " + event.Tool + " 结果"
+		}
+		color.New(color.FgYellow).Fprintf(p.activity, "   🔒 已遮盖%s中的敏感信息: %s\n", source, event.Redacted.Summary())
+		color.New(color.Faint).Fprintf(p.activity, "      模型只会看到占位符 %s\n", strings.Join(event.Redacted.Placeholders(), " "))
+		p.showThinking()
+	}
+}
+
+// Finish 结束一轮对话。没有流式输出（例如使用对话代理）时一次性打印完整回复。
+func (p *StreamPrinter) Finish(response string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clearThinking()
+	if err != nil {
+		p.endLine()
+		return
+	}
+	if p.plain {
+		if !p.streamed {
+			fmt.Fprint(p.w, response)
+			p.atLine = response == "" || strings.HasSuffix(response, "\n")
+		}
+		p.endLine()
+		return
+	}
+	if !p.streamed {
+		PrintResponse(response)
+		return
+	}
+	p.endLine()
+	fmt.Fprintln(p.w)
+}
+
+// showThinking 显示思考提示
+func (p *StreamPrinter) showThinking() {
+	if !p.thinking && !p.plain {
+		fmt.Fprint(p.w, thinkingText)
+		p.thinking = true
+	}
+}
+
+// clearThinking 清除思考提示
+func (p *StreamPrinter) clearThinking() {
+	if p.thinking {
+		fmt.Fprint(p.w, "\r"+strings.Repeat(" ", 20)+"\r")
+		p.thinking = false
+	}
+}
+
+// endLine 补齐未结束的行
+func (p *StreamPrinter) endLine() {
+	if !p.atLine {
+		fmt.Fprintln(p.w)
+		p.atLine = true
+	}
+}
+
+// endActivityLine 在输出过程信息之前补齐未结束的回复行。
+// 过程信息与回复写入不同位置时不需要换行，以免打断通过管道输出的回复。
+func (p *StreamPrinter) endActivityLine() {
+	if p.activity == p.w {
+		p.endLine()
+	}
+}
+
+// SummarizeToolInput 把工具输入压缩为一行用于显示：
+// JSON 参数优先显示命令、文件路径或搜索内容，过长时截断
+func SummarizeToolInput(input string) string {
+	input = strings.TrimSpace(input)
+	var args map[string]any
+	if json.Unmarshal([]byte(input), &args) == nil {
+		for _, key := range []string{"command", "file_path", "pattern", "glob", "path", "input"} {
+			if v, ok := args[key].(string); ok {
+				input = v
+				break
+			}
+		}
+	}
+	input = strings.Join(strings.Fields(input), " ")
+	if runes := []rune(input); len(runes) > 100 {
+		input = string(runes[:100]) + "…"
+	}
+	return input
+}
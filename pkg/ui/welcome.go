@@ -35,14 +35,13 @@ func PrintWelcome() {
 	printEnvironmentStatus()
 }
 
+// PrintModelInfo 打印当前使用的模型提供方和模型
+func PrintModelInfo(provider, model string) {
+	color.New(color.Faint).Printf("🧠 模型: %s / %s\n\n", provider, model)
+}
+
 // printEnvironmentStatus 打印环境状态信息
 func printEnvironmentStatus() {
-	if os.Getenv("OPENAI_API_KEY") == "" {
-		color.Red("⚠️  警告: 未设置OPENAI_API_KEY环境变量")
-		fmt.Println("   请设置: export OPENAI_API_KEY=your_api_key")
-		fmt.Println("")
-	}
-
 	if os.Getenv("SERPAPI_API_KEY") == "" {
 		color.Yellow("💡 提示: 设置SERPAPI_API_KEY可启用网络搜索功能")
 		fmt.Println("")
@@ -136,8 +135,12 @@ func printShortcuts(yellow, green *color.Color) {
 	green.Println("  • ↑↓ 方向键 - 浏览历史命令")
 	green.Println("  • Tab 键 - 自动补全命令")
 	green.Println("  • Ctrl+R - 搜索历史命令")
-	green.Println("  • Ctrl+C - 中断当前输入")
+	green.Println("  • Ctrl+C - 中断当前输入；助手执行中按下则中断当前这一轮对话")
 	green.Println("  • Ctrl+D 或 'exit' - 退出程序")
+	green.Println("  • 'reset-shell' - 重置持久 shell 会话（AISHELL_PERSISTENT_SHELL=true 时）")
+	green.Println("  • 'plan' / 'plan on' / 'plan off' - 切换演练模式（只生成计划不执行）")
+	green.Println("  • '/sessions' - 列出保存的会话（用 aishell --resume 恢复）")
+	green.Println("  • '/undo [文件]' - 撤销最近一次（或指定文件的）文件写入")
 	fmt.Println()
 }
 
@@ -164,6 +167,24 @@ func PrintError(msg string, err error) {
 	red.Printf("❌ %s: %v\n\n", msg, err)
 }
 
+// PrintInterrupting 打印正在中断的提示
+func PrintInterrupting() {
+	yellow := color.New(color.FgYellow)
+	yellow.Println("\n⏹  正在中断当前对话...（再次按 Ctrl+C 强制退出）")
+}
+
+// PrintInterrupted 打印对话已中断
+func PrintInterrupted() {
+	yellow := color.New(color.FgYellow)
+	yellow.Print("⏹  当前对话已中断，已完成的步骤保留在对话历史中\n\n")
+}
+
+// PrintInfo 打印提示信息
+func PrintInfo(msg string) {
+	green := color.New(color.FgGreen)
+	green.Printf("✅ %s\n\n", msg)
+}
+
 // PrintThinking 打印思考状态
 func PrintThinking() {
 	fmt.Print("\n🤔 思考中...")
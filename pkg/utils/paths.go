@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectConfigDirName 项目级配置目录名
+const ProjectConfigDirName = ".aishell"
+
+// ConfigDir 返回用户级配置目录 (~/.config/aishell)，遵循 XDG_CONFIG_HOME
+func ConfigDir() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "aishell")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "aishell")
+	}
+	return filepath.Join(home, ".config", "aishell")
+}
+
+// FindProjectConfigDir 从当前目录向上查找项目级配置目录 (.aishell)，找不到时返回空字符串
+func FindProjectConfigDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	home, _ := os.UserHomeDir()
+	for {
+		candidate := filepath.Join(dir, ProjectConfigDirName)
+		// 用户主目录下的 .aishell 不视为项目配置，避免与用户级配置混淆
+		if dir != home {
+			if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+				return candidate
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// FindProjectRoot 返回当前项目的根目录：包含 .aishell 配置目录或 .git 的最近一层目录。
+// 用户主目录和文件系统根目录不视为项目，找不到时返回空字符串。
+func FindProjectRoot() string {
+	if dir := FindProjectConfigDir(); dir != "" {
+		return filepath.Dir(dir)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	home, _ := os.UserHomeDir()
+	for dir != home && filepath.Dir(dir) != dir {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	return ""
+}
+
+// ExpandHome 将路径开头的 ~ 展开为用户主目录
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// DataDir 返回用户级数据目录 (~/.local/share/aishell)，遵循 XDG_DATA_HOME，用于保存会话等运行数据
+func DataDir() string {
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, "aishell")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "aishell")
+	}
+	return filepath.Join(home, ".local", "share", "aishell")
+}